@@ -0,0 +1,781 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// CALENDAR INTEGRATION — ICS feeds + CalDAV
+//
+// Providers:
+//   ics     credential = ICS URL (Google "secret address", Outlook publish URL, etc.)
+//   caldav  credential = {"url":"https://.../calendars/me/work/","username":"…","password":"…"}
+//
+// Config JSON (both): {"lookback_days": 7, "lookahead_days": 14,
+//                      "rules": {"sales_call": ["discovery"], "day_off": ["gone fishing"]}}
+//
+// Every poll re-reads the window, classifies each occurrence (sales_call,
+// client_meeting, deep_work, day_off, other) into calendar_events and:
+//   - emits SALES_CALL / CLIENT_MEETING revenue events once a meeting has ended
+//   - feeds schedule features to the pairing engine as a SignalAccount
+//   - marks day_off dates as rest days, which exempt streaks, commitment
+//     penalties and stall hours (see isRestDay / restHoursBetween)
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// calendarEvent is a single VEVENT occurrence (recurrences already expanded).
+type calendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Categories  string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Transparent bool
+	RRule       string
+	ExDates     []time.Time // EXDATE: instances removed from the series
+	Recurrence  time.Time   // RECURRENCE-ID: this event replaces that instance
+	Cancelled   bool        // STATUS:CANCELLED
+	Attendees   int
+}
+
+// calendarKindRules maps a classification to lowercase keywords matched
+// against summary, description and categories. Order of evaluation is fixed
+// in classifyCalendarEvent so day_off always wins over a "client" keyword.
+var calendarKindRules = map[string][]string{
+	"day_off": {"day off", "off day", "ooo", "out of office", "vacation", "holiday",
+		"pto", "rest day", "sabbath", "sick day", "personal day", "time off"},
+	"deep_work": {"deep work", "focus", "heads down", "maker time", "no meetings",
+		"build block", "writing block", "do not book", "dnd"},
+	"sales_call": {"sales", "discovery call", "demo", "intro call", "prospect",
+		"pitch", "consultation", "strategy session", "closing call", "calendly"},
+	"client_meeting": {"client", "kickoff", "kick-off", "onboarding", "check-in",
+		"account review", "retainer", "status update", "qbr"},
+}
+
+// initCalendar creates the calendar_events table.
+func (s *Server) initCalendar() {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS calendar_events (
+			id TEXT PRIMARY KEY,
+			integration_id TEXT NOT NULL,
+			uid TEXT NOT NULL,
+			summary TEXT DEFAULT '',
+			kind TEXT NOT NULL DEFAULT 'other',
+			start_at TEXT NOT NULL,
+			end_at TEXT NOT NULL,
+			all_day BOOLEAN DEFAULT 0,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_calendar_start ON calendar_events(start_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_calendar_kind ON calendar_events(kind, start_at)`,
+	} {
+		s.db.Exec(stmt)
+	}
+}
+
+type calendarConfig struct {
+	LookbackDays  int                 `json:"lookback_days"`
+	LookaheadDays int                 `json:"lookahead_days"`
+	Rules         map[string][]string `json:"rules"`
+}
+
+func parseCalendarConfig(configJSON string) calendarConfig {
+	var cfg calendarConfig
+	json.Unmarshal([]byte(configJSON), &cfg)
+	if cfg.LookbackDays <= 0 {
+		cfg.LookbackDays = 7
+	}
+	if cfg.LookaheadDays <= 0 {
+		cfg.LookaheadDays = 14
+	}
+	return cfg
+}
+
+// pollICS fetches a published ICS feed.
+func (s *Server) pollICS(integrationID, feedURL, configJSON, lastPoll string) error {
+	cfg := parseCalendarConfig(configJSON)
+	// webcal:// is just https:// with a different scheme for calendar apps
+	if strings.HasPrefix(feedURL, "webcal://") {
+		feedURL = "https://" + strings.TrimPrefix(feedURL, "webcal://")
+	}
+	client := providerClient(20 * time.Second)
+	resp, err := client.Get(feedURL)
+	if err != nil {
+		return fmt.Errorf("ics fetch: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("ics fetch: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	if err != nil {
+		return fmt.Errorf("ics read: %w", err)
+	}
+
+	from, to := calendarWindow(cfg)
+	events := expandCalendarEvents(parseICS(string(body)), from, to)
+	return s.ingestCalendarEvents(integrationID, "ics", cfg, events, from, to)
+}
+
+// pollCalDAV issues a calendar-query REPORT for the configured window.
+func (s *Server) pollCalDAV(integrationID, credential, configJSON, lastPoll string) error {
+	var creds struct {
+		URL      string `json:"url"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal([]byte(credential), &creds); err != nil || creds.URL == "" {
+		return fmt.Errorf("caldav: credential must be {\"url\",\"username\",\"password\"}")
+	}
+	cfg := parseCalendarConfig(configJSON)
+	from, to := calendarWindow(cfg)
+
+	report := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`, from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"))
+
+	req, _ := http.NewRequest("REPORT", creds.URL, strings.NewReader(report))
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	if creds.Username != "" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	client := providerClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("caldav report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return fmt.Errorf("caldav: unauthorized (status %d)", resp.StatusCode)
+	}
+	if resp.StatusCode != 207 && resp.StatusCode != 200 {
+		return fmt.Errorf("caldav report: status %d", resp.StatusCode)
+	}
+
+	var ms struct {
+		Responses []struct {
+			Propstat []struct {
+				Prop struct {
+					CalendarData string `xml:"calendar-data"`
+				} `xml:"prop"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 10*1024*1024)).Decode(&ms); err != nil {
+		return fmt.Errorf("caldav decode: %w", err)
+	}
+
+	var raw []calendarEvent
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if ps.Prop.CalendarData != "" {
+				raw = append(raw, parseICS(ps.Prop.CalendarData)...)
+			}
+		}
+	}
+	events := expandCalendarEvents(raw, from, to)
+	return s.ingestCalendarEvents(integrationID, "caldav", cfg, events, from, to)
+}
+
+func calendarWindow(cfg calendarConfig) (time.Time, time.Time) {
+	now := operatorNow()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, operatorTZ)
+	return today.AddDate(0, 0, -cfg.LookbackDays), today.AddDate(0, 0, cfg.LookaheadDays+1)
+}
+
+// ingestCalendarEvents replaces the stored window for an integration, emits
+// scoreboard events for finished meetings and feeds the pairing engine.
+func (s *Server) ingestCalendarEvents(integrationID, provider string, cfg calendarConfig, events []calendarEvent, from, to time.Time) error {
+	now := time.Now()
+	nowStr := now.UTC().Format(time.RFC3339)
+
+	s.mu.Lock()
+	s.db.Exec(`DELETE FROM calendar_events WHERE integration_id=? AND start_at >= ? AND start_at < ?`,
+		integrationID, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	for _, ev := range events {
+		kind := classifyCalendarEvent(ev, cfg.Rules)
+		id := fmt.Sprintf("%s:%s:%d", integrationID, ev.UID, ev.Start.Unix())
+		s.db.Exec(`INSERT OR REPLACE INTO calendar_events (id, integration_id, uid, summary, kind,
+			start_at, end_at, all_day, updated_at) VALUES (?,?,?,?,?,?,?,?,?)`,
+			id, integrationID, ev.UID, ev.Summary, kind,
+			ev.Start.UTC().Format(time.RFC3339), ev.End.UTC().Format(time.RFC3339), ev.AllDay, nowStr)
+	}
+	s.mu.Unlock()
+
+	// Finished sales calls / client meetings become revenue-lane events
+	emitted := 0
+	for _, ev := range events {
+		if ev.End.After(now) {
+			continue
+		}
+		var eventType, icon string
+		switch classifyCalendarEvent(ev, cfg.Rules) {
+		case "sales_call":
+			eventType, icon = "SALES_CALL", "📞"
+		case "client_meeting":
+			eventType, icon = "CLIENT_MEETING", "🤝"
+		default:
+			continue
+		}
+		meta, _ := json.Marshal(map[string]interface{}{
+			"provider":     provider,
+			"uid":          ev.UID,
+			"duration_min": int(ev.End.Sub(ev.Start).Minutes()),
+			"attendees":    ev.Attendees,
+		})
+		s.insertEventIfNew(Event{
+			EventType:     eventType,
+			Lane:          "revenue",
+			Source:        "calendar",
+			ArtifactTitle: fmt.Sprintf("%s %s", icon, ev.Summary),
+			Detail:        fmt.Sprintf("Calendar: %s (%d min)", ev.Summary, int(ev.End.Sub(ev.Start).Minutes())),
+			ScoreDelta:    calcScoreDelta("revenue", eventType, 1.0),
+			Confidence:    1.0,
+			Verification:  "PROVIDER_API",
+			ExternalID:    fmt.Sprintf("cal-%s-%d", ev.UID, ev.Start.Unix()),
+			Timestamp:     ev.End.UTC().Format(time.RFC3339),
+			Metadata:      string(meta),
+		})
+		emitted++
+	}
+
+	if s.pairing != nil {
+		features, daysOff := s.calendarFeatures(from, to)
+		md := map[string]interface{}{
+			"provider":       "calendar",
+			"integration_id": integrationID,
+			"days_off":       daysOff,
+		}
+		for k, v := range features {
+			md[k] = v
+		}
+		s.pairing.Ingest(Signal{
+			Type:      SignalAccount,
+			Source:    "calendar",
+			Timestamp: now,
+			Features:  features,
+			Metadata:  md,
+		})
+	}
+
+	log.Printf("Calendar (%s): %d occurrences in window, %d meeting events", provider, len(events), emitted)
+	return nil
+}
+
+// calendarFeatures summarises the stored schedule for the pairing engine.
+// Hour/duration features are averaged over working (non-rest) days in the window.
+func (s *Server) calendarFeatures(from, to time.Time) (map[string]float64, []string) {
+	rows, err := s.db.Query(`SELECT kind, start_at, end_at, all_day FROM calendar_events
+		WHERE start_at >= ? AND start_at < ?`, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return map[string]float64{}, nil
+	}
+	defer rows.Close()
+
+	var meetingHours, deepHours, deepCenterSum, firstHourSum float64
+	var salesCalls, clientMeetings, deepBlocks int
+	firstByDay := map[string]float64{}
+	offSet := map[string]bool{}
+	for rows.Next() {
+		var kind, startStr, endStr string
+		var allDay bool
+		rows.Scan(&kind, &startStr, &endStr, &allDay)
+		start, _ := time.Parse(time.RFC3339, startStr)
+		end, _ := time.Parse(time.RFC3339, endStr)
+		start, end = start.In(operatorTZ), end.In(operatorTZ)
+		hours := end.Sub(start).Hours()
+
+		if kind == "day_off" {
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				offSet[d.Format("2006-01-02")] = true
+			}
+			continue
+		}
+		if allDay {
+			continue
+		}
+		day := start.Format("2006-01-02")
+		h := float64(start.Hour()) + float64(start.Minute())/60
+		if f, ok := firstByDay[day]; !ok || h < f {
+			firstByDay[day] = h
+		}
+		switch kind {
+		case "deep_work":
+			deepHours += hours
+			deepBlocks++
+			deepCenterSum += h + hours/2
+		case "sales_call":
+			salesCalls++
+			meetingHours += hours
+		case "client_meeting":
+			clientMeetings++
+			meetingHours += hours
+		case "meeting":
+			meetingHours += hours
+		}
+	}
+
+	workDays := 0
+	for d := from.In(operatorTZ); d.Before(to); d = d.AddDate(0, 0, 1) {
+		if !offSet[d.Format("2006-01-02")] {
+			workDays++
+		}
+	}
+	if workDays == 0 {
+		workDays = 1
+	}
+	for _, f := range firstByDay {
+		firstHourSum += f
+	}
+
+	features := map[string]float64{
+		"meeting_hours_per_day":   meetingHours / float64(workDays),
+		"deep_work_hours_per_day": deepHours / float64(workDays),
+		"sales_calls":             float64(salesCalls),
+		"client_meetings":         float64(clientMeetings),
+		"days_off":                float64(len(offSet)),
+	}
+	if deepBlocks > 0 {
+		features["deep_work_peak_hour"] = deepCenterSum / float64(deepBlocks)
+	}
+	if len(firstByDay) > 0 {
+		features["first_event_hour"] = firstHourSum / float64(len(firstByDay))
+	}
+
+	var daysOff []string
+	for d := range offSet {
+		daysOff = append(daysOff, d)
+	}
+	sort.Strings(daysOff)
+	return features, daysOff
+}
+
+// classifyCalendarEvent buckets an occurrence. Custom rules from integration
+// config are checked before the built-in keywords for the same kind.
+func classifyCalendarEvent(ev calendarEvent, custom map[string][]string) string {
+	text := strings.ToLower(ev.Summary + " " + ev.Categories + " " + ev.Description)
+	matches := func(kind string) bool {
+		for _, kw := range custom[kind] {
+			if kw != "" && strings.Contains(text, strings.ToLower(kw)) {
+				return true
+			}
+		}
+		for _, kw := range calendarKindRules[kind] {
+			if strings.Contains(text, kw) {
+				return true
+			}
+		}
+		return false
+	}
+
+	long := ev.AllDay || ev.End.Sub(ev.Start) >= 6*time.Hour
+	if long && matches("day_off") {
+		return "day_off"
+	}
+	for _, kind := range []string{"deep_work", "sales_call", "client_meeting"} {
+		if matches(kind) {
+			return kind
+		}
+	}
+	if ev.AllDay || ev.Transparent {
+		return "other"
+	}
+	if ev.Attendees > 1 || strings.Contains(text, "meeting") || strings.Contains(text, "call") ||
+		strings.Contains(text, "sync") || strings.Contains(text, "zoom") {
+		return "meeting"
+	}
+	return "other"
+}
+
+// ─── Rest days ──────────────────────────────────────────────────────────────
+
+// isRestDay reports whether the operator-local date is covered by a day_off block.
+func (s *Server) isRestDay(date string) bool {
+	day, err := time.ParseInLocation("2006-01-02", date, operatorTZ)
+	if err != nil {
+		return false
+	}
+	var count int
+	s.db.QueryRow(`SELECT COUNT(*) FROM calendar_events WHERE kind='day_off' AND start_at < ? AND end_at > ?`,
+		day.Add(24*time.Hour).UTC().Format(time.RFC3339), day.UTC().Format(time.RFC3339)).Scan(&count)
+	return count > 0
+}
+
+// restHoursBetween returns how many hours of [from, to) fall on rest days.
+func (s *Server) restHoursBetween(from, to time.Time) float64 {
+	var hours float64
+	for d := from.In(operatorTZ); d.Before(to); {
+		dayStart := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, operatorTZ)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		if s.isRestDay(dayStart.Format("2006-01-02")) {
+			end := dayEnd
+			if to.Before(end) {
+				end = to
+			}
+			hours += end.Sub(d).Hours()
+		}
+		d = dayEnd
+	}
+	return hours
+}
+
+// ─── GET /v1/calendar — classified schedule + rest days ─────────────────
+
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	if from == "" {
+		from = operatorNow().AddDate(0, 0, -7).Format("2006-01-02")
+	}
+	if to == "" {
+		to = operatorNow().AddDate(0, 0, 14).Format("2006-01-02")
+	}
+	fromT, err1 := time.ParseInLocation("2006-01-02", from, operatorTZ)
+	toT, err2 := time.ParseInLocation("2006-01-02", to, operatorTZ)
+	if err1 != nil || err2 != nil {
+		http.Error(w, `{"error":"from/to must be YYYY-MM-DD"}`, 400)
+		return
+	}
+	toT = toT.AddDate(0, 0, 1)
+
+	rows, err := s.db.Query(`SELECT uid, summary, kind, start_at, end_at, all_day FROM calendar_events
+		WHERE start_at >= ? AND start_at < ? ORDER BY start_at`,
+		fromT.UTC().Format(time.RFC3339), toT.UTC().Format(time.RFC3339))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), 500)
+		return
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var uid, summary, kind, start, end string
+		var allDay bool
+		rows.Scan(&uid, &summary, &kind, &start, &end, &allDay)
+		items = append(items, map[string]interface{}{
+			"uid": uid, "summary": summary, "kind": kind,
+			"start": start, "end": end, "all_day": allDay,
+		})
+	}
+
+	features, daysOff := s.calendarFeatures(fromT, toT)
+	if daysOff == nil {
+		daysOff = []string{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from": from, "to": to, "events": items, "rest_days": daysOff, "features": features,
+	})
+}
+
+// ─── ICS parsing ────────────────────────────────────────────────────────────
+
+// parseICS extracts VEVENTs from an iCalendar document. It handles line
+// folding, TZID/VALUE=DATE parameters and DURATION, which covers the feeds
+// published by Google, Outlook, iCloud, Fastmail and Nextcloud.
+func parseICS(data string) []calendarEvent {
+	// Unfold continuation lines (RFC 5545 §3.1)
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var events []calendarEvent
+	var cur *calendarEvent
+	var duration time.Duration
+	depth := 0 // nested components inside VEVENT (VALARM)
+	for _, line := range lines {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		nameParams, value := line[:colon], line[colon+1:]
+		parts := strings.Split(nameParams, ";")
+		name := strings.ToUpper(parts[0])
+		params := map[string]string{}
+		for _, p := range parts[1:] {
+			if eq := strings.Index(p, "="); eq > 0 {
+				params[strings.ToUpper(p[:eq])] = strings.Trim(p[eq+1:], `"`)
+			}
+		}
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &calendarEvent{}
+			duration = 0
+			continue
+		case name == "END" && value == "VEVENT":
+			if cur != nil && !cur.Start.IsZero() {
+				if cur.End.IsZero() {
+					switch {
+					case duration > 0:
+						cur.End = cur.Start.Add(duration)
+					case cur.AllDay:
+						cur.End = cur.Start.AddDate(0, 0, 1)
+					default:
+						cur.End = cur.Start
+					}
+				}
+				events = append(events, *cur)
+			}
+			cur = nil
+			continue
+		case cur == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END":
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+
+		switch name {
+		case "UID":
+			cur.UID = value
+		case "SUMMARY":
+			cur.Summary = icsUnescape(value)
+		case "DESCRIPTION":
+			cur.Description = icsUnescape(value)
+		case "LOCATION":
+			cur.Location = icsUnescape(value)
+		case "CATEGORIES":
+			cur.Categories = icsUnescape(value)
+		case "TRANSP":
+			cur.Transparent = strings.EqualFold(value, "TRANSPARENT")
+		case "RRULE":
+			cur.RRule = value
+		case "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _ := parseICSTime(v, params); !t.IsZero() {
+					cur.ExDates = append(cur.ExDates, t)
+				}
+			}
+		case "RECURRENCE-ID":
+			cur.Recurrence, _ = parseICSTime(value, params)
+		case "STATUS":
+			cur.Cancelled = strings.EqualFold(value, "CANCELLED")
+		case "ATTENDEE":
+			cur.Attendees++
+		case "DTSTART":
+			cur.Start, cur.AllDay = parseICSTime(value, params)
+		case "DTEND":
+			cur.End, _ = parseICSTime(value, params)
+		case "DURATION":
+			duration = parseICSDuration(value)
+		}
+	}
+	return events
+}
+
+func icsUnescape(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return r.Replace(s)
+}
+
+// parseICSTime parses DATE and DATE-TIME values. Floating times and unknown
+// TZIDs fall back to the operator timezone.
+func parseICSTime(value string, params map[string]string) (time.Time, bool) {
+	loc := operatorTZ
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, operatorTZ)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, _ := time.Parse("20060102T150405Z", value)
+		return t, false
+	}
+	t, _ := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false
+}
+
+// parseICSDuration parses RFC 5545 durations like PT1H30M, P1D, P1W.
+func parseICSDuration(v string) time.Duration {
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "+"), "P")
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, c := range v {
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			num += string(c)
+		default:
+			n, _ := strconv.Atoi(num)
+			num = ""
+			switch {
+			case c == 'W':
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case c == 'D':
+				d += time.Duration(n) * 24 * time.Hour
+			case c == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case c == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case c == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			}
+		}
+	}
+	return d
+}
+
+// expandCalendarEvents expands simple RRULEs (DAILY/WEEKLY with INTERVAL,
+// COUNT, UNTIL and BYDAY) and keeps occurrences overlapping [from, to).
+// Instances listed in EXDATE are dropped; one with a RECURRENCE-ID override
+// is replaced by the override (moved), or dropped if that is cancelled.
+func expandCalendarEvents(events []calendarEvent, from, to time.Time) []calendarEvent {
+	var out []calendarEvent
+	overlaps := func(e calendarEvent) bool {
+		return e.Start.Before(to) && e.End.After(from)
+	}
+	// UID → instance start (unix) → replaced by an override
+	overridden := map[string]map[int64]bool{}
+	for _, ev := range events {
+		if !ev.Recurrence.IsZero() {
+			if overridden[ev.UID] == nil {
+				overridden[ev.UID] = map[int64]bool{}
+			}
+			overridden[ev.UID][ev.Recurrence.Unix()] = true
+		}
+	}
+	for _, ev := range events {
+		if ev.Cancelled {
+			continue
+		}
+		if ev.RRule == "" || !ev.Recurrence.IsZero() {
+			if overlaps(ev) {
+				out = append(out, ev)
+			}
+			continue
+		}
+		rule := map[string]string{}
+		for _, p := range strings.Split(ev.RRule, ";") {
+			if eq := strings.Index(p, "="); eq > 0 {
+				rule[strings.ToUpper(p[:eq])] = p[eq+1:]
+			}
+		}
+		freq := rule["FREQ"]
+		if freq != "DAILY" && freq != "WEEKLY" {
+			if overlaps(ev) {
+				out = append(out, ev)
+			}
+			continue
+		}
+		interval, _ := strconv.Atoi(rule["INTERVAL"])
+		if interval <= 0 {
+			interval = 1
+		}
+		count, _ := strconv.Atoi(rule["COUNT"])
+		var until time.Time
+		if u := rule["UNTIL"]; u != "" {
+			until, _ = parseICSTime(u, map[string]string{})
+		}
+		byDay := map[time.Weekday]bool{}
+		for _, d := range strings.Split(rule["BYDAY"], ",") {
+			if wd, ok := icsWeekdays[strings.TrimLeft(d, "+-0123456789")]; ok {
+				byDay[wd] = true
+			}
+		}
+		if freq == "WEEKLY" && len(byDay) == 0 {
+			byDay[ev.Start.Weekday()] = true
+		}
+
+		skip := map[int64]bool{}
+		for t := range overridden[ev.UID] {
+			skip[t] = true
+		}
+		for _, t := range ev.ExDates {
+			skip[t.Unix()] = true
+		}
+
+		length := ev.End.Sub(ev.Start)
+		emitted := 0
+		for day := ev.Start; day.Before(to); day = day.AddDate(0, 0, 1) {
+			if !until.IsZero() && day.After(until) {
+				break
+			}
+			daysSince := calendarDaysBetween(ev.Start, day)
+			if freq == "DAILY" {
+				if daysSince%interval != 0 {
+					continue
+				}
+			} else {
+				if !byDay[day.Weekday()] || (daysSince/7)%interval != 0 {
+					continue
+				}
+			}
+			// COUNT includes excluded and moved instances (RFC 5545)
+			emitted++
+			if count > 0 && emitted > count {
+				break
+			}
+			if skip[day.Unix()] {
+				continue
+			}
+			occ := ev
+			occ.Start = day
+			occ.End = day.Add(length)
+			occ.RRule = ""
+			if overlaps(occ) {
+				out = append(out, occ)
+			}
+		}
+	}
+	return out
+}
+
+// calendarDaysBetween counts calendar dates from a to b, so a day that is 23
+// or 25 hours long (a DST change) still counts as one.
+func calendarDaysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
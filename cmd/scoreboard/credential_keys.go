@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// CREDENTIAL KEYS — versioned master keys for integration credentials
+//
+// Every integrations row records the key_id its encrypted_data was sealed
+// with. A key ID is a fingerprint of the key itself ("k" + 8 hex chars of its
+// SHA-256), so nothing extra has to be configured or kept in sync.
+//
+//   SCOREBOARD_MASTER_KEY    primary key: seals every new credential
+//   SCOREBOARD_MASTER_KEYS   comma-separated older keys, still accepted for
+//                            decryption while credentials migrate off them
+//
+// Rows from before key IDs existed (key_id '') are tried against every loaded
+// key and get their key_id filled in on startup.
+//
+// Without any key, the server refuses to start when integrations hold
+// encrypted credentials, and refuses to store new ones — it never invents an
+// ephemeral key that would strand credentials on the next restart.
+//
+// Rotation (offline, then restart with the printed key):
+//
+//   scoreboard rotate-key [-new-key HEX] [-dry-run] [-tenants DIR]
+//
+// re-encrypts every integration in SCOREBOARD_DB and each tenant database
+// under the new key, in one transaction per database.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var masterKeysHex = envOr("SCOREBOARD_MASTER_KEYS", "") // comma-separated 64-char hex keys (decrypt only)
+
+// credentialKeyring holds every key that may have sealed a stored credential.
+type credentialKeyring struct {
+	primary string            // key ID new credentials are sealed with ('' = none configured)
+	keys    map[string][]byte // key ID → AES-256 key
+	order   []string          // primary first, then older keys as configured
+}
+
+var (
+	keyringOnce   sync.Once
+	loadedKeyring *credentialKeyring
+	keyringErr    error
+)
+
+// credentialKeyID fingerprints a key.
+func credentialKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "k" + hex.EncodeToString(sum[:4])
+}
+
+func parseMasterKey(h string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(h))
+	if err != nil {
+		return nil, fmt.Errorf("not hex: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("want 32 bytes (64 hex chars), got %d bytes", len(key))
+	}
+	return key, nil
+}
+
+// newCredentialKeyring builds a keyring from a primary key and older keys, all hex.
+func newCredentialKeyring(primaryHex string, olderHex []string) (*credentialKeyring, error) {
+	kr := &credentialKeyring{keys: map[string][]byte{}}
+	add := func(h, name string) (string, error) {
+		key, err := parseMasterKey(h)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		id := credentialKeyID(key)
+		if _, dup := kr.keys[id]; !dup {
+			kr.keys[id] = key
+			kr.order = append(kr.order, id)
+		}
+		return id, nil
+	}
+	if strings.TrimSpace(primaryHex) != "" {
+		id, err := add(primaryHex, "SCOREBOARD_MASTER_KEY")
+		if err != nil {
+			return nil, err
+		}
+		kr.primary = id
+	}
+	for i, h := range olderHex {
+		if strings.TrimSpace(h) == "" {
+			continue
+		}
+		if _, err := add(h, fmt.Sprintf("SCOREBOARD_MASTER_KEYS[%d]", i)); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+// credentialKeys returns the process keyring, loaded once from the environment.
+func credentialKeys() (*credentialKeyring, error) {
+	keyringOnce.Do(func() {
+		loadedKeyring, keyringErr = newCredentialKeyring(masterKeyHex, strings.Split(masterKeysHex, ","))
+	})
+	return loadedKeyring, keyringErr
+}
+
+func (kr *credentialKeyring) seal(plaintext []byte) (encrypted, nonce []byte, keyID string, err error) {
+	if kr.primary == "" {
+		return nil, nil, "", fmt.Errorf("no master key configured — set SCOREBOARD_MASTER_KEY (64 hex chars) to store credentials")
+	}
+	gcm, err := credentialGCM(kr.keys[kr.primary])
+	if err != nil {
+		return nil, nil, "", err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, "", fmt.Errorf("nonce: %w", err)
+	}
+	return gcm.Seal(nil, nonce, plaintext, nil), nonce, kr.primary, nil
+}
+
+// open decrypts with the named key, or tries every key when keyID is empty.
+// It reports which key worked.
+func (kr *credentialKeyring) open(encrypted, nonce []byte, keyID string) ([]byte, string, error) {
+	if len(kr.keys) == 0 {
+		return nil, "", fmt.Errorf("no master key configured — set SCOREBOARD_MASTER_KEY")
+	}
+	candidates := kr.order
+	if keyID != "" {
+		if _, ok := kr.keys[keyID]; !ok {
+			return nil, "", fmt.Errorf("credential sealed with key %s, which is not loaded (add it to SCOREBOARD_MASTER_KEYS)", keyID)
+		}
+		candidates = []string{keyID}
+	}
+	for _, id := range candidates {
+		gcm, err := credentialGCM(kr.keys[id])
+		if err != nil {
+			return nil, "", err
+		}
+		if plain, err := gcm.Open(nil, nonce, encrypted, nil); err == nil {
+			return plain, id, nil
+		}
+	}
+	if keyID != "" {
+		return nil, "", fmt.Errorf("credential does not decrypt with its key %s (corrupt row?)", keyID)
+	}
+	return nil, "", fmt.Errorf("credential does not decrypt with any loaded key (%s)", strings.Join(kr.order, ", "))
+}
+
+func credentialGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("gcm: %w", err)
+	}
+	return gcm, nil
+}
+
+func (s *Server) initCredentialKeys() {
+	s.db.Exec(`ALTER TABLE integrations ADD COLUMN key_id TEXT DEFAULT ''`)
+}
+
+// verifyCredentialKeys runs at startup: it refuses to start when stored
+// credentials can't possibly be read, backfills key IDs for legacy rows, and
+// reports every integration whose key is missing in one place.
+func (s *Server) verifyCredentialKeys() error {
+	kr, err := credentialKeys()
+	if err != nil {
+		return fmt.Errorf("master key: %w", err)
+	}
+	type stored struct {
+		id, provider, keyID, status, lastError string
+		enc, nonce                             []byte
+	}
+	rows, err := s.db.Query(`SELECT id, provider, COALESCE(key_id,''), status, COALESCE(last_error,''),
+		encrypted_data, nonce FROM integrations WHERE length(encrypted_data) > 0`)
+	if err != nil {
+		return fmt.Errorf("read integrations: %w", err)
+	}
+	var all []stored
+	for rows.Next() {
+		var st stored
+		rows.Scan(&st.id, &st.provider, &st.keyID, &st.status, &st.lastError, &st.enc, &st.nonce)
+		all = append(all, st)
+	}
+	rows.Close()
+
+	if len(kr.keys) == 0 {
+		if len(all) > 0 {
+			return fmt.Errorf("%d integrations hold encrypted credentials but SCOREBOARD_MASTER_KEY is not set — "+
+				"refusing to start; set the key they were sealed with", len(all))
+		}
+		log.Printf("WARNING: SCOREBOARD_MASTER_KEY not set — integrations that need credentials can't be added until it is")
+		return nil
+	}
+	if kr.primary == "" {
+		log.Printf("WARNING: only SCOREBOARD_MASTER_KEYS set — stored credentials decrypt but new ones can't be saved")
+	}
+
+	var failed []string
+	failedErr := map[string]string{}
+	ok, backfilled, recovered := 0, 0, 0
+	for _, st := range all {
+		_, usedID, err := kr.open(st.enc, st.nonce, st.keyID)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s): %v", st.id, st.provider, err))
+			failedErr[st.id] = err.Error()
+			continue
+		}
+		ok++
+		if st.keyID == "" {
+			s.db.Exec(`UPDATE integrations SET key_id=? WHERE id=?`, usedID, st.id)
+			backfilled++
+		}
+		// Integrations a poller disabled because the key was wrong come back
+		if st.status == "error" && isCredentialKeyError(st.lastError) {
+			s.db.Exec(`UPDATE integrations SET status='active', last_error='' WHERE id=?`, st.id)
+			recovered++
+		}
+	}
+	if ok == 0 && len(failed) > 0 {
+		return fmt.Errorf("none of the %d stored credentials decrypt with the configured key(s) %s — wrong SCOREBOARD_MASTER_KEY?",
+			len(failed), strings.Join(kr.order, ", "))
+	}
+	for id, msg := range failedErr {
+		s.db.Exec(`UPDATE integrations SET status='error', last_error=? WHERE id=?`, msg, id)
+	}
+	if len(failed) > 0 {
+		log.Printf("ERROR: %d of %d integration credentials can't be decrypted and were disabled:\n  %s",
+			len(failed), len(all), strings.Join(failed, "\n  "))
+	}
+	log.Printf("Credential keys: primary %s, %d loaded; %d credentials readable (%d key IDs backfilled, %d re-enabled)",
+		kr.primary, len(kr.keys), ok, backfilled, recovered)
+	return nil
+}
+
+// isCredentialKeyError recognises last_error values left by a key problem
+// rather than by the provider.
+func isCredentialKeyError(msg string) bool {
+	for _, marker := range []string{"message authentication failed", "master key", "not loaded", "decrypt with any loaded key"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// ─── Rotation ───────────────────────────────────────────────────────────────
+
+// runRotateKey implements `scoreboard rotate-key`. Returns the exit code.
+func runRotateKey(args []string) int {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	newKeyHex := fs.String("new-key", os.Getenv("SCOREBOARD_NEW_MASTER_KEY"), "new 64-char hex key (default: generate one)")
+	dryRun := fs.Bool("dry-run", false, "decrypt everything and report, but write nothing")
+	tenantsDir := fs.String("tenants", "/data/wirebot/scoreboard/tenants", "directory of tenant databases to rotate too ('' to skip)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	old, err := credentialKeys()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotate-key: current keys: %v\n", err)
+		return 1
+	}
+	generated := false
+	if *newKeyHex == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			fmt.Fprintf(os.Stderr, "rotate-key: generate key: %v\n", err)
+			return 1
+		}
+		*newKeyHex = hex.EncodeToString(key)
+		generated = true
+	}
+	next, err := newCredentialKeyring(*newKeyHex, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotate-key: new key: %v\n", err)
+		return 1
+	}
+
+	dbs := []string{dbPath}
+	if *tenantsDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(*tenantsDir, "*", "events.db"))
+		dbs = append(dbs, matches...)
+	}
+	total := 0
+	for _, path := range dbs {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		n, err := rotateCredentialDB(path, old, next, *dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotate-key: %s: %v\n", path, err)
+			fmt.Fprintln(os.Stderr, "rotate-key: nothing in that database was changed; fix the error and run again")
+			return 1
+		}
+		verb := "re-encrypted"
+		if *dryRun {
+			verb = "would be re-encrypted"
+		}
+		fmt.Printf("%s: %d credentials %s\n", path, n, verb)
+		total += n
+	}
+
+	if *dryRun {
+		fmt.Printf("dry run: %d credentials readable, new key would be %s\n", total, next.primary)
+		return 0
+	}
+	fmt.Printf("\nRotated %d credentials to key %s.\n", total, next.primary)
+	if generated {
+		fmt.Printf("New key (store it now, it is not saved anywhere):\n  SCOREBOARD_MASTER_KEY=%s\n", *newKeyHex)
+	} else {
+		fmt.Println("Set SCOREBOARD_MASTER_KEY to the new key.")
+	}
+	if old.primary != "" {
+		fmt.Printf("Keep the old key in SCOREBOARD_MASTER_KEYS until the server has restarted, so credentials it\n" +
+			"saved in the meantime still decrypt; run rotate-key again afterwards to move them.\n")
+	}
+	return 0
+}
+
+// rotateCredentialDB re-encrypts one database's integrations in a transaction.
+func rotateCredentialDB(path string, old, next *credentialKeyring, dryRun bool) (int, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	(&Server{db: db}).initCredentialKeys()
+
+	type sealed struct {
+		id, keyID  string
+		enc, nonce []byte
+	}
+	rows, err := db.Query(`SELECT id, COALESCE(key_id,''), encrypted_data, nonce FROM integrations
+		WHERE length(encrypted_data) > 0`)
+	if err != nil {
+		return 0, err
+	}
+	var all []sealed
+	for rows.Next() {
+		var c sealed
+		rows.Scan(&c.id, &c.keyID, &c.enc, &c.nonce)
+		all = append(all, c)
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rotated := 0
+	for _, c := range all {
+		plain, _, err := old.open(c.enc, c.nonce, c.keyID)
+		if err != nil {
+			// Already under the new key (a re-run) is fine
+			if _, _, err2 := next.open(c.enc, c.nonce, ""); err2 == nil {
+				continue
+			}
+			return 0, fmt.Errorf("integration %s: %w", c.id, err)
+		}
+		enc, nonce, keyID, err := next.seal(plain)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE integrations SET encrypted_data=?, nonce=?, key_id=? WHERE id=?`,
+			enc, nonce, keyID, c.id); err != nil {
+			return 0, err
+		}
+		rotated++
+	}
+	if dryRun {
+		return rotated, nil
+	}
+	return rotated, tx.Commit()
+}
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// DISTRIBUTION OUTCOMES — delayed scoring on reach, not just on publishing
+//
+// BLOG_PUBLISHED / VIDEO_PUBLISHED / PODCAST_PUBLISHED / CAMPAIGN_SENT earn
+// flat points the moment they ship. A few days later (OUTCOME_DELAY_DAYS) we
+// go back and measure what the artifact actually did:
+//
+//   video     YouTube views, likes, comments        (youtube integration)
+//   blog      PostHog pageviews on the artifact URL (posthog integration),
+//             else Cloudflare edge requests to its path (cloudflare integration)
+//   campaign  Sendy opens and clicks, sampled by pollSendy on every poll
+//
+// Reach is compared to a baseline: the median reach of the last
+// outcomeBaselineSize scored artifacts of the same type, or a built-in
+// default until there are enough of them. The follow-up DISTRIBUTION_OUTCOME
+// event earns a share of the original points that grows with that ratio —
+// nothing below half the baseline, the full points again at 2–4×, 1.5× the
+// points beyond that. Artifacts nobody measures after outcomeMaxAttempts
+// tries are marked unmeasured and never score.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	outcomeDelayDays    = envOr("OUTCOME_DELAY_DAYS", "3")     // days after publication before measuring
+	outcomeLookbackDays = envOr("OUTCOME_LOOKBACK_DAYS", "14") // only artifacts published this recently enrol
+)
+
+const (
+	outcomeBaselineSize  = 10
+	outcomeMinBaseline   = 3 // scored artifacts needed before the median replaces the default
+	outcomeMaxAttempts   = 6
+	outcomeRetryInterval = 12 * time.Hour
+)
+
+// outcomeEventTypes maps each scored artifact type to the metric its reach is
+// measured in.
+var outcomeEventTypes = map[string]string{
+	"BLOG_PUBLISHED":      "pageviews",
+	"PODCAST_PUBLISHED":   "pageviews",
+	"VIDEO_PUBLISHED":     "views",
+	"CAMPAIGN_SENT":       "opens",
+	"EMAIL_CAMPAIGN_SENT": "opens",
+}
+
+// outcomeDefaultBaseline is the reach treated as "normal" for a type until
+// the operator's own history takes over.
+var outcomeDefaultBaseline = map[string]float64{
+	"BLOG_PUBLISHED":      100,
+	"PODCAST_PUBLISHED":   50,
+	"VIDEO_PUBLISHED":     100,
+	"CAMPAIGN_SENT":       100,
+	"EMAIL_CAMPAIGN_SENT": 100,
+}
+
+func (s *Server) initDistributionOutcomes() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS distribution_outcomes (
+		event_id TEXT PRIMARY KEY,
+		event_type TEXT NOT NULL,
+		source TEXT DEFAULT '',
+		artifact_url TEXT DEFAULT '',
+		artifact_title TEXT DEFAULT '',
+		published_at TEXT NOT NULL,
+		due_at TEXT NOT NULL,
+		next_check_at TEXT DEFAULT '',
+		attempts INTEGER DEFAULT 0,
+		status TEXT DEFAULT 'pending',
+		metrics TEXT DEFAULT '{}',
+		reach REAL DEFAULT 0,
+		baseline REAL DEFAULT 0,
+		ratio REAL DEFAULT 0,
+		bonus INTEGER DEFAULT 0,
+		outcome_event_id TEXT DEFAULT '',
+		scored_at TEXT DEFAULT ''
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_outcomes_status ON distribution_outcomes(status, due_at)`)
+	// Raw engagement samples, one per artifact/provider/metric/day, so the
+	// outcome can be explained and trends survive provider outages.
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS engagement_samples (
+		event_id TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		value REAL NOT NULL,
+		sample_date TEXT NOT NULL,
+		observed_at TEXT NOT NULL,
+		PRIMARY KEY (event_id, provider, metric, sample_date)
+	)`)
+}
+
+// recordEngagement stores the latest metric values seen for an artifact, one
+// row per metric per day, and only when a value changed — pollers report
+// every campaign or video on every run, long after its numbers settle.
+func (s *Server) recordEngagement(eventID, provider string, metrics map[string]float64) {
+	now := time.Now().UTC()
+	for metric, v := range metrics {
+		var last sql.NullFloat64
+		s.db.QueryRow(`SELECT value FROM engagement_samples WHERE event_id=? AND provider=? AND metric=?
+			ORDER BY sample_date DESC LIMIT 1`, eventID, provider, metric).Scan(&last)
+		if last.Valid && last.Float64 == v {
+			continue
+		}
+		s.db.Exec(`INSERT INTO engagement_samples (event_id, provider, metric, value, sample_date, observed_at)
+			VALUES (?,?,?,?,?,?)
+			ON CONFLICT(event_id, provider, metric, sample_date) DO UPDATE SET value=excluded.value, observed_at=excluded.observed_at`,
+			eventID, provider, metric, v, now.Format("2006-01-02"), now.Format(time.RFC3339))
+	}
+}
+
+// latestEngagement returns the most recent value of each metric for an artifact.
+func (s *Server) latestEngagement(eventID string) map[string]float64 {
+	out := map[string]float64{}
+	rows, err := s.db.Query(`SELECT metric, value FROM engagement_samples WHERE event_id=?
+		ORDER BY observed_at ASC`, eventID)
+	if err != nil {
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var metric string
+		var v float64
+		rows.Scan(&metric, &v)
+		out[metric] = v // ascending, so the newest wins
+	}
+	return out
+}
+
+// activeIntegration returns the decrypted credential and config of the first
+// active integration for any of the given providers.
+func (s *Server) activeIntegration(providers ...string) (credential, config string, ok bool) {
+	for _, p := range providers {
+		var encData, nonce []byte
+		var keyID string
+		err := s.db.QueryRow(`SELECT encrypted_data, nonce, COALESCE(key_id,''), COALESCE(config,'') FROM integrations
+			WHERE provider=? AND status='active' ORDER BY created_at LIMIT 1`, p).Scan(&encData, &nonce, &keyID, &config)
+		if err != nil || len(encData) == 0 {
+			continue
+		}
+		decrypted, err := s.decryptCredentialKey(encData, nonce, keyID)
+		if err != nil {
+			continue
+		}
+		return string(decrypted), config, true
+	}
+	return "", "", false
+}
+
+// enrollDistributionOutcomes queues recent distribution artifacts for measurement.
+func (s *Server) enrollDistributionOutcomes() {
+	delay, _ := strconv.Atoi(outcomeDelayDays)
+	lookback, _ := strconv.Atoi(outcomeLookbackDays)
+	if lookback <= 0 {
+		lookback = 14
+	}
+	since := time.Now().UTC().AddDate(0, 0, -lookback).Format(time.RFC3339)
+
+	types := make([]string, 0, len(outcomeEventTypes))
+	args := []interface{}{since}
+	for t := range outcomeEventTypes {
+		types = append(types, "?")
+		args = append(args, t)
+	}
+	rows, err := s.db.Query(`SELECT e.id, e.event_type, e.source, COALESCE(e.artifact_url,''),
+		COALESCE(e.artifact_title,''), e.timestamp
+		FROM events e LEFT JOIN distribution_outcomes o ON o.event_id = e.id
+		WHERE e.lane='distribution' AND e.status='approved' AND e.timestamp >= ?
+		AND e.event_type IN (`+strings.Join(types, ",")+`) AND o.event_id IS NULL`, args...)
+	if err != nil {
+		return
+	}
+	type pending struct{ id, etype, source, url, title, ts string }
+	var queue []pending
+	for rows.Next() {
+		var p pending
+		rows.Scan(&p.id, &p.etype, &p.source, &p.url, &p.title, &p.ts)
+		queue = append(queue, p)
+	}
+	rows.Close()
+
+	for _, p := range queue {
+		pub := parseFlexibleTime(p.ts)
+		if pub.IsZero() {
+			continue
+		}
+		due := pub.UTC().AddDate(0, 0, delay).Format(time.RFC3339)
+		s.db.Exec(`INSERT OR IGNORE INTO distribution_outcomes (event_id, event_type, source, artifact_url,
+			artifact_title, published_at, due_at, next_check_at) VALUES (?,?,?,?,?,?,?,?)`,
+			p.id, p.etype, p.source, p.url, p.title, pub.UTC().Format(time.RFC3339), due, due)
+	}
+}
+
+// distributionOutcome is one queued or scored artifact.
+type distributionOutcome struct {
+	EventID        string             `json:"event_id"`
+	EventType      string             `json:"event_type"`
+	Source         string             `json:"source"`
+	ArtifactURL    string             `json:"artifact_url"`
+	ArtifactTitle  string             `json:"artifact_title"`
+	PublishedAt    string             `json:"published_at"`
+	DueAt          string             `json:"due_at"`
+	Attempts       int                `json:"attempts"`
+	Status         string             `json:"status"` // pending, scored, unmeasured
+	Metrics        map[string]float64 `json:"metrics"`
+	Reach          float64            `json:"reach"`
+	Baseline       float64            `json:"baseline"`
+	Ratio          float64            `json:"ratio"`
+	Bonus          int                `json:"bonus"`
+	OutcomeEventID string             `json:"outcome_event_id,omitempty"`
+	ScoredAt       string             `json:"scored_at,omitempty"`
+}
+
+const distributionOutcomeCols = `event_id, event_type, source, artifact_url, artifact_title, published_at,
+	due_at, attempts, status, metrics, reach, baseline, ratio, bonus, outcome_event_id, scored_at`
+
+func scanDistributionOutcome(scan func(...interface{}) error) (distributionOutcome, error) {
+	var o distributionOutcome
+	var metrics string
+	err := scan(&o.EventID, &o.EventType, &o.Source, &o.ArtifactURL, &o.ArtifactTitle, &o.PublishedAt,
+		&o.DueAt, &o.Attempts, &o.Status, &metrics, &o.Reach, &o.Baseline, &o.Ratio, &o.Bonus,
+		&o.OutcomeEventID, &o.ScoredAt)
+	o.Metrics = map[string]float64{}
+	json.Unmarshal([]byte(metrics), &o.Metrics)
+	return o, err
+}
+
+// checkDistributionOutcomes enrols new artifacts and scores the ones that are due.
+func (s *Server) checkDistributionOutcomes() {
+	s.enrollDistributionOutcomes()
+
+	now := time.Now().UTC()
+	rows, err := s.db.Query(`SELECT `+distributionOutcomeCols+` FROM distribution_outcomes
+		WHERE status='pending' AND due_at <= ? AND next_check_at <= ? ORDER BY due_at LIMIT 20`,
+		now.Format(time.RFC3339), now.Format(time.RFC3339))
+	if err != nil {
+		return
+	}
+	var due []distributionOutcome
+	for rows.Next() {
+		if o, err := scanDistributionOutcome(rows.Scan); err == nil {
+			due = append(due, o)
+		}
+	}
+	rows.Close()
+
+	scored := false
+	for _, o := range due {
+		metrics := s.measureDistributionOutcome(o)
+		reach, measured := metrics[outcomeEventTypes[o.EventType]]
+		if !measured {
+			attempts := o.Attempts + 1
+			status := "pending"
+			if attempts >= outcomeMaxAttempts {
+				status = "unmeasured"
+			}
+			s.db.Exec(`UPDATE distribution_outcomes SET attempts=?, status=?, next_check_at=? WHERE event_id=?`,
+				attempts, status, now.Add(outcomeRetryInterval).Format(time.RFC3339), o.EventID)
+			continue
+		}
+		if s.scoreDistributionOutcome(o, metrics, reach) {
+			scored = true
+		}
+	}
+	if scored {
+		s.updateDailyScore(operatorToday())
+		s.recalcSeason()
+	}
+}
+
+// measureDistributionOutcome gathers every metric available for the artifact.
+func (s *Server) measureDistributionOutcome(o distributionOutcome) map[string]float64 {
+	switch o.EventType {
+	case "VIDEO_PUBLISHED":
+		if m, err := s.youtubeVideoStats(o.ArtifactURL); err == nil {
+			s.recordEngagement(o.EventID, "youtube", m)
+		} else {
+			log.Printf("Outcomes: youtube %s: %v", o.EventID, err)
+		}
+	case "BLOG_PUBLISHED", "PODCAST_PUBLISHED":
+		since, _ := time.Parse(time.RFC3339, o.PublishedAt)
+		if o.ArtifactURL == "" {
+			break
+		}
+		if n, err := s.posthogURLPageviews(o.ArtifactURL, since); err == nil {
+			s.recordEngagement(o.EventID, "posthog", map[string]float64{"pageviews": n})
+		} else if n, err := s.cloudflarePathRequests(o.ArtifactURL, since); err == nil {
+			s.recordEngagement(o.EventID, "cloudflare", map[string]float64{"pageviews": n})
+		} else {
+			log.Printf("Outcomes: no traffic source for %s: %v", o.EventID, err)
+		}
+	}
+	// Campaign opens/clicks arrive through pollSendy's samples
+	return s.latestEngagement(o.EventID)
+}
+
+// outcomeBaseline is the median reach of recently scored artifacts of a type.
+func (s *Server) outcomeBaseline(eventType, excludeID string) (float64, string) {
+	rows, err := s.db.Query(`SELECT reach FROM distribution_outcomes
+		WHERE event_type=? AND status='scored' AND event_id != ? ORDER BY scored_at DESC LIMIT ?`,
+		eventType, excludeID, outcomeBaselineSize)
+	var history []float64
+	if err == nil {
+		for rows.Next() {
+			var v float64
+			rows.Scan(&v)
+			history = append(history, v)
+		}
+		rows.Close()
+	}
+	if len(history) < outcomeMinBaseline {
+		return outcomeDefaultBaseline[eventType], "default"
+	}
+	sort.Float64s(history)
+	mid := len(history) / 2
+	median := history[mid]
+	if len(history)%2 == 0 {
+		median = (history[mid-1] + history[mid]) / 2
+	}
+	if median < 1 {
+		median = 1
+	}
+	return median, fmt.Sprintf("median of last %d", len(history))
+}
+
+// outcomeBonus scales the artifact's original points by how it did against baseline.
+func outcomeBonus(eventType string, ratio float64) int {
+	factor := 0.0
+	switch {
+	case ratio >= 4:
+		factor = 1.5
+	case ratio >= 2:
+		factor = 1.0
+	case ratio >= 1:
+		factor = 0.5
+	case ratio >= 0.5:
+		factor = 0.25
+	}
+	return int(math.Round(float64(calcScoreDelta("distribution", eventType, 1.0)) * factor))
+}
+
+// scoreDistributionOutcome records the result and emits the follow-up event.
+// Reports whether a score changed.
+func (s *Server) scoreDistributionOutcome(o distributionOutcome, metrics map[string]float64, reach float64) bool {
+	baseline, basis := s.outcomeBaseline(o.EventType, o.EventID)
+	ratio := 0.0
+	if baseline > 0 {
+		ratio = math.Round(reach/baseline*100) / 100
+	}
+	bonus := outcomeBonus(o.EventType, ratio)
+	now := time.Now().UTC().Format(time.RFC3339)
+	metricsJSON, _ := json.Marshal(metrics)
+
+	outcomeID := ""
+	if bonus > 0 {
+		outcomeID = "evt-outcome-" + o.EventID
+		meta, _ := json.Marshal(map[string]interface{}{
+			"parent_event_id": o.EventID, "parent_type": o.EventType, "metrics": metrics,
+			"reach": reach, "baseline": baseline, "baseline_basis": basis, "ratio": ratio,
+		})
+		title := fmt.Sprintf("📈 Outcome: %s — %s %s (%.1f× baseline)", o.ArtifactTitle,
+			strconv.FormatFloat(reach, 'f', -1, 64), outcomeEventTypes[o.EventType], ratio)
+		var exists int
+		s.db.QueryRow("SELECT COUNT(*) FROM events WHERE id=?", outcomeID).Scan(&exists)
+		if exists == 0 {
+			s.mu.Lock()
+			s.db.Exec(`INSERT INTO events (id, event_type, lane, source, timestamp,
+				artifact_url, artifact_title, confidence, verifiers, verification_level,
+				score_delta, metadata, external_id, created_at, status) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+				outcomeID, "DISTRIBUTION_OUTCOME", "distribution", o.Source, now,
+				o.ArtifactURL, title, 0.9, `["engagement_metrics"]`, "STRONG",
+				bonus, string(meta), "outcome-"+o.EventID, now, "approved")
+			s.mu.Unlock()
+		}
+	}
+	s.db.Exec(`UPDATE distribution_outcomes SET status='scored', attempts=attempts+1, metrics=?, reach=?,
+		baseline=?, ratio=?, bonus=?, outcome_event_id=?, scored_at=? WHERE event_id=?`,
+		string(metricsJSON), reach, baseline, ratio, bonus, outcomeID, now, o.EventID)
+	log.Printf("Outcomes: %s %q reach=%.0f baseline=%.0f (%s) → +%d", o.EventType, o.ArtifactTitle,
+		reach, baseline, basis, bonus)
+	return bonus > 0
+}
+
+// ─── Metric collectors ──────────────────────────────────────────────────────
+
+// youtubeVideoStats reads view/like/comment counts for a watch URL.
+func (s *Server) youtubeVideoStats(videoURL string) (map[string]float64, error) {
+	u, err := url.Parse(videoURL)
+	if err != nil || u.Query().Get("v") == "" {
+		return nil, fmt.Errorf("not a watch URL: %s", videoURL)
+	}
+	apiKey, _, ok := s.activeIntegration("youtube", "youtube_key")
+	if !ok {
+		return nil, fmt.Errorf("no active youtube integration")
+	}
+	endpoint := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?part=statistics&id=%s&key=%s",
+		url.QueryEscape(u.Query().Get("v")), url.QueryEscape(apiKey))
+	client := providerClient(15 * time.Second)
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("youtube api: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Items []struct {
+			Statistics struct {
+				ViewCount    string `json:"viewCount"`
+				LikeCount    string `json:"likeCount"`
+				CommentCount string `json:"commentCount"`
+			} `json:"statistics"`
+		} `json:"items"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.Error.Message != "" {
+		return nil, fmt.Errorf("youtube: %s", result.Error.Message)
+	}
+	if len(result.Items) == 0 {
+		return nil, fmt.Errorf("video not found")
+	}
+	st := result.Items[0].Statistics
+	views, _ := strconv.ParseFloat(st.ViewCount, 64)
+	likes, _ := strconv.ParseFloat(st.LikeCount, 64)
+	comments, _ := strconv.ParseFloat(st.CommentCount, 64)
+	return map[string]float64{"views": views, "likes": likes, "comments": comments}, nil
+}
+
+// posthogURLPageviews counts $pageview events on the artifact URL since publication.
+func (s *Server) posthogURLPageviews(artifactURL string, since time.Time) (float64, error) {
+	apiKey, config, ok := s.activeIntegration("posthog")
+	if !ok {
+		return 0, fmt.Errorf("no active posthog integration")
+	}
+	var cfg struct {
+		Host string `json:"host"`
+	}
+	json.Unmarshal([]byte(config), &cfg)
+	host := cfg.Host
+	if host == "" {
+		host = "https://data.philoveracity.com"
+	}
+	// Match on the path so http/https and tracking params don't split the count
+	match := artifactURL
+	if u, err := url.Parse(artifactURL); err == nil && u.Path != "" && u.Path != "/" {
+		match = u.Host + strings.TrimRight(u.Path, "/")
+	}
+	events, _ := json.Marshal([]map[string]interface{}{{
+		"id": "$pageview",
+		"properties": []map[string]string{{
+			"key": "$current_url", "value": match, "operator": "icontains", "type": "event",
+		}},
+	}})
+	endpoint := fmt.Sprintf("%s/api/projects/@current/insights/trend/?events=%s&date_from=%s&date_to=now",
+		strings.TrimRight(host, "/"), url.QueryEscape(string(events)), since.Format("2006-01-02"))
+
+	client := providerClient(15 * time.Second)
+	req, _ := http.NewRequest("GET", endpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("posthog api: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("posthog: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Result []struct {
+			Data []float64 `json:"data"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("posthog: %w", err)
+	}
+	total := 0.0
+	for _, series := range result.Result {
+		for _, v := range series.Data {
+			total += v
+		}
+	}
+	return total, nil
+}
+
+// cloudflarePathRequests counts edge requests to the artifact's path since
+// publication, for sites without product analytics.
+func (s *Server) cloudflarePathRequests(artifactURL string, since time.Time) (float64, error) {
+	u, err := url.Parse(artifactURL)
+	if err != nil || u.Host == "" {
+		return 0, fmt.Errorf("bad artifact URL")
+	}
+	apiToken, config, ok := s.activeIntegration("cloudflare")
+	if !ok {
+		return 0, fmt.Errorf("no active cloudflare integration")
+	}
+	var cfg struct {
+		Email string `json:"email"`
+	}
+	json.Unmarshal([]byte(config), &cfg)
+	client := providerClient(15 * time.Second)
+	do := func(method, endpoint string, body io.Reader) (*http.Response, error) {
+		req, _ := http.NewRequest(method, endpoint, body)
+		if cfg.Email != "" {
+			req.Header.Set("X-Auth-Email", cfg.Email)
+			req.Header.Set("X-Auth-Key", apiToken)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+apiToken)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return client.Do(req)
+	}
+
+	// Find the zone serving this host (longest matching zone name wins)
+	resp, err := do("GET", "https://api.cloudflare.com/client/v4/zones?per_page=50", nil)
+	if err != nil {
+		return 0, fmt.Errorf("cloudflare zones: %w", err)
+	}
+	var zones struct {
+		Result []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"result"`
+	}
+	json.NewDecoder(resp.Body).Decode(&zones)
+	resp.Body.Close()
+	zoneID, zoneName := "", ""
+	for _, z := range zones.Result {
+		if (u.Host == z.Name || strings.HasSuffix(u.Host, "."+z.Name)) && len(z.Name) > len(zoneName) {
+			zoneID, zoneName = z.ID, z.Name
+		}
+	}
+	if zoneID == "" {
+		return 0, fmt.Errorf("no cloudflare zone for %s", u.Host)
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	query, _ := json.Marshal(map[string]interface{}{
+		"query": `query($zone: String!, $since: Time!, $until: Time!, $host: String!, $path: String!) {
+			viewer { zones(filter: {zoneTag: $zone}) {
+				httpRequestsAdaptiveGroups(limit: 1, filter: {datetime_geq: $since, datetime_leq: $until,
+					clientRequestHTTPHost: $host, clientRequestPath: $path, requestSource: "eyeball"}) { count }
+			} }
+		}`,
+		"variables": map[string]string{
+			"zone": zoneID, "host": u.Host, "path": path,
+			"since": since.UTC().Format(time.RFC3339), "until": time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+	resp, err = do("POST", "https://api.cloudflare.com/client/v4/graphql", strings.NewReader(string(query)))
+	if err != nil {
+		return 0, fmt.Errorf("cloudflare graphql: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Data struct {
+			Viewer struct {
+				Zones []struct {
+					Groups []struct {
+						Count float64 `json:"count"`
+					} `json:"httpRequestsAdaptiveGroups"`
+				} `json:"zones"`
+			} `json:"viewer"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Errors) > 0 {
+		return 0, fmt.Errorf("cloudflare graphql: %s", result.Errors[0].Message)
+	}
+	total := 0.0
+	for _, z := range result.Data.Viewer.Zones {
+		for _, g := range z.Groups {
+			total += g.Count
+		}
+	}
+	return total, nil
+}
+
+// ─── API ────────────────────────────────────────────────────────────────────
+
+// handleDistributionOutcomes: GET /v1/distribution/outcomes[?status=pending|scored|unmeasured]
+func (s *Server) handleDistributionOutcomes(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+	q := `SELECT ` + distributionOutcomeCols + ` FROM distribution_outcomes`
+	var args []interface{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		q += ` WHERE status=?`
+		args = append(args, status)
+	}
+	rows, err := s.db.Query(q+` ORDER BY published_at DESC LIMIT 100`, args...)
+	if err != nil {
+		http.Error(w, `{"error":"query failed"}`, 500)
+		return
+	}
+	outcomes := []distributionOutcome{}
+	for rows.Next() {
+		if o, err := scanDistributionOutcome(rows.Scan); err == nil {
+			outcomes = append(outcomes, o)
+		}
+	}
+	rows.Close()
+
+	baselines := map[string]interface{}{}
+	for t, metric := range outcomeEventTypes {
+		b, basis := s.outcomeBaseline(t, "")
+		baselines[t] = map[string]interface{}{"metric": metric, "baseline": b, "basis": basis}
+	}
+	writeJSON(w, map[string]interface{}{
+		"outcomes":   outcomes,
+		"baselines":  baselines,
+		"delay_days": outcomeDelayDays,
+	})
+}
@@ -0,0 +1,685 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// EXPENSES, P&L AND RUNWAY
+//
+// Plaid outflows (EXPENSE) and FreshBooks expenses (EXPENSE_RECORDED) score 0
+// on the board but are the other half of the money picture. syncExpenses
+// mirrors them into an expenses ledger in the base currency and categorises
+// each one:
+//
+//   1. manual      operator recategorised this expense (never overwritten)
+//   2. rule        operator merchant rule (expense_rules, newest first)
+//   3. provider    FreshBooks category — an accountant already picked it
+//   4. default     built-in merchant / Plaid category patterns
+//   5. Uncategorized
+//
+// A bank outflow that pays an expense already booked in FreshBooks (same
+// amount within 3 days) is marked duplicate_of so it isn't counted twice.
+// "Transfer" (card payments, moves between own accounts) is non-operating
+// and stays out of the P&L.
+//
+// Monthly P&L takes revenue from the reconciled revenue report (duplicates
+// and test transactions removed) and subtracts categorised expenses. Burn is
+// the average of the last 3 complete months; runway divides net cash (Plaid
+// depository balances minus credit card balances) by net burn and feeds the
+// pairing engine's FINANCIAL_PRESSURE context window.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+const expenseTransferCategory = "Transfer"
+
+// expenseCategories is the chart the UI offers for recategorisation.
+var expenseCategories = []string{
+	"Software & SaaS", "Hosting & Infrastructure", "Marketing & Ads", "Contractors",
+	"Payroll", "Payment Processing", "Bank Fees", "Office & Equipment", "Travel",
+	"Meals", "Professional Services", "Taxes", "Insurance", "Education", "Other",
+	expenseTransferCategory, "Uncategorized",
+}
+
+// defaultExpenseRules are case-insensitive substrings checked against the
+// merchant, description and provider category, in order.
+var defaultExpenseRules = []struct{ pattern, category string }{
+	{"transfer", expenseTransferCategory},
+	{"credit card", expenseTransferCategory},
+	{"payment/credit", expenseTransferCategory},
+	{"aws.amazon", "Hosting & Infrastructure"},
+	{"amazon web services", "Hosting & Infrastructure"},
+	{"digitalocean", "Hosting & Infrastructure"},
+	{"hetzner", "Hosting & Infrastructure"},
+	{"vercel", "Hosting & Infrastructure"},
+	{"cloudflare", "Hosting & Infrastructure"},
+	{"github", "Software & SaaS"},
+	{"openai", "Software & SaaS"},
+	{"anthropic", "Software & SaaS"},
+	{"notion", "Software & SaaS"},
+	{"slack", "Software & SaaS"},
+	{"google workspace", "Software & SaaS"},
+	{"gsuite", "Software & SaaS"},
+	{"figma", "Software & SaaS"},
+	{"adobe", "Software & SaaS"},
+	{"facebook ads", "Marketing & Ads"},
+	{"meta ads", "Marketing & Ads"},
+	{"google ads", "Marketing & Ads"},
+	{"advertising", "Marketing & Ads"},
+	{"upwork", "Contractors"},
+	{"fiverr", "Contractors"},
+	{"gusto", "Payroll"},
+	{"deel", "Payroll"},
+	{"payroll", "Payroll"},
+	{"stripe fee", "Payment Processing"},
+	{"paypal fee", "Payment Processing"},
+	{"bank fees", "Bank Fees"},
+	{"overdraft", "Bank Fees"},
+	{"airlines", "Travel"},
+	{"travel", "Travel"},
+	{"uber", "Travel"},
+	{"lyft", "Travel"},
+	{"restaurants", "Meals"},
+	{"food and drink", "Meals"},
+	{"irs treas", "Taxes"},
+	{"internal revenue", "Taxes"},
+	{"tax payment", "Taxes"},
+	{"franchise tax", "Taxes"},
+	{"insurance", "Insurance"},
+	{"legal", "Professional Services"},
+	{"accounting", "Professional Services"},
+}
+
+func (s *Server) initExpenses() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS expenses (
+		id TEXT PRIMARY KEY,
+		source TEXT NOT NULL,
+		date TEXT NOT NULL,
+		merchant TEXT DEFAULT '',
+		description TEXT DEFAULT '',
+		amount REAL NOT NULL,
+		amount_original REAL NOT NULL,
+		currency TEXT NOT NULL,
+		provider_category TEXT DEFAULT '',
+		category TEXT NOT NULL,
+		category_source TEXT NOT NULL,
+		rule_id INTEGER,
+		duplicate_of TEXT DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_expenses_date ON expenses(date)`)
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS expense_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pattern TEXT NOT NULL,
+		field TEXT NOT NULL DEFAULT 'merchant',
+		category TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	)`)
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS bank_balances (
+		account_id TEXT PRIMARY KEY,
+		integration_id TEXT NOT NULL,
+		name TEXT DEFAULT '',
+		mask TEXT DEFAULT '',
+		type TEXT DEFAULT '',
+		subtype TEXT DEFAULT '',
+		current REAL DEFAULT 0,
+		available REAL DEFAULT 0,
+		currency TEXT DEFAULT '',
+		updated_at TEXT NOT NULL
+	)`)
+}
+
+// expenseRule is an operator-defined merchant rule.
+type expenseRule struct {
+	ID       int64  `json:"id"`
+	Pattern  string `json:"pattern"`
+	Field    string `json:"field"` // merchant | description | category
+	Category string `json:"category"`
+}
+
+func (s *Server) loadExpenseRules() []expenseRule {
+	var rules []expenseRule
+	rows, err := s.db.Query(`SELECT id, pattern, field, category FROM expense_rules ORDER BY id DESC`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r expenseRule
+		rows.Scan(&r.ID, &r.Pattern, &r.Field, &r.Category)
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// categorizeExpense returns category, how it was decided, and the rule ID.
+func categorizeExpense(source, merchant, description, providerCategory string, rules []expenseRule) (string, string, int64) {
+	fields := map[string]string{
+		"merchant":    strings.ToLower(merchant),
+		"description": strings.ToLower(description),
+		"category":    strings.ToLower(providerCategory),
+	}
+	for _, r := range rules {
+		if strings.Contains(fields[r.Field], strings.ToLower(r.Pattern)) {
+			return r.Category, "rule", r.ID
+		}
+	}
+	if source == "freshbooks" && providerCategory != "" {
+		return providerCategory, "provider", 0
+	}
+	for _, field := range []string{"merchant", "description", "category"} {
+		for _, d := range defaultExpenseRules {
+			if strings.Contains(fields[field], d.pattern) {
+				return d.category, "default", 0
+			}
+		}
+	}
+	return "Uncategorized", "default", 0
+}
+
+// syncExpenses mirrors expense events into the ledger, re-categorising
+// everything that wasn't set by hand. Cheap enough to run after every poll.
+func (s *Server) syncExpenses() int {
+	rows, err := s.db.Query(`SELECT id, source, timestamp, COALESCE(artifact_title,''), COALESCE(metadata,'')
+		FROM events WHERE lane='revenue' AND event_type IN ('EXPENSE','EXPENSE_RECORDED') AND status='approved'`)
+	if err != nil {
+		log.Printf("[expenses] query: %v", err)
+		return 0
+	}
+	type expRow struct{ id, source, ts, title, metadata string }
+	var pending []expRow
+	for rows.Next() {
+		var e expRow
+		if rows.Scan(&e.id, &e.source, &e.ts, &e.title, &e.metadata) == nil {
+			pending = append(pending, e)
+		}
+	}
+	rows.Close()
+
+	rules := s.loadExpenseRules()
+	base := s.baseCurrency()
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range pending {
+		var meta map[string]interface{}
+		json.Unmarshal([]byte(e.metadata), &meta)
+		if meta == nil {
+			meta = map[string]interface{}{}
+		}
+		original, currency := revenueEventMoney(e.source, e.title, meta, base)
+		amount := original
+		if v, ok := meta["amount_base"].(float64); ok {
+			amount = v
+		}
+		if v, ok := meta["amount_original"].(float64); ok {
+			original = v
+		}
+		merchant, _ := meta["merchant"].(string)
+		if merchant == "" {
+			merchant, _ = meta["vendor"].(string)
+		}
+		providerCategory, _ := meta["category"].(string)
+		description := e.title
+		if d, _ := meta["name"].(string); d != "" {
+			description = d
+		}
+
+		t, _ := time.Parse(time.RFC3339, e.ts)
+		date := t.In(operatorTZ).Format("2006-01-02")
+		if t.IsZero() && len(e.ts) >= 10 {
+			date = e.ts[:10]
+		}
+		category, catSource, ruleID := categorizeExpense(e.source, merchant, description, providerCategory, rules)
+		var rule interface{}
+		if ruleID > 0 {
+			rule = ruleID
+		}
+
+		s.db.Exec(`INSERT INTO expenses (id, source, date, merchant, description, amount, amount_original, currency,
+			provider_category, category, category_source, rule_id, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				date=excluded.date, merchant=excluded.merchant, description=excluded.description,
+				amount=excluded.amount, amount_original=excluded.amount_original, currency=excluded.currency,
+				provider_category=excluded.provider_category,
+				category=CASE WHEN expenses.category_source='manual' THEN expenses.category ELSE excluded.category END,
+				rule_id=CASE WHEN expenses.category_source='manual' THEN expenses.rule_id ELSE excluded.rule_id END,
+				category_source=CASE WHEN expenses.category_source='manual' THEN 'manual' ELSE excluded.category_source END,
+				updated_at=excluded.updated_at`,
+			e.id, e.source, date, merchant, description, math.Abs(amount), math.Abs(original), currency,
+			providerCategory, category, catSource, rule, now, now)
+	}
+
+	s.dedupeBankExpenses()
+	return len(pending)
+}
+
+// dedupeBankExpenses pairs each FreshBooks expense with at most one Plaid
+// outflow of the same amount within 3 days; the bank side becomes duplicate_of.
+func (s *Server) dedupeBankExpenses() {
+	type exp struct {
+		id, source, date string
+		amount           float64
+		t                time.Time
+	}
+	rows, err := s.db.Query(`SELECT id, source, date, amount FROM expenses
+		WHERE source IN ('plaid','freshbooks') ORDER BY date`)
+	if err != nil {
+		return
+	}
+	var books, bank []exp
+	for rows.Next() {
+		var e exp
+		rows.Scan(&e.id, &e.source, &e.date, &e.amount)
+		e.t, _ = time.Parse("2006-01-02", e.date)
+		if e.source == "freshbooks" {
+			books = append(books, e)
+		} else {
+			bank = append(bank, e)
+		}
+	}
+	rows.Close()
+
+	matched := map[string]string{}
+	claimed := map[string]bool{}
+	for _, b := range bank {
+		for _, f := range books {
+			if claimed[f.id] || math.Abs(b.amount-f.amount) > 0.01 {
+				continue
+			}
+			if math.Abs(b.t.Sub(f.t).Hours()) <= 72 {
+				matched[b.id] = f.id
+				claimed[f.id] = true
+				break
+			}
+		}
+	}
+	for _, b := range bank {
+		s.db.Exec(`UPDATE expenses SET duplicate_of=? WHERE id=?`, matched[b.id], b.id)
+	}
+}
+
+// recordBankBalance keeps the latest Plaid balance per account for runway.
+func (s *Server) recordBankBalance(integrationID string, acct map[string]interface{}) {
+	id, _ := acct["account_id"].(string)
+	if id == "" {
+		return
+	}
+	balances, _ := acct["balances"].(map[string]interface{})
+	current, _ := balances["current"].(float64)
+	available, _ := balances["available"].(float64)
+	currency, _ := balances["iso_currency_code"].(string)
+	name, _ := acct["name"].(string)
+	mask, _ := acct["mask"].(string)
+	acctType, _ := acct["type"].(string)
+	subtype, _ := acct["subtype"].(string)
+	s.db.Exec(`INSERT OR REPLACE INTO bank_balances
+		(account_id, integration_id, name, mask, type, subtype, current, available, currency, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, integrationID, name, mask, acctType, subtype, current, available, strings.ToUpper(currency),
+		time.Now().UTC().Format(time.RFC3339))
+}
+
+// ─── P&L ────────────────────────────────────────────────────────────────────
+
+// PnLMonth is one month of profit and loss in the base currency.
+type PnLMonth struct {
+	Month      string             `json:"month"`
+	Revenue    float64            `json:"revenue"`
+	Refunds    float64            `json:"refunds"`
+	NetRevenue float64            `json:"net_revenue"`
+	Expenses   float64            `json:"expenses"`
+	ByCategory map[string]float64 `json:"expenses_by_category"`
+	NetProfit  float64            `json:"net_profit"`
+	Margin     float64            `json:"margin"` // net_profit / net_revenue, 0 without revenue
+}
+
+// profitAndLoss returns the last n months oldest first, the current
+// (partial) month included.
+func (s *Server) profitAndLoss(n int) []PnLMonth {
+	now := operatorNow()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, operatorTZ).AddDate(0, -(n - 1), 0)
+	months := make([]PnLMonth, n)
+	index := map[string]int{}
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	for i := range months {
+		from := start.AddDate(0, i, 0)
+		report := s.reconcileReport(from, from.AddDate(0, 1, 0))
+		gross, _ := report["gross_revenue"].(float64)
+		refunds, _ := report["refunds"].(float64)
+		months[i] = PnLMonth{
+			Month:      from.Format("2006-01"),
+			Revenue:    gross,
+			Refunds:    refunds,
+			NetRevenue: round(gross - refunds),
+			ByCategory: map[string]float64{},
+		}
+		index[months[i].Month] = i
+	}
+
+	rows, err := s.db.Query(`SELECT substr(date,1,7), category, SUM(amount) FROM expenses
+		WHERE date >= ? AND duplicate_of = '' AND category != ?
+		GROUP BY substr(date,1,7), category`, start.Format("2006-01-02"), expenseTransferCategory)
+	if err == nil {
+		for rows.Next() {
+			var month, category string
+			var total float64
+			rows.Scan(&month, &category, &total)
+			i, ok := index[month]
+			if !ok {
+				continue
+			}
+			months[i].ByCategory[category] = round(total)
+			months[i].Expenses += total
+		}
+		rows.Close()
+	}
+
+	for i := range months {
+		m := &months[i]
+		m.Expenses = round(m.Expenses)
+		m.NetProfit = round(m.NetRevenue - m.Expenses)
+		if m.NetRevenue > 0 {
+			m.Margin = math.Round(m.NetProfit/m.NetRevenue*1000) / 1000
+		}
+	}
+	return months
+}
+
+// ─── Runway ─────────────────────────────────────────────────────────────────
+
+// Runway is cash position, burn and months of runway in the base currency.
+type Runway struct {
+	BaseCurrency  string   `json:"base_currency"`
+	Cash          float64  `json:"cash"`          // depository balances
+	CreditOwed    float64  `json:"credit_owed"`   // credit card balances
+	NetCash       float64  `json:"net_cash"`      // cash - credit_owed
+	GrossBurn     float64  `json:"gross_burn"`    // avg monthly expenses
+	NetBurn       float64  `json:"net_burn"`      // avg monthly expenses - net revenue
+	RunwayMonths  *float64 `json:"runway_months"` // nil when not burning
+	DefaultAlive  bool     `json:"default_alive"` // revenue covers expenses
+	Pressure      float64  `json:"pressure"`      // 0-1, fed to FINANCIAL_PRESSURE
+	BurnMonths    []string `json:"burn_months"`   // months averaged
+	Accounts      int      `json:"accounts"`
+	BalancesAsOf  string   `json:"balances_as_of,omitempty"`
+	MissingFXRate []string `json:"missing_fx_rates,omitempty"`
+}
+
+// runwayPressure maps months of runway to 0-1: a year or more is no pressure,
+// 3 months is 0.75.
+func runwayPressure(months float64) float64 {
+	return math.Max(0, math.Min(1, 1-months/12))
+}
+
+func (s *Server) computeRunway() Runway {
+	rw := Runway{BaseCurrency: s.baseCurrency()}
+
+	rows, err := s.db.Query(`SELECT type, current, currency, updated_at FROM bank_balances`)
+	if err == nil {
+		type bal struct {
+			kind, currency, updated string
+			current                 float64
+		}
+		var bals []bal
+		for rows.Next() {
+			var b bal
+			rows.Scan(&b.kind, &b.current, &b.currency, &b.updated)
+			bals = append(bals, b)
+		}
+		rows.Close()
+		now := time.Now()
+		for _, b := range bals {
+			amount, _, _, ok := s.toBase(b.current, b.currency, now)
+			if !ok {
+				rw.MissingFXRate = append(rw.MissingFXRate, b.currency)
+			}
+			switch b.kind {
+			case "depository":
+				rw.Cash += amount
+			case "credit":
+				rw.CreditOwed += amount
+			default:
+				continue // investments and loans aren't spendable runway
+			}
+			rw.Accounts++
+			if b.updated > rw.BalancesAsOf {
+				rw.BalancesAsOf = b.updated
+			}
+		}
+	}
+
+	// Burn: last 3 complete months
+	pnl := s.profitAndLoss(4)
+	var expenses, net float64
+	for _, m := range pnl[:3] {
+		expenses += m.Expenses
+		net += m.Expenses - m.NetRevenue
+		rw.BurnMonths = append(rw.BurnMonths, m.Month)
+	}
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	rw.Cash, rw.CreditOwed = round(rw.Cash), round(rw.CreditOwed)
+	rw.NetCash = round(rw.Cash - rw.CreditOwed)
+	rw.GrossBurn = round(expenses / 3)
+	rw.NetBurn = round(net / 3)
+	rw.DefaultAlive = rw.NetBurn <= 0
+
+	if !rw.DefaultAlive && rw.Accounts > 0 {
+		months := math.Round(math.Max(0, rw.NetCash)/rw.NetBurn*10) / 10
+		rw.RunwayMonths = &months
+		rw.Pressure = math.Round(runwayPressure(months)*100) / 100
+	}
+	return rw
+}
+
+// publishRunway sends the runway picture to the pairing engine, which holds
+// FINANCIAL_PRESSURE at least at the runway-derived level.
+func (s *Server) publishRunway() {
+	if s.pairing == nil {
+		return
+	}
+	rw := s.computeRunway()
+	if rw.Accounts == 0 {
+		return // no balances, no runway signal
+	}
+	features := map[string]float64{
+		"runway_pressure": rw.Pressure,
+		"net_burn":        rw.NetBurn,
+		"net_cash":        rw.NetCash,
+	}
+	md := map[string]interface{}{
+		"provider":      "finance",
+		"default_alive": rw.DefaultAlive,
+	}
+	if rw.RunwayMonths != nil {
+		features["runway_months"] = *rw.RunwayMonths
+		md["runway_months"] = *rw.RunwayMonths
+	}
+	s.pairing.Ingest(Signal{
+		Type:      SignalAccount,
+		Source:    "finance",
+		Timestamp: time.Now(),
+		Features:  features,
+		Metadata:  md,
+	})
+}
+
+// ─── API ────────────────────────────────────────────────────────────────────
+
+// GET /v1/financial/pnl?months=12 — monthly P&L in the base currency.
+func (s *Server) handlePnL(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+	months := 12
+	if v, err := strconv.Atoi(r.URL.Query().Get("months")); err == nil && v > 0 && v <= 60 {
+		months = v
+	}
+
+	s.normalizeRevenueEvents(false)
+	s.syncExpenses()
+	pnl := s.profitAndLoss(months)
+
+	var revenue, expenses float64
+	for _, m := range pnl {
+		revenue += m.NetRevenue
+		expenses += m.Expenses
+	}
+	writeJSON(w, map[string]interface{}{
+		"base_currency": s.baseCurrency(),
+		"months":        pnl,
+		"totals": map[string]float64{
+			"net_revenue": math.Round(revenue*100) / 100,
+			"expenses":    math.Round(expenses*100) / 100,
+			"net_profit":  math.Round((revenue-expenses)*100) / 100,
+		},
+	})
+}
+
+// GET /v1/financial/runway — cash, burn, runway months and pressure.
+func (s *Server) handleRunway(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+	s.normalizeRevenueEvents(false)
+	s.syncExpenses()
+	writeJSON(w, s.computeRunway())
+}
+
+// GET   /v1/financial/expenses?month=2026-09&category=…  — ledger rows
+// PATCH /v1/financial/expenses  {"id":"…","category":"…","rule":true}
+//
+//	recategorise by hand; "rule" also saves a merchant rule and reapplies it
+//
+// POST  /v1/financial/expenses  {"pattern":"…","field":"merchant","category":"…"}  — add a rule
+// DELETE /v1/financial/expenses?rule_id=N — remove a rule
+func (s *Server) handleExpenses(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(200)
+
+	case "GET":
+		s.normalizeRevenueEvents(false)
+		s.syncExpenses()
+		q := r.URL.Query()
+		query := `SELECT id, source, date, merchant, description, amount, amount_original, currency,
+			provider_category, category, category_source, COALESCE(rule_id,0), duplicate_of
+			FROM expenses WHERE 1=1`
+		var args []interface{}
+		if m := q.Get("month"); m != "" {
+			query += ` AND substr(date,1,7)=?`
+			args = append(args, m)
+		}
+		if c := q.Get("category"); c != "" {
+			query += ` AND category=?`
+			args = append(args, c)
+		}
+		query += ` ORDER BY date DESC LIMIT 500`
+		rows, err := s.db.Query(query, args...)
+		if err != nil {
+			http.Error(w, `{"error":"query failed"}`, 500)
+			return
+		}
+		var out []map[string]interface{}
+		for rows.Next() {
+			var id, source, date, merchant, desc, currency, provCat, cat, catSource, dup string
+			var amount, original float64
+			var ruleID int64
+			rows.Scan(&id, &source, &date, &merchant, &desc, &amount, &original, &currency,
+				&provCat, &cat, &catSource, &ruleID, &dup)
+			out = append(out, map[string]interface{}{
+				"id": id, "source": source, "date": date, "merchant": merchant, "description": desc,
+				"amount": amount, "amount_original": original, "currency": currency,
+				"provider_category": provCat, "category": cat, "category_source": catSource,
+				"rule_id": ruleID, "duplicate_of": dup,
+			})
+		}
+		rows.Close()
+		writeJSON(w, map[string]interface{}{
+			"expenses":      out,
+			"rules":         s.loadExpenseRules(),
+			"categories":    expenseCategories,
+			"base_currency": s.baseCurrency(),
+		})
+
+	case "PATCH":
+		var req struct {
+			ID       string `json:"id"`
+			Category string `json:"category"`
+			Rule     bool   `json:"rule"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" || req.Category == "" {
+			http.Error(w, `{"error":"id and category required"}`, 400)
+			return
+		}
+		var merchant string
+		if err := s.db.QueryRow(`SELECT merchant FROM expenses WHERE id=?`, req.ID).Scan(&merchant); err != nil {
+			http.Error(w, `{"error":"expense not found"}`, 404)
+			return
+		}
+		s.db.Exec(`UPDATE expenses SET category=?, category_source='manual', rule_id=NULL, updated_at=? WHERE id=?`,
+			req.Category, time.Now().UTC().Format(time.RFC3339), req.ID)
+		if req.Rule && merchant != "" {
+			s.addExpenseRule(merchant, "merchant", req.Category)
+			s.syncExpenses()
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "id": req.ID, "category": req.Category})
+
+	case "POST":
+		var req expenseRule
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Pattern) == "" || req.Category == "" {
+			http.Error(w, `{"error":"pattern and category required"}`, 400)
+			return
+		}
+		if req.Field == "" {
+			req.Field = "merchant"
+		}
+		if req.Field != "merchant" && req.Field != "description" && req.Field != "category" {
+			http.Error(w, `{"error":"field must be merchant, description or category"}`, 400)
+			return
+		}
+		id := s.addExpenseRule(req.Pattern, req.Field, req.Category)
+		updated := s.syncExpenses()
+		writeJSON(w, map[string]interface{}{"ok": true, "rule_id": id, "expenses_checked": updated})
+
+	case "DELETE":
+		id, err := strconv.ParseInt(r.URL.Query().Get("rule_id"), 10, 64)
+		if err != nil {
+			http.Error(w, `{"error":"rule_id required"}`, 400)
+			return
+		}
+		s.db.Exec(`DELETE FROM expense_rules WHERE id=?`, id)
+		s.syncExpenses()
+		writeJSON(w, map[string]interface{}{"ok": true, "deleted": id})
+
+	default:
+		http.Error(w, `{"error":"GET, PATCH, POST or DELETE"}`, 405)
+	}
+}
+
+func (s *Server) addExpenseRule(pattern, field, category string) int64 {
+	res, err := s.db.Exec(`INSERT INTO expense_rules (pattern, field, category, created_at) VALUES (?, ?, ?, ?)`,
+		strings.TrimSpace(pattern), field, category, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
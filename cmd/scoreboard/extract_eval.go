@@ -0,0 +1,465 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// EXTRACTION EVAL — is a prompt or classifier change better or worse?
+//
+//   scoreboard eval-extraction -corpus corpus.json [-backend fake|gateway|…]
+//       [-model m] [-fixtures f.json] [-sensitivity public] [-match 0.5]
+//       [-out report.json] [-baseline old.json] [-min-f1 0.6]
+//       [-max-hallucination 0.1] [-json] [-v]
+//
+// Runs ExtractMemoriesFromDocument over a labelled corpus, in a scratch
+// database so nothing is queued or stored, and reports:
+//
+//   precision       extracted memories that match an expected one
+//   recall          expected memories that were extracted
+//   hallucination   extractions dropped because their quote is not in the
+//                   document (containsFuzzy), over all extractions returned
+//   doc types       the same per classifyDocument type, plus classification
+//                   accuracy where the corpus says what the type should be
+//
+// Matching is one-to-one on normalised tokens (memory_dedup.go): Jaccard at
+// or above -match, or 85% containment for memories of 3+ tokens.
+//
+// Corpus: a JSON array (or {"cases": [...]}) of
+//   {"path": "Daily Journal/2024-03-01.md",   how extraction sees the file
+//    "file": "docs/0301.md" | "content": "…", file is relative to the corpus
+//    "doc_type": "journal",                   optional, checks classifyDocument
+//    "sensitivity": "standard",               optional, overrides -sensitivity
+//    "expected": ["The author lives in Corona, California", …],
+//    "fake_response": [...] | "…"}            what the fake backend answers
+//
+// With -backend fake the harness is deterministic: fake_response is served
+// for any prompt naming the case's path, so parsing, quote checks and
+// matching can be tested without a model. Against a real backend the same
+// corpus measures the prompts. -baseline prints deltas against an earlier
+// -out report; -min-f1 and -max-hallucination make it fail (exit 1) in CI.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+type evalCase struct {
+	Path         string          `json:"path"`
+	File         string          `json:"file,omitempty"`
+	Content      string          `json:"content,omitempty"`
+	DocType      string          `json:"doc_type,omitempty"`
+	Sensitivity  string          `json:"sensitivity,omitempty"`
+	Expected     []string        `json:"expected"`
+	FakeResponse json.RawMessage `json:"fake_response,omitempty"`
+}
+
+type evalCounts struct {
+	Docs          int     `json:"docs"`
+	Errors        int     `json:"errors"`
+	Expected      int     `json:"expected"`
+	Extracted     int     `json:"extracted"`
+	Recalled      int     `json:"recalled"` // expected memories matched
+	Correct       int     `json:"correct"`  // extracted memories matched
+	Rejected      int     `json:"rejected"` // dropped by the quote check
+	Classified    int     `json:"classified,omitempty"`
+	ClassifiedOK  int     `json:"classified_ok,omitempty"`
+	Precision     float64 `json:"precision"`
+	Recall        float64 `json:"recall"`
+	F1            float64 `json:"f1"`
+	Hallucination float64 `json:"hallucination_rate"`
+}
+
+func (c *evalCounts) add(o evalCounts) {
+	c.Docs += o.Docs
+	c.Errors += o.Errors
+	c.Expected += o.Expected
+	c.Extracted += o.Extracted
+	c.Recalled += o.Recalled
+	c.Correct += o.Correct
+	c.Rejected += o.Rejected
+	c.Classified += o.Classified
+	c.ClassifiedOK += o.ClassifiedOK
+}
+
+// finish computes the rates. Precision with nothing extracted and recall
+// with nothing expected count as perfect.
+func (c *evalCounts) finish() {
+	c.Precision, c.Recall = 1, 1
+	if c.Extracted > 0 {
+		c.Precision = float64(c.Correct) / float64(c.Extracted)
+	}
+	if c.Expected > 0 {
+		c.Recall = float64(c.Recalled) / float64(c.Expected)
+	}
+	c.F1 = 0
+	if c.Precision+c.Recall > 0 {
+		c.F1 = 2 * c.Precision * c.Recall / (c.Precision + c.Recall)
+	}
+	c.Hallucination = 0
+	if c.Extracted+c.Rejected > 0 {
+		c.Hallucination = float64(c.Rejected) / float64(c.Extracted+c.Rejected)
+	}
+}
+
+type evalDocResult struct {
+	Path            string     `json:"path"`
+	DocType         string     `json:"doc_type"`
+	ExpectedDocType string     `json:"expected_doc_type,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	Missed          []string   `json:"missed,omitempty"`
+	Unexpected      []string   `json:"unexpected,omitempty"`
+	Rejected        []string   `json:"rejected,omitempty"`
+	Counts          evalCounts `json:"counts"`
+}
+
+type evalReport struct {
+	Corpus    string                 `json:"corpus"`
+	Backend   string                 `json:"backend"`
+	Model     string                 `json:"model"`
+	Match     float64                `json:"match"`
+	RanAt     string                 `json:"ran_at"`
+	Overall   evalCounts             `json:"overall"`
+	ByDocType map[string]*evalCounts `json:"by_doc_type"`
+	Docs      []evalDocResult        `json:"docs"`
+}
+
+func loadEvalCorpus(path string) ([]evalCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []evalCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		var wrapped struct {
+			Cases []evalCase `json:"cases"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return nil, fmt.Errorf("corpus: %v", err)
+		}
+		cases = wrapped.Cases
+	}
+	dir := filepath.Dir(path)
+	for i := range cases {
+		c := &cases[i]
+		if c.File != "" && c.Content == "" {
+			content, err := os.ReadFile(filepath.Join(dir, c.File))
+			if err != nil {
+				return nil, fmt.Errorf("case %d: %v", i+1, err)
+			}
+			c.Content = string(content)
+		}
+		if c.Path == "" {
+			c.Path = c.File
+		}
+		if c.Path == "" {
+			return nil, fmt.Errorf("case %d: path or file required", i+1)
+		}
+	}
+	return cases, nil
+}
+
+// matchMemories pairs extracted with expected memories one-to-one, best
+// match first. Returns which of each side was matched.
+func matchMemories(expected, extracted []string, threshold float64) (expOK, extOK []bool) {
+	expOK = make([]bool, len(expected))
+	extOK = make([]bool, len(extracted))
+	type pair struct {
+		i, j  int
+		score float64
+	}
+	var pairs []pair
+	for i, e := range expected {
+		et := dedupTokens(e)
+		for j, x := range extracted {
+			xt := dedupTokens(x)
+			jac, cont := dedupSimilarity(et, xt)
+			if jac >= threshold || (cont >= 0.85 && len(et) >= 3 && len(xt) >= 3) {
+				pairs = append(pairs, pair{i, j, jac + cont})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].score > pairs[b].score })
+	for _, p := range pairs {
+		if !expOK[p.i] && !extOK[p.j] {
+			expOK[p.i], extOK[p.j] = true, true
+		}
+	}
+	return expOK, extOK
+}
+
+func runExtractionEval(args []string) int {
+	fs := flag.NewFlagSet("eval-extraction", flag.ContinueOnError)
+	corpus := fs.String("corpus", "", "labelled corpus (JSON)")
+	backend := fs.String("backend", "fake", "LLM backend for the extraction route")
+	model := fs.String("model", llmRouteDefaults["extraction"].Model, "model to request")
+	fixtures := fs.String("fixtures", "", "extra fake backend fixtures (JSON)")
+	sensitivity := fs.String("sensitivity", "public", "redaction policy for cases that don't set one")
+	match := fs.Float64("match", 0.5, "token Jaccard needed to count a memory as matched")
+	out := fs.String("out", "", "write the JSON report here")
+	baseline := fs.String("baseline", "", "earlier JSON report to compare against")
+	minF1 := fs.Float64("min-f1", 0, "exit 1 if overall F1 is below this")
+	maxHalluc := fs.Float64("max-hallucination", 1, "exit 1 if the hallucination rate is above this")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	verbose := fs.Bool("v", false, "show per-document misses and extraction logs")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *corpus == "" {
+		fmt.Fprintln(os.Stderr, "eval-extraction: -corpus is required")
+		fs.Usage()
+		return 2
+	}
+	cases, err := loadEvalCorpus(*corpus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval-extraction: %v\n", err)
+		return 1
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	if *fixtures != "" {
+		llmFakeFixtures = *fixtures
+	}
+	b, ok := llmBackends()[*backend]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "eval-extraction: unknown backend %q\n", *backend)
+		return 1
+	}
+	// Scratch database: extraction reads routes and redaction settings from it
+	dir, err := os.MkdirTemp("", "scoreboard-eval-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval-extraction: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "eval.db")+"?_journal_mode=WAL")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval-extraction: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	s := &Server{db: db}
+	s.initDB()
+	// Unlimited budget, whatever LLM_DAILY_TOKENS says: a long run against a
+	// real backend must not start failing part way through
+	s.db.Exec(`INSERT OR REPLACE INTO llm_budget (id, daily_tokens, daily_cost_usd, updated_at) VALUES (1,0,0,?)`,
+		time.Now().UTC().Format(time.RFC3339))
+	s.db.Exec(`INSERT OR REPLACE INTO llm_routes (task, backend, model, fallback, fallback_model, cache_ttl_secs, updated_at)
+		VALUES ('extraction',?,?,'','',0,?)`, *backend, *model, time.Now().UTC().Format(time.RFC3339))
+
+	// Fixtures are keyed on the prompt's FILE: line, which carries the path as
+	// redacted. Redacting the content first, as extraction does, numbers the
+	// placeholders the same way.
+	if fake, isFake := b.(*fakeLLMBackend); isFake {
+		for _, c := range cases {
+			if len(c.FakeResponse) == 0 {
+				continue
+			}
+			var text string
+			if json.Unmarshal(c.FakeResponse, &text) != nil {
+				text = string(c.FakeResponse)
+			}
+			sens := c.Sensitivity
+			if sens == "" {
+				sens = *sensitivity
+			}
+			rd := s.newRedactor(sens)
+			rd.Redact(c.Content)
+			fake.fixtures["contains:FILE: "+rd.Redact(c.Path)] = text
+		}
+	}
+
+	rejected := map[string][]string{}
+	onQuoteRejected = func(docPath, memory, quote string) {
+		rejected[docPath] = append(rejected[docPath], memory)
+	}
+	chunkErrors := map[string][]string{}
+	onChunkError = func(docPath string, chunk int, err error) {
+		chunkErrors[docPath] = append(chunkErrors[docPath], fmt.Sprintf("chunk %d: %v", chunk, err))
+	}
+	defer func() { onQuoteRejected, onChunkError = nil, nil }()
+
+	report := evalReport{
+		Corpus:    *corpus,
+		Backend:   *backend,
+		Model:     *model,
+		Match:     *match,
+		RanAt:     time.Now().UTC().Format(time.RFC3339),
+		ByDocType: map[string]*evalCounts{},
+	}
+	for i, c := range cases {
+		docType, _ := classifyDocument(c.Path, c.Content)
+		res := evalDocResult{Path: c.Path, DocType: docType, ExpectedDocType: c.DocType}
+		res.Counts.Docs = 1
+		if c.DocType != "" {
+			res.Counts.Classified = 1
+			if c.DocType == docType {
+				res.Counts.ClassifiedOK = 1
+			}
+		}
+		sens := c.Sensitivity
+		if sens == "" {
+			sens = *sensitivity
+		}
+		memories, err := s.ExtractMemoriesFromDocument(c.Path, c.Content, sens)
+		errs := chunkErrors[c.Path]
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		if len(errs) > 0 {
+			res.Error = strings.Join(errs, "; ")
+			res.Counts.Errors = len(errs)
+		}
+		var texts []string
+		for _, m := range memories {
+			texts = append(texts, m.MemoryText)
+		}
+		expOK, extOK := matchMemories(c.Expected, texts, *match)
+		res.Counts.Expected, res.Counts.Extracted = len(c.Expected), len(texts)
+		for k, okMatch := range expOK {
+			if okMatch {
+				res.Counts.Recalled++
+			} else {
+				res.Missed = append(res.Missed, c.Expected[k])
+			}
+		}
+		for k, okMatch := range extOK {
+			if okMatch {
+				res.Counts.Correct++
+			} else {
+				res.Unexpected = append(res.Unexpected, texts[k])
+			}
+		}
+		res.Rejected = rejected[c.Path]
+		res.Counts.Rejected = len(res.Rejected)
+		res.Counts.finish()
+
+		report.Overall.add(res.Counts)
+		if report.ByDocType[docType] == nil {
+			report.ByDocType[docType] = &evalCounts{}
+		}
+		report.ByDocType[docType].add(res.Counts)
+		report.Docs = append(report.Docs, res)
+		if *verbose && !*asJSON {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s: %d/%d recalled, %d extracted\n", i+1, len(cases), c.Path,
+				res.Counts.Recalled, res.Counts.Expected, res.Counts.Extracted)
+		}
+	}
+	report.Overall.finish()
+	for _, c := range report.ByDocType {
+		c.finish()
+	}
+
+	if *out != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if err := os.WriteFile(*out, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "eval-extraction: %v\n", err)
+			return 1
+		}
+	}
+	var base *evalReport
+	if *baseline != "" {
+		data, err := os.ReadFile(*baseline)
+		if err == nil {
+			base = &evalReport{}
+			err = json.Unmarshal(data, base)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "eval-extraction: baseline: %v\n", err)
+			return 1
+		}
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printEvalReport(os.Stdout, &report, base, *verbose)
+	}
+
+	if report.Overall.F1 < *minF1 || report.Overall.Hallucination > *maxHalluc {
+		fmt.Fprintf(os.Stderr, "eval-extraction: below threshold (f1 %.3f, min %.3f; hallucination %.3f, max %.3f)\n",
+			report.Overall.F1, *minF1, report.Overall.Hallucination, *maxHalluc)
+		return 1
+	}
+	return 0
+}
+
+func printEvalReport(w io.Writer, r *evalReport, base *evalReport, verbose bool) {
+	fmt.Fprintf(w, "Extraction eval: %s (%s/%s, match %.2f)\n\n", r.Corpus, r.Backend, r.Model, r.Match)
+
+	delta := func(now, was float64) string {
+		if base == nil {
+			return ""
+		}
+		return fmt.Sprintf(" (%+.3f)", now-was)
+	}
+	row := func(name string, c *evalCounts, was *evalCounts) {
+		if was == nil {
+			was = c
+		}
+		fmt.Fprintf(w, "%-12s %5d %5d %5d %5d  %.3f%s  %.3f%s  %.3f%s  %.3f%s\n", name, c.Docs, c.Expected, c.Extracted, c.Rejected,
+			c.Precision, delta(c.Precision, was.Precision), c.Recall, delta(c.Recall, was.Recall),
+			c.F1, delta(c.F1, was.F1), c.Hallucination, delta(c.Hallucination, was.Hallucination))
+	}
+	fmt.Fprintf(w, "%-12s %5s %5s %5s %5s  %-5s  %-6s  %-5s  %s\n", "doc type", "docs", "exp", "got", "rej", "prec", "recall", "f1", "halluc")
+	types := make([]string, 0, len(r.ByDocType))
+	for t := range r.ByDocType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		var was *evalCounts
+		if base != nil {
+			was = base.ByDocType[t]
+		}
+		row(t, r.ByDocType[t], was)
+	}
+	var was *evalCounts
+	if base != nil {
+		was = &base.Overall
+	}
+	row("overall", &r.Overall, was)
+
+	if r.Overall.Classified > 0 {
+		fmt.Fprintf(w, "\nclassification: %d/%d doc types as labelled\n", r.Overall.ClassifiedOK, r.Overall.Classified)
+	}
+	if r.Overall.Errors > 0 {
+		fmt.Fprintf(w, "errors: %d extraction calls failed (whole documents or chunks)\n", r.Overall.Errors)
+	}
+
+	for _, d := range r.Docs {
+		misclassified := d.ExpectedDocType != "" && d.ExpectedDocType != d.DocType
+		differs := len(d.Missed)+len(d.Unexpected)+len(d.Rejected) > 0
+		if d.Error == "" && !misclassified && !(verbose && differs) {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (%s)\n", d.Path, d.DocType)
+		if misclassified {
+			fmt.Fprintf(w, "  classified as %s, labelled %s\n", d.DocType, d.ExpectedDocType)
+		}
+		if d.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", d.Error)
+		}
+		if !verbose {
+			continue
+		}
+		for _, m := range d.Missed {
+			fmt.Fprintf(w, "  - missed:     %s\n", m)
+		}
+		for _, m := range d.Unexpected {
+			fmt.Fprintf(w, "  + unexpected: %s\n", m)
+		}
+		for _, m := range d.Rejected {
+			fmt.Fprintf(w, "  ! bad quote:  %s\n", m)
+		}
+	}
+}
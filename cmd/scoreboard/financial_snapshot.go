@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// FINANCIAL SNAPSHOT — provider-agnostic revenue picture for operator + Wirebot
+//
+// Built from revenue-lane events of every source (Stripe, WooCommerce,
+// FreshBooks, Plaid, Paddle, Lemon Squeezy, Gumroad, Shopify, custom webhooks)
+// after reconciliation, so a payment seen by both the processor and the bank
+// counts once, and test transactions never count. Amounts are the base-currency
+// amount_base written by normalizeRevenueEvents (fx.go).
+//
+//   windows        30d / 90d / 365d gross, refunds, net, count, per-source split
+//   trend          last 30d vs the 30d before, and 90d vs the prior 90d
+//   top_customers  by gross over 365d, where events carry a customer
+//   mrr / arr      from the subscription ledger when it has subscriptions
+//   receivables    open invoices, aging and collections velocity (receivables.go)
+//
+// revenue_30d / revenue_90d / mrr_estimate keep their old meaning for the
+// memory-bridge plugin, which reads them directly.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// snapshotTrendThreshold is the relative change below which revenue is "flat".
+const snapshotTrendThreshold = 0.05
+
+// snapshotWindow is revenue over the trailing N days.
+type snapshotWindow struct {
+	Days     int                        `json:"days"`
+	Gross    float64                    `json:"gross"`
+	Refunds  float64                    `json:"refunds"`
+	Net      float64                    `json:"net"`
+	Count    int                        `json:"count"`
+	BySource map[string]*snapshotSource `json:"by_source"`
+}
+
+type snapshotSource struct {
+	Gross   float64 `json:"gross"`
+	Refunds float64 `json:"refunds"`
+	Count   int     `json:"count"`
+	Share   float64 `json:"share"` // of window gross
+}
+
+type snapshotCustomer struct {
+	Customer string   `json:"customer"`
+	Gross    float64  `json:"gross"`
+	Count    int      `json:"count"`
+	Sources  []string `json:"sources"`
+	LastPaid string   `json:"last_paid"`
+}
+
+// snapshotCustomerKeys are metadata keys providers use for the payer.
+var snapshotCustomerKeys = []string{"customer", "customer_name", "customer_email", "email", "client"}
+
+func snapshotCustomerName(meta map[string]interface{}) string {
+	for _, k := range snapshotCustomerKeys {
+		if v, ok := meta[k].(string); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// revenueTrend compares two equal-length periods.
+func revenueTrend(current, previous float64) map[string]interface{} {
+	direction := "flat"
+	var change *float64
+	if previous > 0 {
+		c := math.Round((current-previous)/previous*1000) / 1000
+		change = &c
+		switch {
+		case c > snapshotTrendThreshold:
+			direction = "up"
+		case c < -snapshotTrendThreshold:
+			direction = "down"
+		}
+	} else if current > 0 {
+		direction = "up"
+	}
+	return map[string]interface{}{
+		"current":   math.Round(current*100) / 100,
+		"previous":  math.Round(previous*100) / 100,
+		"change":    change, // nil when there's nothing to compare against
+		"direction": direction,
+	}
+}
+
+// financialSnapshot assembles the snapshot. It never needs Stripe.
+func (s *Server) financialSnapshot() map[string]interface{} {
+	s.normalizeRevenueEvents(false)
+	now := time.Now().UTC()
+	horizon := now.AddDate(0, 0, -365)
+
+	rows, err := s.db.Query(`SELECT e.event_type, e.source, e.timestamp, COALESCE(e.artifact_title,''),
+		COALESCE(e.metadata,''), COALESCE(r.status,''), t.event_id IS NOT NULL
+		FROM events e
+		LEFT JOIN reconciled_events r ON r.event_id = e.id
+		LEFT JOIN test_transactions t ON t.event_id = e.id
+		WHERE e.lane='revenue' AND e.status='approved' AND e.timestamp >= ?`,
+		horizon.Format(time.RFC3339))
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	windows := map[string]*snapshotWindow{}
+	for _, d := range []int{30, 90, 365} {
+		windows[strconv.Itoa(d)+"d"] = &snapshotWindow{Days: d, BySource: map[string]*snapshotSource{}}
+	}
+	var prev30, prev90 float64
+	customers := map[string]*snapshotCustomer{}
+	customerSources := map[string]map[string]bool{}
+	var failed, cancellations int
+	var duplicates, tests int
+
+	for rows.Next() {
+		var etype, source, ts, title, metadata, reconStatus string
+		var isTest bool
+		rows.Scan(&etype, &source, &ts, &title, &metadata, &reconStatus, &isTest)
+		t, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			continue
+		}
+		age := now.Sub(t).Hours() / 24
+		src := canonicalSource(source)
+
+		switch etype {
+		case "PAYMENT_FAILED", "INVOICE_FAILED":
+			if age <= 30 {
+				failed++
+			}
+			continue
+		case "SUBSCRIPTION_CANCELED":
+			if age <= 30 {
+				cancellations++
+			}
+			continue
+		}
+		refund := etype == "REFUND_ISSUED"
+		if !refund && !reconcileGrossTypes[etype] {
+			continue // payouts, expenses, deals, meetings
+		}
+		if isTest {
+			tests++
+			continue
+		}
+		if reconStatus == "duplicate" {
+			duplicates++
+			continue
+		}
+
+		amount := reconEventAmount(metadata, title)
+		for _, w := range windows {
+			if age > float64(w.Days) {
+				continue
+			}
+			bs := w.BySource[src]
+			if bs == nil {
+				bs = &snapshotSource{}
+				w.BySource[src] = bs
+			}
+			if refund {
+				w.Refunds += amount
+				bs.Refunds += amount
+			} else {
+				w.Gross += amount
+				w.Count++
+				bs.Gross += amount
+				bs.Count++
+			}
+		}
+		if refund {
+			continue
+		}
+		if age > 30 && age <= 60 {
+			prev30 += amount
+		}
+		if age > 90 && age <= 180 {
+			prev90 += amount
+		}
+
+		var meta map[string]interface{}
+		json.Unmarshal([]byte(metadata), &meta)
+		if name := snapshotCustomerName(meta); name != "" {
+			c := customers[name]
+			if c == nil {
+				c = &snapshotCustomer{Customer: name}
+				customers[name] = c
+				customerSources[name] = map[string]bool{}
+			}
+			c.Gross += amount
+			c.Count++
+			if !customerSources[name][src] {
+				customerSources[name][src] = true
+				c.Sources = append(c.Sources, src)
+			}
+			if ts > c.LastPaid {
+				c.LastPaid = ts
+			}
+		}
+	}
+	rows.Close()
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	var sources []string
+	for _, w := range windows {
+		w.Gross, w.Refunds = round(w.Gross), round(w.Refunds)
+		w.Net = round(w.Gross - w.Refunds)
+		for name, bs := range w.BySource {
+			bs.Gross, bs.Refunds = round(bs.Gross), round(bs.Refunds)
+			if w.Gross > 0 {
+				bs.Share = math.Round(bs.Gross/w.Gross*1000) / 1000
+			}
+			if w.Days == 365 {
+				sources = append(sources, name)
+			}
+		}
+	}
+	sort.Strings(sources)
+
+	var top []snapshotCustomer
+	for _, c := range customers {
+		c.Gross = round(c.Gross)
+		top = append(top, *c)
+	}
+	sort.Slice(top, func(i, j int) bool { return top[i].Gross > top[j].Gross })
+	if len(top) > 10 {
+		top = top[:10]
+	}
+
+	w30, w90 := windows["30d"], windows["90d"]
+	trend30 := revenueTrend(w30.Gross, prev30)
+	snapshot := map[string]interface{}{
+		"base_currency":      s.baseCurrency(),
+		"generated_at":       now.Format(time.RFC3339),
+		"sources":            sources,
+		"windows":            windows,
+		"revenue_30d":        w30.Gross,
+		"revenue_90d":        w90.Gross,
+		"revenue_365d":       windows["365d"].Gross,
+		"charges_30d":        w30.Count,
+		"charges_90d":        w90.Count,
+		"mrr_estimate":       w30.Net, // trailing 30d until the subscription ledger knows better
+		"top_customers":      top,
+		"failed_charges_30d": failed,
+		"cancellations_30d":  cancellations,
+		"duplicates_removed": duplicates,
+		"test_transactions":  tests,
+		"trend": map[string]interface{}{
+			"30d":       trend30,
+			"90d":       revenueTrend(w90.Gross, prev90),
+			"direction": trend30["direction"],
+		},
+	}
+	if mrr, counts := s.currentMRR(); len(counts) > 0 {
+		// Subscription ledger is authoritative once it has seen any subscription
+		snapshot["mrr_estimate"] = mrr
+		snapshot["mrr"] = mrr
+		snapshot["arr"] = round(mrr * 12)
+		snapshot["subscribers"] = counts
+	}
+	if rw := s.computeRunway(); rw.Accounts > 0 {
+		snapshot["runway"] = map[string]interface{}{
+			"net_cash":      rw.NetCash,
+			"net_burn":      rw.NetBurn,
+			"runway_months": rw.RunwayMonths,
+			"default_alive": rw.DefaultAlive,
+		}
+	}
+	ar := s.receivablesSummary()
+	openInvoices, _ := toFloat64(ar["open_invoices"])
+	var paid90d float64
+	if collections, ok := ar["collections"].(map[string]interface{}); ok {
+		paid90d, _ = toFloat64(collections["paid_invoices_90d"])
+	}
+	if openInvoices > 0 || paid90d > 0 {
+		delete(ar, "invoices") // full list lives at /v1/financial/receivables
+		snapshot["receivables"] = ar
+	}
+	snapshot["recent_events"] = s.snapshotRecentEvents(10)
+	return snapshot
+}
+
+// snapshotRecentEvents lists the latest money events from any source.
+func (s *Server) snapshotRecentEvents(limit int) []map[string]interface{} {
+	var out []map[string]interface{}
+	rows, err := s.db.Query(`SELECT e.event_type, e.source, COALESCE(e.artifact_title,''), e.timestamp,
+		e.score_delta, COALESCE(e.metadata,''), COALESCE(r.status,'')
+		FROM events e LEFT JOIN reconciled_events r ON r.event_id = e.id
+		WHERE e.lane='revenue' AND e.status='approved'
+		AND e.event_type IN ('PAYMENT_RECEIVED','INVOICE_PAID','revenue','REFUND_ISSUED','PAYMENT_FAILED',
+			'INVOICE_FAILED','SUBSCRIPTION_CREATED','SUBSCRIPTION_CANCELED','PAYOUT_RECEIVED','payout')
+		ORDER BY e.timestamp DESC LIMIT ?`, limit)
+	if err != nil {
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var et, src, title, ts, meta, recon string
+		var sd int
+		rows.Scan(&et, &src, &title, &ts, &sd, &meta, &recon)
+		ev := map[string]interface{}{
+			"type": et, "source": canonicalSource(src), "title": title, "timestamp": ts,
+			"score_delta": sd, "amount": reconEventAmount(meta, title),
+		}
+		if recon == "duplicate" {
+			ev["duplicate"] = true
+		}
+		out = append(out, ev)
+	}
+	return out
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MULTI-CURRENCY — local FX table + base-currency normalisation
+//
+// Every revenue event ends up with explicit money fields in metadata:
+//   amount_original  original amount in major units (sources disagree on
+//                    what `amount` means — Stripe webhooks store cents)
+//   currency         ISO 4217 of the original amount
+//   amount_base      amount converted to the tenant's base currency
+//   base_currency    the base currency at conversion time
+//   fx_rate          rate used (1 currency = fx_rate base_currency)
+//   fx_date          date of the rate used ("" for same-currency or missing)
+//   fx_missing       set when no rate existed; amount_base is then unconverted
+//
+// Rates live in fx_rates and are imported via POST /v1/financial/fx (JSON or
+// CSV). The base currency is per tenant (financial_settings.base_currency,
+// default BASE_CURRENCY env, default USD). Lookups use the latest rate on or
+// before the event date, falling back to the earliest later rate, and
+// triangulate through any currency with rates on both sides.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var defaultBaseCurrency = strings.ToUpper(envOr("BASE_CURRENCY", "USD"))
+
+func (s *Server) initFX() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS fx_rates (
+		base TEXT NOT NULL,
+		quote TEXT NOT NULL,
+		date TEXT NOT NULL,
+		rate REAL NOT NULL,
+		source TEXT DEFAULT 'import',
+		imported_at TEXT NOT NULL,
+		PRIMARY KEY (base, quote, date)
+	)`)
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS financial_settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`)
+}
+
+// baseCurrency returns the tenant's reporting currency.
+func (s *Server) baseCurrency() string {
+	var cur string
+	s.db.QueryRow(`SELECT value FROM financial_settings WHERE key='base_currency'`).Scan(&cur)
+	if cur == "" {
+		return defaultBaseCurrency
+	}
+	return cur
+}
+
+func (s *Server) setBaseCurrency(cur string) {
+	s.db.Exec(`INSERT OR REPLACE INTO financial_settings (key, value, updated_at) VALUES ('base_currency', ?, ?)`,
+		strings.ToUpper(cur), time.Now().UTC().Format(time.RFC3339))
+}
+
+func validCurrency(cur string) bool {
+	if len(cur) != 3 {
+		return false
+	}
+	for _, c := range cur {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// directRate looks up 1 base = ? quote nearest to date (on/before preferred).
+func (s *Server) directRate(base, quote, date string) (float64, string, bool) {
+	var rate float64
+	var d string
+	err := s.db.QueryRow(`SELECT rate, date FROM fx_rates WHERE base=? AND quote=? AND date<=?
+		ORDER BY date DESC LIMIT 1`, base, quote, date).Scan(&rate, &d)
+	if err != nil {
+		err = s.db.QueryRow(`SELECT rate, date FROM fx_rates WHERE base=? AND quote=?
+			ORDER BY date ASC LIMIT 1`, base, quote).Scan(&rate, &d)
+	}
+	if err != nil || rate <= 0 {
+		return 0, "", false
+	}
+	return rate, d, true
+}
+
+// pairRate resolves a direct or inverse rate for from → to.
+func (s *Server) pairRate(from, to, date string) (float64, string, bool) {
+	if r, d, ok := s.directRate(from, to, date); ok {
+		return r, d, true
+	}
+	if r, d, ok := s.directRate(to, from, date); ok {
+		return 1 / r, d, true
+	}
+	return 0, "", false
+}
+
+// fxRate returns how many `to` one unit of `from` buys on date, plus the rate
+// date actually used. Triangulates through any currency with rates to both.
+func (s *Server) fxRate(from, to string, on time.Time) (float64, string, bool) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, "", true
+	}
+	date := on.In(operatorTZ).Format("2006-01-02")
+	if r, d, ok := s.pairRate(from, to, date); ok {
+		return r, d, true
+	}
+	rows, err := s.db.Query(`SELECT DISTINCT base FROM fx_rates UNION SELECT DISTINCT quote FROM fx_rates`)
+	if err != nil {
+		return 0, "", false
+	}
+	var pivots []string
+	for rows.Next() {
+		var p string
+		rows.Scan(&p)
+		if p != from && p != to {
+			pivots = append(pivots, p)
+		}
+	}
+	rows.Close()
+	for _, p := range pivots {
+		r1, d1, ok1 := s.pairRate(from, p, date)
+		if !ok1 {
+			continue
+		}
+		if r2, d2, ok2 := s.pairRate(p, to, date); ok2 {
+			d := d1
+			if d2 < d1 {
+				d = d2 // report the staler of the two legs
+			}
+			return r1 * r2, d, true
+		}
+	}
+	return 0, "", false
+}
+
+// toBase converts an amount into the tenant's base currency. ok is false when
+// no rate exists; the amount is then returned unconverted.
+func (s *Server) toBase(amount float64, currency string, on time.Time) (float64, float64, string, bool) {
+	base := s.baseCurrency()
+	if currency == "" {
+		currency = base
+	}
+	rate, d, ok := s.fxRate(currency, base, on)
+	if !ok {
+		return amount, 0, "", false
+	}
+	return math.Round(amount*rate*100) / 100, rate, d, true
+}
+
+// revenueEventMoney derives the original amount (major units) and currency of
+// a revenue event from the conventions each source has historically used.
+func revenueEventMoney(source, title string, meta map[string]interface{}, base string) (float64, string) {
+	num := func(k string) (float64, bool) {
+		switch v := meta[k].(type) {
+		case float64:
+			return v, true
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		}
+		return 0, false
+	}
+	currency, _ := meta["currency"].(string)
+	if currency == "" {
+		currency, _ = meta["iso_currency_code"].(string)
+	}
+	currency = strings.ToUpper(currency)
+
+	// Normalised providers (revenue_providers.go) and already-normalised events
+	if minor, ok := num("amount_minor"); ok {
+		return minorToMajor(int64(minor), currency), currency
+	}
+	switch {
+	case source == "stripe-webhook":
+		// Stripe webhooks store amount in the smallest currency unit
+		if amt, ok := num("amount"); ok {
+			return minorToMajor(int64(amt), currency), currency
+		}
+		if amt, ok := num("plan_amount"); ok {
+			return minorToMajor(int64(amt), currency), currency
+		}
+	case source == "woocommerce":
+		if amt, ok := num("total"); ok {
+			return amt, currency
+		}
+	}
+	if amt, ok := num("amount"); ok {
+		return amt, currency
+	}
+	// Legacy events: scrape "$123.45" (USD) from the title
+	if amt := extractAmountFromTitle(title); amt > 0 {
+		if currency == "" {
+			currency = "USD"
+		}
+		return amt, currency
+	}
+	if currency == "" {
+		currency = base
+	}
+	return 0, currency
+}
+
+// normalizeRevenueEvents writes explicit currency + base-currency amounts into
+// revenue event metadata. force re-converts every event (after an FX import or
+// base currency change); otherwise only events without amount_base are touched.
+func (s *Server) normalizeRevenueEvents(force bool) int {
+	query := `SELECT id, source, COALESCE(artifact_title,''), COALESCE(metadata,''), timestamp
+		FROM events WHERE lane='revenue'`
+	if !force {
+		// CASE guards json_extract: malformed metadata would abort the whole query
+		query += ` AND (CASE WHEN json_valid(metadata) THEN json_extract(metadata,'$.amount_base') END) IS NULL`
+	}
+	rows, err := s.db.Query(query)
+	if err != nil {
+		log.Printf("[fx] normalize query: %v", err)
+		return 0
+	}
+	// Read everything first: rate lookups below must not run while rows is open
+	type revRow struct{ id, source, title, metadata, ts string }
+	var pendingRows []revRow
+	for rows.Next() {
+		var rr revRow
+		if rows.Scan(&rr.id, &rr.source, &rr.title, &rr.metadata, &rr.ts) == nil {
+			pendingRows = append(pendingRows, rr)
+		}
+	}
+	rows.Close()
+
+	type pending struct{ id, meta string }
+	var updates []pending
+	base := s.baseCurrency()
+	for _, rr := range pendingRows {
+		id, source, title, ts := rr.id, rr.source, rr.title, rr.ts
+		meta := map[string]interface{}{}
+		json.Unmarshal([]byte(rr.metadata), &meta)
+		if meta == nil {
+			meta = map[string]interface{}{}
+		}
+
+		// Keep the original amount once recorded; base fields are derived
+		var amount float64
+		var currency string
+		if orig, ok := meta["amount_original"].(float64); ok {
+			amount = orig
+			currency, _ = meta["currency"].(string)
+		} else {
+			amount, currency = revenueEventMoney(source, title, meta, base)
+			meta["amount_original"] = amount
+			meta["currency"] = currency
+		}
+
+		when, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			when = time.Now()
+		}
+		converted, rate, rateDate, ok := s.toBase(amount, currency, when)
+		meta["amount_base"] = converted
+		meta["base_currency"] = base
+		meta["fx_rate"] = rate
+		meta["fx_date"] = rateDate
+		if !ok {
+			meta["fx_missing"] = true
+		} else {
+			delete(meta, "fx_missing")
+		}
+		b, _ := json.Marshal(meta)
+		updates = append(updates, pending{id, string(b)})
+	}
+
+	s.mu.Lock()
+	for _, u := range updates {
+		s.db.Exec(`UPDATE events SET metadata=? WHERE id=?`, u.meta, u.id)
+	}
+	s.mu.Unlock()
+	if len(updates) > 0 {
+		log.Printf("[fx] normalised %d revenue events to %s", len(updates), base)
+	}
+	return len(updates)
+}
+
+// fxRateRow is one imported rate.
+type fxRateRow struct {
+	Base  string  `json:"base"`
+	Quote string  `json:"quote"`
+	Date  string  `json:"date"`
+	Rate  float64 `json:"rate"`
+}
+
+// parseFXImport accepts either
+//
+//	{"base":"USD","date":"2026-10-01","rates":{"EUR":0.92,"GBP":0.79}}
+//	{"rates":[{"base":"EUR","quote":"USD","date":"2026-10-01","rate":1.08}, …]}
+//
+// or CSV with a header row: date,base,quote,rate
+func parseFXImport(contentType string, body []byte) ([]fxRateRow, string, error) {
+	var rows []fxRateRow
+	if strings.Contains(contentType, "csv") {
+		records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid csv: %v", err)
+		}
+		for i, rec := range records {
+			if len(rec) < 4 || (i == 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "date")) {
+				continue
+			}
+			rate, err := strconv.ParseFloat(strings.TrimSpace(rec[3]), 64)
+			if err != nil {
+				return nil, "", fmt.Errorf("line %d: bad rate", i+1)
+			}
+			rows = append(rows, fxRateRow{Date: strings.TrimSpace(rec[0]), Base: rec[1], Quote: rec[2], Rate: rate})
+		}
+		return rows, "", nil
+	}
+
+	var req struct {
+		Base         string          `json:"base"`
+		Date         string          `json:"date"`
+		Rates        json.RawMessage `json:"rates"`
+		BaseCurrency string          `json:"base_currency"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, "", fmt.Errorf("invalid json")
+	}
+	if len(req.Rates) > 0 {
+		var table map[string]float64
+		if json.Unmarshal(req.Rates, &table) == nil {
+			for quote, rate := range table {
+				rows = append(rows, fxRateRow{Base: req.Base, Quote: quote, Date: req.Date, Rate: rate})
+			}
+		} else if err := json.Unmarshal(req.Rates, &rows); err != nil {
+			return nil, "", fmt.Errorf("rates must be an object or an array")
+		}
+	}
+	return rows, req.BaseCurrency, nil
+}
+
+// GET  /v1/financial/fx        — base currency + latest rate per pair
+// POST /v1/financial/fx        — import rates (JSON/CSV) and/or set base_currency
+func (s *Server) handleFX(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		var rates []fxRateRow
+		rows, err := s.db.Query(`SELECT base, quote, MAX(date), rate FROM fx_rates GROUP BY base, quote ORDER BY base, quote`)
+		if err == nil {
+			for rows.Next() {
+				var fr fxRateRow
+				rows.Scan(&fr.Base, &fr.Quote, &fr.Date, &fr.Rate)
+				rates = append(rates, fr)
+			}
+			rows.Close()
+		}
+		var total, missing int
+		s.db.QueryRow(`SELECT COUNT(*) FROM fx_rates`).Scan(&total)
+		s.db.QueryRow(`SELECT COUNT(*) FROM events WHERE lane='revenue'
+			AND (CASE WHEN json_valid(metadata) THEN json_extract(metadata,'$.fx_missing') END)=1`).Scan(&missing)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"base_currency":       s.baseCurrency(),
+			"latest":              rates,
+			"rate_count":          total,
+			"events_missing_rate": missing,
+		})
+
+	case "POST":
+		body, err := io.ReadAll(io.LimitReader(r.Body, 4<<20))
+		if err != nil {
+			http.Error(w, `{"error":"read failed"}`, 400)
+			return
+		}
+		rows, newBase, err := parseFXImport(r.Header.Get("Content-Type"), body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
+			return
+		}
+		if newBase != "" {
+			newBase = strings.ToUpper(newBase)
+			if !validCurrency(newBase) {
+				http.Error(w, `{"error":"base_currency must be an ISO 4217 code"}`, 400)
+				return
+			}
+		}
+		if len(rows) == 0 && newBase == "" {
+			http.Error(w, `{"error":"no rates or base_currency supplied"}`, 400)
+			return
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		imported := 0
+		var rejected []string
+		s.mu.Lock()
+		for _, fr := range rows {
+			fr.Base, fr.Quote = strings.ToUpper(strings.TrimSpace(fr.Base)), strings.ToUpper(strings.TrimSpace(fr.Quote))
+			if _, err := time.Parse("2006-01-02", fr.Date); err != nil || !validCurrency(fr.Base) ||
+				!validCurrency(fr.Quote) || fr.Rate <= 0 || fr.Base == fr.Quote {
+				rejected = append(rejected, fmt.Sprintf("%s/%s@%s", fr.Base, fr.Quote, fr.Date))
+				continue
+			}
+			s.db.Exec(`INSERT OR REPLACE INTO fx_rates (base, quote, date, rate, source, imported_at)
+				VALUES (?, ?, ?, ?, 'import', ?)`, fr.Base, fr.Quote, fr.Date, fr.Rate, now)
+			imported++
+		}
+		s.mu.Unlock()
+		if newBase != "" {
+			s.setBaseCurrency(newBase)
+		}
+
+		// Rates or base changed: re-convert every revenue event
+		normalized := s.normalizeRevenueEvents(true)
+		log.Printf("[fx] imported %d rates (%d rejected), base=%s", imported, len(rejected), s.baseCurrency())
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":            true,
+			"imported":      imported,
+			"rejected":      rejected,
+			"base_currency": s.baseCurrency(),
+			"renormalized":  normalized,
+		})
+
+	default:
+		http.Error(w, `{"error":"GET or POST"}`, 405)
+	}
+}
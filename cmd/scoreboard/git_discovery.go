@@ -0,0 +1,457 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// GIT DISCOVERY — built-in replacement for bin/wb-discover
+//
+// Walks GIT_DISCOVERY_ROOTS (comma-separated) for repositories, reads new
+// commits and tags with the pure-Go reader (git_reader.go) and emits:
+//   CODE_PUSHED      one per non-merge commit (diminishing returns, 8 pts/repo/day)
+//   PRODUCT_RELEASE  one per new tag
+//
+// Unknown repos are registered as pending rows in `projects`. Events follow
+// project approval exactly like the script did:
+//   approved + auto_approve → events land approved
+//   rejected                → commits are skipped (watermark still advances)
+//   otherwise               → events land pending
+//
+// Watermarks (HEAD sha + known tags per repo) live in git_watermarks so a
+// restart never re-emits history.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	gitDiscoveryRoots    = envOr("GIT_DISCOVERY_ROOTS", "")          // e.g. /home/wirebot,/data/wirebot
+	gitDiscoveryInterval = envOr("GIT_DISCOVERY_INTERVAL", "5m")     // Go duration between scans
+	gitDiscoveryBackfill = envOr("GIT_DISCOVERY_BACKFILL_DAYS", "1") // first-sight lookback
+)
+
+// gitDiscoverySkip matches directories never worth descending into.
+var gitDiscoverySkip = regexp.MustCompile(`(^|/)(node_modules|vendor|\.cache|\.nvm|\.npm|\.cargo|target|dist|build)(/|$)`)
+
+// Commit message patterns from wb-discover's classify_commit.
+var (
+	gitMergeRe    = regexp.MustCompile(`^merge (branch|pull|remote|tag)|^merge commit`)
+	gitNoiseRe    = regexp.MustCompile(`^wip[: ]|^fixup!|^squash!|chore: trigger|chore: bump version`)
+	gitMicroRe    = regexp.MustCompile(`^docs:|^typo|readme|^style:|^chore:`)
+	gitStandardRe = regexp.MustCompile(`^feat:|^fix:|^test:|^perf:|^refactor:`)
+)
+
+// initGitDiscovery creates the watermark table.
+func (s *Server) initGitDiscovery() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS git_watermarks (
+		repo_path TEXT PRIMARY KEY,
+		repo_name TEXT NOT NULL,
+		head_sha TEXT DEFAULT '',
+		tags TEXT DEFAULT '[]',
+		last_scan_at TEXT DEFAULT '',
+		last_error TEXT DEFAULT ''
+	)`)
+}
+
+// gitDiscoveryWorker scans configured roots on GIT_DISCOVERY_INTERVAL.
+func (s *Server) gitDiscoveryWorker() {
+	if gitDiscoveryRoots == "" {
+		log.Println("[git-discovery] No GIT_DISCOVERY_ROOTS configured, discovery disabled")
+		return
+	}
+	interval, err := time.ParseDuration(gitDiscoveryInterval)
+	if err != nil || interval < time.Minute {
+		interval = 5 * time.Minute
+	}
+
+	time.Sleep(20 * time.Second) // let integrations poll first
+	s.runGitDiscovery()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.runGitDiscovery()
+	}
+}
+
+// runGitDiscovery performs one full scan of all roots.
+func (s *Server) runGitDiscovery() {
+	var roots []string
+	for _, r := range strings.Split(gitDiscoveryRoots, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roots = append(roots, r)
+		}
+	}
+	repos := findGitRepos(roots, 5)
+
+	var total, approved int
+	for _, path := range repos {
+		n, a, err := s.discoverRepo(path)
+		if err != nil {
+			log.Printf("[git-discovery] %s: %v", path, err)
+			s.db.Exec(`UPDATE git_watermarks SET last_error=? WHERE repo_path=?`, err.Error(), path)
+			continue
+		}
+		total += n
+		approved += a
+	}
+
+	if total > 0 {
+		today := operatorToday()
+		if approved > 0 {
+			s.updateDailyScore(today)
+			s.updateStreak(today, "")
+			s.recalcSeason()
+		}
+		log.Printf("[git-discovery] %d repos scanned: %d events (%d auto-approved)", len(repos), total, approved)
+	}
+}
+
+// findGitRepos walks roots up to maxDepth and returns working-tree paths.
+func findGitRepos(roots []string, maxDepth int) []string {
+	var repos []string
+	seen := map[string]bool{}
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		baseDepth := strings.Count(root, string(filepath.Separator))
+		filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if gitDiscoverySkip.MatchString(filepath.ToSlash(p)) {
+				return filepath.SkipDir
+			}
+			if strings.Count(p, string(filepath.Separator))-baseDepth > maxDepth {
+				return filepath.SkipDir
+			}
+			if d.Name() == ".git" {
+				repo := filepath.Dir(p)
+				if !seen[repo] {
+					seen[repo] = true
+					repos = append(repos, repo)
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	return repos
+}
+
+// discoverRepo emits events for new commits/tags in one repository.
+// Returns (events emitted, events auto-approved).
+func (s *Server) discoverRepo(path string) (int, int, error) {
+	g, err := openGitRepo(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer g.Close()
+
+	headSHA, branch, err := g.head()
+	if err != nil || headSHA == "" {
+		return 0, 0, nil // empty repo or detached without commits
+	}
+
+	name := filepath.Base(path)
+	github := githubSlugFromRemote(g.originURL())
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// Register unknown repos as pending projects (never overwrite operator choices)
+	s.db.Exec(`INSERT OR IGNORE INTO projects (name, path, github, status, created_at) VALUES (?, ?, ?, 'pending', ?)`,
+		name, path, github, now)
+	s.db.Exec(`UPDATE projects SET path=? WHERE name=? AND path=''`, path, name)
+
+	var projStatus string
+	var autoApprove bool
+	var business string
+	s.db.QueryRow(`SELECT status, auto_approve, business FROM projects WHERE name=?`, name).Scan(&projStatus, &autoApprove, &business)
+
+	var lastHead, tagsJSON, lastScan string
+	known := s.db.QueryRow(`SELECT head_sha, tags, last_scan_at FROM git_watermarks WHERE repo_path=?`, path).Scan(&lastHead, &tagsJSON, &lastScan) == nil
+	knownTags := map[string]bool{}
+	var tagList []string
+	json.Unmarshal([]byte(tagsJSON), &tagList)
+	for _, t := range tagList {
+		knownTags[t] = true
+	}
+
+	// Commits older than the backfill window are never emitted. For a known
+	// repo the window reaches back to the last scan if that was earlier, so a
+	// rebase or a merged side branch can't replay history the watermark missed.
+	days, _ := strconv.Atoi(gitDiscoveryBackfill)
+	since := time.Now().AddDate(0, 0, -days)
+	if t, err := time.Parse(time.RFC3339, lastScan); known && err == nil && t.Before(since) {
+		since = t
+	}
+
+	// Collect new commits: walk parents from HEAD until the watermark (or cutoff)
+	var commits []*gitCommit
+	if lastHead != headSHA {
+		commits = collectNewCommits(g, headSHA, lastHead, since, 200)
+	}
+
+	status := "pending"
+	if projStatus == "approved" && autoApprove {
+		status = "approved"
+	}
+	skip := projStatus == "rejected"
+
+	emitted, approvedCount := 0, 0
+	if !skip {
+		dayPts := map[string]int{}
+		dayIdx := map[string]int{}
+		// Oldest first so diminishing returns apply in commit order
+		for i := len(commits) - 1; i >= 0; i-- {
+			c := commits[i]
+			if len(c.Parents) > 1 {
+				continue // merges carry no new work
+			}
+			parentTree := ""
+			if len(c.Parents) == 1 {
+				if pc, err := g.commit(c.Parents[0]); err == nil {
+					parentTree = pc.Tree
+				}
+			}
+			files := g.changedFiles(parentTree, c.Tree, 1000)
+			class, base, reason := classifyGitCommit(c.Subject(), files, false)
+			if class == "SKIP" {
+				continue
+			}
+
+			day := c.When.In(operatorTZ).Format("2006-01-02")
+			if _, ok := dayIdx[day]; !ok {
+				dayIdx[day], dayPts[day] = s.discoveryDayTotals(name, day)
+			}
+			dayIdx[day]++
+			effective := applyDiminishingReturns(base, dayIdx[day])
+			if class == "NOISE" {
+				effective = 0
+			}
+			if dayPts[day]+effective > 8 {
+				effective = 8 - dayPts[day]
+				if effective < 0 {
+					effective = 0
+				}
+			}
+			dayPts[day] += effective
+
+			artifactURL := ""
+			if github != "" {
+				artifactURL = "https://github.com/" + github + "/commit/" + c.SHA
+			}
+			meta := map[string]interface{}{
+				"sha": c.SHA, "repo": name, "repo_path": path, "branch": branch,
+				"author": c.Author, "files_changed": files, "class": class, "reason": reason,
+				"commit_index": dayIdx[day], "effective_score": effective, "base_score": base,
+				"diminishing": dayIdx[day] > 3,
+			}
+			if s.insertDiscoveryEvent("CODE_PUSHED", fmt.Sprintf("[%s] %s", name, c.Subject()),
+				artifactURL, business, "git-"+c.SHA, c.When, effective, status, meta) {
+				emitted++
+				if status == "approved" {
+					approvedCount++
+				}
+			}
+		}
+	}
+
+	// Tags → PRODUCT_RELEASE. On first sight only tags inside the backfill window count.
+	tags := g.refs("refs/tags/")
+	var newTagList []string
+	for ref, sha := range tags {
+		tag := strings.TrimPrefix(ref, "refs/tags/")
+		newTagList = append(newTagList, tag)
+		if knownTags[tag] || skip {
+			continue
+		}
+		when := time.Time{}
+		message := ""
+		if obj, err := g.readObject(sha); err == nil && obj.Type == "tag" {
+			t := parseGitTag(obj.Data)
+			when, message = t.When, t.Message
+		}
+		c, err := g.commit(sha)
+		if err != nil {
+			continue
+		}
+		if when.IsZero() {
+			when = c.Committed
+		}
+		if !known && when.Before(since) {
+			continue
+		}
+		artifactURL := ""
+		if github != "" {
+			artifactURL = "https://github.com/" + github + "/releases/tag/" + tag
+		}
+		class, base, reason := classifyGitCommit(message, 0, true)
+		meta := map[string]interface{}{
+			"sha": c.SHA, "repo": name, "repo_path": path, "tag": tag,
+			"class": class, "reason": reason, "effective_score": base, "base_score": base,
+		}
+		if s.insertDiscoveryEvent("PRODUCT_RELEASE", fmt.Sprintf("[%s] Release %s", name, tag),
+			artifactURL, business, "git-tag-"+name+"-"+tag, when, base, status, meta) {
+			emitted++
+			if status == "approved" {
+				approvedCount++
+			}
+		}
+	}
+
+	tagsOut, _ := json.Marshal(newTagList)
+	s.db.Exec(`INSERT INTO git_watermarks (repo_path, repo_name, head_sha, tags, last_scan_at, last_error)
+		VALUES (?, ?, ?, ?, ?, '')
+		ON CONFLICT(repo_path) DO UPDATE SET repo_name=excluded.repo_name, head_sha=excluded.head_sha,
+			tags=excluded.tags, last_scan_at=excluded.last_scan_at, last_error=''`,
+		path, name, headSHA, string(tagsOut), now)
+
+	return emitted, approvedCount, nil
+}
+
+// discoveryDayTotals returns how many commits were already emitted for a repo
+// on an operator-local day and the points they used, so the per-repo daily
+// cap and diminishing returns hold across scans.
+func (s *Server) discoveryDayTotals(repo, date string) (int, int) {
+	localDate, err := time.ParseInLocation("2006-01-02", date, operatorTZ)
+	if err != nil {
+		return 0, 0
+	}
+	var count, pts int
+	s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(CAST(json_extract(metadata, '$.effective_score') AS INTEGER)),0)
+		FROM events WHERE source='git-discovery' AND event_type='CODE_PUSHED'
+		AND json_extract(metadata, '$.repo')=? AND timestamp >= ? AND timestamp < ?`,
+		repo, localDate.UTC().Format(time.RFC3339), localDate.Add(24*time.Hour).UTC().Format(time.RFC3339)).Scan(&count, &pts)
+	return count, pts
+}
+
+// collectNewCommits walks history from head (all parents) and stops at the
+// previous watermark, and along any parent chain at commits older than since.
+func collectNewCommits(g *gitRepo, head, stop string, since time.Time, limit int) []*gitCommit {
+	var out []*gitCommit
+	seen := map[string]bool{}
+	queue := []string{head}
+	for len(queue) > 0 && len(out) < limit {
+		sha := queue[0]
+		queue = queue[1:]
+		if seen[sha] || sha == stop {
+			continue
+		}
+		seen[sha] = true
+		c, err := g.commit(sha)
+		if err != nil {
+			continue
+		}
+		if c.Committed.Before(since) {
+			continue
+		}
+		out = append(out, c)
+		queue = append(queue, c.Parents...)
+	}
+	return out
+}
+
+// insertDiscoveryEvent writes a git-discovery event, deduplicated on external_id.
+// Pending events carry score 0 until approved; effective_score in metadata is
+// what project approval applies (see handleProjectAction).
+func (s *Server) insertDiscoveryEvent(eventType, title, artifactURL, business, externalID string,
+	when time.Time, effective int, status string, meta map[string]interface{}) bool {
+	var exists int
+	s.db.QueryRow("SELECT COUNT(*) FROM events WHERE external_id=?", externalID).Scan(&exists)
+	if exists > 0 {
+		return false
+	}
+
+	delta := 0
+	if status == "approved" {
+		delta = effective
+	}
+	metaJSON, _ := json.Marshal(meta)
+	now := time.Now().UTC().Format(time.RFC3339)
+	id := fmt.Sprintf("evt-%d", time.Now().UnixNano())
+
+	s.mu.Lock()
+	_, err := s.db.Exec(`INSERT INTO events (id, event_type, lane, source, timestamp,
+		artifact_type, artifact_url, artifact_title, confidence, verifiers, verification_level,
+		score_delta, business_id, metadata, external_id, status, created_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		id, eventType, "shipping", "git-discovery", when.UTC().Format(time.RFC3339),
+		"git", artifactURL, title, 0.9, `["local_git"]`, "MEDIUM",
+		delta, business, string(metaJSON), externalID, status, now)
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("[git-discovery] insert %s: %v", externalID, err)
+		return false
+	}
+
+	if s.pairing != nil {
+		s.pairing.Ingest(Signal{
+			Type:      SignalEvent,
+			Source:    "git-discovery",
+			Timestamp: when,
+			Metadata: map[string]interface{}{
+				"event_type": eventType,
+				"lane":       "shipping",
+				"project":    meta["repo"],
+				"status":     status,
+			},
+		})
+	}
+	return true
+}
+
+// classifyGitCommit mirrors wb-discover's classify_commit: returns
+// (class, base score, reason) with class in SKIP|NOISE|MICRO|STANDARD|MACRO.
+func classifyGitCommit(msg string, filesChanged int, isTag bool) (string, int, string) {
+	m := strings.ToLower(strings.TrimSpace(msg))
+	switch {
+	case gitMergeRe.MatchString(m):
+		return "SKIP", 0, "merge"
+	case isTag:
+		return "MACRO", 8, "release"
+	case filesChanged >= 10:
+		return "MACRO", 5, "large-change"
+	case gitNoiseRe.MatchString(m):
+		return "NOISE", 0, "wip"
+	case gitMicroRe.MatchString(m):
+		return "MICRO", 1, "docs-or-chore"
+	case gitStandardRe.MatchString(m):
+		return "STANDARD", 2, "feature-work"
+	case filesChanged >= 3:
+		return "STANDARD", 2, "multi-file"
+	}
+	return "MICRO", 1, "unclassified"
+}
+
+// applyDiminishingReturns: commits 1-3 full value, 4-6 half, 7+ nothing.
+func applyDiminishingReturns(base, index int) int {
+	switch {
+	case index <= 3:
+		return base
+	case index <= 6:
+		return base / 2
+	}
+	return 0
+}
+
+// githubSlugFromRemote turns git@github.com:Org/Repo.git or
+// https://github.com/Org/Repo into "Org/Repo". Non-GitHub remotes return "".
+func githubSlugFromRemote(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimSpace(remote), ".git")
+	for _, prefix := range []string{"git@github.com:", "https://github.com/", "http://github.com/", "ssh://git@github.com/"} {
+		if strings.HasPrefix(remote, prefix) {
+			return strings.TrimPrefix(remote, prefix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// PURE-GO GIT READER — read-only access to local repositories
+//
+// Enough of the git object model for discovery (git_discovery.go) without
+// shelling out: refs (loose + packed-refs, symbolic HEAD), loose objects,
+// packfiles via .idx v2 with OFS_DELTA / REF_DELTA resolution, commits,
+// annotated tags and trees. No writes, no index, no working-tree status.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// gitRepo is an opened repository (the .git directory, or the common dir for worktrees).
+type gitRepo struct {
+	gitDir    string
+	commonDir string
+	packs     []*gitPack
+
+	cacheMu sync.Mutex
+	cache   map[string]gitObject // sha → object (bounded, for delta bases)
+}
+
+type gitObject struct {
+	Type string // commit, tree, blob, tag
+	Data []byte
+}
+
+// gitCommit is a parsed commit object.
+type gitCommit struct {
+	SHA       string
+	Tree      string
+	Parents   []string
+	Author    string
+	Email     string
+	When      time.Time
+	Committed time.Time
+	Message   string
+}
+
+// Subject returns the first line of the commit message.
+func (c *gitCommit) Subject() string {
+	if i := strings.IndexByte(c.Message, '\n'); i >= 0 {
+		return strings.TrimSpace(c.Message[:i])
+	}
+	return strings.TrimSpace(c.Message)
+}
+
+// gitTreeEntry is one entry of a tree object.
+type gitTreeEntry struct {
+	Mode string
+	Name string
+	SHA  string
+}
+
+func (e gitTreeEntry) isDir() bool { return e.Mode == "40000" }
+
+// openGitRepo opens a repository from its working tree or .git directory.
+// Handles "gitdir:" files (worktrees, submodules) and commondir.
+func openGitRepo(path string) (*gitRepo, error) {
+	gitDir := path
+	if filepath.Base(path) != ".git" {
+		if st, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			gitDir = filepath.Join(path, ".git")
+			if !st.IsDir() {
+				data, err := os.ReadFile(gitDir)
+				if err != nil {
+					return nil, err
+				}
+				ref := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(ref) {
+					ref = filepath.Join(path, ref)
+				}
+				gitDir = ref
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, fmt.Errorf("not a git repository: %s", path)
+	}
+
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		cd := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(cd) {
+			cd = filepath.Join(gitDir, cd)
+		}
+		commonDir = filepath.Clean(cd)
+	}
+
+	g := &gitRepo{gitDir: gitDir, commonDir: commonDir, cache: make(map[string]gitObject)}
+	idxFiles, _ := filepath.Glob(filepath.Join(commonDir, "objects", "pack", "*.idx"))
+	for _, idx := range idxFiles {
+		p, err := openGitPack(idx)
+		if err != nil {
+			continue // corrupt or unsupported pack: loose objects may still work
+		}
+		g.packs = append(g.packs, p)
+	}
+	return g, nil
+}
+
+// Close releases pack file handles.
+func (g *gitRepo) Close() {
+	for _, p := range g.packs {
+		p.f.Close()
+	}
+}
+
+// ─── Refs ───────────────────────────────────────────────────────────────────
+
+// head resolves HEAD to a commit SHA and the branch it points at (if any).
+func (g *gitRepo) head() (sha, branch string, err error) {
+	data, err := os.ReadFile(filepath.Join(g.gitDir, "HEAD"))
+	if err != nil {
+		return "", "", err
+	}
+	v := strings.TrimSpace(string(data))
+	if strings.HasPrefix(v, "ref: ") {
+		ref := strings.TrimPrefix(v, "ref: ")
+		sha, err = g.resolveRef(ref)
+		return sha, strings.TrimPrefix(ref, "refs/heads/"), err
+	}
+	return v, "", nil
+}
+
+// resolveRef follows a (possibly symbolic) ref to a SHA.
+func (g *gitRepo) resolveRef(ref string) (string, error) {
+	for i := 0; i < 5; i++ {
+		data, err := os.ReadFile(filepath.Join(g.gitDir, ref))
+		if err != nil {
+			data, err = os.ReadFile(filepath.Join(g.commonDir, ref))
+		}
+		if err == nil {
+			v := strings.TrimSpace(string(data))
+			if strings.HasPrefix(v, "ref: ") {
+				ref = strings.TrimPrefix(v, "ref: ")
+				continue
+			}
+			return v, nil
+		}
+		if sha, ok := g.packedRefs()[ref]; ok {
+			return sha, nil
+		}
+		return "", fmt.Errorf("ref not found: %s", ref)
+	}
+	return "", fmt.Errorf("ref loop: %s", ref)
+}
+
+// refs lists refs under prefix (e.g. "refs/tags/") from loose files and packed-refs.
+// Loose refs win over packed ones.
+func (g *gitRepo) refs(prefix string) map[string]string {
+	out := map[string]string{}
+	for name, sha := range g.packedRefs() {
+		if strings.HasPrefix(name, prefix) {
+			out[name] = sha
+		}
+	}
+	root := filepath.Join(g.commonDir, filepath.FromSlash(prefix))
+	filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(g.commonDir, p)
+		v := strings.TrimSpace(string(data))
+		if len(v) == 40 {
+			out[filepath.ToSlash(rel)] = v
+		}
+		return nil
+	})
+	return out
+}
+
+func (g *gitRepo) packedRefs() map[string]string {
+	out := map[string]string{}
+	f, err := os.Open(filepath.Join(g.commonDir, "packed-refs"))
+	if err != nil {
+		return out
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		if sp := strings.IndexByte(line, ' '); sp == 40 {
+			out[line[41:]] = line[:40]
+		}
+	}
+	return out
+}
+
+// originURL reads remote.origin.url from the repo config.
+func (g *gitRepo) originURL() string {
+	data, err := os.ReadFile(filepath.Join(g.commonDir, "config"))
+	if err != nil {
+		return ""
+	}
+	inOrigin := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+		if inOrigin && strings.HasPrefix(line, "url") {
+			if eq := strings.IndexByte(line, '='); eq > 0 {
+				return strings.TrimSpace(line[eq+1:])
+			}
+		}
+	}
+	return ""
+}
+
+// ─── Objects ────────────────────────────────────────────────────────────────
+
+// readObject loads an object from loose storage or any pack.
+func (g *gitRepo) readObject(sha string) (gitObject, error) {
+	g.cacheMu.Lock()
+	if obj, ok := g.cache[sha]; ok {
+		g.cacheMu.Unlock()
+		return obj, nil
+	}
+	g.cacheMu.Unlock()
+
+	obj, err := g.readLoose(sha)
+	if err != nil {
+		obj, err = g.readPacked(sha)
+	}
+	if err != nil {
+		return gitObject{}, err
+	}
+
+	g.cacheMu.Lock()
+	if len(g.cache) > 512 {
+		g.cache = make(map[string]gitObject)
+	}
+	g.cache[sha] = obj
+	g.cacheMu.Unlock()
+	return obj, nil
+}
+
+func (g *gitRepo) readLoose(sha string) (gitObject, error) {
+	if len(sha) != 40 {
+		return gitObject{}, fmt.Errorf("bad sha %q", sha)
+	}
+	f, err := os.Open(filepath.Join(g.commonDir, "objects", sha[:2], sha[2:]))
+	if err != nil {
+		return gitObject{}, err
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return gitObject{}, err
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return gitObject{}, err
+	}
+	nul := bytes.IndexByte(raw, 0)
+	if nul < 0 {
+		return gitObject{}, fmt.Errorf("loose object %s: missing header", sha)
+	}
+	header := strings.SplitN(string(raw[:nul]), " ", 2)
+	return gitObject{Type: header[0], Data: raw[nul+1:]}, nil
+}
+
+func (g *gitRepo) readPacked(sha string) (gitObject, error) {
+	want, err := hex.DecodeString(sha)
+	if err != nil || len(want) != 20 {
+		return gitObject{}, fmt.Errorf("bad sha %q", sha)
+	}
+	for _, p := range g.packs {
+		if off, ok := p.find(want); ok {
+			return p.readAt(g, off)
+		}
+	}
+	return gitObject{}, fmt.Errorf("object not found: %s", sha)
+}
+
+// commit reads and parses a commit. Annotated tags are peeled.
+func (g *gitRepo) commit(sha string) (*gitCommit, error) {
+	for i := 0; i < 5; i++ {
+		obj, err := g.readObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		switch obj.Type {
+		case "commit":
+			return parseGitCommit(sha, obj.Data), nil
+		case "tag":
+			t := parseGitTag(obj.Data)
+			sha = t.Object
+		default:
+			return nil, fmt.Errorf("%s is a %s, not a commit", sha, obj.Type)
+		}
+	}
+	return nil, fmt.Errorf("tag chain too deep: %s", sha)
+}
+
+func parseGitCommit(sha string, data []byte) *gitCommit {
+	c := &gitCommit{SHA: sha}
+	text := string(data)
+	headerEnd := strings.Index(text, "\n\n")
+	headers := text
+	if headerEnd >= 0 {
+		headers = text[:headerEnd]
+		c.Message = text[headerEnd+2:]
+	}
+	for _, line := range strings.Split(headers, "\n") {
+		switch {
+		case strings.HasPrefix(line, "tree "):
+			c.Tree = line[5:]
+		case strings.HasPrefix(line, "parent "):
+			c.Parents = append(c.Parents, line[7:])
+		case strings.HasPrefix(line, "author "):
+			c.Author, c.Email, c.When = parseGitSignature(line[7:])
+		case strings.HasPrefix(line, "committer "):
+			_, _, c.Committed = parseGitSignature(line[10:])
+		}
+	}
+	if c.Committed.IsZero() {
+		c.Committed = c.When
+	}
+	return c
+}
+
+// gitTag is a parsed annotated tag object.
+type gitTag struct {
+	Object  string
+	Type    string
+	Name    string
+	Tagger  string
+	When    time.Time
+	Message string
+}
+
+func parseGitTag(data []byte) gitTag {
+	var t gitTag
+	text := string(data)
+	headerEnd := strings.Index(text, "\n\n")
+	headers := text
+	if headerEnd >= 0 {
+		headers = text[:headerEnd]
+		t.Message = strings.TrimSpace(text[headerEnd+2:])
+	}
+	for _, line := range strings.Split(headers, "\n") {
+		switch {
+		case strings.HasPrefix(line, "object "):
+			t.Object = line[7:]
+		case strings.HasPrefix(line, "type "):
+			t.Type = line[5:]
+		case strings.HasPrefix(line, "tag "):
+			t.Name = line[4:]
+		case strings.HasPrefix(line, "tagger "):
+			t.Tagger, _, t.When = parseGitSignature(line[7:])
+		}
+	}
+	return t
+}
+
+// parseGitSignature parses "Name <email> 1700000000 +0100".
+func parseGitSignature(s string) (name, email string, when time.Time) {
+	lt := strings.IndexByte(s, '<')
+	gt := strings.IndexByte(s, '>')
+	if lt < 0 || gt < lt {
+		return strings.TrimSpace(s), "", time.Time{}
+	}
+	name = strings.TrimSpace(s[:lt])
+	email = s[lt+1 : gt]
+	fields := strings.Fields(s[gt+1:])
+	if len(fields) >= 1 {
+		if ts, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			when = time.Unix(ts, 0).UTC()
+		}
+	}
+	return name, email, when
+}
+
+func (g *gitRepo) tree(sha string) ([]gitTreeEntry, error) {
+	obj, err := g.readObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != "tree" {
+		return nil, fmt.Errorf("%s is a %s, not a tree", sha, obj.Type)
+	}
+	var entries []gitTreeEntry
+	data := obj.Data
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || nul+21 > len(data) {
+			break
+		}
+		entries = append(entries, gitTreeEntry{
+			Mode: string(data[:sp]),
+			Name: string(data[sp+1 : nul]),
+			SHA:  hex.EncodeToString(data[nul+1 : nul+21]),
+		})
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// changedFiles counts paths that differ between two trees (like
+// `git diff --name-only a b | wc -l`). Either tree may be "" (empty).
+// Stops counting at limit.
+func (g *gitRepo) changedFiles(treeA, treeB string, limit int) int {
+	if treeA == treeB || limit <= 0 {
+		return 0
+	}
+	load := func(sha string) map[string]gitTreeEntry {
+		m := map[string]gitTreeEntry{}
+		if sha == "" {
+			return m
+		}
+		entries, _ := g.tree(sha)
+		for _, e := range entries {
+			m[e.Name] = e
+		}
+		return m
+	}
+	a, b := load(treeA), load(treeB)
+	names := map[string]bool{}
+	for n := range a {
+		names[n] = true
+	}
+	for n := range b {
+		names[n] = true
+	}
+	keys := make([]string, 0, len(names))
+	for n := range names {
+		keys = append(keys, n)
+	}
+	sort.Strings(keys)
+
+	count := 0
+	for _, n := range keys {
+		ea, okA := a[n]
+		eb, okB := b[n]
+		if okA && okB && ea.SHA == eb.SHA {
+			continue
+		}
+		var subA, subB string
+		dirA, dirB := okA && ea.isDir(), okB && eb.isDir()
+		if dirA {
+			subA = ea.SHA
+		}
+		if dirB {
+			subB = eb.SHA
+		}
+		if dirA || dirB {
+			count += g.changedFiles(subA, subB, limit-count)
+			// a file replaced by a directory (or vice versa) also changes the file path
+			if (okA && !dirA) || (okB && !dirB) {
+				count++
+			}
+		} else {
+			count++
+		}
+		if count >= limit {
+			return limit
+		}
+	}
+	return count
+}
+
+// ─── Packfiles ──────────────────────────────────────────────────────────────
+
+type gitPack struct {
+	f       *os.File
+	fanout  [256]uint32
+	shas    []byte // N*20
+	offsets []uint32
+	large   []byte // 8-byte big offsets
+}
+
+func openGitPack(idxPath string) (*gitPack, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) ||
+		binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("%s: unsupported idx version", idxPath)
+	}
+	p := &gitPack{}
+	for i := 0; i < 256; i++ {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(idx) < pos+n*28 {
+		return nil, fmt.Errorf("%s: truncated idx", idxPath)
+	}
+	p.shas = idx[pos : pos+n*20]
+	pos += n * 20
+	pos += n * 4 // CRC32s
+	p.offsets = make([]uint32, n)
+	for i := 0; i < n; i++ {
+		p.offsets[i] = binary.BigEndian.Uint32(idx[pos+i*4:])
+	}
+	pos += n * 4
+	p.large = idx[pos:]
+
+	packPath := strings.TrimSuffix(idxPath, ".idx") + ".pack"
+	p.f, err = os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// find returns the pack offset of an object via the fanout table + binary search.
+func (p *gitPack) find(sha []byte) (int64, bool) {
+	lo := 0
+	if sha[0] > 0 {
+		lo = int(p.fanout[sha[0]-1])
+	}
+	hi := int(p.fanout[sha[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.shas[(lo+i)*20:(lo+i)*20+20], sha) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.shas[i*20:i*20+20], sha) {
+		return 0, false
+	}
+	off := p.offsets[i]
+	if off&0x80000000 != 0 {
+		li := int(off & 0x7fffffff)
+		if li*8+8 > len(p.large) {
+			return 0, false
+		}
+		return int64(binary.BigEndian.Uint64(p.large[li*8:])), true
+	}
+	return int64(off), true
+}
+
+var gitPackTypes = map[byte]string{1: "commit", 2: "tree", 3: "blob", 4: "tag"}
+
+// readAt decodes the object at offset, applying deltas recursively.
+func (p *gitPack) readAt(g *gitRepo, offset int64) (gitObject, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.f, offset, 1<<62))
+	c, err := r.ReadByte()
+	if err != nil {
+		return gitObject{}, err
+	}
+	typ := (c >> 4) & 7
+	size := int64(c & 0x0f)
+	shift := uint(4)
+	for c&0x80 != 0 {
+		if c, err = r.ReadByte(); err != nil {
+			return gitObject{}, err
+		}
+		size |= int64(c&0x7f) << shift
+		shift += 7
+	}
+
+	switch typ {
+	case 1, 2, 3, 4:
+		data, err := inflateN(r, size)
+		if err != nil {
+			return gitObject{}, err
+		}
+		return gitObject{Type: gitPackTypes[typ], Data: data}, nil
+
+	case 6, 7: // OFS_DELTA, REF_DELTA
+		var base gitObject
+		if typ == 6 {
+			c, err := r.ReadByte()
+			if err != nil {
+				return gitObject{}, err
+			}
+			rel := int64(c & 0x7f)
+			for c&0x80 != 0 {
+				if c, err = r.ReadByte(); err != nil {
+					return gitObject{}, err
+				}
+				rel = ((rel + 1) << 7) | int64(c&0x7f)
+			}
+			delta, err := inflateN(r, size)
+			if err != nil {
+				return gitObject{}, err
+			}
+			if base, err = p.readAt(g, offset-rel); err != nil {
+				return gitObject{}, err
+			}
+			data, err := applyGitDelta(base.Data, delta)
+			return gitObject{Type: base.Type, Data: data}, err
+		}
+		baseSHA := make([]byte, 20)
+		if _, err := io.ReadFull(r, baseSHA); err != nil {
+			return gitObject{}, err
+		}
+		delta, err := inflateN(r, size)
+		if err != nil {
+			return gitObject{}, err
+		}
+		if base, err = g.readObject(hex.EncodeToString(baseSHA)); err != nil {
+			return gitObject{}, err
+		}
+		data, err := applyGitDelta(base.Data, delta)
+		return gitObject{Type: base.Type, Data: data}, err
+
+	default:
+		return gitObject{}, fmt.Errorf("unknown pack object type %d at %d", typ, offset)
+	}
+}
+
+func inflateN(r io.Reader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(zr, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// applyGitDelta applies a git delta (copy/insert instructions) to base.
+func applyGitDelta(base, delta []byte) ([]byte, error) {
+	pos := 0
+	readVarint := func() int {
+		v, shift := 0, uint(0)
+		for pos < len(delta) {
+			c := delta[pos]
+			pos++
+			v |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		return v
+	}
+	if srcSize := readVarint(); srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch: %d != %d", srcSize, len(base))
+	}
+	out := make([]byte, 0, readVarint())
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+		if op&0x80 != 0 {
+			var off, n int
+			for i := uint(0); i < 4; i++ {
+				if op&(1<<i) != 0 && pos < len(delta) {
+					off |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if op&(1<<(4+i)) != 0 && pos < len(delta) {
+					n |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if off+n > len(base) {
+				return nil, fmt.Errorf("delta copy out of range")
+			}
+			out = append(out, base[off:off+n]...)
+		} else if op != 0 {
+			n := int(op)
+			if pos+n > len(delta) {
+				return nil, fmt.Errorf("delta insert out of range")
+			}
+			out = append(out, delta[pos:pos+n]...)
+			pos += n
+		} else {
+			return nil, fmt.Errorf("delta opcode 0")
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// INTEGRATION TEST / DRY-RUN — see what a poll would do before it does it
+//
+// A dry run executes the provider's real poller against a throwaway sandbox
+// database: same code path, same API calls, same lane/score decisions, but
+// nothing lands in the scoreboard. The sandbox is seeded with the bits of
+// state that change poller output (the integration row, trusted sources) and
+// thrown away afterwards. Each event it produced is then checked against the
+// live events table (id, external_id, artifact_url — the keys pollers dedup
+// on) so the response says which ones would be new and which already exist.
+//
+//   POST /v1/integrations/{id}/test[?days=7]   stored integration
+//   POST /v1/integrations/test                 unsaved: {provider, auth_type,
+//                                              credential, config, days}
+//
+// Stored OAuth integrations get the normal pre-poll token refresh (the next
+// scheduled poll would do the same); the sandbox itself never refreshes or
+// stores tokens, and skips document extraction (gdrive/dropbox).
+// ═══════════════════════════════════════════════════════════════════════════════
+
+const (
+	dryRunDefaultDays = 7
+	dryRunMaxDays     = 90
+)
+
+type dryRunEvent struct {
+	ID          string  `json:"id"`
+	EventType   string  `json:"event_type"`
+	Lane        string  `json:"lane"`
+	Source      string  `json:"source"`
+	Timestamp   string  `json:"timestamp"`
+	Title       string  `json:"artifact_title"`
+	URL         string  `json:"artifact_url,omitempty"`
+	ExternalID  string  `json:"external_id,omitempty"`
+	Confidence  float64 `json:"confidence"`
+	ScoreDelta  int     `json:"score_delta"`
+	Status      string  `json:"status"` // approved | pending, as it would be inserted
+	Dedup       string  `json:"dedup"`  // new | duplicate
+	DuplicateOf string  `json:"duplicate_of,omitempty"`
+}
+
+type dryRunResult struct {
+	IntegrationID string         `json:"integration_id,omitempty"`
+	Provider      string         `json:"provider"`
+	OK            bool           `json:"ok"`
+	Credential    string         `json:"credential"` // ok | refreshed | rejected | expired | undecryptable | unknown
+	Error         string         `json:"error,omitempty"`
+	Since         string         `json:"since"`
+	Days          int            `json:"days"`
+	Events        []dryRunEvent  `json:"events"`
+	WouldCreate   int            `json:"would_create"`
+	Duplicates    int            `json:"duplicates"`
+	LanePoints    map[string]int `json:"lane_points"` // score_delta of new events by lane
+	Writes        map[string]int `json:"writes"`      // other rows the poll would write, by table
+	DurationMS    int64          `json:"duration_ms"`
+}
+
+// newDryRunSandbox builds a Server over a temporary database with the full
+// schema. cleanup closes and deletes it.
+func (s *Server) newDryRunSandbox() (*Server, func(), error) {
+	dir, err := os.MkdirTemp("", "scoreboard-dryrun-")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "dryrun.db")+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	sb := &Server{
+		db:       db,
+		tenantID: s.tenantID,
+		dryRun:   true,
+		// Nobody drains this; Ingest drops signals once it fills
+		pairing: &PairingEngine{signalChan: make(chan Signal, 64)},
+	}
+	sb.initDB()
+	sb.loadSeason()
+
+	rows, err := s.db.Query(`SELECT source, approved_at, approved_count FROM trusted_sources`)
+	if err == nil {
+		for rows.Next() {
+			var src, at string
+			var n int
+			rows.Scan(&src, &at, &n)
+			sb.db.Exec(`INSERT OR IGNORE INTO trusted_sources (source, approved_at, approved_count) VALUES (?,?,?)`, src, at, n)
+		}
+		rows.Close()
+	}
+	return sb, func() { db.Close(); os.RemoveAll(dir) }, nil
+}
+
+// tableCounts returns row counts for every table in db.
+func tableCounts(db *sql.DB) map[string]int {
+	counts := map[string]int{}
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return counts
+	}
+	var names []string
+	for rows.Next() {
+		var n string
+		rows.Scan(&n)
+		names = append(names, n)
+	}
+	rows.Close()
+	for _, n := range names {
+		var c int
+		db.QueryRow(`SELECT COUNT(*) FROM "` + n + `"`).Scan(&c)
+		counts[n] = c
+	}
+	return counts
+}
+
+// dryRunPoll polls provider into a sandbox and reports what it would write.
+// credential must already be in the form the poller expects.
+func (s *Server) dryRunPoll(integrationID, userID, provider, authType, credential, config string, days int) *dryRunResult {
+	started := time.Now()
+	since := started.AddDate(0, 0, -days).UTC().Format(time.RFC3339)
+	res := &dryRunResult{
+		IntegrationID: integrationID, Provider: provider, Credential: "unknown",
+		Since: since, Days: days, Events: []dryRunEvent{},
+		LanePoints: map[string]int{}, Writes: map[string]int{},
+	}
+	defer func() { res.DurationMS = time.Since(started).Milliseconds() }()
+
+	sb, cleanup, err := s.newDryRunSandbox()
+	if err != nil {
+		res.Error = "sandbox: " + err.Error()
+		return res
+	}
+	defer cleanup()
+
+	id := integrationID
+	if id == "" {
+		id = "int-dryrun"
+	}
+	now := started.UTC().Format(time.RFC3339)
+	sb.db.Exec(`INSERT INTO integrations (id, user_id, provider, auth_type, config, created_at, updated_at)
+		VALUES (?,?,?,?,?,?,?)`, id, userID, provider, authType, config, now, now)
+	before := tableCounts(sb.db)
+
+	if err := sb.pollProvider(id, provider, credential, config, since); err != nil {
+		res.Error = err.Error()
+		if looksUnauthorized(err) {
+			res.Credential = "rejected"
+		}
+		return res
+	}
+	res.OK = true
+	res.Credential = "ok"
+
+	for table, n := range tableCounts(sb.db) {
+		switch table {
+		case "events", "integrations":
+			continue
+		}
+		if d := n - before[table]; d > 0 {
+			res.Writes[table] = d
+		}
+	}
+
+	rows, err := sb.db.Query(`SELECT id, event_type, lane, source, timestamp, COALESCE(artifact_title,''),
+		COALESCE(artifact_url,''), COALESCE(external_id,''), confidence, score_delta, COALESCE(status,'approved')
+		FROM events ORDER BY timestamp DESC`)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	for rows.Next() {
+		var e dryRunEvent
+		rows.Scan(&e.ID, &e.EventType, &e.Lane, &e.Source, &e.Timestamp, &e.Title,
+			&e.URL, &e.ExternalID, &e.Confidence, &e.ScoreDelta, &e.Status)
+		res.Events = append(res.Events, e)
+	}
+	rows.Close()
+
+	for i := range res.Events {
+		e := &res.Events[i]
+		s.db.QueryRow(`SELECT id FROM events WHERE id=? OR (?<>'' AND external_id=?) OR (?<>'' AND artifact_url=?) LIMIT 1`,
+			e.ID, e.ExternalID, e.ExternalID, e.URL, e.URL).Scan(&e.DuplicateOf)
+		if e.DuplicateOf != "" {
+			e.Dedup = "duplicate"
+			res.Duplicates++
+			continue
+		}
+		e.Dedup = "new"
+		res.WouldCreate++
+		res.LanePoints[e.Lane] += e.ScoreDelta
+	}
+	return res
+}
+
+func (s *Server) handleIntegrationTest(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method == "OPTIONS" {
+		return
+	}
+	if r.Method != "POST" && !(r.Method == "GET" && id != "") {
+		http.Error(w, `{"error":"POST"}`, 405)
+		return
+	}
+	auth := resolveAuth(r)
+	userID := "default"
+	if auth.Authenticated && auth.Username != "" {
+		userID = auth.Username
+	} else if auth.Authenticated && auth.UserID != 0 {
+		userID = fmt.Sprintf("user-%d", auth.UserID)
+	}
+
+	var body struct {
+		Provider   string `json:"provider"`
+		AuthType   string `json:"auth_type"`
+		Credential string `json:"credential"`
+		Config     string `json:"config"`
+		Days       int    `json:"days"`
+	}
+	if r.Method == "POST" && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, 400)
+			return
+		}
+	}
+	days := body.Days
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil {
+		days = v
+	}
+	if days <= 0 {
+		days = dryRunDefaultDays
+	}
+	if days > dryRunMaxDays {
+		days = dryRunMaxDays
+	}
+
+	// Unsaved integration: test what's in the body
+	if id == "" {
+		if body.Provider == "" {
+			http.Error(w, `{"error":"provider required"}`, 400)
+			return
+		}
+		if body.AuthType == "" {
+			body.AuthType = "api_key"
+		}
+		if body.Config == "" {
+			body.Config = "{}"
+		}
+		writeJSON(w, s.dryRunPoll("", userID, body.Provider, body.AuthType,
+			pollerCredential(body.Provider, body.Credential), body.Config, days))
+		return
+	}
+
+	var owner, provider, authType, keyID, config string
+	var encData, nonce []byte
+	err := s.db.QueryRow(`SELECT user_id, provider, auth_type, encrypted_data, nonce, COALESCE(key_id,''), config
+		FROM integrations WHERE id=?`, id).Scan(&owner, &provider, &authType, &encData, &nonce, &keyID, &config)
+	if err != nil || (owner != userID && auth.TierLevel < 99) {
+		http.Error(w, `{"error":"integration not found"}`, 404)
+		return
+	}
+
+	var credential string
+	if len(encData) > 0 && len(nonce) > 0 {
+		plain, err := s.decryptCredentialKey(encData, nonce, keyID)
+		if err != nil {
+			writeJSON(w, &dryRunResult{IntegrationID: id, Provider: provider, Credential: "undecryptable",
+				Error: err.Error(), Events: []dryRunEvent{}, LanePoints: map[string]int{}, Writes: map[string]int{}})
+			return
+		}
+		credential = string(plain)
+	}
+
+	refreshed := false
+	if authType == "oauth2" {
+		fresh, err := s.ensureFreshToken(id, provider, credential)
+		if err != nil {
+			status := "unknown"
+			if isTokenRevoked(err) {
+				status = "expired"
+			}
+			writeJSON(w, &dryRunResult{IntegrationID: id, Provider: provider, Credential: status,
+				Error: err.Error(), Events: []dryRunEvent{}, LanePoints: map[string]int{}, Writes: map[string]int{}})
+			return
+		}
+		refreshed = fresh != credential
+		credential = pollerCredential(provider, fresh)
+	}
+
+	res := s.dryRunPoll(id, owner, provider, authType, credential, config, days)
+	if refreshed && res.Credential == "ok" {
+		res.Credential = "refreshed"
+	}
+	writeJSON(w, res)
+}
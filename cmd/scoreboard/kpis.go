@@ -0,0 +1,697 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// KPI TIME SERIES — gauges from any poller, queryable and alertable
+//
+// Pollers see numbers (pageviews, uptime %, subscribers, MRR, cash) that only
+// ever became discrete events. recordKPI keeps them as a time series instead:
+//
+//   kpi_samples   one row per (kpi, source, resolution, bucket). Each row keeps
+//                 value (last seen), min, max, sum and count, so rollups can
+//                 still answer avg / min / max / sum / last exactly.
+//     raw         written by pollers; a sample within kpiMergeInterval of the
+//                 previous one is merged into it, so a 60s poll loop doesn't
+//                 write 1440 rows a day
+//     1h          raw older than KPI_RAW_DAYS is rolled up into hours
+//     1d          hours older than KPI_HOURLY_DAYS are rolled up into days
+//                 (UTC), and days older than KPI_RETENTION_DAYS are dropped
+//
+//   kpi_targets   operator thresholds. A target fires once when its series
+//                 crosses the threshold (optionally aggregated over a window)
+//                 and re-arms when it crosses back.
+//
+//   GET  /v1/kpis                   series list with latest value and targets
+//   POST /v1/kpis                   record samples {kpi, value, source?, timestamp?}
+//   GET  /v1/kpis/{kpi}?from=&to=&window=1h&agg=avg&source=
+//   GET|POST|DELETE /v1/kpis/targets
+//
+// KPI names are dotted, lower-case: "analytics.pageviews_today", "revenue.mrr".
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	kpiRawDays       = envOr("KPI_RAW_DAYS", "7")
+	kpiHourlyDays    = envOr("KPI_HOURLY_DAYS", "90")
+	kpiRetentionDays = envOr("KPI_RETENTION_DAYS", "730")
+)
+
+const kpiMergeInterval = 5 * time.Minute
+
+var kpiAggregations = map[string]bool{"avg": true, "min": true, "max": true, "sum": true, "last": true, "count": true}
+
+func (s *Server) initKPIs() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS kpi_samples (
+		kpi TEXT NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		resolution TEXT NOT NULL DEFAULT 'raw',
+		ts TEXT NOT NULL,
+		value REAL NOT NULL,
+		min_value REAL NOT NULL,
+		max_value REAL NOT NULL,
+		sum_value REAL NOT NULL,
+		sample_count INTEGER NOT NULL DEFAULT 1,
+		PRIMARY KEY (kpi, source, resolution, ts)
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_kpi_samples_ts ON kpi_samples(resolution, ts)`)
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS kpi_targets (
+		id TEXT PRIMARY KEY,
+		kpi TEXT NOT NULL,
+		source TEXT DEFAULT '',
+		op TEXT NOT NULL,
+		threshold REAL NOT NULL,
+		agg_window TEXT DEFAULT '',
+		agg TEXT DEFAULT 'last',
+		severity TEXT DEFAULT 'warning',
+		note TEXT DEFAULT '',
+		state TEXT DEFAULT 'ok',
+		last_value REAL,
+		last_checked TEXT DEFAULT '',
+		breached_at TEXT DEFAULT '',
+		created_at TEXT NOT NULL
+	)`)
+}
+
+func kpiName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// recordKPI writes a gauge sample at the current time.
+func (s *Server) recordKPI(kpi, source string, value float64) {
+	s.recordKPIAt(kpi, source, value, time.Now())
+}
+
+func (s *Server) recordKPIAt(kpi, source string, value float64, at time.Time) {
+	if kpi = writeKPISample(s.db, kpi, source, value, at); kpi != "" {
+		s.checkKPITargets(kpi)
+	}
+}
+
+// kpiDB is what writing a sample needs: the database or a transaction.
+type kpiDB interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// writeKPISample stores one sample without checking targets. Returns the
+// normalised KPI name, or "" if the sample was dropped.
+func writeKPISample(db kpiDB, kpi, source string, value float64, at time.Time) string {
+	kpi = kpiName(kpi)
+	if kpi == "" || math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+	at = at.UTC()
+	ts := at.Format(time.RFC3339)
+
+	// Merge into the previous raw sample if it's recent — keeps the row count
+	// proportional to wall time, not to poll frequency.
+	var prevTS string
+	db.QueryRow(`SELECT ts FROM kpi_samples WHERE kpi=? AND source=? AND resolution='raw'
+		ORDER BY ts DESC LIMIT 1`, kpi, source).Scan(&prevTS)
+	if prev, err := time.Parse(time.RFC3339, prevTS); err == nil && !at.Before(prev) && at.Sub(prev) < kpiMergeInterval {
+		db.Exec(`UPDATE kpi_samples SET value=?, min_value=MIN(min_value, ?), max_value=MAX(max_value, ?),
+			sum_value=sum_value+?, sample_count=sample_count+1
+			WHERE kpi=? AND source=? AND resolution='raw' AND ts=?`, value, value, value, value, kpi, source, prevTS)
+	} else {
+		db.Exec(`INSERT INTO kpi_samples (kpi, source, resolution, ts, value, min_value, max_value, sum_value, sample_count)
+			VALUES (?,?,'raw',?,?,?,?,?,1)
+			ON CONFLICT(kpi, source, resolution, ts) DO UPDATE SET value=excluded.value`,
+			kpi, source, ts, value, value, value, value)
+	}
+	return kpi
+}
+
+// sampleFinanceKPIs records the money gauges computed from the ledgers.
+func (s *Server) sampleFinanceKPIs() {
+	if mrr, counts := s.currentMRR(); len(counts) > 0 {
+		s.recordKPI("revenue.mrr", "subscriptions", mrr)
+		s.recordKPI("revenue.active_subscriptions", "subscriptions", float64(counts["active"]))
+	}
+	if rw := s.computeRunway(); rw.Accounts > 0 {
+		s.recordKPI("finance.net_cash", "plaid", rw.NetCash)
+		s.recordKPI("finance.net_burn", "plaid", rw.NetBurn)
+		if rw.RunwayMonths != nil {
+			s.recordKPI("finance.runway_months", "plaid", *rw.RunwayMonths)
+		}
+	}
+	ar := s.receivablesSummary()
+	if n, _ := ar["open_invoices"].(int); n > 0 {
+		out, _ := ar["outstanding"].(float64)
+		s.recordKPI("receivables.outstanding", "freshbooks", out)
+	}
+}
+
+// ─── Downsampling + retention ───────────────────────────────────────────────
+
+// kpiRollup folds rows of one resolution older than cutoff into coarser buckets.
+// bucketLen is the length of the ts prefix that identifies the bucket and
+// suffix completes it back to RFC3339 ("2026-10-18T14" + ":00:00Z").
+func (s *Server) kpiRollup(from, to string, cutoff time.Time, bucketLen int, suffix string) int64 {
+	c := cutoff.UTC().Format(time.RFC3339)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`INSERT INTO kpi_samples (kpi, source, resolution, ts, value, min_value, max_value, sum_value, sample_count)
+		SELECT k.kpi, k.source, ?, substr(k.ts, 1, ?) || ?,
+			(SELECT k2.value FROM kpi_samples k2 WHERE k2.kpi=k.kpi AND k2.source=k.source AND k2.resolution=?
+				AND substr(k2.ts, 1, ?) = substr(k.ts, 1, ?) ORDER BY k2.ts DESC LIMIT 1),
+			MIN(k.min_value), MAX(k.max_value), SUM(k.sum_value), SUM(k.sample_count)
+		FROM kpi_samples k WHERE k.resolution=? AND k.ts < ?
+		GROUP BY k.kpi, k.source, substr(k.ts, 1, ?)
+		ON CONFLICT(kpi, source, resolution, ts) DO UPDATE SET
+			value=excluded.value, min_value=MIN(min_value, excluded.min_value),
+			max_value=MAX(max_value, excluded.max_value), sum_value=sum_value+excluded.sum_value,
+			sample_count=sample_count+excluded.sample_count`,
+		to, bucketLen, suffix, from, bucketLen, bucketLen, from, c, bucketLen)
+	if err != nil {
+		log.Printf("KPI rollup %s→%s: %v", from, to, err)
+		return 0
+	}
+	res, _ := s.db.Exec(`DELETE FROM kpi_samples WHERE resolution=? AND ts < ?`, from, c)
+	n, _ := res.RowsAffected()
+	return n
+}
+
+// compactKPIs downsamples old samples and drops expired ones.
+func (s *Server) compactKPIs() {
+	days := func(v string, def int) int {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		return def
+	}
+	now := time.Now().UTC()
+	// Only roll complete buckets: cut at the start of the hour / day
+	rawCut := now.AddDate(0, 0, -days(kpiRawDays, 7)).Truncate(time.Hour)
+	hourCut := now.AddDate(0, 0, -days(kpiHourlyDays, 90)).Truncate(24 * time.Hour)
+	raw := s.kpiRollup("raw", "1h", rawCut, 13, ":00:00Z")
+	hourly := s.kpiRollup("1h", "1d", hourCut, 10, "T00:00:00Z")
+	res, _ := s.db.Exec(`DELETE FROM kpi_samples WHERE resolution='1d' AND ts < ?`,
+		now.AddDate(0, 0, -days(kpiRetentionDays, 730)).Format(time.RFC3339))
+	expired, _ := res.RowsAffected()
+	if raw+hourly+expired > 0 {
+		log.Printf("KPIs: rolled up %d raw, %d hourly samples; expired %d", raw, hourly, expired)
+	}
+}
+
+// ─── Querying ───────────────────────────────────────────────────────────────
+
+type kpiSample struct {
+	TS    time.Time
+	Value float64
+	Min   float64
+	Max   float64
+	Sum   float64
+	Count int
+}
+
+type kpiPoint struct {
+	T     string  `json:"t"`
+	V     float64 `json:"v"`
+	Count int     `json:"count"`
+}
+
+// parseKPIWindow accepts Go durations plus a "d" (day) suffix; "" or "raw" is 0.
+func parseKPIWindow(w string) (time.Duration, error) {
+	if w == "" || w == "raw" {
+		return 0, nil
+	}
+	if strings.HasSuffix(w, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(w, "d"))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("bad window %q", w)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(w)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad window %q", w)
+	}
+	return d, nil
+}
+
+// kpiSamples loads every resolution of a series in [from, to], oldest first,
+// grouped by source.
+func (s *Server) kpiSamples(kpi, source string, from, to time.Time) map[string][]kpiSample {
+	q := `SELECT source, ts, value, min_value, max_value, sum_value, sample_count FROM kpi_samples
+		WHERE kpi=? AND ts >= ? AND ts <= ?`
+	args := []interface{}{kpi, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)}
+	if source != "" {
+		q += ` AND source=?`
+		args = append(args, source)
+	}
+	out := map[string][]kpiSample{}
+	rows, err := s.db.Query(q+` ORDER BY ts`, args...)
+	if err != nil {
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var src, ts string
+		var k kpiSample
+		rows.Scan(&src, &ts, &k.Value, &k.Min, &k.Max, &k.Sum, &k.Count)
+		if k.TS, err = time.Parse(time.RFC3339, ts); err != nil {
+			continue
+		}
+		out[src] = append(out[src], k)
+	}
+	return out
+}
+
+// aggregateKPI reduces samples (oldest first) to one value.
+func aggregateKPI(samples []kpiSample, agg string) (float64, int) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	count := 0
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, k := range samples {
+		sum += k.Sum
+		count += k.Count
+		lo = math.Min(lo, k.Min)
+		hi = math.Max(hi, k.Max)
+	}
+	switch agg {
+	case "min":
+		return lo, count
+	case "max":
+		return hi, count
+	case "sum":
+		return sum, count
+	case "count":
+		return float64(count), count
+	case "last":
+		return samples[len(samples)-1].Value, count
+	}
+	return sum / float64(count), count
+}
+
+// bucketKPI groups samples into window-sized buckets; window 0 returns them as stored.
+func bucketKPI(samples []kpiSample, window time.Duration, agg string) []kpiPoint {
+	points := []kpiPoint{}
+	if window == 0 {
+		for _, k := range samples {
+			v, n := aggregateKPI([]kpiSample{k}, agg)
+			points = append(points, kpiPoint{T: k.TS.Format(time.RFC3339), V: v, Count: n})
+		}
+		return points
+	}
+	for i := 0; i < len(samples); {
+		start := samples[i].TS.Truncate(window)
+		j := i
+		for j < len(samples) && samples[j].TS.Truncate(window).Equal(start) {
+			j++
+		}
+		v, n := aggregateKPI(samples[i:j], agg)
+		points = append(points, kpiPoint{T: start.Format(time.RFC3339), V: math.Round(v*10000) / 10000, Count: n})
+		i = j
+	}
+	return points
+}
+
+// ─── Targets ────────────────────────────────────────────────────────────────
+
+type kpiTarget struct {
+	ID          string   `json:"id"`
+	KPI         string   `json:"kpi"`
+	Source      string   `json:"source,omitempty"`
+	Op          string   `json:"op"` // above | below
+	Threshold   float64  `json:"threshold"`
+	Window      string   `json:"window,omitempty"` // aggregate over this trailing window; "" = latest sample
+	Agg         string   `json:"agg"`
+	Severity    string   `json:"severity"`
+	Note        string   `json:"note,omitempty"`
+	State       string   `json:"state"` // ok | breached
+	LastValue   *float64 `json:"last_value"`
+	LastChecked string   `json:"last_checked,omitempty"`
+	BreachedAt  string   `json:"breached_at,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+func (s *Server) loadKPITargets(where string, args ...interface{}) []kpiTarget {
+	targets := []kpiTarget{}
+	q := `SELECT id, kpi, source, op, threshold, agg_window, agg, severity, note, state, last_value,
+		last_checked, breached_at, created_at FROM kpi_targets`
+	if where != "" {
+		q += " WHERE " + where
+	}
+	rows, err := s.db.Query(q+" ORDER BY kpi, created_at", args...)
+	if err != nil {
+		return targets
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t kpiTarget
+		rows.Scan(&t.ID, &t.KPI, &t.Source, &t.Op, &t.Threshold, &t.Window, &t.Agg, &t.Severity, &t.Note,
+			&t.State, &t.LastValue, &t.LastChecked, &t.BreachedAt, &t.CreatedAt)
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// checkKPITargets evaluates every target on a KPI and alerts on new breaches.
+func (s *Server) checkKPITargets(kpi string) {
+	targets := s.loadKPITargets("kpi=?", kpi)
+	if len(targets) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	for _, t := range targets {
+		window, _ := parseKPIWindow(t.Window)
+		var samples []kpiSample
+		if window > 0 {
+			for _, ss := range s.kpiSamples(t.KPI, t.Source, now.Add(-window), now) {
+				samples = append(samples, ss...)
+			}
+			sort.Slice(samples, func(i, j int) bool { return samples[i].TS.Before(samples[j].TS) })
+		} else {
+			samples = s.latestKPISamples(t.KPI, t.Source)
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		agg := t.Agg
+		if window == 0 {
+			agg = "last"
+		}
+		value, _ := aggregateKPI(samples, agg)
+		breached := (t.Op == "above" && value > t.Threshold) || (t.Op == "below" && value < t.Threshold)
+
+		state, breachedAt := "ok", ""
+		if breached {
+			state, breachedAt = "breached", t.BreachedAt
+			if t.State != "breached" {
+				breachedAt = now.Format(time.RFC3339)
+				title := fmt.Sprintf("KPI %s is %s target: %s (threshold %s)", t.KPI, t.Op,
+					strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64),
+					strconv.FormatFloat(t.Threshold, 'f', -1, 64))
+				detail := t.Note
+				if t.Window != "" {
+					detail = strings.TrimSpace(fmt.Sprintf("%s over the last %s. %s", agg, t.Window, t.Note))
+				}
+				alertID := "kpi_" + sanitizeID(t.ID) + "_" + now.Format("20060102T1504")
+				if s.insertAlert(alertID, "kpi_target", title, detail, t.Severity) {
+					log.Printf("KPI target %s breached: %s", t.ID, title)
+				}
+			}
+		}
+		s.db.Exec(`UPDATE kpi_targets SET state=?, last_value=?, last_checked=?, breached_at=? WHERE id=?`,
+			state, value, now.Format(time.RFC3339), breachedAt, t.ID)
+	}
+}
+
+// latestKPISamples returns the newest sample of each source (or of one source).
+func (s *Server) latestKPISamples(kpi, source string) []kpiSample {
+	q := `SELECT source, ts, value, min_value, max_value, sum_value, sample_count FROM kpi_samples k
+		WHERE kpi=? AND ts = (SELECT MAX(ts) FROM kpi_samples WHERE kpi=k.kpi AND source=k.source)`
+	args := []interface{}{kpi}
+	if source != "" {
+		q += ` AND source=?`
+		args = append(args, source)
+	}
+	var out []kpiSample
+	rows, err := s.db.Query(q+` ORDER BY ts`, args...)
+	if err != nil {
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var src, ts string
+		var k kpiSample
+		rows.Scan(&src, &ts, &k.Value, &k.Min, &k.Max, &k.Sum, &k.Count)
+		if k.TS, err = time.Parse(time.RFC3339, ts); err == nil {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// ─── API ────────────────────────────────────────────────────────────────────
+
+// handleKPIs: GET /v1/kpis lists series; POST records samples.
+func (s *Server) handleKPIs(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		writeJSON(w, map[string]interface{}{"series": s.kpiSeriesList(), "targets": s.loadKPITargets("")})
+	case "POST":
+		// A single sample or an array of them. Every sample is checked before
+		// any is written, and an array is written in one transaction.
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"invalid JSON"}`, 400)
+			return
+		}
+		type sampleIn struct {
+			KPI       string   `json:"kpi"`
+			Value     *float64 `json:"value"`
+			Source    string   `json:"source"`
+			Timestamp string   `json:"timestamp"`
+		}
+		var samples []sampleIn
+		isArray := strings.HasPrefix(strings.TrimSpace(string(body)), "[")
+		if isArray {
+			if json.Unmarshal(body, &samples) != nil {
+				http.Error(w, `{"error":"invalid sample array"}`, 400)
+				return
+			}
+		} else {
+			var one sampleIn
+			if json.Unmarshal(body, &one) != nil {
+				http.Error(w, `{"error":"invalid sample"}`, 400)
+				return
+			}
+			samples = []sampleIn{one}
+		}
+		fail := func(i int, msg string) {
+			if isArray {
+				msg = fmt.Sprintf("sample %d: %s", i+1, msg)
+			}
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, msg), 400)
+		}
+		times := make([]time.Time, len(samples))
+		for i, in := range samples {
+			if kpiName(in.KPI) == "" || in.Value == nil {
+				fail(i, "kpi and value required")
+				return
+			}
+			times[i] = time.Now()
+			if in.Timestamp != "" {
+				if times[i] = parseFlexibleTime(in.Timestamp); times[i].IsZero() {
+					fail(i, "bad timestamp")
+					return
+				}
+			}
+		}
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			http.Error(w, `{"error":"db error"}`, 500)
+			return
+		}
+		touched := map[string]bool{}
+		for i, in := range samples {
+			source := in.Source
+			if source == "" {
+				source = "manual"
+			}
+			if kpi := writeKPISample(tx, in.KPI, source, *in.Value, times[i]); kpi != "" {
+				touched[kpi] = true
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, `{"error":"db error"}`, 500)
+			return
+		}
+		for kpi := range touched {
+			s.checkKPITargets(kpi)
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "recorded": len(samples)})
+	default:
+		http.Error(w, `{"error":"GET or POST"}`, 405)
+	}
+}
+
+// kpiSeriesList summarises every (kpi, source) series.
+func (s *Server) kpiSeriesList() []map[string]interface{} {
+	series := []map[string]interface{}{}
+	rows, err := s.db.Query(`SELECT kpi, source, COUNT(*), MIN(ts), MAX(ts) FROM kpi_samples
+		GROUP BY kpi, source ORDER BY kpi, source`)
+	if err != nil {
+		return series
+	}
+	for rows.Next() {
+		var kpi, source, first, last string
+		var n int
+		rows.Scan(&kpi, &source, &n, &first, &last)
+		series = append(series, map[string]interface{}{
+			"kpi": kpi, "source": source, "samples": n, "first": first, "last": last,
+		})
+	}
+	rows.Close()
+	for _, m := range series {
+		if latest := s.latestKPISamples(m["kpi"].(string), m["source"].(string)); len(latest) > 0 {
+			m["value"] = latest[len(latest)-1].Value
+		}
+	}
+	return series
+}
+
+// handleKPISeries: GET /v1/kpis/{kpi}?from=&to=&window=1h&agg=avg&source=
+func (s *Server) handleKPISeries(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	kpi := kpiName(strings.TrimPrefix(r.URL.Path, "/v1/kpis/"))
+	if kpi == "targets" {
+		s.handleKPITargets(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+	if kpi == "" {
+		http.Error(w, `{"error":"kpi required"}`, 400)
+		return
+	}
+	q := r.URL.Query()
+	to := time.Now().UTC()
+	if v := q.Get("to"); v != "" {
+		if to = parseFlexibleTime(v); to.IsZero() {
+			http.Error(w, `{"error":"bad to"}`, 400)
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if v := q.Get("from"); v != "" {
+		if from = parseFlexibleTime(v); from.IsZero() {
+			http.Error(w, `{"error":"bad from"}`, 400)
+			return
+		}
+	}
+	if q.Get("to") != "" && len(q.Get("to")) == len("2006-01-02") {
+		to = to.Add(24*time.Hour - time.Second) // whole day inclusive
+	}
+	window, err := parseKPIWindow(q.Get("window"))
+	if err != nil {
+		http.Error(w, `{"error":"bad window (e.g. raw, 15m, 1h, 1d, 7d)"}`, 400)
+		return
+	}
+	agg := q.Get("agg")
+	if agg == "" {
+		agg = "avg"
+	}
+	if !kpiAggregations[agg] {
+		http.Error(w, `{"error":"agg must be avg, min, max, sum, last or count"}`, 400)
+		return
+	}
+
+	bySource := s.kpiSamples(kpi, q.Get("source"), from, to)
+	sources := make([]string, 0, len(bySource))
+	for src := range bySource {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	series := []map[string]interface{}{}
+	for _, src := range sources {
+		series = append(series, map[string]interface{}{
+			"source": src,
+			"points": bucketKPI(bySource[src], window, agg),
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"kpi":     kpi,
+		"from":    from.Format(time.RFC3339),
+		"to":      to.Format(time.RFC3339),
+		"window":  q.Get("window"),
+		"agg":     agg,
+		"series":  series,
+		"targets": s.loadKPITargets("kpi=?", kpi),
+	})
+}
+
+// handleKPITargets: GET lists, POST creates/updates, DELETE ?id= removes.
+func (s *Server) handleKPITargets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, map[string]interface{}{"targets": s.loadKPITargets("")})
+	case "POST":
+		var t kpiTarget
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			http.Error(w, `{"error":"invalid JSON"}`, 400)
+			return
+		}
+		t.KPI = kpiName(t.KPI)
+		if t.KPI == "" || (t.Op != "above" && t.Op != "below") {
+			http.Error(w, `{"error":"kpi and op (above|below) required"}`, 400)
+			return
+		}
+		if _, err := parseKPIWindow(t.Window); err != nil {
+			http.Error(w, `{"error":"bad window"}`, 400)
+			return
+		}
+		if t.Agg == "" {
+			t.Agg = "avg"
+			if t.Window == "" {
+				t.Agg = "last"
+			}
+		}
+		if !kpiAggregations[t.Agg] {
+			http.Error(w, `{"error":"bad agg"}`, 400)
+			return
+		}
+		switch t.Severity {
+		case "info", "warning", "critical":
+		case "":
+			t.Severity = "warning"
+		default:
+			http.Error(w, `{"error":"severity must be info, warning or critical"}`, 400)
+			return
+		}
+		if t.ID == "" {
+			t.ID = fmt.Sprintf("kt-%d", time.Now().UnixNano())
+		}
+		// Changing a target re-arms it
+		s.db.Exec(`INSERT INTO kpi_targets (id, kpi, source, op, threshold, agg_window, agg, severity, note, created_at)
+			VALUES (?,?,?,?,?,?,?,?,?,?)
+			ON CONFLICT(id) DO UPDATE SET kpi=excluded.kpi, source=excluded.source, op=excluded.op,
+				threshold=excluded.threshold, agg_window=excluded.agg_window, agg=excluded.agg,
+				severity=excluded.severity, note=excluded.note, state='ok', breached_at=''`,
+			t.ID, t.KPI, t.Source, t.Op, t.Threshold, t.Window, t.Agg, t.Severity, t.Note,
+			time.Now().UTC().Format(time.RFC3339))
+		s.checkKPITargets(t.KPI)
+		if saved := s.loadKPITargets("id=?", t.ID); len(saved) == 1 {
+			writeJSON(w, saved[0])
+			return
+		}
+		http.Error(w, `{"error":"save failed"}`, 500)
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		res, _ := s.db.Exec(`DELETE FROM kpi_targets WHERE id=?`, id)
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error":"target not found"}`, 404)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "deleted": id})
+	default:
+		http.Error(w, `{"error":"GET, POST or DELETE"}`, 405)
+	}
+}
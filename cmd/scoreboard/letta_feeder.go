@@ -14,7 +14,8 @@ import (
 // LLM decides which blocks to update. This is a data pipeline, not an agent loop.
 //
 // Rate limited to 10 messages per 5-minute window. Watermarks survive restarts
-// via the letta_feeder_state table.
+// via the letta_feeder_state table. Memories whose provenance already shows
+// Letta (e.g. adopted on re-confirmation, memory_ttl.go) are not sent again.
 func (s *Server) lettaStateFeeder() {
 	if s.lettaAgentID == "" {
 		log.Println("[letta-feeder] No LETTA_AGENT_ID configured, feeder disabled")
@@ -46,15 +47,17 @@ func (s *Server) lettaStateFeeder() {
 
 		// ── 1. Approved memories → Letta ─────────────────────────────────
 		rows, err := s.db.Query(`
-			SELECT rowid, memory_text, COALESCE(correction, '') 
+			SELECT rowid, id, memory_text, COALESCE(correction, '') 
 			FROM memory_queue
 			WHERE status='approved' AND rowid > ?
+			AND NOT EXISTS (SELECT 1 FROM memory_provenance p
+				WHERE p.memory_id=memory_queue.id AND p.destination='letta' AND p.status IN ('written','assumed'))
 			ORDER BY rowid LIMIT 1`, lastApprovedRowID)
 		if err == nil {
 			for rows.Next() {
 				var rowid int64
-				var text, correction string
-				if rows.Scan(&rowid, &text, &correction) != nil {
+				var memID, text, correction string
+				if rows.Scan(&rowid, &memID, &text, &correction) != nil {
 					continue
 				}
 				// Use correction if operator edited, otherwise original
@@ -70,6 +73,7 @@ func (s *Server) lettaStateFeeder() {
 				}
 				msgCount++
 				lastApprovedRowID = rowid
+				s.recordProvenance(memID, "letta", s.lettaAgentID, fact)
 				log.Printf("[letta-feeder] Sent approved memory (rowid=%d, %d chars)", rowid, len(fact))
 				if msgCount >= 10 {
 					break
@@ -0,0 +1,875 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// LLM CLIENT — one path to every model: routing, retries, cache, budgets
+//
+// Memory extraction, the chat proxy and task drafts all call s.callLLM (or
+// s.streamLLM) with a task name instead of building their own request:
+//
+//   route     llm_routes picks backend + model per task, with an optional
+//             fallback backend (llmRouteDefaults until one is set)
+//   retry     retryable failures (network, 429, 5xx) are retried with backoff,
+//             then the fallback backend gets the same request. Requests with a
+//             session user go to a stateful agent session, so they are only
+//             retried when they never reached the backend (llmUndelivered)
+//   cache     responses are cached by a hash of model + messages for the
+//             route's cache_ttl_secs; requests carrying a session user never
+//             are, nor answers the request's Cacheable check turns down
+//   budget    tokens and cost per day are counted in llm_usage; once the
+//             day's budget (llm_budget, or the env default) is spent, calls
+//             fail with errLLMBudget until midnight operator time
+//
+// Backends (process-wide):
+//   gateway   the OpenClaw gateway (GATEWAY_URL), OpenAI-compatible
+//   fake      deterministic, no network: answers from LLM_FAKE_FIXTURES
+//             (JSON: prompt hash, task, or "contains:<text>" → content), else
+//             "[]" for prompts asking for a JSON array and an echo otherwise
+//   LLM_BACKENDS adds more: [{"name","type":"openai"|"fake","url","token",
+//             "fixtures"}]. LLM_FAKE=1 sends every task to fake.
+//
+// Cost uses LLM_PRICES, {"model": {"input": usd_per_mtok, "output": …}};
+// unpriced models count tokens only.
+//
+//   GET    /v1/llm/usage[?days=7]    usage per day/task/backend + budget
+//   GET    /v1/llm/budget            PUT {daily_tokens, daily_cost_usd} (admin)
+//   GET    /v1/llm/routes            PUT {task, backend, model, fallback,
+//                                         fallback_model, cache_ttl_secs}
+//   DELETE /v1/llm/cache[?task=]
+//
+// Each tenant has its own database, so usage and budgets are per tenant.
+// Tenants default to LLM_TENANT_DAILY_TOKENS; the operator is unlimited.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	llmBackendsConfig    = envOr("LLM_BACKENDS", "")
+	llmFake              = envOr("LLM_FAKE", "")
+	llmFakeFixtures      = envOr("LLM_FAKE_FIXTURES", "")
+	llmFallbackBackend   = envOr("LLM_FALLBACK_BACKEND", "")
+	llmPricesConfig      = envOr("LLM_PRICES", "")
+	llmDailyTokens       = envOr("LLM_DAILY_TOKENS", "0")
+	llmDailyCostUSD      = envOr("LLM_DAILY_COST_USD", "0")
+	llmTenantDailyTokens = envOr("LLM_TENANT_DAILY_TOKENS", "200000")
+	llmTenantDailyCost   = envOr("LLM_TENANT_DAILY_COST_USD", "0")
+)
+
+const llmRetries = 3
+
+var errLLMBudget = errors.New("daily LLM budget exhausted")
+
+type LLMRequest struct {
+	Task      string              // routing key: extraction, draft, chat, …
+	Messages  []map[string]string // role + content
+	MaxTokens int
+	User      string            // gateway session key; such requests are never cached
+	Headers   map[string]string // extra headers for HTTP backends
+	// Cacheable, when set, decides whether an answer is worth caching, so an
+	// answer the caller can't use is asked for again rather than replayed.
+	Cacheable func(content string) bool
+}
+
+type LLMResponse struct {
+	Content          string          `json:"content"`
+	Backend          string          `json:"backend"`
+	Model            string          `json:"model"`
+	PromptTokens     int             `json:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens"`
+	CostUSD          float64         `json:"cost_usd"`
+	Cached           bool            `json:"cached"`
+	Raw              json.RawMessage `json:"-"` // backend's own response body, when it has one
+}
+
+// LLMBackend is a chat-completion provider.
+type LLMBackend interface {
+	Name() string
+	// Complete runs one request. model "" leaves the choice to the backend.
+	Complete(req LLMRequest, model string) (*LLMResponse, error)
+	// Stream passes raw server-sent-event bytes to onChunk as they arrive and
+	// returns the assembled response. An error before the first chunk means
+	// nothing was passed on, so the caller may try elsewhere.
+	Stream(req LLMRequest, model string, onChunk func([]byte)) (*LLMResponse, error)
+}
+
+// llmHTTPError is a non-2xx answer from an HTTP backend.
+type llmHTTPError struct {
+	Backend string
+	Status  int
+	Body    string
+}
+
+func (e *llmHTTPError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Backend, e.Status, e.Body)
+}
+
+// llmRetryable reports whether trying the same backend again could help.
+func llmRetryable(err error) bool {
+	var he *llmHTTPError
+	if errors.As(err, &he) {
+		return he.Status == 429 || he.Status >= 500
+	}
+	return !errors.Is(err, errLLMBudget)
+}
+
+// llmUndelivered reports whether a failed request certainly never reached the
+// backend: the connection itself could not be made.
+func llmUndelivered(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+// estimateTokens is the usual four-characters-per-token guess, for backends
+// that don't report usage.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+func messagesText(messages []map[string]string) string {
+	var b strings.Builder
+	for _, m := range messages {
+		b.WriteString(m["role"])
+		b.WriteString(": ")
+		b.WriteString(m["content"])
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// llmPromptHash identifies a prompt for the cache and fake fixtures.
+func llmPromptHash(model string, maxTokens int, messages []map[string]string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", model, maxTokens, messagesText(messages))))
+	return hex.EncodeToString(sum[:])
+}
+
+// ─── OpenAI-compatible HTTP ─────────────────────────────────────────────────
+
+type openAILLMBackend struct {
+	name   string
+	url    string // base URL, /v1/chat/completions is appended
+	token  string
+	client *http.Client
+}
+
+func (b *openAILLMBackend) Name() string { return b.name }
+
+func (b *openAILLMBackend) request(req LLMRequest, model string, stream bool) (*http.Response, error) {
+	body := map[string]interface{}{
+		"messages":   req.Messages,
+		"max_tokens": req.MaxTokens,
+	}
+	if model != "" {
+		body["model"] = model
+	}
+	if req.User != "" {
+		body["user"] = req.User
+	}
+	if stream {
+		body["stream"] = true
+	}
+	bodyBytes, _ := json.Marshal(body)
+	httpReq, err := http.NewRequest("POST", b.url+"/v1/chat/completions", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if b.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		resp.Body.Close()
+		return nil, &llmHTTPError{Backend: b.name, Status: resp.StatusCode, Body: string(respBody)}
+	}
+	return resp, nil
+}
+
+func (b *openAILLMBackend) Complete(req LLMRequest, model string) (*LLMResponse, error) {
+	resp, err := b.request(req, model, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+
+	var chatResp struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &chatResp); err != nil {
+		return nil, err
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from LLM")
+	}
+	out := &LLMResponse{
+		Content:          chatResp.Choices[0].Message.Content,
+		Backend:          b.name,
+		Model:            model,
+		PromptTokens:     chatResp.Usage.PromptTokens,
+		CompletionTokens: chatResp.Usage.CompletionTokens,
+		Raw:              respBody,
+	}
+	if out.Model == "" {
+		out.Model = chatResp.Model
+	}
+	if out.PromptTokens == 0 && out.CompletionTokens == 0 {
+		out.PromptTokens = estimateTokens(messagesText(req.Messages))
+		out.CompletionTokens = estimateTokens(out.Content)
+	}
+	return out, nil
+}
+
+func (b *openAILLMBackend) Stream(req LLMRequest, model string, onChunk func([]byte)) (*LLMResponse, error) {
+	resp, err := b.request(req, model, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			onChunk(line)
+			if payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: ")); ok && string(payload) != "[DONE]" {
+				var sse struct {
+					Choices []struct {
+						Delta struct {
+							Content string `json:"content"`
+						} `json:"delta"`
+					} `json:"choices"`
+				}
+				if json.Unmarshal(payload, &sse) == nil && len(sse.Choices) > 0 {
+					content.WriteString(sse.Choices[0].Delta.Content)
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	return &LLMResponse{
+		Content:          content.String(),
+		Backend:          b.name,
+		Model:            model,
+		PromptTokens:     estimateTokens(messagesText(req.Messages)),
+		CompletionTokens: estimateTokens(content.String()),
+	}, nil
+}
+
+// ─── Fake ───────────────────────────────────────────────────────────────────
+
+type fakeLLMBackend struct {
+	name     string
+	fixtures map[string]string // prompt hash (model ""), task, or "contains:<text>" → content
+}
+
+func newFakeLLMBackend(name, fixturesPath string) *fakeLLMBackend {
+	b := &fakeLLMBackend{name: name, fixtures: map[string]string{}}
+	if fixturesPath == "" {
+		return b
+	}
+	data, err := os.ReadFile(fixturesPath)
+	if err == nil {
+		err = json.Unmarshal(data, &b.fixtures)
+	}
+	if err != nil {
+		log.Printf("[llm] fake fixtures %s: %v", fixturesPath, err)
+	}
+	return b
+}
+
+func (b *fakeLLMBackend) Name() string { return b.name }
+
+func (b *fakeLLMBackend) Complete(req LLMRequest, model string) (*LLMResponse, error) {
+	hash := llmPromptHash("", req.MaxTokens, req.Messages)
+	last := ""
+	if len(req.Messages) > 0 {
+		last = req.Messages[len(req.Messages)-1]["content"]
+	}
+	content, ok := b.fixtures[hash]
+	if !ok {
+		content, ok = b.fixtures[req.Task]
+	}
+	if !ok {
+		// The longest "contains:" key found in the prompt, so fixtures survive
+		// prompt wording changes (the extraction eval relies on this). Equal
+		// lengths go to the smaller key, so the answer never depends on map order.
+		best := ""
+		for key := range b.fixtures {
+			text, isContains := strings.CutPrefix(key, "contains:")
+			if isContains && (len(text) > len(best) || len(text) == len(best) && ok && text < best) &&
+				strings.Contains(last, text) {
+				best, content, ok = text, b.fixtures[key], true
+			}
+		}
+	}
+	if !ok {
+		if strings.Contains(last, "JSON array") {
+			content = "[]"
+		} else {
+			content = fmt.Sprintf("[fake %s %s] %s", req.Task, hash[:12], truncateRunes(strings.Join(strings.Fields(last), " "), 120))
+		}
+	}
+	return &LLMResponse{
+		Content:          content,
+		Backend:          b.name,
+		Model:            model,
+		PromptTokens:     estimateTokens(messagesText(req.Messages)),
+		CompletionTokens: estimateTokens(content),
+	}, nil
+}
+
+func (b *fakeLLMBackend) Stream(req LLMRequest, model string, onChunk func([]byte)) (*LLMResponse, error) {
+	resp, _ := b.Complete(req, model)
+	for _, word := range strings.SplitAfter(resp.Content, " ") {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"delta": map[string]string{"content": word}}},
+		})
+		onChunk([]byte("data: " + string(payload) + "\n\n"))
+	}
+	onChunk([]byte("data: [DONE]\n\n"))
+	return resp, nil
+}
+
+// ─── Registry ───────────────────────────────────────────────────────────────
+
+var (
+	llmBackendsOnce sync.Once
+	llmBackendsByID map[string]LLMBackend
+	llmPrices       map[string]struct {
+		Input  float64 `json:"input"`
+		Output float64 `json:"output"`
+	}
+)
+
+// llmBackends returns the configured backends by name.
+func llmBackends() map[string]LLMBackend {
+	llmBackendsOnce.Do(func() {
+		gwToken := gatewayToken
+		if gwToken == "" {
+			gwToken = authToken // fallback to scoreboard token
+		}
+		llmBackendsByID = map[string]LLMBackend{
+			"gateway": &openAILLMBackend{
+				name:   "gateway",
+				url:    strings.TrimRight(envOr("GATEWAY_URL", "http://127.0.0.1:18789"), "/"),
+				token:  gwToken,
+				client: &http.Client{Timeout: 120 * time.Second},
+			},
+			"fake": newFakeLLMBackend("fake", llmFakeFixtures),
+		}
+		if llmBackendsConfig != "" {
+			var extra []struct {
+				Name     string `json:"name"`
+				Type     string `json:"type"`
+				URL      string `json:"url"`
+				Token    string `json:"token"`
+				Fixtures string `json:"fixtures"`
+			}
+			if err := json.Unmarshal([]byte(llmBackendsConfig), &extra); err != nil {
+				log.Printf("[llm] LLM_BACKENDS: %v", err)
+			}
+			for _, c := range extra {
+				switch {
+				case c.Name == "":
+					log.Printf("[llm] LLM_BACKENDS: entry without a name ignored")
+				case c.Type == "fake":
+					llmBackendsByID[c.Name] = newFakeLLMBackend(c.Name, c.Fixtures)
+				case c.Type == "openai" || c.Type == "":
+					llmBackendsByID[c.Name] = &openAILLMBackend{
+						name:   c.Name,
+						url:    strings.TrimRight(c.URL, "/"),
+						token:  c.Token,
+						client: &http.Client{Timeout: 120 * time.Second},
+					}
+				default:
+					log.Printf("[llm] LLM_BACKENDS: unknown type %q for %s", c.Type, c.Name)
+				}
+			}
+		}
+		if llmPricesConfig != "" {
+			if err := json.Unmarshal([]byte(llmPricesConfig), &llmPrices); err != nil {
+				log.Printf("[llm] LLM_PRICES: %v", err)
+			}
+		}
+	})
+	return llmBackendsByID
+}
+
+func llmCost(model string, promptTokens, completionTokens int) float64 {
+	llmBackends()
+	p, ok := llmPrices[model]
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
+
+// ─── Routes, budget, usage ──────────────────────────────────────────────────
+
+type llmRoute struct {
+	Task          string `json:"task"`
+	Backend       string `json:"backend"`
+	Model         string `json:"model"`
+	Fallback      string `json:"fallback,omitempty"`
+	FallbackModel string `json:"fallback_model,omitempty"`
+	CacheTTL      int    `json:"cache_ttl_secs"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
+}
+
+// llmRouteDefaults apply until a route is set for the task.
+var llmRouteDefaults = map[string]llmRoute{
+	"extraction": {Backend: "gateway", Model: envOr("EXTRACTION_MODEL", "kimi-coding/k2p5"), CacheTTL: 30 * 86400},
+	"draft":      {Backend: "gateway", Model: envOr("DRAFT_MODEL", "kimi-coding/k2p5"), CacheTTL: 86400},
+	"chat":       {Backend: "gateway"}, // the agent picks its model; sessions make caching meaningless
+}
+
+type llmBudget struct {
+	DailyTokens  int     `json:"daily_tokens"`   // 0 = unlimited
+	DailyCostUSD float64 `json:"daily_cost_usd"` // 0 = unlimited
+	UpdatedAt    string  `json:"updated_at,omitempty"`
+}
+
+func (s *Server) initLLM() {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS llm_routes (
+			task TEXT PRIMARY KEY,
+			backend TEXT NOT NULL,
+			model TEXT DEFAULT '',
+			fallback TEXT DEFAULT '',
+			fallback_model TEXT DEFAULT '',
+			cache_ttl_secs INTEGER DEFAULT 0,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS llm_budget (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			daily_tokens INTEGER DEFAULT 0,
+			daily_cost_usd REAL DEFAULT 0,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS llm_usage (
+			day TEXT NOT NULL,
+			task TEXT NOT NULL,
+			backend TEXT NOT NULL,
+			model TEXT NOT NULL,
+			requests INTEGER DEFAULT 0,
+			cached INTEGER DEFAULT 0,
+			failures INTEGER DEFAULT 0,
+			prompt_tokens INTEGER DEFAULT 0,
+			completion_tokens INTEGER DEFAULT 0,
+			cost_usd REAL DEFAULT 0,
+			PRIMARY KEY (day, task, backend, model)
+		)`,
+		`CREATE TABLE IF NOT EXISTS llm_cache (
+			key TEXT PRIMARY KEY,
+			task TEXT NOT NULL,
+			backend TEXT NOT NULL,
+			model TEXT NOT NULL,
+			content TEXT NOT NULL,
+			prompt_tokens INTEGER DEFAULT 0,
+			completion_tokens INTEGER DEFAULT 0,
+			created_at TEXT NOT NULL,
+			expires_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_llm_cache_expires ON llm_cache(expires_at)`,
+	} {
+		s.db.Exec(stmt)
+	}
+}
+
+func (s *Server) llmRoute(task string) llmRoute {
+	rt := llmRoute{Task: task}
+	err := s.db.QueryRow(`SELECT backend, model, fallback, fallback_model, cache_ttl_secs, updated_at FROM llm_routes WHERE task=?`, task).
+		Scan(&rt.Backend, &rt.Model, &rt.Fallback, &rt.FallbackModel, &rt.CacheTTL, &rt.UpdatedAt)
+	if err != nil {
+		rt = llmRouteDefaults[task]
+		rt.Task = task
+		if rt.Backend == "" {
+			rt.Backend = "gateway"
+		}
+		if rt.Fallback == "" {
+			rt.Fallback = llmFallbackBackend
+		}
+	}
+	if llmFake == "1" || s.dryRun {
+		rt.Backend, rt.Fallback = "fake", ""
+	}
+	return rt
+}
+
+func (s *Server) llmBudget() llmBudget {
+	var b llmBudget
+	if s.db.QueryRow(`SELECT daily_tokens, daily_cost_usd, updated_at FROM llm_budget WHERE id=1`).
+		Scan(&b.DailyTokens, &b.DailyCostUSD, &b.UpdatedAt) == nil {
+		return b
+	}
+	tokens, cost := llmDailyTokens, llmDailyCostUSD
+	if s.tenantID != "" {
+		tokens, cost = llmTenantDailyTokens, llmTenantDailyCost
+	}
+	b.DailyTokens, _ = strconv.Atoi(tokens)
+	b.DailyCostUSD, _ = strconv.ParseFloat(cost, 64)
+	return b
+}
+
+// llmSpentToday returns today's tokens and cost, cache hits excluded.
+func (s *Server) llmSpentToday() (tokens int, cost float64) {
+	s.db.QueryRow(`SELECT COALESCE(SUM(prompt_tokens + completion_tokens),0), COALESCE(SUM(cost_usd),0)
+		FROM llm_usage WHERE day=?`, operatorToday()).Scan(&tokens, &cost)
+	return tokens, cost
+}
+
+func (s *Server) llmOverBudget() bool {
+	b := s.llmBudget()
+	if b.DailyTokens <= 0 && b.DailyCostUSD <= 0 {
+		return false
+	}
+	tokens, cost := s.llmSpentToday()
+	return (b.DailyTokens > 0 && tokens >= b.DailyTokens) || (b.DailyCostUSD > 0 && cost >= b.DailyCostUSD)
+}
+
+func (s *Server) recordLLMUsage(task, backend, model string, resp *LLMResponse, failed bool) {
+	var cached, failures, prompt, completion int
+	var cost float64
+	switch {
+	case failed:
+		failures = 1
+	case resp.Cached:
+		cached = 1
+	default:
+		prompt, completion, cost = resp.PromptTokens, resp.CompletionTokens, resp.CostUSD
+	}
+	s.db.Exec(`INSERT INTO llm_usage (day, task, backend, model, requests, cached, failures, prompt_tokens, completion_tokens, cost_usd)
+		VALUES (?,?,?,?,1,?,?,?,?,?)
+		ON CONFLICT(day, task, backend, model) DO UPDATE SET requests=requests+1, cached=cached+excluded.cached,
+		failures=failures+excluded.failures, prompt_tokens=prompt_tokens+excluded.prompt_tokens,
+		completion_tokens=completion_tokens+excluded.completion_tokens, cost_usd=cost_usd+excluded.cost_usd`,
+		operatorToday(), task, backend, model, cached, failures, prompt, completion, cost)
+}
+
+// llmChain is the route's backends in the order they are tried.
+func llmChain(rt llmRoute) []struct{ backend, model string } {
+	chain := []struct{ backend, model string }{{rt.Backend, rt.Model}}
+	if rt.Fallback != "" && rt.Fallback != rt.Backend {
+		model := rt.FallbackModel
+		if model == "" {
+			model = rt.Model
+		}
+		chain = append(chain, struct{ backend, model string }{rt.Fallback, model})
+	}
+	return chain
+}
+
+// callLLM runs a request through the task's route.
+func (s *Server) callLLM(req LLMRequest) (*LLMResponse, error) {
+	if req.MaxTokens == 0 {
+		req.MaxTokens = 1024
+	}
+	rt := s.llmRoute(req.Task)
+
+	key := ""
+	if rt.CacheTTL > 0 && req.User == "" {
+		key = llmPromptHash(rt.Backend+"/"+rt.Model, req.MaxTokens, req.Messages)
+		resp := &LLMResponse{Cached: true}
+		err := s.db.QueryRow(`SELECT backend, model, content, prompt_tokens, completion_tokens FROM llm_cache
+			WHERE key=? AND expires_at > ?`, key, time.Now().UTC().Format(time.RFC3339)).
+			Scan(&resp.Backend, &resp.Model, &resp.Content, &resp.PromptTokens, &resp.CompletionTokens)
+		if err == nil {
+			s.recordLLMUsage(req.Task, resp.Backend, resp.Model, resp, false)
+			return resp, nil
+		}
+	}
+
+	if s.llmOverBudget() {
+		return nil, errLLMBudget
+	}
+
+	var lastErr error
+	for i, step := range llmChain(rt) {
+		backend, ok := llmBackends()[step.backend]
+		if !ok {
+			lastErr = fmt.Errorf("unknown LLM backend %q", step.backend)
+			continue
+		}
+		if i > 0 {
+			log.Printf("[llm] %s: falling back to %s after: %v", req.Task, step.backend, lastErr)
+		}
+		for attempt := 0; attempt < llmRetries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(500<<(attempt-1)) * time.Millisecond)
+			}
+			resp, err := backend.Complete(req, step.model)
+			if err != nil {
+				lastErr = err
+				s.recordLLMUsage(req.Task, step.backend, step.model, nil, true)
+				if req.User != "" && !llmUndelivered(err) {
+					return nil, err // the session may already have this turn
+				}
+				if !llmRetryable(err) {
+					break
+				}
+				continue
+			}
+			resp.CostUSD = llmCost(resp.Model, resp.PromptTokens, resp.CompletionTokens)
+			s.recordLLMUsage(req.Task, step.backend, step.model, resp, false)
+			if key != "" && (req.Cacheable == nil || req.Cacheable(resp.Content)) {
+				now := time.Now().UTC()
+				s.db.Exec(`DELETE FROM llm_cache WHERE expires_at <= ?`, now.Format(time.RFC3339))
+				s.db.Exec(`INSERT OR REPLACE INTO llm_cache (key, task, backend, model, content, prompt_tokens, completion_tokens, created_at, expires_at)
+					VALUES (?,?,?,?,?,?,?,?,?)`, key, req.Task, resp.Backend, resp.Model, resp.Content, resp.PromptTokens, resp.CompletionTokens,
+					now.Format(time.RFC3339), now.Add(time.Duration(rt.CacheTTL)*time.Second).Format(time.RFC3339))
+			}
+			return resp, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// streamLLM is callLLM for server-sent events. Nothing is cached; retries and
+// fallback only happen before the first chunk has been passed on.
+func (s *Server) streamLLM(req LLMRequest, onChunk func([]byte)) (*LLMResponse, error) {
+	if req.MaxTokens == 0 {
+		req.MaxTokens = 1024
+	}
+	if s.llmOverBudget() {
+		return nil, errLLMBudget
+	}
+	rt := s.llmRoute(req.Task)
+
+	var lastErr error
+	started := false
+	forward := func(chunk []byte) {
+		started = true
+		onChunk(chunk)
+	}
+	for i, step := range llmChain(rt) {
+		backend, ok := llmBackends()[step.backend]
+		if !ok {
+			lastErr = fmt.Errorf("unknown LLM backend %q", step.backend)
+			continue
+		}
+		if i > 0 {
+			log.Printf("[llm] %s: falling back to %s after: %v", req.Task, step.backend, lastErr)
+		}
+		for attempt := 0; attempt < llmRetries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(500<<(attempt-1)) * time.Millisecond)
+			}
+			resp, err := backend.Stream(req, step.model, forward)
+			if err != nil {
+				lastErr = err
+				s.recordLLMUsage(req.Task, step.backend, step.model, nil, true)
+				if started || (req.User != "" && !llmUndelivered(err)) {
+					return nil, err
+				}
+				if !llmRetryable(err) {
+					break
+				}
+				continue
+			}
+			resp.CostUSD = llmCost(resp.Model, resp.PromptTokens, resp.CompletionTokens)
+			s.recordLLMUsage(req.Task, step.backend, step.model, resp, false)
+			return resp, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// ─── API ────────────────────────────────────────────────────────────────────
+
+// GET /v1/llm/usage[?days=7]
+func (s *Server) handleLLMUsage(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 || days > 90 {
+		days = 7
+	}
+	since := operatorNow().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+
+	type usageRow struct {
+		Day              string  `json:"day"`
+		Task             string  `json:"task"`
+		Backend          string  `json:"backend"`
+		Model            string  `json:"model"`
+		Requests         int     `json:"requests"`
+		Cached           int     `json:"cached"`
+		Failures         int     `json:"failures"`
+		PromptTokens     int     `json:"prompt_tokens"`
+		CompletionTokens int     `json:"completion_tokens"`
+		CostUSD          float64 `json:"cost_usd"`
+	}
+	usage := []usageRow{}
+	rows, err := s.db.Query(`SELECT day, task, backend, model, requests, cached, failures, prompt_tokens, completion_tokens, cost_usd
+		FROM llm_usage WHERE day >= ? ORDER BY day DESC, task, backend`, since)
+	if err == nil {
+		for rows.Next() {
+			var u usageRow
+			rows.Scan(&u.Day, &u.Task, &u.Backend, &u.Model, &u.Requests, &u.Cached, &u.Failures, &u.PromptTokens, &u.CompletionTokens, &u.CostUSD)
+			usage = append(usage, u)
+		}
+		rows.Close()
+	}
+	tokens, cost := s.llmSpentToday()
+	writeJSON(w, map[string]interface{}{
+		"usage":  usage,
+		"budget": s.llmBudget(),
+		"today": map[string]interface{}{
+			"day":         operatorToday(),
+			"tokens":      tokens,
+			"cost_usd":    cost,
+			"over_budget": s.llmOverBudget(),
+		},
+	})
+}
+
+// GET/PUT /v1/llm/budget
+func (s *Server) handleLLMBudget(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	switch r.Method {
+	case "GET":
+		writeJSON(w, s.llmBudget())
+	case "PUT":
+		if resolveAuth(r).TierLevel < 99 {
+			http.Error(w, `{"error":"admin only"}`, 403)
+			return
+		}
+		var body llmBudget
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.DailyTokens < 0 || body.DailyCostUSD < 0 {
+			http.Error(w, `{"error":"daily_tokens and daily_cost_usd must be >= 0 (0 = unlimited)"}`, 400)
+			return
+		}
+		s.db.Exec(`INSERT INTO llm_budget (id, daily_tokens, daily_cost_usd, updated_at) VALUES (1,?,?,?)
+			ON CONFLICT(id) DO UPDATE SET daily_tokens=excluded.daily_tokens, daily_cost_usd=excluded.daily_cost_usd,
+			updated_at=excluded.updated_at`, body.DailyTokens, body.DailyCostUSD, time.Now().UTC().Format(time.RFC3339))
+		writeJSON(w, map[string]interface{}{"ok": true, "budget": s.llmBudget()})
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, 405)
+	}
+}
+
+// GET/PUT /v1/llm/routes
+func (s *Server) handleLLMRoutes(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	switch r.Method {
+	case "GET":
+		tasks := map[string]bool{}
+		for task := range llmRouteDefaults {
+			tasks[task] = true
+		}
+		if rows, err := s.db.Query(`SELECT task FROM llm_routes`); err == nil {
+			for rows.Next() {
+				var task string
+				rows.Scan(&task)
+				tasks[task] = true
+			}
+			rows.Close()
+		}
+		routes := []llmRoute{}
+		for task := range tasks {
+			routes = append(routes, s.llmRoute(task))
+		}
+		sort.Slice(routes, func(i, j int) bool { return routes[i].Task < routes[j].Task })
+		backends := []string{}
+		for name := range llmBackends() {
+			backends = append(backends, name)
+		}
+		sort.Strings(backends)
+		writeJSON(w, map[string]interface{}{"routes": routes, "backends": backends, "fake": llmFake == "1" || s.dryRun})
+	case "PUT":
+		var body llmRoute
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Task == "" {
+			http.Error(w, `{"error":"task required"}`, 400)
+			return
+		}
+		for _, name := range []string{body.Backend, body.Fallback} {
+			if _, ok := llmBackends()[name]; name != "" && !ok {
+				http.Error(w, fmt.Sprintf(`{"error":%q}`, "unknown backend: "+name), 400)
+				return
+			}
+		}
+		if body.Backend == "" || body.CacheTTL < 0 {
+			http.Error(w, `{"error":"backend required, cache_ttl_secs >= 0"}`, 400)
+			return
+		}
+		s.db.Exec(`INSERT OR REPLACE INTO llm_routes (task, backend, model, fallback, fallback_model, cache_ttl_secs, updated_at)
+			VALUES (?,?,?,?,?,?,?)`, body.Task, body.Backend, body.Model, body.Fallback, body.FallbackModel, body.CacheTTL,
+			time.Now().UTC().Format(time.RFC3339))
+		writeJSON(w, map[string]interface{}{"ok": true, "route": s.llmRoute(body.Task)})
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, 405)
+	}
+}
+
+// DELETE /v1/llm/cache[?task=]
+func (s *Server) handleLLMCache(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	if r.Method != "DELETE" {
+		http.Error(w, `{"error":"DELETE only"}`, 405)
+		return
+	}
+	var res sql.Result
+	var err error
+	if task := r.URL.Query().Get("task"); task != "" {
+		res, err = s.db.Exec(`DELETE FROM llm_cache WHERE task=?`, task)
+	} else {
+		res, err = s.db.Exec(`DELETE FROM llm_cache`)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 500)
+		return
+	}
+	n, _ := res.RowsAffected()
+	writeJSON(w, map[string]interface{}{"ok": true, "cleared": n})
+}
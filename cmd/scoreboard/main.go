@@ -2,8 +2,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
@@ -14,6 +12,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -24,7 +23,6 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
-	"syscall"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -255,6 +253,8 @@ type Server struct {
 	pairing       *PairingEngine // Living profile engine (pairing.go)
 	lettaURL      string         // Letta server (default: http://localhost:8283)
 	lettaAgentID  string         // Letta agent for state feeder
+	dryRun        bool           // sandbox for integration test polls (integration_dryrun.go)
+	memory        MemoryStore    // long-term memory backend (memory_store.go)
 }
 
 // ─── Tenant Manager ─────────────────────────────────────────────────────────
@@ -332,10 +332,11 @@ func (tm *TenantManager) GetOrCreate(tenantID string) (*Server, error) {
 	s.loadSeason()
 
 	// Each tenant gets their own pairing engine with isolated profile
-	// No Letta/Mem0/Gateway by default — tenant configures their own memory stack
+	// No Letta/Mem0/Gateway by default — profile syncs to the tenant's memory
+	// store (built-in SQLite unless configured, memory_store.go)
 	profilePath := tenantDir + "/profile.json"
 	os.MkdirAll(tenantDir, 0750)
-	s.pairing = NewPairingEngine(profilePath, db, PairingConfig{})
+	s.pairing = NewPairingEngine(profilePath, db, PairingConfig{Memory: s.memoryStore})
 	s.pairing.Start()
 
 	tm.tenants[tenantID] = s
@@ -395,6 +396,12 @@ func (tm *TenantManager) List() []TenantInfo {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rotate-key" {
+		os.Exit(runRotateKey(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "eval-extraction" {
+		os.Exit(runExtractionEval(os.Args[2:]))
+	}
 	os.MkdirAll("/data/wirebot/scoreboard", 0750)
 
 	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL")
@@ -409,6 +416,9 @@ func main() {
 		lettaAgentID: envOr("LETTA_AGENT_ID", "agent-82610d14-ec65-4d10-9ec2-8c479848cea9"),
 	}
 	s.initDB()
+	if err := s.verifyCredentialKeys(); err != nil {
+		log.Fatalf("Credentials: %v", err)
+	}
 	s.loadSeason()
 
 	// Initialize and start the Pairing Engine
@@ -461,11 +471,29 @@ func main() {
 	// Memory Queue — approve/reject inferred memories from docs
 	mux.HandleFunc("/v1/memory/queue", s.auth(s.handleMemoryQueue))
 	mux.HandleFunc("/v1/memory/queue/", s.auth(s.handleMemoryQueueAction))
+	mux.HandleFunc("/v1/memory/queue/bulk", s.auth(s.handleMemoryBulkReview))
+	mux.HandleFunc("/v1/memory/rules", s.auth(s.handleMemoryReviewRules))
+	mux.HandleFunc("/v1/memory/rules/", s.auth(s.handleMemoryReviewRules))
+	mux.HandleFunc("/v1/memory/review-log", s.auth(s.handleMemoryReviewLog))
+	mux.HandleFunc("/v1/memory/review-log/revert", s.auth(s.handleMemoryReviewRevert))
 	mux.HandleFunc("/v1/memory/conflicts", s.auth(s.handleMemoryConflicts))
+	mux.HandleFunc("/v1/memory/facts", s.auth(s.handleMemoryFacts))
+	mux.HandleFunc("/v1/memory/ttl", s.auth(s.handleMemoryTTL))
+	mux.HandleFunc("/v1/memory/backend", s.auth(s.handleMemoryBackend))
+	mux.HandleFunc("/v1/memory/store", s.auth(s.handleMemoryStore))
+	mux.HandleFunc("/v1/memory/store/", s.auth(s.handleMemoryStore))
 	mux.HandleFunc("/v1/memory/grid", s.auth(s.handleMemoryGrid))
 	mux.HandleFunc("/v1/memory/item/", s.auth(s.handleMemoryItem))
 	mux.HandleFunc("/v1/memory/extract-vault", s.auth(s.handleMemoryExtractVault))
+	mux.HandleFunc("/v1/memory/jobs", s.auth(s.handleMemoryJobs))
+	mux.HandleFunc("/v1/memory/jobs/", s.auth(s.handleMemoryJobs))
+	mux.HandleFunc("/v1/memory/redaction", s.auth(s.handleMemoryRedaction))
+	mux.HandleFunc("/v1/memory/redaction/", s.auth(s.handleMemoryRedaction))
 	mux.HandleFunc("/v1/memory/extract-conversation", s.auth(s.handleMemoryExtractConversation))
+	mux.HandleFunc("/v1/llm/usage", s.auth(s.handleLLMUsage))
+	mux.HandleFunc("/v1/llm/budget", s.auth(s.handleLLMBudget))
+	mux.HandleFunc("/v1/llm/routes", s.auth(s.handleLLMRoutes))
+	mux.HandleFunc("/v1/llm/cache", s.auth(s.handleLLMCache))
 	mux.HandleFunc("/v1/system/health", s.auth(s.handleSystemHealth))
 	mux.HandleFunc("/v1/system/restart", s.auth(s.handleSystemRestart))
 	mux.HandleFunc("/v1/alerts", s.authMember(s.handleAlerts))
@@ -491,7 +519,21 @@ func main() {
 	// Webhook receivers (use their own verification, not bearer auth)
 	mux.HandleFunc("/v1/webhooks/github", s.auth(s.handleGitHubWebhook))
 	mux.HandleFunc("/v1/webhooks/stripe", s.handleStripeWebhook) // Stripe signs its own webhooks
+	mux.HandleFunc("/v1/webhooks/paddle", s.handlePaddleWebhook)
+	mux.HandleFunc("/v1/webhooks/lemonsqueezy", s.handleLemonSqueezyWebhook)
+	mux.HandleFunc("/v1/webhooks/gumroad", s.handleGumroadWebhook) // ?secret= shared secret (pings are unsigned)
+	mux.HandleFunc("/v1/webhooks/shopify", s.handleShopifyWebhook)
 	mux.HandleFunc("/v1/financial/snapshot", s.auth(s.handleFinancialSnapshot))
+	mux.HandleFunc("/v1/financial/mrr", s.auth(s.handleMRR))
+	mux.HandleFunc("/v1/financial/fx", s.auth(s.handleFX))
+	mux.HandleFunc("/v1/financial/pnl", s.auth(s.handlePnL))
+	mux.HandleFunc("/v1/financial/runway", s.auth(s.handleRunway))
+	mux.HandleFunc("/v1/financial/expenses", s.auth(s.handleExpenses))
+	mux.HandleFunc("/v1/financial/receivables", s.auth(s.handleReceivables))
+	mux.HandleFunc("/v1/distribution/outcomes", s.auth(s.handleDistributionOutcomes))
+	mux.HandleFunc("/v1/kpis", s.auth(s.handleKPIs))
+	mux.HandleFunc("/v1/kpis/", s.auth(s.handleKPISeries))
+	mux.HandleFunc("/v1/calendar", s.authMember(s.handleCalendar))
 
 	// Discord audit & training
 	mux.HandleFunc("/v1/discord/interaction", s.auth(s.handleDiscordInteraction))
@@ -502,6 +544,8 @@ func main() {
 	// Transaction reconciliation
 	mux.HandleFunc("/v1/reconcile", s.auth(s.handleReconcile))
 	mux.HandleFunc("/v1/reconcile/test-transactions", s.auth(s.handleTestTransactions))
+	mux.HandleFunc("/v1/reconcile/report", s.auth(s.handleReconcileReport))
+	mux.HandleFunc("/v1/reconcile/", s.auth(s.handleReconcileCluster))
 
 	// Plaid (bank account connections)
 	mux.HandleFunc("/v1/plaid/link-token", s.authMember(s.handlePlaidLinkToken))
@@ -515,6 +559,7 @@ func main() {
 	mux.HandleFunc("/v1/oauth/hubspot/authorize", s.authMember(s.handleOAuthStart))
 	mux.HandleFunc("/v1/oauth/dropbox/authorize", s.authMember(s.handleOAuthStart))
 	mux.HandleFunc("/v1/oauth/callback", s.handleOAuthCallback) // Provider redirects back here
+	mux.HandleFunc("/v1/oauth/tokens", s.authMember(s.handleOAuthTokens))
 
 	// Checklist data for Dashboard view
 	mux.HandleFunc("/v1/checklist", s.authMember(s.handleChecklist))
@@ -664,6 +709,7 @@ func main() {
 
 	go s.lettaStateFeeder()
 	go s.lettaAlertChecker()
+	go s.gitDiscoveryWorker()
 
 	log.Printf("Scoreboard listening on %s (multi-tenant enabled)", listenAddr)
 	log.Fatal(http.ListenAndServe(listenAddr, topHandler))
@@ -688,6 +734,11 @@ func buildTenantMux(s *Server) *http.ServeMux {
 	mux.HandleFunc("/v1/projects", s.handleProjects)
 	mux.HandleFunc("/v1/projects/", s.auth(s.handleProjectAction))
 	mux.HandleFunc("/v1/lock", s.auth(s.handleLock))
+	mux.HandleFunc("/v1/memory/backend", s.auth(s.handleMemoryBackend))
+	mux.HandleFunc("/v1/memory/store", s.auth(s.handleMemoryStore))
+	mux.HandleFunc("/v1/memory/store/", s.auth(s.handleMemoryStore))
+	mux.HandleFunc("/v1/llm/usage", s.auth(s.handleLLMUsage))
+	mux.HandleFunc("/v1/llm/budget", s.auth(s.handleLLMBudget))
 	mux.HandleFunc("/health", s.handleHealth)
 	return mux
 }
@@ -900,6 +951,29 @@ func (s *Server) initDB() {
 	// Init alerts table (Letta state → deterministic alerts)
 	s.initAlerts()
 
+	// Init calendar table (ICS/CalDAV schedule + rest days)
+	s.initCalendar()
+
+	// Init git discovery watermarks (built-in wb-discover)
+	s.initGitDiscovery()
+	s.initSubscriptions()
+	s.initFX()
+	s.initExpenses()
+	s.initReceivables()
+	s.initDistributionOutcomes()
+	s.initKPIs()
+	s.initCredentialKeys()
+	s.initOAuthTokens()
+	s.initMemoryFacts()
+	s.initMemoryProvenance()
+	s.initMemoryTTL()
+	s.initMemoryStore()
+	s.initMemoryDedup()
+	s.initMemoryReview()
+	s.initMemoryJobs()
+	s.initLLM()
+	s.initRedaction()
+
 	// Seed default season
 	var count int
 	s.db.QueryRow("SELECT COUNT(*) FROM seasons").Scan(&count)
@@ -2698,7 +2772,11 @@ func (s *Server) handleStripeWebhook(w http.ResponseWriter, r *http.Request) {
 	var evtType, lane, title string
 	var amount float64
 	var scoreDelta int
-	metadata := map[string]interface{}{"stripe_event": evtTypeStripe, "currency": "usd", "stripe_account": stripeAccount}
+	currency, _ := obj["currency"].(string)
+	if currency == "" {
+		currency = "usd"
+	}
+	metadata := map[string]interface{}{"stripe_event": evtTypeStripe, "currency": currency, "stripe_account": stripeAccount}
 
 	switch evtTypeStripe {
 	case "charge.succeeded":
@@ -2763,18 +2841,21 @@ func (s *Server) handleStripeWebhook(w http.ResponseWriter, r *http.Request) {
 		metadata["plan_amount"] = planAmt
 		metadata["interval"] = interval
 		scoreDelta = 8
+		s.applyStripeSubscriptionEvent(payload, obj)
 
 	case "customer.subscription.deleted":
 		evtType = "SUBSCRIPTION_CANCELED"
 		lane = "revenue"
 		title = "📉 Subscription canceled"
 		scoreDelta = -3
+		s.applyStripeSubscriptionEvent(payload, obj)
 
 	case "customer.subscription.updated":
 		evtType = "SUBSCRIPTION_UPDATED"
 		lane = "revenue"
 		title = "🔄 Subscription updated"
 		scoreDelta = 0
+		s.applyStripeSubscriptionEvent(payload, obj)
 
 	default:
 		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "skipped": evtTypeStripe})
@@ -2864,7 +2945,8 @@ func verifyStripeSignature(payload []byte, sigHeader, secret string) bool {
 }
 
 // ─── Financial Snapshot (Operator + Wirebot reasoning) ──────────────────────
-// Returns real-time financial state from Stripe for AI reasoning.
+// Returns real-time financial state across every revenue source for AI
+// reasoning (financial_snapshot.go).
 
 func (s *Server) handleFinancialSnapshot(w http.ResponseWriter, r *http.Request) {
 	cors(w)
@@ -2873,60 +2955,7 @@ func (s *Server) handleFinancialSnapshot(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if stripeKey == "" {
-		json.NewEncoder(w).Encode(map[string]interface{}{"error": "Stripe not configured"})
-		return
-	}
-
-	snapshot := map[string]interface{}{}
-
-	// Revenue from scored events (last 30/90 days)
-	var rev30, rev90 float64
-	var charges30, charges90 int
-	s.db.QueryRow(`SELECT COALESCE(SUM(json_extract(metadata,'$.amount')),0), COUNT(*)
-		FROM events WHERE source='stripe-webhook' AND event_type='PAYMENT_RECEIVED'
-		AND timestamp > datetime('now','-30 days')`).Scan(&rev30, &charges30)
-	s.db.QueryRow(`SELECT COALESCE(SUM(json_extract(metadata,'$.amount')),0), COUNT(*)
-		FROM events WHERE source='stripe-webhook' AND event_type='PAYMENT_RECEIVED'
-		AND timestamp > datetime('now','-90 days')`).Scan(&rev90, &charges90)
-
-	snapshot["revenue_30d"] = rev30 / 100
-	snapshot["revenue_90d"] = rev90 / 100
-	snapshot["charges_30d"] = charges30
-	snapshot["charges_90d"] = charges90
-	snapshot["mrr_estimate"] = rev30 / 100 // Rough MRR from last 30 days
-
-	// Recent events
-	var recentEvents []map[string]interface{}
-	rows, _ := s.db.Query(`SELECT event_type, artifact_title, timestamp, score_delta, metadata
-		FROM events WHERE source='stripe-webhook' ORDER BY timestamp DESC LIMIT 10`)
-	if rows != nil {
-		defer rows.Close()
-		for rows.Next() {
-			var et, title, ts, meta string
-			var sd int
-			rows.Scan(&et, &title, &ts, &sd, &meta)
-			recentEvents = append(recentEvents, map[string]interface{}{
-				"type": et, "title": title, "timestamp": ts, "score_delta": sd,
-			})
-		}
-	}
-	snapshot["recent_events"] = recentEvents
-
-	// Churn signals
-	var failedCharges int
-	s.db.QueryRow(`SELECT COUNT(*) FROM events WHERE source='stripe-webhook'
-		AND event_type IN ('PAYMENT_FAILED','INVOICE_FAILED')
-		AND timestamp > datetime('now','-30 days')`).Scan(&failedCharges)
-	snapshot["failed_charges_30d"] = failedCharges
-
-	var cancellations int
-	s.db.QueryRow(`SELECT COUNT(*) FROM events WHERE source='stripe-webhook'
-		AND event_type='SUBSCRIPTION_CANCELED'
-		AND timestamp > datetime('now','-30 days')`).Scan(&cancellations)
-	snapshot["cancellations_30d"] = cancellations
-
-	json.NewEncoder(w).Encode(snapshot)
+	json.NewEncoder(w).Encode(s.financialSnapshot())
 }
 
 // ─── SSO Callback ───────────────────────────────────────────────────────────
@@ -2948,7 +2977,7 @@ func plaidBaseURL() string {
 
 func plaidRequest(endpoint string, body interface{}) (map[string]interface{}, error) {
 	payload, _ := json.Marshal(body)
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	req, _ := http.NewRequest("POST", plaidBaseURL()+endpoint, strings.NewReader(string(payload)))
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := client.Do(req)
@@ -3116,7 +3145,7 @@ func (s *Server) handlePlaidExchange(w http.ResponseWriter, r *http.Request) {
 
 	// Encrypt access_token
 	tokenJSON, _ := json.Marshal(map[string]string{"access_token": accessToken, "item_id": itemID})
-	encrypted, nonce, err := s.encryptCredential(tokenJSON)
+	encrypted, nonce, keyID, err := s.encryptCredential(tokenJSON)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{"error": "Encryption failed"})
 		return
@@ -3128,10 +3157,10 @@ func (s *Server) handlePlaidExchange(w http.ResponseWriter, r *http.Request) {
 	nextPoll := now // Poll immediately on new connection
 
 	s.mu.Lock()
-	s.db.Exec(`INSERT INTO integrations (id, user_id, provider, auth_type, encrypted_data, nonce,
+	s.db.Exec(`INSERT INTO integrations (id, user_id, provider, auth_type, encrypted_data, nonce, key_id,
 		display_name, poll_interval_seconds, config, created_at, updated_at, next_poll_at, sensitivity)
-		VALUES (?, ?, 'plaid', 'plaid', ?, ?, ?, 1800, ?, ?, ?, ?, 'sensitive')`,
-		id, userID, encrypted, nonce, displayName, string(configJSON), now, now, nextPoll)
+		VALUES (?, ?, 'plaid', 'plaid', ?, ?, ?, ?, 1800, ?, ?, ?, ?, 'sensitive')`,
+		id, userID, encrypted, nonce, keyID, displayName, string(configJSON), now, now, nextPoll)
 	s.mu.Unlock()
 
 	log.Printf("Plaid: connected %s (%s), %d accounts, integration %s", body.Institution.Name, itemID, len(body.Accounts), id)
@@ -3202,6 +3231,7 @@ func (s *Server) pollPlaid(integrationID, accessToken, configJSON, lastPoll stri
 			config=json_set(config, '$.balance_current', ?, '$.balance_available', ?, '$.balance_account', ?)
 			WHERE id=?`,
 			time.Now().UTC().Format(time.RFC3339), current, available, name+" ••"+mask, integrationID)
+		s.recordBankBalance(integrationID, acct)
 		s.mu.Unlock()
 
 		log.Printf("Plaid: %s ••%s balance: $%.2f current, $%.2f available", name, mask, current, available)
@@ -3282,9 +3312,11 @@ func (s *Server) pollPlaid(integrationID, accessToken, configJSON, lastPoll stri
 			"amount":         absAmount,
 			"is_income":      isIncome,
 			"merchant":       merchantName,
+			"name":           txnName,
 			"category":       catStr,
 			"transaction_id": txnID,
 			"plaid_source":   true,
+			"currency":       txn["iso_currency_code"],
 		})
 
 		now := time.Now().UTC().Format(time.RFC3339)
@@ -3310,6 +3342,11 @@ func (s *Server) pollPlaid(integrationID, accessToken, configJSON, lastPoll stri
 	if newCount > 0 {
 		log.Printf("Plaid: %d new transactions from integration %s", newCount, integrationID)
 	}
+
+	// Outflows + balances feed the P&L and runway (expenses.go)
+	s.normalizeRevenueEvents(false)
+	s.syncExpenses()
+	s.publishRunway()
 	return nil
 }
 
@@ -3902,6 +3939,13 @@ func (s *Server) handleOAuthStart(w http.ResponseWriter, r *http.Request) {
 		Name: "oauth_provider", Value: provider,
 		Path: "/", MaxAge: 300, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode,
 	})
+	// Re-authorising an expired integration updates it in place (oauth_tokens.go)
+	if reauth := r.URL.Query().Get("reauth"); reauth != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name: "oauth_reauth", Value: reauth,
+			Path: "/", MaxAge: 300, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode,
+		})
+	}
 
 	http.Redirect(w, r, authURL, 302)
 }
@@ -3926,12 +3970,17 @@ func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
 	if ck, _ := r.Cookie("oauth_google_kind"); ck != nil {
 		googleKind = ck.Value
 	}
+	reauthID := ""
+	if ck, _ := r.Cookie("oauth_reauth"); ck != nil {
+		reauthID = ck.Value
+	}
 
 	// Clear cookies
 	http.SetCookie(w, &http.Cookie{Name: "oauth_state", Path: "/", MaxAge: -1})
 	http.SetCookie(w, &http.Cookie{Name: "oauth_provider", Path: "/", MaxAge: -1})
 	http.SetCookie(w, &http.Cookie{Name: "oauth_user_id", Path: "/", MaxAge: -1})
 	http.SetCookie(w, &http.Cookie{Name: "oauth_google_kind", Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: "oauth_reauth", Path: "/", MaxAge: -1})
 
 	if errParam != "" {
 		http.Redirect(w, r, fmt.Sprintf("/?oauth=%s&oauth_status=fail&error=%s", provider, errParam), 302)
@@ -3956,7 +4005,7 @@ func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
 	tokenBody := fmt.Sprintf("grant_type=authorization_code&code=%s&redirect_uri=%s&client_id=%s&client_secret=%s",
 		code, callbackURL, cfg.ClientID, cfg.ClientSecret)
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	tokenReq, _ := http.NewRequest("POST", cfg.TokenURL, strings.NewReader(tokenBody))
 	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	if provider == "github" {
@@ -3984,8 +4033,9 @@ func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store the token as an encrypted integration
+	stampTokenExpiry(tokenData)
 	tokenJSON, _ := json.Marshal(tokenData)
-	encrypted, nonce, err := s.encryptCredential(tokenJSON)
+	encrypted, nonce, keyID, err := s.encryptCredential(tokenJSON)
 	if err != nil {
 		http.Redirect(w, r, fmt.Sprintf("/?oauth=%s&oauth_status=fail&error=Encryption+failed", provider), 302)
 		return
@@ -4110,11 +4160,28 @@ func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
 		scopesToStore = s
 	}
 
+	// Reconnecting an expired integration: swap the credential, keep history
+	if reauthID != "" {
+		s.mu.Lock()
+		res, _ := s.db.Exec(`UPDATE integrations SET encrypted_data=?, nonce=?, key_id=?, scopes=?, status='active',
+			last_error='', next_poll_at=?, updated_at=? WHERE id=? AND provider=? AND user_id=?`,
+			encrypted, nonce, keyID, scopesToStore, now, now, reauthID, scoreProvider, userID)
+		s.mu.Unlock()
+		if n, _ := res.RowsAffected(); n > 0 {
+			expiresAt, _ := tokenData["expires_at"].(string)
+			s.auditToken(reauthID, scoreProvider, "reauthorized", "operator reconnected", expiresAt)
+			s.db.Exec(`UPDATE alerts SET dismissed_at=CURRENT_TIMESTAMP WHERE id=?`, "oauth_expired_"+sanitizeID(reauthID))
+			log.Printf("OAuth: %s re-authorised (integration %s)", provider, reauthID)
+			http.Redirect(w, r, fmt.Sprintf("/?oauth=%s&oauth_status=ok", scoreProvider), 302)
+			return
+		}
+	}
+
 	s.mu.Lock()
-	s.db.Exec(`INSERT INTO integrations (id, user_id, provider, auth_type, encrypted_data, nonce,
+	s.db.Exec(`INSERT INTO integrations (id, user_id, provider, auth_type, encrypted_data, nonce, key_id,
 		display_name, poll_interval_seconds, created_at, updated_at, next_poll_at, scopes)
-		VALUES (?, ?, ?, 'oauth2', ?, ?, ?, 1800, ?, ?, ?, ?)`,
-		id, userID, scoreProvider, encrypted, nonce, displayName, now, now, nextPoll, scopesToStore)
+		VALUES (?, ?, ?, 'oauth2', ?, ?, ?, ?, 1800, ?, ?, ?, ?)`,
+		id, userID, scoreProvider, encrypted, nonce, keyID, displayName, now, now, nextPoll, scopesToStore)
 	s.mu.Unlock()
 
 	// For GitHub: auto-create webhooks on user's repos
@@ -4133,7 +4200,7 @@ func (s *Server) setupGitHubWebhooks(tokenData map[string]interface{}) {
 		return
 	}
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	webhookURL := fmt.Sprintf("%s/v1/webhooks/github", oauthCallbackBase)
 
 	// List user's repos
@@ -4255,11 +4322,14 @@ func calcScoreDelta(lane, eventType string, confidence float64) int {
 			"SOCIAL_POST_BUSINESS": 4, "COLD_OUTREACH": 4, "PODCAST_PUBLISHED": 6,
 			"DOCS_PUBLISHED": 4, "EXTENSION_PUBLISHED": 5, "CODE_PUBLISHED": 3,
 			"CAMPAIGN_SENT": 5, "DEPLOY": 3, "EMAIL_HEALTH": 1,
+			"DISTRIBUTION_OUTCOME": 0, // scored from measured reach (distribution_outcomes.go)
 		},
 		"revenue": {
 			"PAYMENT_RECEIVED": 10, "SUBSCRIPTION_CREATED": 12, "DEAL_CLOSED": 8,
 			"PROPOSAL_SENT": 4, "INVOICE_PAID": 8, "PAYOUT_RECEIVED": 2,
+			"INVOICE_SENT": 4, "INVOICE_OVERDUE": 0,
 			"PAYMENT_FAILED": 0, "REFUND_ISSUED": -2, "EXPENSE_RECORDED": 0,
+			"SALES_CALL": 4, "CLIENT_MEETING": 3,
 		},
 		"systems": {
 			"AUTOMATION_DEPLOYED": 6, "SOP_DOCUMENTED": 4, "TOOL_INTEGRATED": 5,
@@ -4331,7 +4401,8 @@ func (s *Server) updateDailyScore(date string) {
 		var intent string
 		var fulfilled int
 		s.db.QueryRow("SELECT COALESCE(intent,''), intent_fulfilled FROM daily_scores WHERE date=?", date).Scan(&intent, &fulfilled)
-		if intent != "" && fulfilled == 0 && ships == 0 {
+		// Rest days (calendar day_off) never carry a commitment penalty
+		if intent != "" && fulfilled == 0 && ships == 0 && !s.isRestDay(date) {
 			commitmentPenalty = 10
 		}
 	}
@@ -4405,7 +4476,12 @@ func (s *Server) updateStreak(date string, artifact string) {
 	var current, best int
 	s.db.QueryRow("SELECT current_len, best_len, last_date FROM streaks WHERE streak_type='ship'").Scan(&current, &best, &lastDate)
 
-	yesterday := operatorNow().AddDate(0, 0, -1).Format("2006-01-02")
+	// Rest days between the last ship and today are bridged, not broken
+	prev := operatorNow().AddDate(0, 0, -1)
+	for i := 0; i < 30 && prev.Format("2006-01-02") > lastDate && s.isRestDay(prev.Format("2006-01-02")); i++ {
+		prev = prev.AddDate(0, 0, -1)
+	}
+	yesterday := prev.Format("2006-01-02")
 	if lastDate == date {
 		// Already counted today
 	} else if lastDate == yesterday {
@@ -4751,129 +4827,86 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	// Inject context — pairing protocol if incomplete, scoreboard state always
 	contextMessages := s.buildChatContext(req.Messages)
 
-	// Build proxy request to OpenClaw
+	// Send through the "chat" LLM route (llm_client.go) — the OpenClaw gateway by default
 	// "user" field = stable session key → persistent conversation with memory
-	gatewayURL := "http://127.0.0.1:18789/v1/chat/completions"
-	gwToken := gatewayToken
-	if gwToken == "" {
-		gwToken = authToken // fallback to scoreboard token
-	}
-
-	proxyBody := map[string]interface{}{
-		"messages":   contextMessages,
-		"user":       "scoreboard:" + userID + ":" + sessionID, // stable per-session key
-		"max_tokens": 2048,
-	}
-	if req.Stream {
-		proxyBody["stream"] = true
-	}
-
-	bodyBytes, _ := json.Marshal(proxyBody)
-	proxyReq, err := http.NewRequest("POST", gatewayURL, bytes.NewReader(bodyBytes))
-	if err != nil {
-		http.Error(w, `{"error":"Failed to create proxy request"}`, 502)
-		return
-	}
-	proxyReq.Header.Set("Content-Type", "application/json")
-	proxyReq.Header.Set("Authorization", "Bearer "+gwToken)
-	proxyReq.Header.Set("x-openclaw-agent-id", "verious") // Use the Wirebot agent, not "main"
-
-	client := &http.Client{Timeout: 120 * time.Second}
-
-	if req.Stream {
-		// SSE streaming — pipe through and capture full response for persistence
-		proxyResp, err := client.Do(proxyReq)
-		if err != nil {
-			http.Error(w, `{"error":"Gateway unavailable"}`, 502)
+	llmReq := LLMRequest{
+		Task:      "chat",
+		Messages:  contextMessages,
+		User:      "scoreboard:" + userID + ":" + sessionID, // stable per-session key
+		MaxTokens: 2048,
+		Headers:   map[string]string{"x-openclaw-agent-id": "verious"}, // Use the Wirebot agent, not "main"
+	}
+	llmError := func(err error) {
+		if errors.Is(err, errLLMBudget) {
+			http.Error(w, `{"error":"Daily AI budget reached — try again tomorrow"}`, 429)
 			return
 		}
-		defer proxyResp.Body.Close()
-
-		// Prepend session_id as first SSE event
-		w.Header().Set("Content-Type", "text/event-stream")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Connection", "keep-alive")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.WriteHeader(proxyResp.StatusCode)
+		http.Error(w, `{"error":"Gateway unavailable"}`, 502)
+	}
 
+	if req.Stream {
+		// SSE streaming — pipe through; the client layer assembles the full response for persistence
 		flusher, ok := w.(http.Flusher)
-		// Send session_id as custom event
-		fmt.Fprintf(w, "event: session\ndata: %s\n\n", sessionID)
-		if ok {
-			flusher.Flush()
-		}
-
-		var fullResponse strings.Builder
-		buf := make([]byte, 4096)
-		for {
-			n, readErr := proxyResp.Body.Read(buf)
-			if n > 0 {
-				chunk := string(buf[:n])
-				w.Write(buf[:n])
-				if ok {
-					flusher.Flush()
-				}
-
-				// Extract content deltas for persistence
-				for _, line := range strings.Split(chunk, "\n") {
-					if !strings.HasPrefix(line, "data: ") {
-						continue
-					}
-					payload := strings.TrimPrefix(line, "data: ")
-					if payload == "[DONE]" {
-						continue
-					}
-					var sse struct {
-						Choices []struct {
-							Delta struct {
-								Content string `json:"content"`
-							} `json:"delta"`
-						} `json:"choices"`
-					}
-					if json.Unmarshal([]byte(payload), &sse) == nil && len(sse.Choices) > 0 {
-						fullResponse.WriteString(sse.Choices[0].Delta.Content)
-					}
-				}
+		started := false
+		resp, err := s.streamLLM(llmReq, func(chunk []byte) {
+			if !started {
+				started = true
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+				w.Header().Set("Connection", "keep-alive")
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				w.WriteHeader(200)
+				// Send session_id as custom event
+				fmt.Fprintf(w, "event: session\ndata: %s\n\n", sessionID)
+			}
+			w.Write(chunk)
+			if ok {
+				flusher.Flush()
 			}
-			if readErr != nil {
-				break
+		})
+		if err != nil {
+			log.Printf("[chat] stream failed: %v", err)
+			if !started {
+				llmError(err)
 			}
+			return
 		}
 
 		// Save assistant response
-		if resp := fullResponse.String(); resp != "" {
-			s.saveMessage(sessionID, "assistant", resp)
+		if resp.Content != "" {
+			s.saveMessage(sessionID, "assistant", resp.Content)
 			// Async: extract memories from this exchange into approval queue
-			go s.extractConversationToQueue(lastMsg.Content, resp)
+			go s.extractConversationToQueue(lastMsg.Content, resp.Content)
 		}
 	} else {
 		// Non-streaming — simple proxy
-		proxyResp, err := client.Do(proxyReq)
+		resp, err := s.callLLM(llmReq)
 		if err != nil {
-			http.Error(w, `{"error":"Gateway unavailable"}`, 502)
+			log.Printf("[chat] request failed: %v", err)
+			llmError(err)
 			return
 		}
-		defer proxyResp.Body.Close()
-
-		respBody, _ := io.ReadAll(proxyResp.Body)
 
 		// Save assistant response
-		var result struct {
-			Choices []struct {
-				Message struct {
-					Content string `json:"content"`
-				} `json:"message"`
-			} `json:"choices"`
-		}
-		if json.Unmarshal(respBody, &result) == nil && len(result.Choices) > 0 {
-			s.saveMessage(sessionID, "assistant", result.Choices[0].Message.Content)
+		if resp.Content != "" {
+			s.saveMessage(sessionID, "assistant", resp.Content)
 			// Async: extract memories from this exchange into approval queue
-			go s.extractConversationToQueue(lastMsg.Content, result.Choices[0].Message.Content)
+			go s.extractConversationToQueue(lastMsg.Content, resp.Content)
+		}
+
+		respBody := []byte(resp.Raw)
+		if len(respBody) == 0 {
+			respBody, _ = json.Marshal(map[string]interface{}{
+				"model": resp.Model,
+				"choices": []map[string]interface{}{{
+					"index":         0,
+					"message":       map[string]string{"role": "assistant", "content": resp.Content},
+					"finish_reason": "stop",
+				}},
+			})
 		}
-
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.WriteHeader(proxyResp.StatusCode)
 		w.Write(respBody)
 	}
 }
@@ -5799,27 +5832,13 @@ func (s *Server) recordProposalFeedback(taskID, file, section, snippet string, k
 
 // storeInMem0 persists memory for long-term learning
 func (s *Server) storeInMem0(category string, data map[string]interface{}) {
-	mem0URL := "http://127.0.0.1:8200/v1/memory"
 	text, _ := json.Marshal(data)
-	payload := map[string]interface{}{
-		"text":     fmt.Sprintf("[%s] %s", category, string(text)),
-		"user_id":  "verious",
-		"metadata": data,
-	}
-	body, _ := json.Marshal(payload)
-
-	req, _ := http.NewRequest("POST", mem0URL, bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("[mem0] Failed to store memory: %v", err)
+	store := s.memoryStore()
+	if _, err := store.Put(fmt.Sprintf("[%s] %s", category, string(text)), category); err != nil {
+		log.Printf("[%s] Failed to store memory: %v", store.Name(), err)
 		return
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		log.Printf("[mem0] ✓ Stored feedback memory")
-	}
+	log.Printf("[%s] ✓ Stored feedback memory", store.Name())
 }
 
 func (s *Server) generateProposals(tasks []interface{}) []TaskProposal {
@@ -6811,52 +6830,19 @@ Requirements:
 
 Write the draft now:`, bizName, title)
 
-	// Call the gateway for AI generation
-	gatewayURL := "http://127.0.0.1:18789/v1/chat/completions"
-	token := gatewayToken
-	if token == "" {
-		token = authToken // fallback to scoreboard token
-	}
-
-	reqBody, _ := json.Marshal(map[string]interface{}{
-		"model": envOr("DRAFT_MODEL", "kimi-coding/k2p5"),
-		"messages": []map[string]string{
+	// Call the "draft" LLM route (llm_client.go)
+	resp, err := s.callLLM(LLMRequest{
+		Task: "draft",
+		Messages: []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens": 800,
+		MaxTokens: 800,
 	})
-
-	req, _ := http.NewRequest("POST", gatewayURL, bytes.NewReader(reqBody))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("[defer] Draft generation failed for '%s': %v", title, err)
 		return ""
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("[defer] Draft generation HTTP %d for '%s': %s", resp.StatusCode, title, string(body))
-		return ""
-	}
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-
-	if len(result.Choices) > 0 && result.Choices[0].Message.Content != "" {
-		return result.Choices[0].Message.Content
-	}
-	return ""
+	return resp.Content
 }
 
 // runSEOCheckForTask probes the business's domain for SEO basics
@@ -7270,6 +7256,10 @@ type MemoryQueueItem struct {
 	Correction    string  `json:"correction,omitempty"`
 	CreatedAt     string  `json:"created_at"`
 	ReviewedAt    string  `json:"reviewed_at,omitempty"`
+	Category      string  `json:"category,omitempty"`
+	ExpiresAt     string  `json:"expires_at,omitempty"`
+	ReconfirmOf   string  `json:"reconfirm_of,omitempty"` // "still true?" item for this memory (memory_ttl.go)
+	MergeCount    int     `json:"merge_count,omitempty"`  // near-duplicates merged in (memory_dedup.go)
 }
 
 // GET /v1/memory/queue — list pending memories
@@ -7308,7 +7298,8 @@ func (s *Server) handleMemoryQueue(w http.ResponseWriter, r *http.Request) {
 			args = append(args, sourceFilter)
 		}
 		q := `SELECT id, memory_text, source_type, source_file, source_context, 
-		       confidence, status, correction, created_at, reviewed_at
+		       confidence, status, correction, created_at, reviewed_at,
+		       COALESCE(category,''), COALESCE(expires_at,''), COALESCE(reconfirm_of,''), COALESCE(merge_count,0)
 		FROM memory_queue`
 		if len(where) > 0 {
 			q += " WHERE " + strings.Join(where, " AND ")
@@ -7330,7 +7321,8 @@ func (s *Server) handleMemoryQueue(w http.ResponseWriter, r *http.Request) {
 			var item MemoryQueueItem
 			var srcFile, srcCtx, correction, reviewedAt sql.NullString
 			rows.Scan(&item.ID, &item.MemoryText, &item.SourceType, &srcFile, &srcCtx,
-				&item.Confidence, &item.Status, &correction, &item.CreatedAt, &reviewedAt)
+				&item.Confidence, &item.Status, &correction, &item.CreatedAt, &reviewedAt,
+				&item.Category, &item.ExpiresAt, &item.ReconfirmOf, &item.MergeCount)
 			if srcFile.Valid {
 				item.SourceFile = srcFile.String
 			}
@@ -7347,15 +7339,16 @@ func (s *Server) handleMemoryQueue(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Also get counts
-		var pending, approved, rejected int
+		var pending, approved, rejected, reconfirm int
 		s.db.QueryRow("SELECT COUNT(*) FROM memory_queue WHERE status='pending'").Scan(&pending)
 		s.db.QueryRow("SELECT COUNT(*) FROM memory_queue WHERE status='approved'").Scan(&approved)
 		s.db.QueryRow("SELECT COUNT(*) FROM memory_queue WHERE status='rejected'").Scan(&rejected)
+		s.db.QueryRow("SELECT COUNT(*) FROM memory_queue WHERE status='pending' AND reconfirm_of != ''").Scan(&reconfirm)
 
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"items": items,
 			"counts": map[string]int{
-				"pending": pending, "approved": approved, "rejected": rejected,
+				"pending": pending, "approved": approved, "rejected": rejected, "reconfirm": reconfirm,
 			},
 		})
 
@@ -7373,6 +7366,15 @@ func (s *Server) handleMemoryQueue(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		memoryDedupMu.Lock()
+		defer memoryDedupMu.Unlock()
+		if dup := s.findNearDuplicate(body.MemoryText); dup != nil {
+			s.mergeDuplicate(dup, MemoryExtraction{MemoryText: body.MemoryText, SourceType: body.SourceType,
+				SourceFile: body.SourceFile, SourceContext: body.SourceContext, Confidence: body.Confidence})
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "id": dup.ID, "merged": true})
+			return
+		}
+
 		randBytes := make([]byte, 4)
 		rand.Read(randBytes)
 		id := fmt.Sprintf("mem-%d-%x", time.Now().UnixNano(), randBytes)
@@ -7395,6 +7397,7 @@ func (s *Server) handleMemoryQueue(w http.ResponseWriter, r *http.Request) {
 // POST /v1/memory/queue/{id}/approve — approve memory
 // POST /v1/memory/queue/{id}/reject — reject memory
 // POST /v1/memory/queue/{id}/correct — correct and approve
+// POST /v1/memory/queue/{id}/confirm|update|retire — answer a "still true?" item (memory_ttl.go)
 func (s *Server) handleMemoryQueueAction(w http.ResponseWriter, r *http.Request) {
 	cors(w)
 	if r.Method != "POST" {
@@ -7421,6 +7424,18 @@ func (s *Server) handleMemoryQueueAction(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// "Still true?" items: the generic review buttons mean confirm/retire/update
+	if item.SourceType == "reconfirm" {
+		switch action {
+		case "approve":
+			action = "confirm"
+		case "reject":
+			action = "retire"
+		case "correct":
+			action = "update"
+		}
+	}
+
 	switch action {
 	case "approve":
 		finalText := item.MemoryText
@@ -7441,6 +7456,11 @@ func (s *Server) handleMemoryQueueAction(w http.ResponseWriter, r *http.Request)
 			http.Error(w, `{"error":"db update failed"}`, 500)
 			return
 		}
+		// Rejecting something already written out pulls it back from every store,
+		// keeping the row as 'rejected' rather than tombstoning it
+		if s.hasProvenance(id) {
+			go s.unwriteMemory(id, "rejected", false)
+		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "action": "rejected"})
 
 	case "correct":
@@ -7461,51 +7481,53 @@ func (s *Server) handleMemoryQueueAction(w http.ResponseWriter, r *http.Request)
 		go s.writebackApprovedMemory(id, body.Correction)
 		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "action": "corrected", "memory": body.Correction, "hash": corrHash})
 
+	case "confirm", "update", "retire":
+		s.handleMemoryReconfirm(w, r, id, action)
+
 	default:
-		http.Error(w, `{"error":"invalid action, use approve/reject/correct"}`, 400)
+		http.Error(w, `{"error":"invalid action, use approve/reject/correct (or confirm/update/retire)"}`, 400)
 	}
 }
 
-// mem0Store stores a fact in Mem0 via HTTP API.
+// mem0Client is shared by the Mem0 memory store (memory_store.go).
+// Mem0 uses an LLM for fact extraction, so allow 30s timeout.
 var mem0Client = &http.Client{Timeout: 30 * time.Second}
 
-func mem0Store(baseURL, namespace, text string, messages []map[string]string) error {
-	body := map[string]interface{}{"namespace": namespace, "category": "approved"}
-	if len(messages) > 0 {
-		body["messages"] = messages
-	} else {
-		body["text"] = text
-	}
-	bodyBytes, _ := json.Marshal(body)
-	resp, err := mem0Client.Post(baseURL+"/v1/store", "application/json", bytes.NewReader(bodyBytes))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("mem0 store returned %d: %s", resp.StatusCode, string(respBody[:min(200, len(respBody))]))
-	}
-	return nil
-}
-
 // writebackApprovedMemory fans out an approved memory to all three layers:
-//   1. Mem0 (cross-surface fact store)
+//   1. Memory store (Mem0 for the operator — cross-surface fact store)
 //   2. MEMORY.md (workspace knowledge — immediate append, not waiting for syncd)
 //   3. Letta agent message (if business-relevant — agent self-edits its blocks)
+// Tenants only have 1: the workspace and the Letta agent are the operator's.
 func (s *Server) writebackApprovedMemory(memID, text string) {
 	var dests []string
+	s.stampMemoryExpiry(memID, text, time.Now())
 
-	// 1. Mem0 — vector store for semantic search
-	if err := mem0Store("http://127.0.0.1:8200", "wirebot_verious", text, nil); err != nil {
-		log.Printf("[memory-writeback] Mem0 error: %v", err)
+	// 1. Memory store — semantic search (memory_store.go)
+	store := s.memoryStore()
+	if ids, err := store.Put(text, "approved"); err != nil {
+		log.Printf("[memory-writeback] %s error: %v", store.Name(), err)
 	} else {
-		dests = append(dests, "mem0")
+		dests = append(dests, store.Name())
+		// Provenance: every destination ID, so forget can find it (memory_provenance.go)
+		if len(ids) == 0 {
+			s.recordProvenance(memID, store.Name(), "", text)
+		}
+		for _, mid := range ids {
+			s.recordProvenance(memID, store.Name(), mid, text)
+		}
+	}
+	if s.tenantID != "" {
+		if len(dests) > 0 {
+			destsJSON, _ := json.Marshal(dests)
+			s.db.Exec(`UPDATE memory_queue SET destinations=? WHERE id=?`, string(destsJSON), memID)
+		}
+		return
 	}
 
 	// 2. Append to MEMORY.md (immediate, not waiting for syncd 60s poll)
 	safeLine := strings.Join(strings.Fields(text), " ")
 	memoryPath := "/home/wirebot/clawd/MEMORY.md"
+	memoryFileMu.Lock()
 	f, err := os.OpenFile(memoryPath, os.O_APPEND|os.O_WRONLY, 0644)
 	if err == nil {
 		_, writeErr := f.WriteString("- " + safeLine + "\n")
@@ -7514,11 +7536,13 @@ func (s *Server) writebackApprovedMemory(memID, text string) {
 			log.Printf("[memory-writeback] MEMORY.md write error: %v", writeErr)
 		} else {
 			dests = append(dests, "memory_md")
+			s.recordProvenance(memID, "memory_md", memoryPath, "- "+safeLine)
 			exec.Command("chown", "wirebot:wirebot", memoryPath).Run()
 		}
 	} else {
 		log.Printf("[memory-writeback] MEMORY.md open error: %v", err)
 	}
+	memoryFileMu.Unlock()
 
 	// 3. Git-backed YAML fact file — local only, no remote push
 	factsDir := "/home/wirebot/clawd/memory/facts"
@@ -7551,23 +7575,12 @@ func (s *Server) writebackApprovedMemory(memID, text string) {
 		log.Printf("[memory-writeback] YAML write error: %v", err)
 	} else {
 		dests = append(dests, "git")
+		s.recordProvenance(memID, "git", yamlPath, "")
 		// Fix ownership (scoreboard runs as root, clawd owned by wirebot)
 		exec.Command("chown", "wirebot:wirebot", yamlPath).Run()
 		// Auto-commit as wirebot (non-blocking, best-effort)
-		go func() {
-			commitMsg := fmt.Sprintf("memory: %s", truncateRunes(safeLine, 60))
-			clawdDir := "/home/wirebot/clawd"
-			gitCmd := func(args ...string) {
-				cmd := exec.Command("git", args...)
-				cmd.Dir = clawdDir
-				cmd.SysProcAttr = &syscall.SysProcAttr{
-					Credential: &syscall.Credential{Uid: 549, Gid: 547},
-				}
-				cmd.Run()
-			}
-			gitCmd("add", "memory/facts/"+memID+".yaml", "MEMORY.md")
-			gitCmd("commit", "-m", commitMsg, "--author", "wirebot <wirebot@wirebot.chat>")
-		}()
+		go clawdCommit(fmt.Sprintf("memory: %s", truncateRunes(safeLine, 60)),
+			"memory/facts/"+memID+".yaml", "MEMORY.md")
 	}
 
 	// 4. Letta: handled by lettaStateFeeder goroutine (polls approved with watermarks)
@@ -7583,161 +7596,22 @@ func (s *Server) writebackApprovedMemory(memID, text string) {
 // ─── Memory Conflicts Detection ──────────────────────────────────────────
 
 type ConflictItem struct {
-	ID     string `json:"id"`
-	Text   string `json:"text"`
-	Source string `json:"source"`
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Source    string `json:"source"`
+	FactID    int64  `json:"fact_id,omitempty"` // memory_facts.go
+	Value     string `json:"value,omitempty"`
+	ValidFrom string `json:"valid_from,omitempty"`
 }
 
 type MemoryConflict struct {
-	ID       string       `json:"id"`
-	A        ConflictItem `json:"a"`
-	B        ConflictItem `json:"b"`
-	Category string       `json:"category"` // location, name, business, etc.
-}
-
-// GET /v1/memory/conflicts — detect contradictory memories using category grouping
-func (s *Server) handleMemoryConflicts(w http.ResponseWriter, r *http.Request) {
-	cors(w)
-	if r.Method == "OPTIONS" {
-		return
-	}
-
-	// Fetch all memories from Mem0
-	resp, err := http.Post("http://127.0.0.1:8200/v1/list", "application/json",
-		strings.NewReader(`{"namespace": "wirebot_verious"}`))
-	if err != nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{"conflicts": []MemoryConflict{}})
-		return
-	}
-	defer resp.Body.Close()
-
-	var listResp struct {
-		Results []struct {
-			ID     string `json:"id"`
-			Memory string `json:"memory"`
-		} `json:"results"`
-	}
-	json.NewDecoder(resp.Body).Decode(&listResp)
-
-	conflicts := []MemoryConflict{}
-
-	// Category keyword groups — memories in the same category that contradict are flagged
-	categories := map[string][]string{
-		"location":     {"location", "located", "lives in", "based in", "moved to", "timezone", "city", "state"},
-		"name":         {"name is", "called", "goes by", "preferred name", "my name"},
-		"stage":        {"idea stage", "launch stage", "growth stage", "pre-mvp", "post-mvp"},
-		"revenue":      {"revenue", "mrr", "income", "earning", "making $"},
-		"team":         {"solo", "co-founder", "team of", "employees", "hired"},
-		"progress":     {"% progress", "% complete", "completion rate"},
-	}
-
-	// Group memories by category
-	type catMem struct{ id, text string }
-	grouped := map[string][]catMem{}
-	for _, m := range listResp.Results {
-		lower := strings.ToLower(m.Memory)
-		for cat, keywords := range categories {
-			for _, kw := range keywords {
-				if strings.Contains(lower, kw) {
-					grouped[cat] = append(grouped[cat], catMem{m.ID, m.Memory})
-					break
-				}
-			}
-		}
-	}
-
-	// Within each category, look for contradictions
-	// Two memories contradict if they're in the same category but contain
-	// mutually exclusive values (e.g. different cities, different stages)
-	for cat, mems := range grouped {
-		if len(mems) < 2 {
-			continue
-		}
-		for i := 0; i < len(mems) && i < 20; i++ { // cap to avoid O(n²) blowup
-			for j := i + 1; j < len(mems) && j < 20; j++ {
-				a, b := mems[i], mems[j]
-				// Skip if one contains the other (likely consistent, not contradictory)
-				if strings.Contains(strings.ToLower(a.text), strings.ToLower(b.text)) ||
-					strings.Contains(strings.ToLower(b.text), strings.ToLower(a.text)) {
-					continue
-				}
-				// For location: check if they mention different specific places
-				if cat == "location" {
-					places := []string{"corona", "providence", "los angeles", "new york", "riverside",
-						"portland", "san francisco", "inland empire", "chicago"}
-					aPlaces, bPlaces := []string{}, []string{}
-					aLower, bLower := strings.ToLower(a.text), strings.ToLower(b.text)
-					for _, p := range places {
-						if strings.Contains(aLower, p) { aPlaces = append(aPlaces, p) }
-						if strings.Contains(bLower, p) { bPlaces = append(bPlaces, p) }
-					}
-					if len(aPlaces) > 0 && len(bPlaces) > 0 {
-						// Check if any places differ
-						for _, ap := range aPlaces {
-							for _, bp := range bPlaces {
-								if ap != bp {
-									idA := a.id; if len(idA) > 8 { idA = idA[:8] }
-									idB := b.id; if len(idB) > 8 { idB = idB[:8] }
-									conflicts = append(conflicts, MemoryConflict{
-										ID:       fmt.Sprintf("conflict-%s-%s", idA, idB),
-										Category: cat,
-										A:        ConflictItem{ID: a.id, Text: a.text, Source: "mem0"},
-										B:        ConflictItem{ID: b.id, Text: b.text, Source: "mem0"},
-									})
-								}
-							}
-						}
-					}
-				}
-				// For stage: check if they mention different stages
-				if cat == "stage" {
-					stages := []string{"idea", "launch", "growth", "pre-mvp", "post-mvp"}
-					aStage, bStage := "", ""
-					for _, st := range stages {
-						if strings.Contains(strings.ToLower(a.text), st) { aStage = st }
-						if strings.Contains(strings.ToLower(b.text), st) { bStage = st }
-					}
-					if aStage != "" && bStage != "" && aStage != bStage {
-						idA := a.id; if len(idA) > 8 { idA = idA[:8] }
-						idB := b.id; if len(idB) > 8 { idB = idB[:8] }
-						conflicts = append(conflicts, MemoryConflict{
-							ID:       fmt.Sprintf("conflict-%s-%s", idA, idB),
-							Category: cat,
-							A:        ConflictItem{ID: a.id, Text: a.text, Source: "mem0"},
-							B:        ConflictItem{ID: b.id, Text: b.text, Source: "mem0"},
-						})
-					}
-				}
-				// For numeric categories (revenue, progress, team): flag if numbers differ significantly
-				if cat == "revenue" || cat == "progress" || cat == "team" {
-					// Extract any numbers from both
-					aNums := extractNumbers(a.text)
-					bNums := extractNumbers(b.text)
-					if len(aNums) > 0 && len(bNums) > 0 {
-						// If the primary numbers differ by >50%, flag
-						if aNums[0] > 0 && bNums[0] > 0 {
-							ratio := aNums[0] / bNums[0]
-							if ratio < 0.5 || ratio > 2.0 {
-								idA := a.id; if len(idA) > 8 { idA = idA[:8] }
-								idB := b.id; if len(idB) > 8 { idB = idB[:8] }
-								conflicts = append(conflicts, MemoryConflict{
-									ID:       fmt.Sprintf("conflict-%s-%s", idA, idB),
-									Category: cat,
-									A:        ConflictItem{ID: a.id, Text: a.text, Source: "mem0"},
-									B:        ConflictItem{ID: b.id, Text: b.text, Source: "mem0"},
-								})
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"conflicts": conflicts,
-		"checked":   len(listResp.Results),
-	})
+	ID         string          `json:"id"`
+	A          ConflictItem    `json:"a"` // older
+	B          ConflictItem    `json:"b"` // newer
+	Category   string          `json:"category"` // = attribute, kept for older clients
+	Subject    string          `json:"subject"`
+	Attribute  string          `json:"attribute"`
+	Resolution *factResolution `json:"resolution,omitempty"`
 }
 
 // ─── GET /v1/memory/grid — heatmap data for memory audit tab ─────────────
@@ -7827,6 +7701,11 @@ func (s *Server) handleMemoryItem(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"id required"}`, 400)
 		return
 	}
+	// /v1/memory/item/{id}/forget, /v1/memory/item/{id}/provenance (memory_provenance.go)
+	if base, sub, ok := strings.Cut(id, "/"); ok {
+		s.handleMemoryItemProvenance(w, r, base, sub)
+		return
+	}
 
 	if r.Method == "GET" {
 		var memID, memText, sourceType, status, createdAt string
@@ -7890,70 +7769,32 @@ func (s *Server) handleMemoryItem(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, `{"error":"GET or PATCH only"}`, 405)
 }
 
-// extractNumbers pulls numeric values from text
-func extractNumbers(text string) []float64 {
-	var nums []float64
-	words := strings.Fields(text)
-	for _, w := range words {
-		w = strings.Trim(w, "$%,()") 
-		var n float64
-		if _, err := fmt.Sscanf(w, "%f", &n); err == nil && n > 0 {
-			nums = append(nums, n)
-		}
-	}
-	return nums
-}
-
 // ─── Encryption Helpers ──────────────────────────────────────────────────
 
-func (s *Server) getMasterKey() ([]byte, error) {
-	if masterKeyHex == "" {
-		// Generate and log a new key on first run (operator must persist it)
-		key := make([]byte, 32)
-		rand.Read(key)
-		masterKeyHex = hex.EncodeToString(key)
-		log.Printf("WARNING: No SCOREBOARD_MASTER_KEY set. Generated ephemeral key: %s", masterKeyHex)
-		log.Printf("Set SCOREBOARD_MASTER_KEY=%s in your environment to persist credentials across restarts.", masterKeyHex)
-		return key, nil
-	}
-	return hex.DecodeString(masterKeyHex)
-}
+// Keys are versioned — see credential_keys.go. keyID is stored in
+// integrations.key_id next to encrypted_data and nonce.
 
-func (s *Server) encryptCredential(plaintext []byte) (encrypted []byte, nonce []byte, err error) {
-	key, err := s.getMasterKey()
-	if err != nil {
-		return nil, nil, fmt.Errorf("master key: %w", err)
-	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, nil, fmt.Errorf("aes cipher: %w", err)
-	}
-	gcm, err := cipher.NewGCM(block)
+func (s *Server) encryptCredential(plaintext []byte) (encrypted []byte, nonce []byte, keyID string, err error) {
+	kr, err := credentialKeys()
 	if err != nil {
-		return nil, nil, fmt.Errorf("gcm: %w", err)
+		return nil, nil, "", fmt.Errorf("master key: %w", err)
 	}
-	nonce = make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, nil, fmt.Errorf("nonce: %w", err)
-	}
-	encrypted = gcm.Seal(nil, nonce, plaintext, nil)
-	return encrypted, nonce, nil
+	return kr.seal(plaintext)
 }
 
+// decryptCredential tries every loaded key; use decryptCredentialKey when the
+// row's key_id is at hand.
 func (s *Server) decryptCredential(encrypted []byte, nonce []byte) ([]byte, error) {
-	key, err := s.getMasterKey()
+	return s.decryptCredentialKey(encrypted, nonce, "")
+}
+
+func (s *Server) decryptCredentialKey(encrypted, nonce []byte, keyID string) ([]byte, error) {
+	kr, err := credentialKeys()
 	if err != nil {
 		return nil, fmt.Errorf("master key: %w", err)
 	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("aes cipher: %w", err)
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("gcm: %w", err)
-	}
-	return gcm.Open(nil, nonce, encrypted, nil)
+	plain, _, err := kr.open(encrypted, nonce, keyID)
+	return plain, err
 }
 
 // ─── Integration Management ─────────────────────────────────────────────
@@ -8026,7 +7867,7 @@ func (s *Server) handleIntegrations(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Encrypt credential
-		encrypted, nonce, err := s.encryptCredential([]byte(body.Credential))
+		encrypted, nonce, keyID, err := s.encryptCredential([]byte(body.Credential))
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"encryption failed: %s"}`, err), 500)
 			return
@@ -8049,10 +7890,10 @@ func (s *Server) handleIntegrations(w http.ResponseWriter, r *http.Request) {
 		nextPoll := now // Poll immediately on new connection
 
 		s.mu.Lock()
-		_, err = s.db.Exec(`INSERT INTO integrations (id, user_id, provider, auth_type, encrypted_data, nonce,
+		_, err = s.db.Exec(`INSERT INTO integrations (id, user_id, provider, auth_type, encrypted_data, nonce, key_id,
 			display_name, sensitivity, poll_interval_seconds, config, business_id, created_at, updated_at, next_poll_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			id, userID, body.Provider, body.AuthType, encrypted, nonce,
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, userID, body.Provider, body.AuthType, encrypted, nonce, keyID,
 			body.DisplayName, sensitivity, pollInterval, config, body.BusinessID, now, now, nextPoll)
 		s.mu.Unlock()
 		if err != nil {
@@ -8079,6 +7920,16 @@ func (s *Server) handleIntegrationConfig(w http.ResponseWriter, r *http.Request)
 	}
 	id := parts[3]
 
+	// POST /v1/integrations/test, /v1/integrations/<id>/test — dry-run poll (integration_dryrun.go)
+	if id == "test" {
+		s.handleIntegrationTest(w, r, "")
+		return
+	}
+	if len(parts) > 4 && parts[4] == "test" {
+		s.handleIntegrationTest(w, r, id)
+		return
+	}
+
 	if r.Method == "DELETE" {
 		s.mu.Lock()
 		s.db.Exec("DELETE FROM integrations WHERE id=?", id)
@@ -8167,6 +8018,35 @@ func (s *Server) startPoller() {
 			s.runAutoDetectCron()
 		}
 	}()
+
+	// Memory shelf life: re-surface expired memories as "still true?" items
+	go func() {
+		time.Sleep(2 * time.Minute)
+		s.checkMemoryExpiry()
+		ticker := time.NewTicker(6 * time.Hour)
+		for range ticker.C {
+			s.checkMemoryExpiry()
+		}
+	}()
+
+	// Vault extraction jobs: resume whatever was running, then wait for more
+	s.startMemoryJobWorker()
+
+	// OAuth tokens: refresh ahead of expiry
+	go func() {
+		ticker := time.NewTicker(oauthSweepInterval)
+		for range ticker.C {
+			s.refreshExpiringTokens()
+		}
+	}()
+
+	// KPI downsampling + retention: hourly
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		for range ticker.C {
+			s.compactKPIs()
+		}
+	}()
 }
 
 func (s *Server) runAutoDetectCron() {
@@ -8251,7 +8131,7 @@ func (s *Server) checkSystemsHealth() {
 
 func (s *Server) pollDueIntegrations() {
 	now := time.Now().UTC().Format(time.RFC3339)
-	rows, err := s.db.Query(`SELECT id, provider, auth_type, encrypted_data, nonce, config,
+	rows, err := s.db.Query(`SELECT id, provider, auth_type, encrypted_data, nonce, COALESCE(key_id,''), config,
 		last_poll_at, poll_interval_seconds FROM integrations
 		WHERE status='active' AND (next_poll_at <= ? OR next_poll_at = '') LIMIT 10`, now)
 	if err != nil {
@@ -8261,15 +8141,15 @@ func (s *Server) pollDueIntegrations() {
 	defer rows.Close()
 
 	for rows.Next() {
-		var id, provider, authType, config, lastPoll string
+		var id, provider, authType, keyID, config, lastPoll string
 		var encData, nonce []byte
 		var pollInterval int
-		rows.Scan(&id, &provider, &authType, &encData, &nonce, &config, &lastPoll, &pollInterval)
+		rows.Scan(&id, &provider, &authType, &encData, &nonce, &keyID, &config, &lastPoll, &pollInterval)
 
 		// Decrypt credential
 		var credential string
 		if len(encData) > 0 && len(nonce) > 0 {
-			decrypted, err := s.decryptCredential(encData, nonce)
+			decrypted, err := s.decryptCredentialKey(encData, nonce, keyID)
 			if err != nil {
 				log.Printf("Poller: failed to decrypt %s (%s): %v", id, provider, err)
 				s.db.Exec("UPDATE integrations SET last_error=?, status='error' WHERE id=?", err.Error(), id)
@@ -8278,70 +8158,42 @@ func (s *Server) pollDueIntegrations() {
 			credential = string(decrypted)
 		}
 
-		// Poll based on provider
-		var pollErr error
-		switch provider {
-		case "rss", "blog_rss", "podcast_rss":
-			pollErr = s.pollRSS(id, credential, lastPoll)
-		case "youtube", "youtube_key":
-			pollErr = s.pollYouTube(id, credential, config, lastPoll)
-		case "plaid":
-			var creds map[string]string
-			json.Unmarshal([]byte(credential), &creds)
-			pollErr = s.pollPlaid(id, creds["access_token"], config, lastPoll)
-		case "posthog":
-			pollErr = s.pollPostHog(id, credential, config, lastPoll)
-		case "uptimerobot":
-			pollErr = s.pollUptimeRobot(id, credential, lastPoll)
-		case "rescuetime":
-			pollErr = s.pollRescueTime(id, credential, lastPoll)
-		case "woocommerce":
-			pollErr = s.pollWooCommerce(id, credential, config, lastPoll)
-		case "cloudflare":
-			pollErr = s.pollCloudflare(id, credential, config, lastPoll)
-		case "hubspot":
-			pollErr = s.pollHubSpot(id, credential, lastPoll)
-		case "discord_webhook":
-			pollErr = s.pollDiscord(id, credential, lastPoll)
-		case "sendy":
-			pollErr = s.pollSendy(id, credential, config, lastPoll)
-		case "freshbooks":
-			pollErr = s.pollFreshBooks(id, credential, config, lastPoll)
-		case "gdrive":
-			pollErr = s.pollGoogleDrive(id, credential, config, lastPoll)
-		case "dropbox":
-			pollErr = s.pollDropbox(id, credential, config, lastPoll)
-		case "stripe":
-			// OAuth tokens are stored as JSON {"access_token":"sk_...","stripe_user_id":"acct_..."}
-			// API keys are stored as plain strings
-			apiKey := credential
-			if strings.HasPrefix(credential, "{") {
-				var creds map[string]string
-				json.Unmarshal([]byte(credential), &creds)
-				if at := creds["access_token"]; at != "" {
-					apiKey = at
-				}
-			}
-			if apiKey == "" && config != "" {
-				var cfg map[string]string
-				json.Unmarshal([]byte(config), &cfg)
-				if envVar := cfg["api_key_env"]; envVar != "" {
-					apiKey = os.Getenv(envVar)
+		// OAuth: refresh ahead of expiry; pollers below get a usable token
+		oauthCredential := credential
+		if authType == "oauth2" {
+			fresh, err := s.ensureFreshToken(id, provider, credential)
+			if err != nil {
+				log.Printf("Poller: %s (%s) token: %v", id, provider, err)
+				if !isTokenRevoked(err) {
+					s.db.Exec("UPDATE integrations SET last_error=? WHERE id=?", err.Error(), id)
 				}
+				s.db.Exec("UPDATE integrations SET last_poll_at=?, next_poll_at=? WHERE id=?", now,
+					time.Now().Add(time.Duration(pollInterval)*time.Second).UTC().Format(time.RFC3339), id)
+				continue
 			}
-			if apiKey == "" {
-				apiKey = stripeKey // Fallback to default STRIPE_SECRET_KEY
+			oauthCredential = fresh
+			credential = pollerCredential(provider, fresh)
+		}
+
+		pollErr := s.pollProvider(id, provider, credential, config, lastPoll)
+
+		// A rejected OAuth token gets one refresh; a revoked grant parks the integration.
+		// Drive and Dropbox handle their own 401s, with the credential they last used.
+		if authType == "oauth2" && provider != "gdrive" && provider != "dropbox" &&
+			!isTokenRevoked(pollErr) && looksUnauthorized(pollErr) {
+			if _, err := s.handleTokenRejected(id, provider, oauthCredential); err != nil {
+				pollErr = fmt.Errorf("%v; token refresh: %w", pollErr, err)
+			} else {
+				pollErr = fmt.Errorf("%v (token refreshed, next poll retries)", pollErr)
 			}
-			pollErr = s.pollStripe(id, apiKey, lastPoll)
-		case "github":
-			pollErr = s.pollGitHub(id, credential, lastPoll)
-		default:
-			log.Printf("Poller: unknown provider %s for integration %s", provider, id)
 		}
 
 		// Update poll timestamps
 		nextPoll := time.Now().Add(time.Duration(pollInterval) * time.Second).UTC().Format(time.RFC3339)
-		if pollErr != nil {
+		if isTokenRevoked(pollErr) {
+			// markTokenExpired already left the re-auth link in last_error
+			s.db.Exec("UPDATE integrations SET last_poll_at=?, next_poll_at=? WHERE id=?", now, nextPoll, id)
+		} else if pollErr != nil {
 			s.db.Exec("UPDATE integrations SET last_poll_at=?, next_poll_at=?, last_error=? WHERE id=?",
 				now, nextPoll, pollErr.Error(), id)
 		} else {
@@ -8362,10 +8214,91 @@ func (s *Server) pollDueIntegrations() {
 			})
 		}
 	}
+
+	// New revenue events get explicit currency + base-currency amounts (fx.go)
+	rows.Close()
+	s.normalizeRevenueEvents(false)
+	s.syncExpenses()
+	s.checkDistributionOutcomes()
+	s.sampleFinanceKPIs()
+}
+
+// pollProvider runs one provider's poller. Shared by the scheduled poller and
+// integration test/dry-run polls.
+func (s *Server) pollProvider(id, provider, credential, config, lastPoll string) error {
+	switch provider {
+	case "rss", "blog_rss", "podcast_rss":
+		return s.pollRSS(id, credential, lastPoll)
+	case "youtube", "youtube_key":
+		return s.pollYouTube(id, credential, config, lastPoll)
+	case "plaid":
+		var creds map[string]string
+		json.Unmarshal([]byte(credential), &creds)
+		return s.pollPlaid(id, creds["access_token"], config, lastPoll)
+	case "posthog":
+		return s.pollPostHog(id, credential, config, lastPoll)
+	case "uptimerobot":
+		return s.pollUptimeRobot(id, credential, lastPoll)
+	case "rescuetime":
+		return s.pollRescueTime(id, credential, lastPoll)
+	case "woocommerce":
+		return s.pollWooCommerce(id, credential, config, lastPoll)
+	case "cloudflare":
+		return s.pollCloudflare(id, credential, config, lastPoll)
+	case "hubspot":
+		return s.pollHubSpot(id, credential, lastPoll)
+	case "discord_webhook":
+		return s.pollDiscord(id, credential, lastPoll)
+	case "sendy":
+		return s.pollSendy(id, credential, config, lastPoll)
+	case "freshbooks":
+		return s.pollFreshBooks(id, credential, config, lastPoll)
+	case "gdrive":
+		return s.pollGoogleDrive(id, credential, config, lastPoll)
+	case "dropbox":
+		return s.pollDropbox(id, credential, config, lastPoll)
+	case "stripe":
+		// OAuth tokens are stored as JSON {"access_token":"sk_...","stripe_user_id":"acct_..."}
+		// API keys are stored as plain strings
+		apiKey := credential
+		if strings.HasPrefix(credential, "{") {
+			var creds map[string]string
+			json.Unmarshal([]byte(credential), &creds)
+			if at := creds["access_token"]; at != "" {
+				apiKey = at
+			}
+		}
+		if apiKey == "" && config != "" {
+			var cfg map[string]string
+			json.Unmarshal([]byte(config), &cfg)
+			if envVar := cfg["api_key_env"]; envVar != "" {
+				apiKey = os.Getenv(envVar)
+			}
+		}
+		if apiKey == "" {
+			apiKey = stripeKey // Fallback to default STRIPE_SECRET_KEY
+		}
+		return s.pollStripe(id, apiKey, lastPoll)
+	case "github":
+		return s.pollGitHub(id, credential, lastPoll)
+	case "ics", "ical":
+		return s.pollICS(id, credential, config, lastPoll)
+	case "caldav":
+		return s.pollCalDAV(id, credential, config, lastPoll)
+	case "paddle":
+		return s.pollPaddle(id, credential, config, lastPoll)
+	case "lemonsqueezy":
+		return s.pollLemonSqueezy(id, credential, config, lastPoll)
+	case "gumroad":
+		return s.pollGumroad(id, credential, config, lastPoll)
+	case "shopify":
+		return s.pollShopify(id, credential, config, lastPoll)
+	}
+	return fmt.Errorf("unknown provider %q", provider)
 }
 
 func (s *Server) pollRSS(integrationID, feedURL, lastPoll string) error {
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	resp, err := client.Get(feedURL)
 	if err != nil {
 		return fmt.Errorf("fetch RSS: %w", err)
@@ -8492,6 +8425,7 @@ func (s *Server) pollYouTube(integrationID, apiKey, configJSON, lastPoll string)
 	if config.ChannelID == "" || apiKey == "" {
 		return fmt.Errorf("channel_id and api_key required")
 	}
+	s.sampleYouTubeChannel(config.ChannelID, apiKey)
 
 	publishedAfter := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
 	if lastPoll != "" {
@@ -8501,7 +8435,7 @@ func (s *Server) pollYouTube(integrationID, apiKey, configJSON, lastPoll string)
 	url := fmt.Sprintf("https://www.googleapis.com/youtube/v3/search?part=snippet&channelId=%s&order=date&publishedAfter=%s&type=video&maxResults=10&key=%s",
 		config.ChannelID, publishedAfter, apiKey)
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	resp, err := client.Get(url)
 	if err != nil {
 		return fmt.Errorf("youtube api: %w", err)
@@ -8559,6 +8493,37 @@ func (s *Server) pollYouTube(integrationID, apiKey, configJSON, lastPoll string)
 	return nil
 }
 
+// sampleYouTubeChannel records channel subscriber and view counts as KPIs.
+func (s *Server) sampleYouTubeChannel(channelID, apiKey string) {
+	url := fmt.Sprintf("https://www.googleapis.com/youtube/v3/channels?part=statistics&id=%s&key=%s", channelID, apiKey)
+	client := providerClient(15 * time.Second)
+	resp, err := client.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Items []struct {
+			Statistics struct {
+				SubscriberCount string `json:"subscriberCount"`
+				ViewCount       string `json:"viewCount"`
+			} `json:"statistics"`
+		} `json:"items"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Items) == 0 {
+		return
+	}
+	st := result.Items[0].Statistics
+	if n, err := strconv.ParseFloat(st.SubscriberCount, 64); err == nil {
+		s.recordKPI("youtube.subscribers", "youtube", n)
+	}
+	if n, err := strconv.ParseFloat(st.ViewCount, 64); err == nil {
+		s.recordKPI("youtube.views", "youtube", n)
+	}
+}
+
 // ─── PostHog Poller ──────────────────────────────────────────────────────
 
 func (s *Server) pollPostHog(integrationID, apiKey, configJSON, lastPoll string) error {
@@ -8580,7 +8545,7 @@ func (s *Server) pollPostHog(integrationID, apiKey, configJSON, lastPoll string)
 	}
 
 	// Insights query: pageviews + unique users
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	url := fmt.Sprintf("%s/api/projects/@current/insights/trend/?events=[{\"id\":\"$pageview\"}]&date_from=%s&date_to=now", host, since)
 	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Set("Authorization", "Bearer "+apiKey)
@@ -8595,17 +8560,22 @@ func (s *Server) pollPostHog(integrationID, apiKey, configJSON, lastPoll string)
 
 	// Extract total pageviews from results
 	totalPageviews := 0
+	latestDay := -1.0 // last data point = today so far
 	if results, ok := result["result"].([]interface{}); ok && len(results) > 0 {
 		if first, ok := results[0].(map[string]interface{}); ok {
 			if counts, ok := first["data"].([]interface{}); ok {
 				for _, c := range counts {
 					if v, ok := c.(float64); ok {
 						totalPageviews += int(v)
+						latestDay = v
 					}
 				}
 			}
 		}
 	}
+	if latestDay >= 0 {
+		s.recordKPI("analytics.pageviews_today", "posthog", latestDay)
+	}
 
 	// Store as a systems health snapshot (not individually scored per-pageview)
 	now := time.Now().UTC().Format(time.RFC3339)
@@ -8650,7 +8620,7 @@ func (s *Server) pollPostHog(integrationID, apiKey, configJSON, lastPoll string)
 // ─── UptimeRobot Poller ─────────────────────────────────────────────────
 
 func (s *Server) pollUptimeRobot(integrationID, apiKey, lastPoll string) error {
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	body := strings.NewReader(fmt.Sprintf("api_key=%s&format=json&all_time_uptime_ratio=1&custom_uptime_ratios=1-7-30", apiKey))
 	req, _ := http.NewRequest("POST", "https://api.uptimerobot.com/v2/getMonitors", body)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -8678,6 +8648,24 @@ func (s *Server) pollUptimeRobot(integrationID, apiKey, lastPoll string) error {
 		return fmt.Errorf("uptimerobot: stat=%s", result.Stat)
 	}
 
+	// KPI gauges on every poll, even once today's event exists
+	var ratioSum float64
+	ratioCount, downNow := 0, 0
+	for _, m := range result.Monitors {
+		if m.Status == 9 {
+			downNow++
+		}
+		// custom_uptime_ratio is "1d-7d-30d", e.g. "99.98-99.95-99.90"
+		if r, err := strconv.ParseFloat(strings.SplitN(m.CustomUptimeRatio, "-", 2)[0], 64); err == nil {
+			ratioSum += r
+			ratioCount++
+		}
+	}
+	if ratioCount > 0 {
+		s.recordKPI("uptime.ratio_1d", "uptimerobot", ratioSum/float64(ratioCount))
+	}
+	s.recordKPI("uptime.monitors_down", "uptimerobot", float64(downNow))
+
 	now := time.Now().UTC().Format(time.RFC3339)
 	today := operatorToday()
 	evtID := fmt.Sprintf("evt-uptime-%s-%s", integrationID[:15], today)
@@ -8734,7 +8722,7 @@ func (s *Server) pollUptimeRobot(integrationID, apiKey, lastPoll string) error {
 // ─── RescueTime Poller ──────────────────────────────────────────────────
 
 func (s *Server) pollRescueTime(integrationID, apiKey, lastPoll string) error {
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	_ = operatorToday()
 
 	// Daily summary: productive hours, productivity pulse
@@ -8844,7 +8832,7 @@ func (s *Server) pollWooCommerce(integrationID, consumerKey, configJSON, lastPol
 	url := fmt.Sprintf("%s/wp-json/wc/v3/orders?after=%s&per_page=50&orderby=date&order=desc",
 		strings.TrimRight(cfg.StoreURL, "/"), after)
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 	req, _ := http.NewRequest("GET", url, nil)
 	req.SetBasicAuth(consumerKey, cfg.ConsumerSecret)
 	resp, err := client.Do(req)
@@ -8942,7 +8930,7 @@ func (s *Server) pollCloudflare(integrationID, apiToken, configJSON, lastPoll st
 	}
 	json.Unmarshal([]byte(configJSON), &cfg)
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 
 	// List zones for this account
 	zonesURL := "https://api.cloudflare.com/client/v4/zones?per_page=50"
@@ -9025,7 +9013,7 @@ func (s *Server) pollCloudflare(integrationID, apiToken, configJSON, lastPoll st
 // ─── HubSpot Poller ─────────────────────────────────────────────────────
 
 func (s *Server) pollHubSpot(integrationID, apiToken, lastPoll string) error {
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 
 	// Get recent deals (pipeline)
 	url := "https://api.hubapi.com/crm/v3/objects/deals?limit=20&properties=dealname,amount,dealstage,closedate,createdate&sorts=-createdate"
@@ -9123,7 +9111,7 @@ func (s *Server) pollDiscord(integrationID, webhookURL, lastPoll string) error {
 	}
 
 	// Validate webhook is still alive (GET returns webhook info)
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := providerClient(10 * time.Second)
 	resp, err := client.Get(webhookURL)
 	if err != nil {
 		return fmt.Errorf("discord webhook check: %w", err)
@@ -9158,6 +9146,7 @@ func (s *Server) pollDiscord(integrationID, webhookURL, lastPoll string) error {
 func (s *Server) pollSendy(integrationID, apiKey, configJSON, lastPoll string) error {
 	var cfg struct {
 		SendyURL string `json:"sendy_url"`
+		ListID   string `json:"list_id"` // optional: tracks list size as a KPI
 	}
 	json.Unmarshal([]byte(configJSON), &cfg)
 	if cfg.SendyURL == "" || apiKey == "" {
@@ -9165,7 +9154,7 @@ func (s *Server) pollSendy(integrationID, apiKey, configJSON, lastPoll string) e
 	}
 	baseURL := strings.TrimRight(cfg.SendyURL, "/")
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := providerClient(15 * time.Second)
 
 	// 1. Get campaigns — Sendy doesn't have a campaign list API,
 	//    but we can check subscriber count per brand/list
@@ -9186,6 +9175,19 @@ func (s *Server) pollSendy(integrationID, apiKey, configJSON, lastPoll string) e
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 
+	if cfg.ListID != "" {
+		if r, err := client.PostForm(baseURL+"/api/subscribers/active-subscriber-count.php", map[string][]string{
+			"api_key": {apiKey}, "list_id": {cfg.ListID},
+		}); err == nil {
+			countBody, _ := io.ReadAll(r.Body)
+			r.Body.Close()
+			// Plain-text integer on success, an error sentence otherwise
+			if n, err := strconv.Atoi(strings.TrimSpace(string(countBody))); err == nil {
+				s.recordKPI("email.subscribers", "sendy", float64(n))
+			}
+		}
+	}
+
 	now := time.Now().UTC().Format(time.RFC3339)
 	today := operatorToday()
 	evtID := fmt.Sprintf("evt-sendy-%s-%s", integrationID[:15], today)
@@ -9252,11 +9254,6 @@ func (s *Server) pollSendy(integrationID, apiKey, configJSON, lastPoll string) e
 					continue
 				}
 				campEvtID := fmt.Sprintf("evt-sendy-camp-%s", campID)
-				var campExists int
-				s.db.QueryRow("SELECT COUNT(*) FROM events WHERE id=?", campEvtID).Scan(&campExists)
-				if campExists > 0 {
-					continue
-				}
 
 				opens := 0
 				clicks := 0
@@ -9272,6 +9269,14 @@ func (s *Server) pollSendy(integrationID, apiKey, configJSON, lastPoll string) e
 				case string:
 					fmt.Sscanf(v, "%d", &clicks)
 				}
+				// Opens/clicks keep growing after send — sample them for outcome scoring
+				s.recordEngagement(campEvtID, "sendy", map[string]float64{"opens": float64(opens), "clicks": float64(clicks)})
+
+				var campExists int
+				s.db.QueryRow("SELECT COUNT(*) FROM events WHERE id=?", campEvtID).Scan(&campExists)
+				if campExists > 0 {
+					continue
+				}
 
 				// Determine timestamp
 				sentTime := now
@@ -9353,7 +9358,11 @@ func (s *Server) getStallHours() float64 {
 	if err != nil {
 		return 0
 	}
-	hours := time.Since(t).Hours()
+	// Hours that fell on calendar rest days don't count as stall
+	hours := time.Since(t).Hours() - s.restHoursBetween(t, time.Now())
+	if hours < 0 {
+		hours = 0
+	}
 	return math.Round(hours*10) / 10
 }
 
@@ -9391,13 +9400,17 @@ func (s *Server) insertEventIfNew(evt Event) {
 	if verLevel == "" {
 		verLevel = "PROVIDER_API"
 	}
+	metadata := evt.Metadata
+	if metadata == "" {
+		metadata = "{}"
+	}
 
 	s.db.Exec(`INSERT INTO events (id, event_type, lane, source, timestamp,
-		artifact_title, detail, score_delta, confidence, verification_level, external_id, status, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		artifact_title, detail, score_delta, confidence, verification_level, external_id, metadata, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id, evt.EventType, evt.Lane, evt.Source, ts,
 		evt.ArtifactTitle, evt.Detail, evt.ScoreDelta, evt.Confidence, verLevel,
-		evt.ExternalID, status, now)
+		evt.ExternalID, metadata, status, now)
 
 	// Signal pairing engine
 	if s.pairing != nil {
@@ -9434,7 +9447,7 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 	}
 
 	baseURL := "https://api.freshbooks.com"
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := providerClient(30 * time.Second)
 	eventsCreated := 0
 
 	doGet := func(path string) (map[string]interface{}, error) {
@@ -9465,9 +9478,15 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 	}
 
 	// ── Invoices ──────────────────────────────────────────────────────────
-	invoiceData, err := doGet(fmt.Sprintf("/accounting/account/%s/invoices/invoices?include[]=lines&per_page=100&search[date_min]=%s",
-		cfg.AccountID, since.Format("2006-01-02")))
-	if err == nil {
+	// New invoices by date, plus anything updated since the last poll so older
+	// invoices that get paid or go overdue are tracked too (receivables.go).
+	seenInvoices := map[string]bool{}
+	for _, search := range []string{"date_min", "updated_min"} {
+		invoiceData, err := doGet(fmt.Sprintf("/accounting/account/%s/invoices/invoices?include[]=lines&per_page=100&search[%s]=%s",
+			cfg.AccountID, search, since.Format("2006-01-02")))
+		if err != nil {
+			continue
+		}
 		if resp, ok := invoiceData["response"].(map[string]interface{}); ok {
 			if result, ok := resp["result"].(map[string]interface{}); ok {
 				if invoices, ok := result["invoices"].([]interface{}); ok {
@@ -9476,6 +9495,13 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 						if !ok {
 							continue
 						}
+						if rec, ok := freshbooksReceivable(i); ok {
+							if seenInvoices[rec.ID] {
+								continue
+							}
+							seenInvoices[rec.ID] = true
+							s.trackReceivable(rec)
+						}
 						status, _ := i["payment_status"].(string) // "paid", "unpaid", "partial"
 						amount, _ := i["amount"].(map[string]interface{})
 						amtStr, _ := amount["amount"].(string)
@@ -9489,6 +9515,13 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 						if status == "paid" {
 							amtFloat := 0.0
 							fmt.Sscanf(amtStr, "%f", &amtFloat)
+							currency, _ := amount["code"].(string)
+							invMeta, _ := json.Marshal(map[string]interface{}{
+								"amount":         amtFloat,
+								"currency":       strings.ToUpper(currency),
+								"customer":       clientName,
+								"invoice_number": invNum,
+							})
 
 							s.insertEventIfNew(Event{
 								EventType:     "INVOICE_PAID",
@@ -9501,6 +9534,7 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 								Verification:  "PROVIDER_API",
 								ExternalID:    fmt.Sprintf("fb-inv-%s", invNum),
 								Timestamp:     updated,
+								Metadata:      string(invMeta),
 							})
 							eventsCreated++
 						}
@@ -9509,6 +9543,7 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 			}
 		}
 	}
+	s.checkReceivables()
 
 	// ── Expenses ──────────────────────────────────────────────────────────
 	expenseData, err := doGet(fmt.Sprintf("/accounting/account/%s/expenses/expenses?per_page=100&search[date_min]=%s",
@@ -9528,9 +9563,17 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 						category, _ := e["category_name"].(string)
 						date, _ := e["date"].(string)
 						expID, _ := e["id"].(float64)
+						currency, _ := amt["code"].(string)
 
 						amtFloat := 0.0
 						fmt.Sscanf(amtStr, "%f", &amtFloat)
+						expMeta, _ := json.Marshal(map[string]interface{}{
+							"amount":     amtFloat,
+							"currency":   strings.ToUpper(currency),
+							"vendor":     vendor,
+							"category":   category,
+							"expense_id": int(expID),
+						})
 
 						s.insertEventIfNew(Event{
 							EventType:     "EXPENSE_RECORDED",
@@ -9543,6 +9586,7 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 							Verification:  "PROVIDER_API",
 							ExternalID:    fmt.Sprintf("fb-exp-%d", int(expID)),
 							Timestamp:     date + "T00:00:00Z",
+							Metadata:      string(expMeta),
 						})
 						eventsCreated++
 					}
@@ -9620,16 +9664,24 @@ func (s *Server) pollFreshBooks(integrationID, token, configJSON, lastPoll strin
 
 // ReconciliationResult represents a cluster of related transactions
 type ReconciliationResult struct {
-	ClusterID    string   `json:"cluster_id"`
-	Amount       float64  `json:"amount"`
-	DateRange    string   `json:"date_range"`
-	Sources      []string `json:"sources"`       // e.g., ["stripe", "freshbooks", "woocommerce"]
-	EventIDs     []string `json:"event_ids"`     // event IDs in cluster
-	PrimaryID    string   `json:"primary_id"`    // highest-confidence event kept
-	DuplicateIDs []string `json:"duplicate_ids"` // events marked as duplicates
-	TestEvents   []string `json:"test_events"`   // events identified as test/fake
-	Confidence   float64  `json:"confidence"`    // 0-1 how confident this is a real match
-	Status       string   `json:"status"`        // "auto", "manual_confirmed", "manual_rejected"
+	ClusterID    string       `json:"cluster_id"`
+	Amount       float64      `json:"amount"`
+	DateRange    string       `json:"date_range"`
+	Sources      []string     `json:"sources"`           // e.g., ["stripe", "freshbooks", "woocommerce"]
+	EventIDs     []string     `json:"event_ids"`         // event IDs in cluster
+	PrimaryID    string       `json:"primary_id"`        // highest-confidence event kept
+	DuplicateIDs []string     `json:"duplicate_ids"`     // events marked as duplicates
+	TestEvents   []string     `json:"test_events"`       // events identified as test/fake
+	Confidence   float64      `json:"confidence"`        // 0-1 how confident this is a real match
+	Status       string       `json:"status"`            // "auto", "manual_confirmed", "manual_rejected"
+	Matches      []ReconMatch `json:"matches,omitempty"` // per-duplicate match score + reasons
+}
+
+// ReconMatch explains why an event was folded into its cluster's primary.
+type ReconMatch struct {
+	EventID string   `json:"event_id"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
 }
 
 // emitIntegrationEvent is a simple helper to log an event from a poller
@@ -9663,7 +9715,7 @@ func (s *Server) pollGoogleDrive(integrationID, credential, configJSON, lastPoll
 	log.Printf("[gdrive] Scanning Google Drive via API")
 
 	// List files via Drive API v3
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := providerClient(30 * time.Second)
 	var files []map[string]interface{}
 	pageToken := ""
 
@@ -9683,18 +9735,15 @@ func (s *Server) pollGoogleDrive(integrationID, credential, configJSON, lastPoll
 
 		if resp.StatusCode == 401 {
 			resp.Body.Close()
-			// Try to refresh the token
-			if tokenData.RefreshToken == "" {
-				return fmt.Errorf("drive API: access token expired and no refresh token")
-			}
+			// Try to refresh the token (stores it and audits the refresh)
 			log.Printf("[gdrive] Access token expired, refreshing...")
-			newToken, err := s.refreshGoogleToken(tokenData.RefreshToken)
+			newCred, err := s.handleTokenRejected(integrationID, "gdrive", credential)
 			if err != nil {
-				return fmt.Errorf("drive API: token refresh failed: %v", err)
+				return fmt.Errorf("drive API: token refresh failed: %w", err)
 			}
+			credential = newCred
+			newToken := oauthAccessToken(newCred)
 			tokenData.AccessToken = newToken
-			// Update stored credential
-			s.updateIntegrationCredential(integrationID, credential, newToken)
 			// Retry the request
 			req, _ = http.NewRequest("GET", apiURL, nil)
 			req.Header.Set("Authorization", "Bearer "+newToken)
@@ -9746,9 +9795,11 @@ func (s *Server) pollGoogleDrive(integrationID, credential, configJSON, lastPoll
 		})
 	}
 
-	// Save index
-	indexJSON, _ := json.MarshalIndent(indexFiles, "", "  ")
-	os.WriteFile("/data/wirebot/integrations/gdrive_index.json", indexJSON, 0644)
+	// Save index (a dry run must not replace the real one)
+	if !s.dryRun {
+		indexJSON, _ := json.MarshalIndent(indexFiles, "", "  ")
+		os.WriteFile("/data/wirebot/integrations/gdrive_index.json", indexJSON, 0644)
+	}
 
 	// Emit discovery event
 	s.emitIntegrationEvent(integrationID, "GDRIVE_SCAN", "systems",
@@ -9759,14 +9810,18 @@ func (s *Server) pollGoogleDrive(integrationID, credential, configJSON, lastPoll
 
 	// Extract memories from text-based docs (Google Docs, plain text)
 	// Only process files we haven't extracted from before (watermark)
-	go s.extractFromGDriveIndex(indexFiles, tokenData.AccessToken)
+	if !s.dryRun {
+		go s.extractFromGDriveIndex(integrationID, indexFiles, tokenData.AccessToken)
+	}
 
 	return nil
 }
 
 // extractFromGDriveIndex fetches content from text-based GDrive files and runs memory extraction.
 // Rate-limited to 5 files per poll cycle, skips already-processed files.
-func (s *Server) extractFromGDriveIndex(files []map[string]string, accessToken string) {
+// Content is redacted per the integration's sensitivity (redaction.go).
+func (s *Server) extractFromGDriveIndex(integrationID string, files []map[string]string, accessToken string) {
+	sensitivity := s.integrationSensitivity(integrationID)
 	watermarkPath := "/data/wirebot/scoreboard/gdrive_watermark.json"
 	processed := map[string]bool{}
 	if data, err := os.ReadFile(watermarkPath); err == nil {
@@ -9778,7 +9833,7 @@ func (s *Server) extractFromGDriveIndex(files []map[string]string, accessToken s
 		}
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := providerClient(30 * time.Second)
 	extracted := 0
 	maxPerCycle := 5
 
@@ -9827,7 +9882,7 @@ func (s *Server) extractFromGDriveIndex(files []map[string]string, accessToken s
 			continue
 		}
 
-		memories, err := s.ExtractMemoriesFromDocument("gdrive:"+name, content)
+		memories, err := s.ExtractMemoriesFromDocument("gdrive:"+name, content, sensitivity)
 		if err != nil {
 			log.Printf("[gdrive-extract] Error on %s: %v", name, err)
 			continue
@@ -9861,89 +9916,10 @@ func (s *Server) extractFromGDriveIndex(files []map[string]string, accessToken s
 	}
 }
 
-// refreshGoogleToken uses refresh_token to get a new access_token
-func (s *Server) refreshGoogleToken(refreshToken string) (string, error) {
-	data := url.Values{}
-	data.Set("client_id", oauthGoogleClientID)
-	data.Set("client_secret", oauthGoogleClientSecret)
-	data.Set("refresh_token", refreshToken)
-	data.Set("grant_type", "refresh_token")
-
-	resp, err := http.PostForm("https://oauth2.googleapis.com/token", data)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		AccessToken string `json:"access_token"`
-		Error       string `json:"error"`
-		ErrorDesc   string `json:"error_description"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Error != "" {
-		return "", fmt.Errorf("%s: %s", result.Error, result.ErrorDesc)
-	}
-	if result.AccessToken == "" {
-		return "", fmt.Errorf("no access_token in response")
-	}
-	return result.AccessToken, nil
-}
-
-// updateIntegrationCredential updates the stored credential with new access_token
-func (s *Server) updateIntegrationCredential(integrationID, oldCred, newAccessToken string) {
-	// Parse old credential, update access_token, re-encrypt
-	var tokenData map[string]interface{}
-	if err := json.Unmarshal([]byte(oldCred), &tokenData); err != nil {
-		return
-	}
-	tokenData["access_token"] = newAccessToken
-	newCred, _ := json.Marshal(tokenData)
-
-	encrypted, nonce, err := s.encryptCredential(newCred)
-	if err != nil {
-		log.Printf("[gdrive] Failed to encrypt updated credential: %v", err)
-		return
-	}
-	s.mu.Lock()
-	s.db.Exec("UPDATE integrations SET encrypted_data=?, nonce=?, updated_at=? WHERE id=?",
-		encrypted, nonce, time.Now().UTC().Format(time.RFC3339), integrationID)
-	s.mu.Unlock()
-	log.Printf("[gdrive] Updated stored access_token for %s", integrationID)
-}
-
 // ─── Dropbox Integration (REST API) ─────────────────────────────────────
 // Uses Dropbox API v2 with OAuth access token.
 // Indexes file names for task proposals.
 
-func (s *Server) refreshDropboxToken(refreshToken string) (string, error) {
-	data := url.Values{}
-	data.Set("client_id", oauthDropboxClientID)
-	data.Set("client_secret", oauthDropboxClientSecret)
-	data.Set("refresh_token", refreshToken)
-	data.Set("grant_type", "refresh_token")
-
-	resp, err := http.PostForm("https://api.dropboxapi.com/oauth2/token", data)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		AccessToken string `json:"access_token"`
-		Error       string `json:"error"`
-		ErrorDesc   string `json:"error_description"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Error != "" {
-		return "", fmt.Errorf("%s: %s", result.Error, result.ErrorDesc)
-	}
-	if result.AccessToken == "" {
-		return "", fmt.Errorf("no access_token in response")
-	}
-	return result.AccessToken, nil
-}
-
 func (s *Server) pollDropbox(integrationID, credential, configJSON, lastPoll string) error {
 	// credential is OAuth token JSON from callback, extract access_token
 	var tokenData struct {
@@ -9975,7 +9951,7 @@ func (s *Server) pollDropbox(integrationID, credential, configJSON, lastPoll str
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := providerClient(30 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("dropbox list_folder failed: %v", err)
@@ -9984,27 +9960,12 @@ func (s *Server) pollDropbox(integrationID, credential, configJSON, lastPoll str
 
 	if resp.StatusCode == 401 {
 		resp.Body.Close()
-		// Try refreshing the token
-		var fullToken struct {
-			RefreshToken string `json:"refresh_token"`
-		}
-		json.Unmarshal([]byte(credential), &fullToken)
-		if fullToken.RefreshToken != "" && oauthDropboxClientID != "" {
-			newToken, err := s.refreshDropboxToken(fullToken.RefreshToken)
-			if err != nil {
-				return fmt.Errorf("dropbox token refresh failed: %v", err)
-			}
-			// Update stored credential with new access_token
-			var stored map[string]interface{}
-			json.Unmarshal([]byte(credential), &stored)
-			stored["access_token"] = newToken
-			updatedJSON, _ := json.Marshal(stored)
-			enc, nonce, _ := s.encryptCredential(updatedJSON)
-			s.db.Exec("UPDATE integrations SET credential_encrypted=?, credential_nonce=?, last_error='' WHERE id=?", enc, nonce, integrationID)
-			log.Printf("[dropbox] Token refreshed for %s", integrationID)
-			return nil // will succeed on next poll cycle
+		// Try refreshing the token (stores it and audits the refresh)
+		if _, err := s.handleTokenRejected(integrationID, "dropbox", credential); err != nil {
+			return fmt.Errorf("dropbox token refresh failed: %w", err)
 		}
-		return fmt.Errorf("dropbox: expired_access_token (no refresh_token available — reconnect in Settings)")
+		log.Printf("[dropbox] Token refreshed for %s", integrationID)
+		return nil // will succeed on next poll cycle
 	}
 	if resp.StatusCode != 200 {
 		respBody, _ := io.ReadAll(resp.Body)
@@ -10063,9 +10024,11 @@ func (s *Server) pollDropbox(integrationID, credential, configJSON, lastPoll str
 		}
 	}
 
-	// Save index
-	indexJSON, _ := json.MarshalIndent(indexFiles, "", "  ")
-	os.WriteFile("/data/wirebot/integrations/dropbox_index.json", indexJSON, 0644)
+	// Save index (a dry run must not replace the real one)
+	if !s.dryRun {
+		indexJSON, _ := json.MarshalIndent(indexFiles, "", "  ")
+		os.WriteFile("/data/wirebot/integrations/dropbox_index.json", indexJSON, 0644)
+	}
 
 	// Emit discovery event
 	s.emitIntegrationEvent(integrationID, "DROPBOX_SCAN", "systems",
@@ -10075,13 +10038,17 @@ func (s *Server) pollDropbox(integrationID, credential, configJSON, lastPoll str
 	log.Printf("[dropbox] Indexed %d files from Dropbox", len(indexFiles))
 
 	// Extract memories from text-based Dropbox files
-	go s.extractFromDropboxIndex(indexFiles, token)
+	if !s.dryRun {
+		go s.extractFromDropboxIndex(integrationID, indexFiles, token)
+	}
 
 	return nil
 }
 
 // extractFromDropboxIndex fetches content from text/markdown Dropbox files and runs memory extraction.
-func (s *Server) extractFromDropboxIndex(files []map[string]string, accessToken string) {
+// Content is redacted per the integration's sensitivity (redaction.go).
+func (s *Server) extractFromDropboxIndex(integrationID string, files []map[string]string, accessToken string) {
+	sensitivity := s.integrationSensitivity(integrationID)
 	watermarkPath := "/data/wirebot/scoreboard/dropbox_watermark.json"
 	processed := map[string]bool{}
 	if data, err := os.ReadFile(watermarkPath); err == nil {
@@ -10093,7 +10060,7 @@ func (s *Server) extractFromDropboxIndex(files []map[string]string, accessToken
 		}
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := providerClient(30 * time.Second)
 	extracted := 0
 	maxPerCycle := 5
 
@@ -10136,7 +10103,7 @@ func (s *Server) extractFromDropboxIndex(files []map[string]string, accessToken
 			continue
 		}
 
-		memories, err := s.ExtractMemoriesFromDocument("dropbox:"+name, content)
+		memories, err := s.ExtractMemoriesFromDocument("dropbox:"+name, content, sensitivity)
 		if err != nil {
 			log.Printf("[dropbox-extract] Error on %s: %v", name, err)
 			continue
@@ -10174,7 +10141,7 @@ func (s *Server) extractFromDropboxIndex(files []map[string]string, accessToken
 // Polls Stripe API for recent charges, payouts, and balance using API key
 
 func (s *Server) pollStripe(integrationID, apiKey, lastPoll string) error {
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := providerClient(30 * time.Second)
 
 	// Parse lastPoll for filtering
 	var sinceTS int64 = 0
@@ -10241,10 +10208,11 @@ func (s *Server) pollStripe(integrationID, apiKey, lastPoll string) error {
 		s.db.QueryRow("SELECT COUNT(*) FROM events WHERE id = ?", eventID).Scan(&exists)
 		if exists == 0 {
 			timeStr := chargeTime.UTC().Format(time.RFC3339)
-			s.db.Exec(`INSERT INTO events (id, event_type, lane, score_delta, artifact_title, artifact_url, source, timestamp, created_at, status)
-				VALUES (?, 'revenue', 'revenue', ?, ?, ?, 'stripe', ?, ?, 'approved')`,
+			meta, _ := json.Marshal(map[string]interface{}{"amount_minor": charge.Amount, "currency": strings.ToUpper(charge.Currency), "customer": charge.Customer})
+			s.db.Exec(`INSERT INTO events (id, event_type, lane, score_delta, artifact_title, artifact_url, source, timestamp, created_at, status, metadata)
+				VALUES (?, 'revenue', 'revenue', ?, ?, ?, 'stripe', ?, ?, 'approved', ?)`,
 				eventID, int(amountDollars/10), title, fmt.Sprintf("https://dashboard.stripe.com/payments/%s", charge.ID),
-				timeStr, timeStr)
+				timeStr, timeStr, string(meta))
 			eventsCreated++
 		}
 		s.mu.Unlock()
@@ -10285,16 +10253,22 @@ func (s *Server) pollStripe(integrationID, apiKey, lastPoll string) error {
 			s.db.QueryRow("SELECT COUNT(*) FROM events WHERE id = ?", eventID).Scan(&exists)
 			if exists == 0 {
 				timeStr := payoutTime.UTC().Format(time.RFC3339)
-				s.db.Exec(`INSERT INTO events (id, event_type, lane, score_delta, artifact_title, artifact_url, source, timestamp, created_at, status)
-					VALUES (?, 'payout', 'revenue', 0, ?, ?, 'stripe', ?, ?, 'approved')`,
+				meta, _ := json.Marshal(map[string]interface{}{"amount_minor": payout.Amount, "currency": strings.ToUpper(payout.Currency)})
+				s.db.Exec(`INSERT INTO events (id, event_type, lane, score_delta, artifact_title, artifact_url, source, timestamp, created_at, status, metadata)
+					VALUES (?, 'payout', 'revenue', 0, ?, ?, 'stripe', ?, ?, 'approved', ?)`,
 					eventID, title, fmt.Sprintf("https://dashboard.stripe.com/payouts/%s", payout.ID),
-					timeStr, timeStr)
+					timeStr, timeStr, string(meta))
 				eventsCreated++
 			}
 			s.mu.Unlock()
 		}
 	}
 
+	// 3. Subscription ledger (catches transitions a missed webhook never delivered)
+	if err := s.syncStripeSubscriptions(apiKey); err != nil {
+		log.Printf("[stripe] %v", err)
+	}
+
 	log.Printf("[stripe] Polled: %d new events from charges/payouts", eventsCreated)
 	return nil
 }
@@ -10303,7 +10277,7 @@ func (s *Server) pollStripe(integrationID, apiKey, lastPoll string) error {
 // Polls GitHub API for recent commits and activity using PAT
 
 func (s *Server) pollGitHub(integrationID, token, lastPoll string) error {
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := providerClient(30 * time.Second)
 
 	// Parse lastPoll for filtering
 	var sinceTime time.Time
@@ -10467,63 +10441,93 @@ func (s *Server) initReconciliation() {
 		reason TEXT NOT NULL,
 		detected_at TEXT NOT NULL
 	)`)
+	s.initReconcileOverrides()
 }
 
-// ReconcileRevenue runs the full reconciliation pipeline on revenue events
+// ReconcileRevenue runs the full reconciliation pipeline on revenue events.
+// Operator overrides (reconcile.go) are applied on every run, and duplicates
+// released by a split or override get their original score back.
 func (s *Server) ReconcileRevenue() []ReconciliationResult {
+	// 0. Make sure every event carries a base-currency amount (fx.go)
+	s.normalizeRevenueEvents(false)
+	overrides := s.loadReconOverrides()
+
+	// Previous run's state, so cluster IDs stay stable and scores can be restored
+	type priorRecon struct {
+		clusterID string
+		status    string
+		original  *int64
+	}
+	prior := make(map[string]priorRecon)
+	existingCluster := make(map[string]string)
+	if prows, err := s.db.Query(`SELECT event_id, cluster_id, status, original_score FROM reconciled_events`); err == nil {
+		for prows.Next() {
+			var id string
+			var p priorRecon
+			prows.Scan(&id, &p.clusterID, &p.status, &p.original)
+			prior[id] = p
+			existingCluster[id] = p.clusterID
+		}
+		prows.Close()
+	}
+
 	// 1. Fetch all revenue events
-	rows, err := s.db.Query(`SELECT id, event_type, source, artifact_title, score_delta, timestamp, external_id
+	rows, err := s.db.Query(`SELECT id, event_type, source, artifact_title, score_delta, timestamp,
+		COALESCE(external_id,''), COALESCE(metadata,''), COALESCE(confidence,1.0)
 		FROM events WHERE lane='revenue' AND status='approved'
 		ORDER BY timestamp DESC LIMIT 1000`)
 	if err != nil {
 		log.Printf("[reconcile] query error: %v", err)
 		return nil
 	}
-	defer rows.Close()
 
-	type revEvent struct {
-		ID        string
-		EventType string
-		Source    string
-		Title     string
-		Amount    float64
-		Timestamp string
-		ExtID     string
-	}
-	var events []revEvent
+	var events []reconEvent
+	confidence := make(map[string]float64)
 	for rows.Next() {
-		var e revEvent
-		rows.Scan(&e.ID, &e.EventType, &e.Source, &e.Title, &e.Amount, &e.Timestamp, &e.ExtID)
-		// Extract dollar amount from title if score_delta is 0
-		if e.Amount == 0 {
-			e.Amount = extractAmountFromTitle(e.Title)
-		}
+		var e reconEvent
+		var conf float64
+		rows.Scan(&e.ID, &e.EventType, &e.Source, &e.Title, &e.Score, &e.Timestamp, &e.ExtID, &e.Metadata, &conf)
+		confidence[e.ID] = conf
+		// Normalised providers (revenue_providers.go) carry the real amount in metadata
+		if amt, test, ok := revenueMetadataAmount(e.Metadata); ok {
+			e.Amount, e.TestMode = amt, test
+		} else if e.Amount = extractAmountFromTitle(e.Title); e.Amount == 0 {
+			// Legacy events without an amount in the title
+			e.Amount = float64(e.Score)
+			if p, ok := prior[e.ID]; ok && p.original != nil {
+				e.Amount = float64(*p.original)
+			}
+		}
+		e.Time, _ = time.Parse(time.RFC3339, e.Timestamp)
 		events = append(events, e)
 	}
+	rows.Close()
 
 	// 2. Detect test/fake transactions first
 	var testIDs []string
+	isTest := make(map[string]bool)
 	for _, e := range events {
-		if reason := detectTestTransaction(e.Source, e.Title, e.Amount, e.ExtID); reason != "" {
+		reason := detectTestTransaction(canonicalSource(e.Source), e.Title, e.Amount, e.ExtID)
+		if e.TestMode {
+			reason = e.Source + " test mode (metadata.test_mode)"
+		}
+		if reason != "" {
 			testIDs = append(testIDs, e.ID)
+			isTest[e.ID] = true
 			s.db.Exec(`INSERT OR REPLACE INTO test_transactions (event_id, reason, detected_at) VALUES (?, ?, ?)`,
 				e.ID, reason, time.Now().UTC().Format(time.RFC3339))
 		}
 	}
 
-	// 3. Build clusters by amount + date proximity
+	// 3. Build clusters by amount + date proximity, honouring cannot_link
 	type cluster struct {
 		amount    float64
 		dateStart time.Time
 		dateEnd   time.Time
-		events    []revEvent
-	}
-	var clusters []cluster
-
-	isTest := make(map[string]bool)
-	for _, id := range testIDs {
-		isTest[id] = true
+		events    []reconEvent
 	}
+	var clusters []*cluster
+	clusterOf := make(map[string]*cluster)
 
 	for _, e := range events {
 		if isTest[e.ID] {
@@ -10532,121 +10536,238 @@ func (s *Server) ReconcileRevenue() []ReconciliationResult {
 		if e.Amount < 0.01 {
 			continue // skip zero-amount
 		}
-
-		eTime, _ := time.Parse(time.RFC3339, e.Timestamp)
-		if eTime.IsZero() {
-			eTime, _ = time.Parse("2006-01-02T15:04:05Z", e.Timestamp)
+		if reconcileExcludedTypes[e.EventType] {
+			continue // money out never duplicates money in
 		}
 
-		matched := false
-		for i := range clusters {
+		var home *cluster
+		for _, c := range clusters {
 			// Amount within $0.50 and date within 3 days
-			if math.Abs(clusters[i].amount-e.Amount) <= 0.50 {
-				if !eTime.IsZero() {
-					dayDiff := math.Abs(eTime.Sub(clusters[i].dateStart).Hours() / 24)
-					if dayDiff <= 3.0 {
-						clusters[i].events = append(clusters[i].events, e)
-						if eTime.Before(clusters[i].dateStart) {
-							clusters[i].dateStart = eTime
-						}
-						if eTime.After(clusters[i].dateEnd) {
-							clusters[i].dateEnd = eTime
-						}
-						matched = true
-						break
-					}
+			if math.Abs(c.amount-e.Amount) > 0.50 || e.Time.IsZero() {
+				continue
+			}
+			if math.Abs(e.Time.Sub(c.dateStart).Hours()/24) > 3.0 {
+				continue
+			}
+			forbidden := false
+			for _, m := range c.events {
+				if overrides.forbidden(e.ID, m.ID) {
+					forbidden = true
+					break
 				}
 			}
+			if !forbidden {
+				home = c
+				break
+			}
 		}
-		if !matched {
-			clusters = append(clusters, cluster{
-				amount:    e.Amount,
-				dateStart: eTime,
-				dateEnd:   eTime,
-				events:    []revEvent{e},
-			})
+		if home == nil {
+			home = &cluster{amount: e.Amount, dateStart: e.Time, dateEnd: e.Time}
+			clusters = append(clusters, home)
 		}
+		home.events = append(home.events, e)
+		if e.Time.Before(home.dateStart) {
+			home.dateStart = e.Time
+		}
+		if e.Time.After(home.dateEnd) {
+			home.dateEnd = e.Time
+		}
+		clusterOf[e.ID] = home
+	}
+
+	// Operator merges win over the amount/date heuristic
+	for _, link := range overrides.mustLink {
+		a, b := clusterOf[link[0]], clusterOf[link[1]]
+		if a == nil || b == nil || a == b {
+			continue
+		}
+		for _, e := range b.events {
+			clusterOf[e.ID] = a
+		}
+		a.events = append(a.events, b.events...)
+		if b.dateStart.Before(a.dateStart) {
+			a.dateStart = b.dateStart
+		}
+		if b.dateEnd.After(a.dateEnd) {
+			a.dateEnd = b.dateEnd
+		}
+		b.events = nil
 	}
 
 	// 4. Process clusters — multi-source clusters are probable duplicates
-	var results []ReconciliationResult
-	for idx, c := range clusters {
+	type pending struct {
+		c       *cluster
+		primary int
+		manual  bool
+	}
+	var kept []pending
+	for _, c := range clusters {
 		if len(c.events) < 2 {
 			continue // single event = no reconciliation needed
 		}
 
-		// Check if multiple sources
+		manual := false
 		sources := make(map[string]bool)
 		for _, e := range c.events {
-			sources[e.Source] = true
+			sources[canonicalSource(e.Source)] = true
+			manual = manual || overrides.touched[e.ID]
 		}
-		if len(sources) < 2 {
+		if len(sources) < 2 && !manual {
 			continue // all from same source = not cross-platform duplicate
 		}
 
-		var sourceList []string
-		for src := range sources {
-			sourceList = append(sourceList, src)
-		}
-
-		// Pick primary: highest confidence source priority
-		// stripe > freshbooks > woocommerce > memberpress > manual
-		primaryIdx := 0
-		primaryScore := sourcePriority(c.events[0].Source)
+		// Pick primary: pinned by the operator, else highest source priority
+		// stripe > paddle/lemonsqueezy/gumroad > freshbooks > plaid > shopify > woocommerce > manual
+		primaryIdx := -1
 		for i, e := range c.events {
-			p := sourcePriority(e.Source)
-			if p > primaryScore {
-				primaryScore = p
+			if overrides.primary[e.ID] {
 				primaryIdx = i
+				break
 			}
 		}
+		if primaryIdx < 0 {
+			primaryIdx = 0
+			for i, e := range c.events {
+				if sourcePriority(e.Source) > sourcePriority(c.events[primaryIdx].Source) {
+					primaryIdx = i
+				}
+			}
+		}
+		kept = append(kept, pending{c, primaryIdx, manual})
+	}
 
-		var eventIDs, dupIDs []string
-		for i, e := range c.events {
+	// Clusters keep the ID their primary had last run; others inherit from a
+	// member or get a fresh one, never colliding with an ID already claimed.
+	ids := make([]string, len(kept))
+	used := make(map[string]bool)
+	for i, k := range kept {
+		if id := existingCluster[k.c.events[k.primary].ID]; id != "" && !used[id] {
+			ids[i], used[id] = id, true
+		}
+	}
+	for i, k := range kept {
+		if ids[i] != "" {
+			continue
+		}
+		ids[i] = stableClusterID(existingCluster, used, k.c.events[k.primary].ID, k.c.events)
+		used[ids[i]] = true
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var results []ReconciliationResult
+	duplicate := make(map[string]bool)
+	inCluster := make(map[string]bool)
+	changedDates := make(map[string]bool)
+	for i, k := range kept {
+		c, primary := k.c, k.c.events[k.primary]
+		clusterID := ids[i]
+
+		sourceSet := make(map[string]bool)
+		var sourceList, eventIDs, dupIDs []string
+		var matches []ReconMatch
+		var dupEvents []reconEvent
+		total := 0.0
+		for j, e := range c.events {
 			eventIDs = append(eventIDs, e.ID)
-			if i != primaryIdx {
-				dupIDs = append(dupIDs, e.ID)
+			inCluster[e.ID] = true
+			if src := canonicalSource(e.Source); !sourceSet[src] {
+				sourceSet[src] = true
+				sourceList = append(sourceList, src)
+			}
+			if j == k.primary {
+				continue
 			}
+			score, reasons := scoreReconMatch(primary, e)
+			dupIDs = append(dupIDs, e.ID)
+			dupEvents = append(dupEvents, e)
+			duplicate[e.ID] = true
+			matches = append(matches, ReconMatch{EventID: e.ID, Score: score, Reasons: reasons})
+			total += score
 		}
 
-		// Confidence: more sources = higher confidence this is real
-		conf := math.Min(1.0, 0.5+float64(len(sources))*0.2)
-
-		clusterID := fmt.Sprintf("rc-%d-%d", idx, time.Now().Unix())
+		conf := math.Round(total/float64(len(matches))*1000) / 1000
+		status := "auto"
+		if k.manual {
+			conf, status = 1.0, "manual_confirmed"
+		}
 		results = append(results, ReconciliationResult{
 			ClusterID:    clusterID,
-			Amount:       c.amount,
+			Amount:       primary.Amount,
 			DateRange:    c.dateStart.Format("2006-01-02") + " → " + c.dateEnd.Format("2006-01-02"),
 			Sources:      sourceList,
 			EventIDs:     eventIDs,
-			PrimaryID:    c.events[primaryIdx].ID,
+			PrimaryID:    primary.ID,
 			DuplicateIDs: dupIDs,
 			TestEvents:   nil,
 			Confidence:   conf,
-			Status:       "auto",
+			Status:       status,
+			Matches:      matches,
 		})
 
-		// Mark duplicates in DB
-		for _, dupID := range dupIDs {
-			s.db.Exec(`INSERT OR REPLACE INTO reconciled_events (event_id, cluster_id, status, primary_event_id, reconciled_at)
-				VALUES (?, ?, 'duplicate', ?, ?)`,
-				dupID, clusterID, c.events[primaryIdx].ID, time.Now().UTC().Format(time.RFC3339))
+		manualFlag := 0
+		if k.manual {
+			manualFlag = 1
+		}
+		s.db.Exec(`INSERT OR REPLACE INTO reconciled_events
+			(event_id, cluster_id, status, primary_event_id, reconciled_at, match_score, reasons, original_score, manual)
+			VALUES (?, ?, 'primary', ?, ?, 1, '[]', NULL, ?)`,
+			primary.ID, clusterID, primary.ID, now, manualFlag)
+
+		// Mark duplicates in DB, remembering the score we zero out
+		for j, e := range dupEvents {
+			m := matches[j]
+			var original interface{} = e.Score
+			if p, ok := prior[e.ID]; ok && p.status == "duplicate" {
+				original = nil
+				if p.original != nil {
+					original = *p.original
+				}
+			}
+			reasonsJSON, _ := json.Marshal(m.Reasons)
+			s.db.Exec(`INSERT OR REPLACE INTO reconciled_events
+				(event_id, cluster_id, status, primary_event_id, reconciled_at, match_score, reasons, original_score, manual)
+				VALUES (?, ?, 'duplicate', ?, ?, ?, ?, ?, ?)`,
+				e.ID, clusterID, primary.ID, now, m.Score, string(reasonsJSON), original, manualFlag)
 			// Zero out the duplicate's score to prevent double-counting
-			s.db.Exec(`UPDATE events SET score_delta = 0 WHERE id = ?`, dupID)
+			if e.Score != 0 {
+				s.db.Exec(`UPDATE events SET score_delta = 0 WHERE id = ?`, e.ID)
+				changedDates[e.Time.In(operatorTZ).Format("2006-01-02")] = true
+			}
 		}
 	}
 
-	if len(results) > 0 || len(testIDs) > 0 {
-		log.Printf("[reconcile] processed %d clusters, %d duplicates zeroed, %d test transactions flagged",
-			len(results),
-			func() int {
-				c := 0
-				for _, r := range results {
-					c += len(r.DuplicateIDs)
-				}
-				return c
-			}(),
-			len(testIDs))
+	// 5. Release events that are no longer duplicates (split, override, or the
+	// other side disappeared). Only events in this run's window are touched.
+	released := 0
+	for _, e := range events {
+		p, ok := prior[e.ID]
+		if !ok || duplicate[e.ID] {
+			continue
+		}
+		if p.status == "duplicate" {
+			restored := calcScoreDelta("revenue", e.EventType, confidence[e.ID])
+			if p.original != nil {
+				restored = int(*p.original)
+			}
+			s.db.Exec(`UPDATE events SET score_delta = ? WHERE id = ?`, restored, e.ID)
+			changedDates[e.Time.In(operatorTZ).Format("2006-01-02")] = true
+			released++
+		}
+		if !inCluster[e.ID] {
+			s.db.Exec(`DELETE FROM reconciled_events WHERE event_id = ?`, e.ID)
+		}
+	}
+
+	if len(changedDates) > 0 {
+		for date := range changedDates {
+			s.updateDailyScore(date)
+		}
+		s.recalcSeason()
+	}
+
+	if len(results) > 0 || len(testIDs) > 0 || released > 0 {
+		log.Printf("[reconcile] processed %d clusters, %d duplicates zeroed, %d released, %d test transactions flagged",
+			len(results), len(duplicate), released, len(testIDs))
 	}
 
 	return results
@@ -10714,13 +10835,17 @@ func extractAmountFromTitle(title string) float64 {
 
 // sourcePriority returns a score for source trustworthiness (higher = more authoritative)
 func sourcePriority(source string) int {
-	switch source {
+	switch canonicalSource(source) {
 	case "stripe":
 		return 100 // payment processor = source of truth
 	case "freshbooks":
 		return 90 // accounting system
+	case "paddle", "lemonsqueezy", "gumroad":
+		return 95 // merchant of record
 	case "plaid":
 		return 85 // bank account
+	case "shopify":
+		return 75 // storefront with its own payments ledger
 	case "woocommerce":
 		return 70 // storefront
 	case "memberpress":
@@ -10748,21 +10873,30 @@ func (s *Server) handleReconcile(w http.ResponseWriter, r *http.Request) {
 				rows.Scan(&clusterID)
 				var r ReconciliationResult
 				r.ClusterID = clusterID
+				r.Status = "auto"
 				// Get events in cluster
-				erows, _ := s.db.Query(`SELECT event_id, status, primary_event_id FROM reconciled_events WHERE cluster_id=?`, clusterID)
+				erows, _ := s.db.Query(`SELECT event_id, status, primary_event_id, COALESCE(match_score,0),
+					COALESCE(reasons,'[]'), COALESCE(manual,0) FROM reconciled_events WHERE cluster_id=?`, clusterID)
 				if erows != nil {
 					for erows.Next() {
-						var eid, st, pid string
-						erows.Scan(&eid, &st, &pid)
+						var eid, st, pid, reasonsJSON string
+						var score float64
+						var manual int
+						erows.Scan(&eid, &st, &pid, &score, &reasonsJSON, &manual)
 						r.EventIDs = append(r.EventIDs, eid)
 						if st == "duplicate" {
 							r.DuplicateIDs = append(r.DuplicateIDs, eid)
+							m := ReconMatch{EventID: eid, Score: score}
+							json.Unmarshal([]byte(reasonsJSON), &m.Reasons)
+							r.Matches = append(r.Matches, m)
 						}
 						r.PrimaryID = pid
+						if manual == 1 {
+							r.Status = "manual_confirmed"
+						}
 					}
 					erows.Close()
 				}
-				r.Status = "auto"
 				recon = append(recon, r)
 			}
 		}
@@ -11100,22 +11234,31 @@ func truncateRunes(s string, n int) string {
 	return string(r[:n])
 }
 
-// mem0Store sends a fact to Mem0 via /v1/store (the correct endpoint).
-// Mem0 uses an LLM for fact extraction, so allow 30s timeout.
+// mem0Store sends a fact to the memory store. On Mem0 it goes in as a
+// message, so Mem0's LLM extracts the facts.
 func (s *Server) mem0Store(text, interactionID string) {
-	payload, _ := json.Marshal(map[string]interface{}{
-		"messages":  []map[string]string{{"role": "user", "content": text}},
-		"namespace": "wirebot_verious",
-		"category":  "training-feedback",
-	})
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Post("http://127.0.0.1:8200/v1/store", "application/json", bytes.NewReader(payload))
+	store := s.memoryStore()
+	var ids []string
+	var err error
+	if m, ok := store.(*mem0MemoryStore); ok {
+		ids, err = m.PutMessages([]map[string]string{{"role": "user", "content": text}}, "training-feedback")
+	} else {
+		ids, err = store.Put(text, "training-feedback")
+	}
 	if err != nil {
-		log.Printf("[training] Mem0 store error: %v", err)
+		log.Printf("[training] %s store error: %v", store.Name(), err)
 		return
 	}
-	resp.Body.Close()
-	log.Printf("[training] Mem0 stored for %s", interactionID)
+	// Provenance under the interaction, so a training memory can be forgotten too
+	memID := "interaction:" + interactionID
+	if len(ids) > 0 {
+		for _, mid := range ids {
+			s.recordProvenance(memID, store.Name(), mid, text)
+		}
+	} else {
+		s.recordProvenance(memID, store.Name(), "", text)
+	}
+	log.Printf("[training] %s stored for %s", store.Name(), interactionID)
 }
 
 // queueForLetta inserts content into the scoreboard memory queue.
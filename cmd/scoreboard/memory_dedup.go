@@ -0,0 +1,416 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MEMORY DEDUP — near-duplicate detection before anything reaches the queue
+//
+// Re-running extract-vault, or talking about the same thing twice, produces
+// paraphrases of memories that are already pending, approved or rejected.
+// QueueMemoryForApproval checks every extraction against all of them first;
+// a near-duplicate is merged into the existing item instead of queued:
+//
+//   - the item's confidence is raised (noisy-OR: two independent sources at
+//     0.7 make 0.91), whatever its status — a rejected item stays rejected.
+//     A document the item already came from doesn't count again
+//   - memory_merges keeps the duplicate's source, so the reviewer can see
+//     what corroborated it (GET /v1/memory/item/{id}/provenance)
+//   - memory_queue.merge_count is shown in the queue listing
+//
+// Similarity is Jaccard over normalised tokens (lowercased, stopwords out,
+// crude suffix stemming). MinHash signatures (32 hashes, 16 LSH bands of 2)
+// are indexed in memory_dedup_bands, so a new extraction is only compared
+// against items sharing a band; pairs at Jaccard 0.5 collide with ~99%
+// probability. A pair is a duplicate at Jaccard >= 0.6, or when the shorter
+// is >= 85% contained in the longer — unless their numbers differ ("MRR $2k"
+// vs "MRR $3k"), only one is negated ("uses Stripe" vs "no longer uses
+// Stripe"), or they state different values for the same fact
+// (memory_facts.go). Those are updates, not duplicates.
+//
+// Forgotten memories only leave a sha256 (memory_provenance.go); an exact
+// re-extraction of one is dropped.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+const (
+	dedupHashes      = 32
+	dedupBandRows    = 2
+	dedupJaccard     = 0.6
+	dedupContainment = 0.85
+)
+
+// memoryDedupMu serialises check-then-insert, so two concurrent extractions of
+// the same fact don't both get queued.
+var memoryDedupMu sync.Mutex
+
+func (s *Server) initMemoryDedup() {
+	for _, stmt := range []string{
+		`ALTER TABLE memory_queue ADD COLUMN merge_count INTEGER DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS memory_dedup_index (
+			memory_id TEXT PRIMARY KEY,
+			indexed_text TEXT NOT NULL,
+			tokens TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS memory_dedup_bands (
+			band INTEGER NOT NULL,
+			bucket TEXT NOT NULL,
+			memory_id TEXT NOT NULL,
+			PRIMARY KEY (band, bucket, memory_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_dedup_bands_memory ON memory_dedup_bands(memory_id)`,
+		`CREATE TABLE IF NOT EXISTS memory_merges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			memory_id TEXT NOT NULL,
+			text TEXT NOT NULL,
+			source_type TEXT DEFAULT '',
+			source_file TEXT DEFAULT '',
+			source_context TEXT DEFAULT '',
+			confidence REAL DEFAULT 0,
+			similarity REAL DEFAULT 0,
+			merged_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_memory_merges_memory ON memory_merges(memory_id)`,
+	} {
+		s.db.Exec(stmt)
+	}
+}
+
+var (
+	dedupWordRe     = regexp.MustCompile(`[\pL\pN]+(?:[.,]\d+)*`)
+	dedupNumberRe   = regexp.MustCompile(`\d+(?:[.,]\d+)*\s*[km%]?`)
+	dedupNegationRe = regexp.MustCompile(`(?i)\b(?:not|no|never|none|nobody|nothing|without|stopped|quit)\b|n't\b`)
+)
+
+// dedupStopwords extends the search stopwords with pronouns and fillers that
+// paraphrases add or drop freely. Negations are kept on purpose.
+var dedupStopwords = func() map[string]bool {
+	m := map[string]bool{}
+	for w := range ftsStopwords {
+		m[w] = true
+	}
+	for _, w := range strings.Fields(`i me my we us our he him his she her they them their you your
+		be been being am this that these those there very really also just about as by into its
+		currently now called named`) {
+		m[w] = true
+	}
+	return m
+}()
+
+// dedupStem folds common inflections: lives/live/living → liv.
+func dedupStem(w string) string {
+	for _, suf := range []string{"ing", "ed", "es", "s", "e"} {
+		if len(w)-len(suf) >= 3 && strings.HasSuffix(w, suf) {
+			return w[:len(w)-len(suf)]
+		}
+	}
+	return w
+}
+
+// dedupTokens returns the sorted, unique normalised tokens of text.
+func dedupTokens(text string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, w := range dedupWordRe.FindAllString(strings.ToLower(text), -1) {
+		if dedupStopwords[w] {
+			continue
+		}
+		if w[0] < '0' || w[0] > '9' {
+			w = dedupStem(w)
+		}
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func dedupNumbers(text string) string {
+	nums := dedupNumberRe.FindAllString(strings.ToLower(text), -1)
+	for i, n := range nums {
+		nums[i] = strings.ReplaceAll(strings.ReplaceAll(n, " ", ""), ",", "")
+	}
+	sort.Strings(nums)
+	return strings.Join(nums, " ")
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// dedupBands computes the MinHash signature of tokens and folds it into LSH
+// band buckets.
+func dedupBands(tokens []string) []string {
+	if len(tokens) == 0 {
+		return nil
+	}
+	var sig [dedupHashes]uint64
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for _, t := range tokens {
+		h := fnv.New64a()
+		h.Write([]byte(t))
+		base := h.Sum64()
+		for i := range sig {
+			if v := splitmix64(base ^ uint64(i)*0x9e3779b97f4a7c15); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	bands := make([]string, 0, dedupHashes/dedupBandRows)
+	for b := 0; b < dedupHashes; b += dedupBandRows {
+		acc := uint64(b)
+		for r := 0; r < dedupBandRows; r++ {
+			acc = splitmix64(acc ^ sig[b+r])
+		}
+		bands = append(bands, fmt.Sprintf("%016x", acc))
+	}
+	return bands
+}
+
+// dedupSimilarity scores two token sets: Jaccard, and containment of the
+// smaller set in the larger.
+func dedupSimilarity(a, b []string) (jaccard, containment float64) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, 0
+	}
+	set := make(map[string]bool, len(a))
+	for _, t := range a {
+		set[t] = true
+	}
+	inter := 0
+	for _, t := range b {
+		if set[t] {
+			inter++
+		}
+	}
+	union := len(a) + len(b) - inter
+	return float64(inter) / float64(union), float64(inter) / float64(min(len(a), len(b)))
+}
+
+// dedupFactsDiffer reports whether a and b state different values for the
+// same fact — "based in Corona" vs "based in Austin" overlap heavily but are
+// a change, not a duplicate.
+func dedupFactsDiffer(a, b string) bool {
+	now := time.Now()
+	fb := parseFacts(b, now)
+	for _, x := range parseFacts(a, now) {
+		for _, y := range fb {
+			if x.Subject != y.Subject || x.Attribute != y.Attribute || factAttributes[x.Attribute].multi ||
+				factsCompatible(x.Attribute, x, y) {
+				continue
+			}
+			// "corona california" vs "corona, ca": one value inside the other
+			if _, cont := dedupSimilarity(dedupTokens(x.Value), dedupTokens(y.Value)); cont < 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// memoryDuplicate is an existing queue item that an extraction duplicates.
+type memoryDuplicate struct {
+	ID         string
+	Text       string
+	Status     string
+	Confidence float64
+	Similarity float64
+}
+
+// syncDedupIndex (re)indexes queue items whose text is new or changed
+// (corrections) and drops ones that were forgotten. Callers hold memoryDedupMu.
+func (s *Server) syncDedupIndex() {
+	type stale struct{ id, text string }
+	var todo []stale
+	rows, err := s.db.Query(`SELECT q.id, CASE WHEN COALESCE(q.correction,'') != '' THEN q.correction ELSE q.memory_text END
+		FROM memory_queue q LEFT JOIN memory_dedup_index d ON d.memory_id = q.id
+		WHERE d.memory_id IS NULL
+		   OR d.indexed_text != CASE WHEN COALESCE(q.correction,'') != '' THEN q.correction ELSE q.memory_text END`)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var st stale
+		rows.Scan(&st.id, &st.text)
+		todo = append(todo, st)
+	}
+	rows.Close()
+	if len(todo) == 0 {
+		return
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	for _, st := range todo {
+		tokens := dedupTokens(st.text)
+		tx.Exec(`DELETE FROM memory_dedup_bands WHERE memory_id=?`, st.id)
+		tx.Exec(`INSERT OR REPLACE INTO memory_dedup_index (memory_id, indexed_text, tokens) VALUES (?,?,?)`,
+			st.id, st.text, strings.Join(tokens, " "))
+		for b, bucket := range dedupBands(tokens) {
+			tx.Exec(`INSERT OR IGNORE INTO memory_dedup_bands (band, bucket, memory_id) VALUES (?,?,?)`, b, bucket, st.id)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[memory-dedup] index: %v", err)
+	}
+}
+
+// findNearDuplicate returns the queue item text duplicates, or nil. Callers
+// hold memoryDedupMu.
+func (s *Server) findNearDuplicate(text string) *memoryDuplicate {
+	s.syncDedupIndex()
+
+	// A forgotten memory coming back verbatim
+	var forgotten string
+	s.db.QueryRow(`SELECT memory_id FROM memory_provenance WHERE destination='queue' AND detail=? LIMIT 1`,
+		fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(text)))).Scan(&forgotten)
+	if forgotten != "" {
+		return &memoryDuplicate{ID: forgotten, Status: "forgotten", Similarity: 1}
+	}
+
+	tokens := dedupTokens(text)
+	bands := dedupBands(tokens)
+	if len(bands) == 0 {
+		return nil
+	}
+	var conds []string
+	var args []interface{}
+	for b, bucket := range bands {
+		conds = append(conds, "(b.band=? AND b.bucket=?)")
+		args = append(args, b, bucket)
+	}
+	// Pending or confirmed reconfirm items copy their original's text; match
+	// the original. An approved one (updated, or a stored memory adopted on
+	// confirm) is the live memory now. Retired ones stand for a memory that is
+	// no longer true, like a rejection.
+	rows, err := s.db.Query(`SELECT DISTINCT q.id, d.indexed_text, d.tokens, q.status, q.confidence
+		FROM memory_dedup_bands b
+		JOIN memory_dedup_index d ON d.memory_id = b.memory_id
+		JOIN memory_queue q ON q.id = b.memory_id
+		WHERE (`+strings.Join(conds, " OR ")+`)
+		  AND (q.status IN ('pending','approved','rejected') AND (q.source_type != 'reconfirm' OR q.status = 'approved')
+		       OR q.status = 'retired')`,
+		args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	normText := normalizeMemoryText(text)
+	nums := dedupNumbers(text)
+	negated := dedupNegationRe.MatchString(text)
+	var best *memoryDuplicate
+	for rows.Next() {
+		var c memoryDuplicate
+		var candTokens string
+		rows.Scan(&c.ID, &c.Text, &candTokens, &c.Status, &c.Confidence)
+		if normalizeMemoryText(c.Text) == normText {
+			c.Similarity = 1
+		} else {
+			ct := strings.Fields(candTokens)
+			if len(tokens) < 2 || len(ct) < 2 {
+				continue // too short to call a paraphrase
+			}
+			jac, cont := dedupSimilarity(tokens, ct)
+			if jac < dedupJaccard && (cont < dedupContainment || min(len(tokens), len(ct)) < 3) {
+				continue
+			}
+			if dedupNumbers(c.Text) != nums || dedupNegationRe.MatchString(c.Text) != negated ||
+				dedupFactsDiffer(text, c.Text) {
+				continue
+			}
+			c.Similarity = jac
+		}
+		// Prefer the closest; on ties, an approved item over a pending one
+		if best == nil || c.Similarity > best.Similarity ||
+			c.Similarity == best.Similarity && c.Status == "approved" && best.Status != "approved" {
+			cc := c
+			best = &cc
+		}
+	}
+	return best
+}
+
+// mergeDuplicate folds extraction m into dup: confidence goes up, and the
+// duplicate's source is kept in memory_merges.
+func (s *Server) mergeDuplicate(dup *memoryDuplicate, m MemoryExtraction) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	sourceFile := m.SourceFile
+	if m.SourceSection != "" {
+		sourceFile = fmt.Sprintf("%s [%s]", m.SourceFile, m.SourceSection)
+	}
+	// Checked before this merge is recorded: the same document seen again
+	// (a re-run, an edited section, a repeat after a crash) is not a second source
+	seenSource := s.mergeSourceSeen(dup.ID, m.SourceFile)
+	s.db.Exec(`INSERT INTO memory_merges (memory_id, text, source_type, source_file, source_context, confidence, similarity, merged_at)
+		VALUES (?,?,?,?,?,?,?,?)`, dup.ID, m.MemoryText, m.SourceType, sourceFile, truncateRunes(m.SourceContext, 500),
+		m.Confidence, dup.Similarity, now)
+	if dup.Status == "forgotten" {
+		log.Printf("[memory-dedup] dropped re-extraction of forgotten %s", dup.ID)
+		return
+	}
+	conf := dup.Confidence
+	if m.Confidence > 0 && m.Confidence < 1 && !seenSource {
+		conf = 1 - (1-dup.Confidence)*(1-m.Confidence)
+	}
+	conf = min(max(conf, dup.Confidence), 0.99)
+	s.db.Exec(`UPDATE memory_queue SET confidence=?, merge_count=COALESCE(merge_count,0)+1 WHERE id=?`, conf, dup.ID)
+	log.Printf("[memory-dedup] merged into %s (%s, sim=%.2f, conf %.2f→%.2f): %s",
+		dup.ID, dup.Status, dup.Similarity, dup.Confidence, conf, truncateRunes(m.MemoryText, 60))
+}
+
+// mergeSourceSeen reports whether file (without its " [section]" suffix) is
+// already the item's own source or one of its earlier merges.
+func (s *Server) mergeSourceSeen(memID, file string) bool {
+	if file == "" {
+		return false
+	}
+	prefix := file + " ["
+	var n int
+	s.db.QueryRow(`SELECT (SELECT COUNT(*) FROM memory_queue WHERE id=?1 AND (source_file=?2 OR substr(source_file, 1, ?3)=?4))
+		+ (SELECT COUNT(*) FROM memory_merges WHERE memory_id=?1 AND (source_file=?2 OR substr(source_file, 1, ?3)=?4))`,
+		memID, file, len([]rune(prefix)), prefix).Scan(&n)
+	return n > 0
+}
+
+type memoryMerge struct {
+	Text          string  `json:"text"`
+	SourceType    string  `json:"source_type"`
+	SourceFile    string  `json:"source_file,omitempty"`
+	SourceContext string  `json:"source_context,omitempty"`
+	Confidence    float64 `json:"confidence"`
+	Similarity    float64 `json:"similarity"`
+	MergedAt      string  `json:"merged_at"`
+}
+
+func (s *Server) memoryMerges(memID string) []memoryMerge {
+	out := []memoryMerge{}
+	rows, err := s.db.Query(`SELECT text, source_type, source_file, source_context, confidence, similarity, merged_at
+		FROM memory_merges WHERE memory_id=? ORDER BY id`, memID)
+	if err != nil {
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m memoryMerge
+		rows.Scan(&m.Text, &m.SourceType, &m.SourceFile, &m.SourceContext, &m.Confidence, &m.Similarity, &m.MergedAt)
+		out = append(out, m)
+	}
+	return out
+}
@@ -1,15 +1,11 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -206,7 +202,8 @@ If no personal facts can be extracted with real quotes, return: []`,
 // ExtractMemoriesFromDocument uses LLM to extract personal facts with real quoted context.
 // Uses document classification for type-specific extraction prompts.
 // Long documents are chunked to avoid truncation loss.
-func (s *Server) ExtractMemoriesFromDocument(docPath, content string) ([]MemoryExtraction, error) {
+// PII is redacted per the source's sensitivity before anything reaches the LLM (redaction.go).
+func (s *Server) ExtractMemoriesFromDocument(docPath, content, sensitivity string) ([]MemoryExtraction, error) {
 	// Don't process tiny files
 	if len(content) < 100 {
 		return nil, nil
@@ -215,6 +212,15 @@ func (s *Server) ExtractMemoriesFromDocument(docPath, content string) ([]MemoryE
 	// Classify document for type-specific extraction
 	docType, meta := classifyDocument(docPath, content)
 
+	// Redact locally; quotes are verified against the redacted text the LLM saw
+	rd := s.newRedactor(sensitivity)
+	content = rd.Redact(content)
+	meta = rd.RedactMeta(meta)
+	promptPath := rd.Redact(docPath)
+	if n := redactionSummary(rd.Counts()); n != "" {
+		log.Printf("[memory-extract] Redacted %s in %s", n, docPath)
+	}
+
 	// Strip YAML frontmatter from content before sending to LLM
 	extractContent := content
 	if strings.HasPrefix(strings.TrimSpace(content), "---") {
@@ -231,12 +237,12 @@ func (s *Server) ExtractMemoriesFromDocument(docPath, content string) ([]MemoryE
 
 	if len(extractContent) <= chunkSize+overlap {
 		// Single chunk — fast path
-		prompt := buildExtractionPrompt(docPath, extractContent, docType, meta)
+		prompt := buildExtractionPrompt(promptPath, redactionPromptNote+extractContent, docType, meta)
 		memories, err := s.extractFromPrompt(docPath, content, prompt, docType)
 		if err != nil {
 			return nil, err
 		}
-		return memories, nil
+		return rd.RestoreMemories(memories), nil
 	}
 
 	// Multi-chunk: slide through document with overlap
@@ -249,11 +255,14 @@ func (s *Server) ExtractMemoriesFromDocument(docPath, content string) ([]MemoryE
 		chunk := extractContent[offset:end]
 		chunkNum++
 
-		chunkPath := fmt.Sprintf("%s [chunk %d]", docPath, chunkNum)
-		prompt := buildExtractionPrompt(chunkPath, chunk, docType, meta)
+		chunkPath := fmt.Sprintf("%s [chunk %d]", promptPath, chunkNum)
+		prompt := buildExtractionPrompt(chunkPath, redactionPromptNote+chunk, docType, meta)
 		memories, err := s.extractFromPrompt(docPath, content, prompt, docType)
 		if err != nil {
 			log.Printf("[memory-extract] Chunk %d error for %s: %v", chunkNum, docPath, err)
+			if onChunkError != nil {
+				onChunkError(docPath, chunkNum, err)
+			}
 			continue
 		}
 		allMemories = append(allMemories, memories...)
@@ -278,9 +287,17 @@ func (s *Server) ExtractMemoriesFromDocument(docPath, content string) ([]MemoryE
 		}
 	}
 
-	return deduped, nil
+	return rd.RestoreMemories(deduped), nil
 }
 
+// onQuoteRejected, when set, sees every extraction dropped because its quote is
+// not in the document. The extraction eval (extract_eval.go) counts hallucinations with it.
+var onQuoteRejected func(docPath, memory, quote string)
+
+// onChunkError, when set, sees every chunk of a long document whose extraction
+// failed; the document carries on without it, so the eval counts these too.
+var onChunkError func(docPath string, chunk int, err error)
+
 // extractFromPrompt handles the LLM call and response parsing for a single extraction prompt.
 func (s *Server) extractFromPrompt(docPath, fullContent, prompt, docType string) ([]MemoryExtraction, error) {
 
@@ -322,6 +339,9 @@ func (s *Server) extractFromPrompt(docPath, fullContent, prompt, docType string)
 		// Verify quote actually exists in content (fuzzy match)
 		if !containsFuzzy(fullContent, e.Quote) {
 			log.Printf("[memory-extract] Quote not found in doc, skipping: %s", e.Quote[:min(50, len(e.Quote))])
+			if onQuoteRejected != nil {
+				onQuoteRejected(docPath, e.Memory, e.Quote)
+			}
 			continue
 		}
 		// Build context with reasoning and entities
@@ -494,61 +514,15 @@ func ExtractMemoryFromPairing(questionID, questionText, answerText string) Memor
 }
 
 // QueueMemoryForApproval adds an extraction to the approval queue with dedup.
-// Skips if an identical memory_text already exists (any status).
+// A near-duplicate of an existing item (any status) is merged into it instead
+// (memory_dedup.go).
 // Auto-approves high-confidence items per MEMORY_APPROVAL_SYSTEM.md rules.
 func (s *Server) QueueMemoryForApproval(m MemoryExtraction) error {
-	// Dedup: check exact match first, then fuzzy match against recent items
-	var existing int
-	s.db.QueryRow(`SELECT COUNT(*) FROM memory_queue WHERE memory_text = ?`, m.MemoryText).Scan(&existing)
-	if existing > 0 {
-		return nil // exact match, skip silently
-	}
-
-	// Fuzzy dedup: check if a semantically similar memory already exists.
-	// Normalize current memory to token set, compare against recent queue items.
-	normNew := strings.ToLower(strings.Join(strings.Fields(m.MemoryText), " "))
-	newTokens := make(map[string]bool)
-	for _, t := range strings.Fields(normNew) {
-		if len(t) >= 3 {
-			newTokens[t] = true
-		}
-	}
-	if len(newTokens) >= 3 {
-		// Check against items from same source file (most likely duplicates)
-		isDupe := false
-		rows, err := s.db.Query(`SELECT memory_text FROM memory_queue WHERE source_file LIKE ? LIMIT 100`,
-			strings.Split(m.SourceFile, " [")[0]+"%")
-		if err == nil {
-			for rows.Next() {
-				var existingText string
-				rows.Scan(&existingText)
-				normExisting := strings.ToLower(strings.Join(strings.Fields(existingText), " "))
-				existingTokens := make(map[string]bool)
-				for _, t := range strings.Fields(normExisting) {
-					if len(t) >= 3 {
-						existingTokens[t] = true
-					}
-				}
-				overlap := 0
-				for t := range newTokens {
-					if existingTokens[t] {
-						overlap++
-					}
-				}
-				if len(existingTokens) > 0 {
-					fwd := float64(overlap) / float64(len(newTokens))
-					rev := float64(overlap) / float64(len(existingTokens))
-					if fwd >= 0.8 && rev >= 0.8 {
-						isDupe = true
-						break
-					}
-				}
-			}
-			rows.Close()
-		}
-		if isDupe {
-			return nil // near-duplicate, skip
-		}
+	memoryDedupMu.Lock()
+	defer memoryDedupMu.Unlock()
+	if dup := s.findNearDuplicate(m.MemoryText); dup != nil {
+		s.mergeDuplicate(dup, m)
+		return nil
 	}
 
 	id := fmt.Sprintf("mem-%d-%04x", time.Now().UnixNano(), rand.Intn(0xFFFF))
@@ -576,18 +550,27 @@ func (s *Server) QueueMemoryForApproval(m MemoryExtraction) error {
 	}
 	// Everything else stays "pending" (including all bulk scans, <0.95 conf, or entity-bearing)
 
+	// Saved review rules (memory_review.go): reject rules override the above
+	action, ruleID, reason := "", "", ""
+	if status == "approved" {
+		action, ruleID, reason = "approve", "builtin", fmt.Sprintf("built-in: conf %.2f, %s", m.Confidence, m.SourceType)
+	}
+	if rule := s.matchReviewRule(m.SourceType, sourceFile, m.Confidence, m.MemoryText); rule != nil &&
+		(rule.Action == "reject" || action == "") {
+		action, ruleID, reason = rule.Action, rule.ID, "rule: "+rule.Name
+	}
+
 	_, err := s.db.Exec(`
-		INSERT INTO memory_queue (id, memory_text, source_type, source_file, source_context, confidence, status, reviewed_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, CASE WHEN ?='approved' THEN CURRENT_TIMESTAMP ELSE NULL END)`,
-		id, m.MemoryText, m.SourceType, sourceFile, m.SourceContext, m.Confidence, status, status)
+		INSERT INTO memory_queue (id, memory_text, source_type, source_file, source_context, confidence, status)
+		VALUES (?, ?, ?, ?, ?, ?, 'pending')`,
+		id, m.MemoryText, m.SourceType, sourceFile, m.SourceContext, m.Confidence)
 	if err != nil {
 		return err
 	}
 
-	// If auto-approved AND recorded in DB, fan out to all memory layers
-	if status == "approved" {
-		go s.writebackApprovedMemory(id, m.MemoryText)
-		log.Printf("[memory-queue] Auto-approved (conf=%.2f, type=%s): %s", m.Confidence, m.SourceType, m.MemoryText[:min(60, len(m.MemoryText))])
+	// Auto-decided: approve fans out to all memory layers; logged either way
+	if action != "" && s.applyReviewDecision(id, action, ruleID, "", "auto", reason) {
+		log.Printf("[memory-queue] Auto-%s (%s): %s", action, reason, m.MemoryText[:min(60, len(m.MemoryText))])
 	}
 
 	return nil
@@ -609,71 +592,37 @@ func containsAmbiguousEntities(text string) bool {
 	return false
 }
 
-// callLLMForExtraction calls the gateway for memory extraction
+// callLLMForExtraction runs an extraction prompt through the "extraction" LLM route (llm_client.go)
 func (s *Server) callLLMForExtraction(prompt string) (string, error) {
-	gatewayURL := os.Getenv("GATEWAY_URL")
-	if gatewayURL == "" {
-		gatewayURL = "http://127.0.0.1:18789"
-	}
-	gwToken := gatewayToken
-	if gwToken == "" {
-		gwToken = authToken // fallback to scoreboard token
-	}
-
-	body := map[string]interface{}{
-		"model": envOr("EXTRACTION_MODEL", "kimi-coding/k2p5"),
-		"messages": []map[string]string{
+	resp, err := s.callLLM(LLMRequest{
+		Task: "extraction",
+		Messages: []map[string]string{
 			{"role": "user", "content": prompt},
 		},
-		"max_tokens": 4000,
-	}
-	bodyBytes, _ := json.Marshal(body)
-
-	req, err := http.NewRequest("POST", gatewayURL+"/v1/chat/completions", bytes.NewReader(bodyBytes))
-	if err != nil {
-		return "", fmt.Errorf("build request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if gwToken != "" {
-		req.Header.Set("Authorization", "Bearer "+gwToken)
-	}
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+		MaxTokens: 4000,
+		Cacheable: hasJSONArray,
+	})
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	respBody, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("gateway returned %d: %s", resp.StatusCode, string(respBody[:min(200, len(respBody))]))
-	}
+	return resp.Content, nil
+}
 
-	var chatResp struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", err
-	}
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from LLM")
+// hasJSONArray reports whether an extraction answer holds a parseable JSON
+// array, the only kind extractFromPrompt can use.
+func hasJSONArray(content string) bool {
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start == -1 || end <= start {
+		return false
 	}
-
-	return chatResp.Choices[0].Message.Content, nil
+	var items []json.RawMessage
+	return json.Unmarshal([]byte(content[start:end+1]), &items) == nil
 }
 
-// vaultExtractMu guards vaultExtractRunning to prevent concurrent vault extractions.
-// Two simultaneous runs would race on the watermark file and double-extract every file.
-var vaultExtractMu sync.Mutex
-var vaultExtractRunning bool
-
-// POST /v1/memory/extract-vault — Extract memories from vault with real context
+// POST /v1/memory/extract-vault — Extract memories from vault with real context.
+// Enqueues a resumable extraction job (memory_jobs.go); progress is at
+// /v1/memory/jobs/{id}.
 func (s *Server) handleMemoryExtractVault(w http.ResponseWriter, r *http.Request) {
 	cors(w)
 	if r.Method != "POST" {
@@ -683,123 +632,35 @@ func (s *Server) handleMemoryExtractVault(w http.ResponseWriter, r *http.Request
 
 	var body struct {
 		Path  string `json:"path"`
-		Limit int    `json:"limit"` // max files to process
+		Limit int    `json:"limit"` // max changed files to process
 	}
 	json.NewDecoder(r.Body).Decode(&body)
 	
 	vaultPath := body.Path
 	if vaultPath == "" {
-		vaultPath = "/data/wirebot/obsidian"
+		vaultPath = memoryJobVault
 	}
 	limit := body.Limit
 	if limit == 0 {
 		limit = 50
 	}
 
-	vaultExtractMu.Lock()
-	if vaultExtractRunning {
-		vaultExtractMu.Unlock()
-		writeJSON(w, map[string]interface{}{
-			"message": "Extraction already in progress — ignoring duplicate request",
-			"path":    vaultPath,
-			"limit":   limit,
-		})
+	job, existing, err := s.createMemoryJob(vaultPath, limit, 0, reviewActor(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
 		return
 	}
-	vaultExtractRunning = true
-	vaultExtractMu.Unlock()
-
+	message := "Memory extraction job queued"
+	if existing {
+		message = "Extraction already in progress — returning the existing job"
+	}
 	writeJSON(w, map[string]interface{}{
-		"message": "Memory extraction started in background",
+		"message": message,
 		"path":    vaultPath,
-		"limit":   limit,
+		"limit":   job.Limit,
+		"job_id":  job.ID,
+		"job":     job,
 	})
-
-	go func() {
-		defer func() {
-			vaultExtractMu.Lock()
-			vaultExtractRunning = false
-			vaultExtractMu.Unlock()
-		}()
-		processed := 0
-		extracted := 0
-		queued := 0
-		skipped := 0
-
-		// Load watermark: set of already-processed file paths
-		watermarkPath := "/data/wirebot/scoreboard/vault_watermark.json"
-		processedFiles := map[string]bool{}
-		if data, err := os.ReadFile(watermarkPath); err == nil {
-			var files []string
-			if json.Unmarshal(data, &files) == nil {
-				for _, f := range files {
-					processedFiles[f] = true
-				}
-			}
-		}
-
-		filepath.Walk(vaultPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil || info.IsDir() {
-				return nil
-			}
-			if processed >= limit {
-				return filepath.SkipAll
-			}
-			if !strings.HasSuffix(strings.ToLower(info.Name()), ".md") {
-				return nil
-			}
-
-			relPath := strings.TrimPrefix(path, vaultPath+"/")
-
-			// Skip already-processed files (watermark)
-			if processedFiles[relPath] {
-				skipped++
-				return nil
-			}
-
-			data, err := os.ReadFile(path)
-			if err != nil {
-				return nil
-			}
-
-			memories, err := s.ExtractMemoriesFromDocument(relPath, string(data))
-			if err != nil {
-				log.Printf("[memory-extract] Error extracting from %s: %v", relPath, err)
-				return nil
-			}
-
-			queueOK := true
-			for _, m := range memories {
-				if err := s.QueueMemoryForApproval(m); err != nil {
-					log.Printf("[memory-extract] Queue error for %s: %v", relPath, err)
-					queueOK = false
-				} else {
-					queued++
-				}
-				extracted++
-			}
-
-			// Only watermark if all queue operations succeeded (or no memories found)
-			if !queueOK {
-				return nil // don't watermark — retry next run
-			}
-			processedFiles[relPath] = true
-			processed++
-			if processed%10 == 0 {
-				log.Printf("[memory-extract] Processed %d files (skipped %d), extracted %d memories", processed, skipped, extracted)
-				// Save watermark periodically so crashes resume from here
-				s.saveVaultWatermark(watermarkPath, processedFiles)
-			}
-
-			// Rate limit to avoid hammering the LLM
-			time.Sleep(2 * time.Second)
-			return nil
-		})
-
-		// Final watermark save
-		s.saveVaultWatermark(watermarkPath, processedFiles)
-		log.Printf("[memory-extract] Complete: %d files processed, %d skipped, %d memories extracted, %d queued", processed, skipped, extracted, queued)
-	}()
 }
 
 // convoExtractMu is separate from chatExtractMu (main.go) because they guard
@@ -865,12 +726,3 @@ func (s *Server) handleMemoryExtractConversation(w http.ResponseWriter, r *http.
 
 	writeJSON(w, map[string]interface{}{"ok": true, "message": "Extraction queued"})
 }
-
-func (s *Server) saveVaultWatermark(path string, processed map[string]bool) {
-	files := make([]string, 0, len(processed))
-	for f := range processed {
-		files = append(files, f)
-	}
-	data, _ := json.Marshal(files)
-	os.WriteFile(path, data, 0644)
-}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MEMORY FACTS — structured (subject, attribute, value, valid-from, source)
+//
+// Memories are free text. Conflict detection works on facts parsed out of
+// them instead of keyword buckets:
+//
+//   "Lives in Corona, CA"                → operator / location = corona, ca
+//   "Moved to Portland in March 2025"    → operator / location = portland (valid from 2025-03)
+//   "Wirebot's MRR is $1,200"            → wirebot / revenue.mrr = 1200
+//   "User's favorite editor is Neovim"   → operator / favorite editor = neovim
+//
+// memory_facts keeps one row per (memory, subject, attribute). valid_from is
+// the date the text states ("since 2024", "in March 2025", "as of ...") or,
+// failing that, when the memory was recorded — valid_from_kind says which.
+//
+// Within a subject + attribute:
+//   - equal or compatible values (corona vs corona, ca; MRR within 2x) are not
+//     conflicts; a later compatible fact simply supersedes the older one
+//   - incompatible values where the newer one states its own date supersede
+//     automatically (the operator told us things changed)
+//   - anything else is a conflict, and GET /v1/memory/conflicts proposes a
+//     resolution: keep the newer fact, or ask when both are from the same day
+//
+// Multi-valued attributes (skills, tools, goals...) never conflict.
+//
+//   GET  /v1/memory/conflicts           re-parse memories, list conflicts
+//   POST /v1/memory/conflicts           {conflict_id, keep: fact_id} or
+//                                       {conflict_id, action: "dismiss"}
+//   GET  /v1/memory/facts?subject=&attribute=&all=1
+// ═══════════════════════════════════════════════════════════════════════════════
+
+type factAttribute struct {
+	numeric bool
+	multi   bool // several values can be true at once
+}
+
+// factAttributes lists attributes with known semantics. Anything parsed by
+// the generic "X's <attribute> is <value>" rule is treated as single-valued.
+var factAttributes = map[string]factAttribute{
+	"location":       {},
+	"timezone":       {},
+	"name":           {},
+	"business.stage": {},
+	"revenue.mrr":    {numeric: true},
+	"revenue":        {numeric: true},
+	"team.size":      {numeric: true},
+	"progress":       {numeric: true},
+	"skill":          {multi: true},
+	"tool":           {multi: true},
+	"goal":           {multi: true},
+}
+
+type memoryFact struct {
+	ID            int64   `json:"id"`
+	MemoryID      string  `json:"memory_id"`
+	Source        string  `json:"source"` // memory store backend (mem0, sqlite...) | queue
+	Subject       string  `json:"subject"`
+	Attribute     string  `json:"attribute"`
+	Value         string  `json:"value"`
+	Number        float64 `json:"number,omitempty"`
+	ValidFrom     string  `json:"valid_from"`
+	ValidFromKind string  `json:"valid_from_kind"` // stated | recorded
+	Text          string  `json:"text"`
+	Status        string  `json:"status"` // active | superseded
+	SupersededBy  int64   `json:"superseded_by,omitempty"`
+}
+
+type factResolution struct {
+	Action     string  `json:"action"` // supersede | review
+	Keep       int64   `json:"keep,omitempty"`
+	Retire     int64   `json:"retire,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+}
+
+func (s *Server) initMemoryFacts() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS memory_facts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		memory_id TEXT NOT NULL,
+		source TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		attribute TEXT NOT NULL,
+		value TEXT NOT NULL,
+		number REAL DEFAULT 0,
+		valid_from TEXT NOT NULL,
+		valid_from_kind TEXT DEFAULT 'recorded',
+		text TEXT DEFAULT '',
+		status TEXT DEFAULT 'active',
+		superseded_by INTEGER DEFAULT 0,
+		updated_at TEXT NOT NULL,
+		UNIQUE(memory_id, source, subject, attribute)
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_memory_facts_key ON memory_facts(subject, attribute, status)`)
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS memory_conflict_dismissals (
+		conflict_id TEXT PRIMARY KEY,
+		dismissed_at TEXT NOT NULL
+	)`)
+}
+
+// ─── Parsing ────────────────────────────────────────────────────────────
+
+var (
+	factSentenceRe = regexp.MustCompile(`[.;!?]+(?:\s+|$)|\n+`)
+	factOperatorRe = regexp.MustCompile(`(?i)^(?:the\s+)?(?:user|operator|founder|i|he|she|they|my|his|her|their)(?:'s|’s)?\s+`)
+	factPossessRe  = regexp.MustCompile(`^([A-Z][\w-]*(?:\s+[A-Z][\w-]*)?)(?:'s|’s)\s+`)
+
+	factLocationRe = regexp.MustCompile(`(?i)\b(?:lives|living|located|based|resides|residing|moved|relocated|relocating|moving)\s+(?:from\s+[^,.;]+?\s+)?(?:in|at|to|out of)\s+([A-Za-z][A-Za-z .'-]*?(?:,\s*[A-Za-z]{2,})?)(?:\s+(?:in|since|as of|from|on|and|but|with|where|for|while|because)\b.*)?$`)
+	factTimezoneRe = regexp.MustCompile(`(?i)\btime\s?zone\s*(?:is|:)?\s*([A-Za-z_/+0-9:-]+)`)
+	factNameRe     = regexp.MustCompile(`(?i)\b(?:name is|goes by|prefers to be called|preferred name is)\s+([A-Za-z][\w'-]*(?:\s+[A-Z][\w'-]*)?)`)
+	factStageRe    = regexp.MustCompile(`(?i)\b(idea|validation|pre-mvp|post-mvp|launch|growth|scale|scaling)\s+stage\b|\bstage\s*(?:is|:)\s*([\w-]+)`)
+	factMRRRe      = regexp.MustCompile(`(?i)\b(mrr|monthly recurring revenue|monthly revenue|revenue|income)\b[^$\d]{0,20}\$?\s*([\d][\d,.]*)\s*(k|m)?\b`)
+	factTeamRe     = regexp.MustCompile(`(?i)\bteam of (\d+)|\b(\d+)\s+(?:employees|people on the team|team members)|\b(solo)(?:\s+founder|preneur)?\b`)
+	factProgressRe = regexp.MustCompile(`(?i)\b(\d{1,3})\s*%\s*(?:progress|complete|completion|done)`)
+	factMultiRe    = regexp.MustCompile(`(?i)\b(?:uses|knows|skilled in|wants to|goal is to)\s+(.+)$`)
+	factGenericRe  = regexp.MustCompile(`(?i)^([a-z][a-z .-]{1,40}?)\s+(?:is|are|=)\s+(.{1,80})$`)
+
+	factMonthYearRe = regexp.MustCompile(`(?i)\b(?:since|in|as of|from|on|starting)\s+((?:jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?\s+(?:\d{1,2},?\s+)?(?:19|20)\d{2})\b`)
+	factISODateRe   = regexp.MustCompile(`\b((?:19|20)\d{2}-\d{2}(?:-\d{2})?)\b`)
+	factYearRe      = regexp.MustCompile(`(?i)\b(?:since|in|as of|from|starting)\s+((?:19|20)\d{2})\b`)
+)
+
+// parseFacts pulls facts out of one memory. recorded is when the memory was
+// written, used when the text doesn't date itself.
+func parseFacts(text string, recorded time.Time) []memoryFact {
+	var facts []memoryFact
+	for _, sentence := range factSentenceRe.Split(text, -1) {
+		sentence = strings.TrimSpace(sentence)
+		if sentence == "" {
+			continue
+		}
+		subject, rest := factSubject(sentence)
+		validFrom, kind := factValidFrom(sentence, recorded)
+		add := func(attr, value string, number float64) {
+			value = normalizeFactValue(value)
+			if value == "" {
+				return
+			}
+			facts = append(facts, memoryFact{
+				Subject: subject, Attribute: attr, Value: value, Number: number,
+				ValidFrom: validFrom, ValidFromKind: kind, Text: sentence, Status: "active",
+			})
+		}
+
+		matched := false
+		if m := factLocationRe.FindStringSubmatch(rest); m != nil {
+			add("location", m[1], 0)
+			matched = true
+		}
+		if m := factTimezoneRe.FindStringSubmatch(rest); m != nil {
+			add("timezone", m[1], 0)
+			matched = true
+		}
+		if m := factNameRe.FindStringSubmatch(rest); m != nil {
+			add("name", m[1], 0)
+			matched = true
+		}
+		if m := factStageRe.FindStringSubmatch(rest); m != nil {
+			add("business.stage", m[1]+m[2], 0)
+			matched = true
+		}
+		if m := factMRRRe.FindStringSubmatch(rest); m != nil {
+			if n, ok := parseFactNumber(m[2], m[3]); ok {
+				attr := "revenue"
+				if l := strings.ToLower(m[1]); strings.Contains(l, "mrr") || strings.Contains(l, "monthly") {
+					attr = "revenue.mrr"
+				}
+				add(attr, strconv.FormatFloat(n, 'f', -1, 64), n)
+				matched = true
+			}
+		}
+		if m := factTeamRe.FindStringSubmatch(rest); m != nil {
+			n := 1.0
+			if v := m[1] + m[2]; v != "" {
+				n, _ = strconv.ParseFloat(v, 64)
+			}
+			add("team.size", strconv.FormatFloat(n, 'f', -1, 64), n)
+			matched = true
+		}
+		if m := factProgressRe.FindStringSubmatch(rest); m != nil {
+			n, _ := strconv.ParseFloat(m[1], 64)
+			add("progress", m[1], n)
+			matched = true
+		}
+		if matched {
+			continue
+		}
+		if m := factMultiRe.FindStringSubmatch(rest); m != nil {
+			attr := "tool"
+			switch l := strings.ToLower(rest); {
+			case strings.Contains(l, "skilled") || strings.Contains(l, "knows"):
+				attr = "skill"
+			case strings.Contains(l, "wants") || strings.Contains(l, "goal"):
+				attr = "goal"
+			}
+			add(attr, m[1], 0)
+			continue
+		}
+		// "X's favorite editor is Neovim" — only with an explicit subject, so
+		// ordinary sentences with "is" don't turn into facts
+		if rest != sentence {
+			if m := factGenericRe.FindStringSubmatch(rest); m != nil {
+				attr := strings.ToLower(strings.TrimSpace(m[1]))
+				if _, known := factAttributes[attr]; !known && len(strings.Fields(attr)) <= 4 {
+					add(attr, m[2], 0)
+				}
+			}
+		}
+	}
+	return facts
+}
+
+// factSubject splits a leading subject off a sentence. Memories about the
+// operator are mostly subject-less ("Lives in Corona") or start with a
+// pronoun; "Wirebot's MRR..." names its own subject.
+func factSubject(sentence string) (subject, rest string) {
+	if loc := factOperatorRe.FindStringIndex(sentence); loc != nil {
+		return "operator", sentence[loc[1]:]
+	}
+	if m := factPossessRe.FindStringSubmatch(sentence); m != nil {
+		return strings.ToLower(m[1]), sentence[len(m[0]):]
+	}
+	return "operator", sentence
+}
+
+func factValidFrom(sentence string, recorded time.Time) (string, string) {
+	if m := factISODateRe.FindStringSubmatch(sentence); m != nil {
+		v := m[1]
+		if len(v) == 7 {
+			v += "-01"
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t.Format("2006-01-02"), "stated"
+		}
+	}
+	if m := factMonthYearRe.FindStringSubmatch(sentence); m != nil {
+		v := strings.NewReplacer(",", "", ".", "").Replace(m[1])
+		for _, layout := range []string{"January 2 2006", "Jan 2 2006", "January 2006", "Jan 2006"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.Format("2006-01-02"), "stated"
+			}
+		}
+		if f := strings.Fields(v); len(f) >= 2 && len(f[0]) >= 3 {
+			if t, err := time.Parse("Jan 2006", f[0][:3]+" "+f[len(f)-1]); err == nil {
+				return t.Format("2006-01-02"), "stated"
+			}
+		}
+	}
+	if m := factYearRe.FindStringSubmatch(sentence); m != nil {
+		return m[1] + "-01-01", "stated"
+	}
+	if recorded.IsZero() {
+		recorded = time.Now()
+	}
+	return recorded.UTC().Format("2006-01-02"), "recorded"
+}
+
+func normalizeFactValue(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	v = strings.Trim(v, " .,;:!?\"'()")
+	v = strings.TrimPrefix(v, "the ")
+	return strings.Join(strings.Fields(v), " ")
+}
+
+func parseFactNumber(digits, suffix string) (float64, bool) {
+	n, err := strconv.ParseFloat(strings.TrimRight(strings.ReplaceAll(digits, ",", ""), "."), 64)
+	if err != nil {
+		return 0, false
+	}
+	switch strings.ToLower(suffix) {
+	case "k":
+		n *= 1000
+	case "m":
+		n *= 1000000
+	}
+	return n, true
+}
+
+// factsCompatible reports whether two values for the same attribute can both
+// be true.
+func factsCompatible(attr string, a, b memoryFact) bool {
+	if a.Value == b.Value {
+		return true
+	}
+	if factAttributes[attr].numeric {
+		if a.Number <= 0 || b.Number <= 0 {
+			return true
+		}
+		ratio := a.Number / b.Number
+		return ratio >= 0.5 && ratio <= 2.0
+	}
+	// "corona" vs "corona, ca" / "corona, california"
+	headA := strings.TrimSpace(strings.SplitN(a.Value, ",", 2)[0])
+	headB := strings.TrimSpace(strings.SplitN(b.Value, ",", 2)[0])
+	if headA == headB {
+		return true
+	}
+	return strings.Contains(a.Value, b.Value) || strings.Contains(b.Value, a.Value)
+}
+
+// ─── Sync + detection ───────────────────────────────────────────────────
+
+type factSourceMemory struct {
+	ID       string
+	Source   string
+	Text     string
+	Recorded time.Time
+}
+
+func parseMemoryTime(v string) time.Time {
+	if t := parseFlexibleTime(v); !t.IsZero() {
+		return t
+	}
+	t, _ := time.Parse("2006-01-02 15:04:05", v)
+	return t
+}
+
+// memoriesForFacts returns the memories facts are parsed from: everything in
+// the memory store plus approved queue items (which may not have reached it
+// yet). storeOK is false when the store couldn't be listed.
+func (s *Server) memoriesForFacts() (out []factSourceMemory, storeOK bool) {
+	store := s.memoryStore()
+	if all, err := store.List(); err == nil {
+		storeOK = true
+		for _, m := range all {
+			ts := m.UpdatedAt
+			if ts == "" {
+				ts = m.CreatedAt
+			}
+			out = append(out, factSourceMemory{ID: m.ID, Source: store.Name(), Text: m.Text, Recorded: parseMemoryTime(ts)})
+		}
+	}
+
+	rows, err := s.db.Query(`SELECT id, CASE WHEN correction != '' THEN correction ELSE memory_text END,
+		COALESCE(reviewed_at, created_at) FROM memory_queue WHERE status='approved'`)
+	if err == nil {
+		for rows.Next() {
+			var m factSourceMemory
+			var ts string
+			rows.Scan(&m.ID, &m.Text, &ts)
+			m.Source, m.Recorded = "queue", parseMemoryTime(ts)
+			out = append(out, m)
+		}
+		rows.Close()
+	}
+	return out, storeOK
+}
+
+// syncMemoryFacts re-parses memories into memory_facts. Facts of memories
+// that are gone are deleted; a source that couldn't be read is left alone.
+func (s *Server) syncMemoryFacts(memories []factSourceMemory, sources ...string) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	seen := map[string]bool{}
+	for _, m := range memories {
+		for _, f := range parseFacts(m.Text, m.Recorded) {
+			key := m.Source + "|" + m.ID + "|" + f.Subject + "|" + f.Attribute
+			if seen[key] {
+				continue // first mention in a memory wins
+			}
+			seen[key] = true
+			s.db.Exec(`INSERT INTO memory_facts (memory_id, source, subject, attribute, value, number,
+				valid_from, valid_from_kind, text, updated_at) VALUES (?,?,?,?,?,?,?,?,?,?)
+				ON CONFLICT(memory_id, source, subject, attribute) DO UPDATE SET
+					value=excluded.value, number=excluded.number, valid_from=excluded.valid_from,
+					valid_from_kind=excluded.valid_from_kind, text=excluded.text, updated_at=excluded.updated_at,
+					status=CASE WHEN memory_facts.value=excluded.value THEN memory_facts.status ELSE 'active' END,
+					superseded_by=CASE WHEN memory_facts.value=excluded.value THEN memory_facts.superseded_by ELSE 0 END`,
+				m.ID, m.Source, f.Subject, f.Attribute, f.Value, f.Number, f.ValidFrom, f.ValidFromKind, f.Text, now)
+		}
+	}
+	for _, src := range sources {
+		var stale []int64
+		for _, f := range s.loadFacts(`WHERE source=?`, src) {
+			if !seen[f.Source+"|"+f.MemoryID+"|"+f.Subject+"|"+f.Attribute] {
+				stale = append(stale, f.ID)
+			}
+		}
+		for _, id := range stale {
+			s.db.Exec(`DELETE FROM memory_facts WHERE id=?`, id)
+		}
+	}
+	s.applyFactSupersession()
+}
+
+func (s *Server) loadFacts(where string, args ...interface{}) []memoryFact {
+	rows, err := s.db.Query(`SELECT id, memory_id, source, subject, attribute, value, number, valid_from,
+		valid_from_kind, text, status, superseded_by FROM memory_facts `+where, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var facts []memoryFact
+	for rows.Next() {
+		var f memoryFact
+		rows.Scan(&f.ID, &f.MemoryID, &f.Source, &f.Subject, &f.Attribute, &f.Value, &f.Number,
+			&f.ValidFrom, &f.ValidFromKind, &f.Text, &f.Status, &f.SupersededBy)
+		facts = append(facts, f)
+	}
+	return facts
+}
+
+// activeFactGroups returns active single-valued facts grouped by subject and
+// attribute, oldest first.
+func (s *Server) activeFactGroups() map[string][]memoryFact {
+	groups := map[string][]memoryFact{}
+	for _, f := range s.loadFacts(`WHERE status='active' ORDER BY valid_from, id`) {
+		if factAttributes[f.Attribute].multi {
+			continue
+		}
+		k := f.Subject + "|" + f.Attribute
+		groups[k] = append(groups[k], f)
+	}
+	return groups
+}
+
+// applyFactSupersession retires older facts that a newer one clearly
+// replaces: a later compatible value (MRR went from 1000 to 1200), or a later
+// incompatible value whose date the memory states ("moved to Portland in
+// March 2025").
+func (s *Server) applyFactSupersession() {
+	for _, facts := range s.activeFactGroups() {
+		for i, older := range facts {
+			for _, newer := range facts[i+1:] {
+				if newer.ValidFrom <= older.ValidFrom {
+					continue
+				}
+				if factsCompatible(older.Attribute, older, newer) || newer.ValidFromKind == "stated" {
+					s.db.Exec(`UPDATE memory_facts SET status='superseded', superseded_by=? WHERE id=? AND status='active'`,
+						newer.ID, older.ID)
+					break
+				}
+			}
+		}
+	}
+}
+
+func factConflictID(a, b memoryFact) string {
+	x, y := a.ID, b.ID
+	if x > y {
+		x, y = y, x
+	}
+	return fmt.Sprintf("conflict-f%d-f%d", x, y)
+}
+
+// proposeResolution suggests how to settle a conflict between an older and a
+// newer fact.
+func proposeResolution(older, newer memoryFact) factResolution {
+	if newer.ValidFrom == older.ValidFrom {
+		return factResolution{
+			Action: "review", Confidence: 0.3,
+			Reason: fmt.Sprintf("both recorded %s — can't tell which %s is current", newer.ValidFrom, newer.Attribute),
+		}
+	}
+	conf := 0.6
+	if older.ValidFromKind == "stated" {
+		conf = 0.75
+	}
+	if newer.ValidFromKind == "stated" {
+		conf = 0.9
+	}
+	return factResolution{
+		Action: "supersede", Keep: newer.ID, Retire: older.ID, Confidence: conf,
+		Reason: fmt.Sprintf("%q (%s %s) is newer than %q (%s %s)", newer.Value, newer.ValidFromKind, newer.ValidFrom,
+			older.Value, older.ValidFromKind, older.ValidFrom),
+	}
+}
+
+// detectFactConflicts lists incompatible active facts for the same subject
+// and attribute.
+func (s *Server) detectFactConflicts() []MemoryConflict {
+	dismissed := map[string]bool{}
+	if rows, err := s.db.Query(`SELECT conflict_id FROM memory_conflict_dismissals`); err == nil {
+		for rows.Next() {
+			var id string
+			rows.Scan(&id)
+			dismissed[id] = true
+		}
+		rows.Close()
+	}
+
+	conflicts := []MemoryConflict{}
+	for _, facts := range s.activeFactGroups() {
+		for i := 0; i < len(facts); i++ {
+			for j := i + 1; j < len(facts); j++ {
+				older, newer := facts[i], facts[j]
+				if older.MemoryID == newer.MemoryID || factsCompatible(older.Attribute, older, newer) {
+					continue
+				}
+				id := factConflictID(older, newer)
+				if dismissed[id] {
+					continue
+				}
+				res := proposeResolution(older, newer)
+				conflicts = append(conflicts, MemoryConflict{
+					ID:         id,
+					Category:   older.Attribute,
+					Subject:    older.Subject,
+					Attribute:  older.Attribute,
+					A:          ConflictItem{ID: older.MemoryID, Text: older.Text, Source: older.Source, FactID: older.ID, Value: older.Value, ValidFrom: older.ValidFrom},
+					B:          ConflictItem{ID: newer.MemoryID, Text: newer.Text, Source: newer.Source, FactID: newer.ID, Value: newer.Value, ValidFrom: newer.ValidFrom},
+					Resolution: &res,
+				})
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Subject != conflicts[j].Subject {
+			return conflicts[i].Subject < conflicts[j].Subject
+		}
+		if conflicts[i].Attribute != conflicts[j].Attribute {
+			return conflicts[i].Attribute < conflicts[j].Attribute
+		}
+		return conflicts[i].ID < conflicts[j].ID
+	})
+	return conflicts
+}
+
+// GET /v1/memory/conflicts — contradictions between structured memory facts
+// POST /v1/memory/conflicts — settle one: {conflict_id, keep} or {conflict_id, action:"dismiss"}
+func (s *Server) handleMemoryConflicts(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method == "POST" {
+		var body struct {
+			ConflictID string `json:"conflict_id"`
+			Keep       int64  `json:"keep"`
+			Action     string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ConflictID == "" {
+			http.Error(w, `{"error":"conflict_id required"}`, 400)
+			return
+		}
+		var a, b int64
+		if _, err := fmt.Sscanf(body.ConflictID, "conflict-f%d-f%d", &a, &b); err != nil {
+			http.Error(w, `{"error":"unknown conflict_id"}`, 400)
+			return
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		switch {
+		case body.Action == "dismiss":
+			s.db.Exec(`INSERT OR REPLACE INTO memory_conflict_dismissals (conflict_id, dismissed_at) VALUES (?,?)`,
+				body.ConflictID, now)
+		case body.Keep == a || body.Keep == b:
+			retire := a
+			if body.Keep == a {
+				retire = b
+			}
+			s.db.Exec(`UPDATE memory_facts SET status='superseded', superseded_by=?, updated_at=? WHERE id=?`,
+				body.Keep, now, retire)
+		default:
+			http.Error(w, `{"error":"keep must be one of the conflict's fact ids, or action dismiss"}`, 400)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "conflict_id": body.ConflictID})
+		return
+	}
+
+	memories, storeOK := s.memoriesForFacts()
+	sources := []string{"queue"}
+	if storeOK {
+		sources = append(sources, s.memoryStore().Name())
+	}
+	s.syncMemoryFacts(memories, sources...)
+
+	var facts int
+	s.db.QueryRow(`SELECT COUNT(*) FROM memory_facts WHERE status='active'`).Scan(&facts)
+	writeJSON(w, map[string]interface{}{
+		"conflicts": s.detectFactConflicts(),
+		"checked":   len(memories),
+		"facts":     facts,
+	})
+}
+
+// GET /v1/memory/facts?subject=&attribute=&all=1 — parsed facts (active only
+// unless all=1), newest first
+func (s *Server) handleMemoryFacts(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	q := r.URL.Query()
+	where := []string{"1=1"}
+	args := []interface{}{}
+	if v := q.Get("subject"); v != "" {
+		where = append(where, "subject=?")
+		args = append(args, strings.ToLower(v))
+	}
+	if v := q.Get("attribute"); v != "" {
+		where = append(where, "attribute=?")
+		args = append(args, v)
+	}
+	if q.Get("all") != "1" {
+		where = append(where, "status='active'")
+	}
+	facts := s.loadFacts("WHERE "+strings.Join(where, " AND ")+" ORDER BY valid_from DESC, id DESC LIMIT 500", args...)
+	if facts == nil {
+		facts = []memoryFact{}
+	}
+	writeJSON(w, map[string]interface{}{"facts": facts, "count": len(facts)})
+}
@@ -0,0 +1,752 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MEMORY EXTRACTION JOBS — persistent, resumable, incremental vault extraction
+//
+// A job is one pass over a vault directory. Its state lives in sqlite, not in
+// the goroutine, so a restart picks up where it stopped:
+//
+//   scan     hash every .md file; files whose sha256 matches what was last
+//            extracted (memory_extracted_files) are left alone, changed and
+//            new ones become memory_job_files rows (up to the job limit)
+//   extract  each file is split into sections (headings, see splitSections);
+//            a section whose hash is already in memory_extracted_sections was
+//            extracted before and is skipped, so an edited file only costs
+//            LLM calls for the sections that changed
+//
+// Sections are redacted (redaction.go, REDACT_VAULT_SENSITIVITY) before
+// they go into a prompt.
+//
+// A section is recorded only once its memories are queued, and a file once all
+// of its sections are, so a crash or an LLM timeout repeats at most one
+// section. Failed files are retried up to memoryJobMaxAttempts times.
+// LLM calls are spaced rate_ms apart (MEMORY_JOB_RATE_MS, default 2000).
+//
+//   GET  /v1/memory/jobs                 recent jobs with progress
+//   POST /v1/memory/jobs                 {path, limit, rate_ms}
+//   GET  /v1/memory/jobs/{id}            job + files (?files=all, default errors)
+//   POST /v1/memory/jobs/{id}/cancel     stops before the next section
+//   POST /v1/memory/jobs/{id}/retry      failed files back to pending
+//
+// What has been extracted is keyed by vault (the cleaned job path) and
+// rel_path, so two vaults sharing a file name never mark each other's files
+// as done.
+//
+// POST /v1/memory/extract-vault is kept and enqueues a job. The old
+// vault_watermark.json is read once to seed memory_extracted_files for
+// MEMORY_JOB_VAULT, so files it lists are not extracted again unless they
+// change.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	memoryJobRate        = envOr("MEMORY_JOB_RATE_MS", "2000")
+	memoryJobVault       = envOr("MEMORY_JOB_VAULT", "/data/wirebot/obsidian")
+	memoryJobWatermark   = "/data/wirebot/scoreboard/vault_watermark.json"
+	memoryJobMaxAttempts = 3
+)
+
+const (
+	jobSectionMin = 200  // shorter sections are folded into the next one
+	jobSectionMax = 6000 // longer ones are split at blank lines
+)
+
+// memoryJobWake nudges the worker when a job is created or retried.
+var memoryJobWake = make(chan struct{}, 1)
+
+type MemoryJob struct {
+	ID             string `json:"id"`
+	Path           string `json:"path"`
+	Status         string `json:"status"` // queued, running, done, cancelled, failed
+	Limit          int    `json:"limit"`
+	RateMS         int    `json:"rate_ms"`
+	FilesTotal     int    `json:"files_total"`
+	FilesDone      int    `json:"files_done"`
+	FilesFailed    int    `json:"files_failed"`
+	FilesUnchanged int    `json:"files_unchanged"`
+	SectionsDone   int    `json:"sections_done"`
+	SectionsSkip   int    `json:"sections_unchanged"`
+	Extracted      int    `json:"extracted"`
+	Queued         int    `json:"queued"`
+	LastError      string `json:"last_error,omitempty"`
+	CreatedBy      string `json:"created_by"`
+	CreatedAt      string `json:"created_at"`
+	StartedAt      string `json:"started_at,omitempty"`
+	UpdatedAt      string `json:"updated_at"`
+	FinishedAt     string `json:"finished_at,omitempty"`
+}
+
+type MemoryJobFile struct {
+	Path        string `json:"path"`
+	ContentHash string `json:"content_hash"`
+	Status      string `json:"status"` // pending, done, error
+	Sections    int    `json:"sections"`
+	Changed     int    `json:"changed_sections"`
+	Memories    int    `json:"memories"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error,omitempty"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+func (s *Server) initMemoryJobs() {
+	s.migrateMemoryExtractedVault()
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS memory_jobs (
+			id TEXT PRIMARY KEY,
+			path TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'queued',
+			file_limit INTEGER DEFAULT 50,
+			rate_ms INTEGER DEFAULT 2000,
+			scanned INTEGER DEFAULT 0,
+			files_total INTEGER DEFAULT 0,
+			files_unchanged INTEGER DEFAULT 0,
+			sections_done INTEGER DEFAULT 0,
+			sections_unchanged INTEGER DEFAULT 0,
+			extracted INTEGER DEFAULT 0,
+			queued INTEGER DEFAULT 0,
+			last_error TEXT DEFAULT '',
+			created_by TEXT DEFAULT '',
+			created_at TEXT NOT NULL,
+			started_at TEXT DEFAULT '',
+			updated_at TEXT NOT NULL,
+			finished_at TEXT DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS memory_job_files (
+			job_id TEXT NOT NULL,
+			rel_path TEXT NOT NULL,
+			content_hash TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			sections INTEGER DEFAULT 0,
+			changed_sections INTEGER DEFAULT 0,
+			memories INTEGER DEFAULT 0,
+			attempts INTEGER DEFAULT 0,
+			error TEXT DEFAULT '',
+			updated_at TEXT NOT NULL,
+			PRIMARY KEY (job_id, rel_path)
+		)`,
+		memoryExtractedFilesSchema,
+		memoryExtractedSectionsSchema,
+		`CREATE INDEX IF NOT EXISTS idx_memory_jobs_status ON memory_jobs(status)`,
+	} {
+		s.db.Exec(stmt)
+	}
+}
+
+const (
+	memoryExtractedFilesSchema = `CREATE TABLE IF NOT EXISTS memory_extracted_files (
+			vault TEXT NOT NULL,
+			rel_path TEXT NOT NULL,
+			content_hash TEXT NOT NULL,
+			job_id TEXT DEFAULT '',
+			extracted_at TEXT NOT NULL,
+			PRIMARY KEY (vault, rel_path)
+		)`
+	memoryExtractedSectionsSchema = `CREATE TABLE IF NOT EXISTS memory_extracted_sections (
+			vault TEXT NOT NULL,
+			rel_path TEXT NOT NULL,
+			section_hash TEXT NOT NULL,
+			title TEXT DEFAULT '',
+			memories INTEGER DEFAULT 0,
+			extracted_at TEXT NOT NULL,
+			PRIMARY KEY (vault, rel_path, section_hash)
+		)`
+)
+
+// migrateMemoryExtractedVault rebuilds the extracted-file tables from before
+// they were keyed by vault. Their rows were all written for MEMORY_JOB_VAULT
+// (the only vault the extractor was pointed at), so they keep that vault.
+func (s *Server) migrateMemoryExtractedVault() {
+	vault := filepath.Clean(memoryJobVault)
+	for _, t := range []struct{ table, schema, cols string }{
+		{"memory_extracted_files", memoryExtractedFilesSchema, "rel_path, content_hash, job_id, extracted_at"},
+		{"memory_extracted_sections", memoryExtractedSectionsSchema, "rel_path, section_hash, title, memories, extracted_at"},
+	} {
+		var exists, hasVault int
+		s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?`, t.table).Scan(&exists)
+		s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name='vault'`, t.table).Scan(&hasVault)
+		if exists == 0 || hasVault > 0 {
+			continue
+		}
+		tx, err := s.db.Begin()
+		if err != nil {
+			log.Printf("[memory-jobs] migrate %s: %v", t.table, err)
+			continue
+		}
+		_, err = tx.Exec(`ALTER TABLE ` + t.table + ` RENAME TO ` + t.table + `_legacy`)
+		if err == nil {
+			_, err = tx.Exec(t.schema)
+		}
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO `+t.table+` (vault, `+t.cols+`) SELECT ?, `+t.cols+` FROM `+t.table+`_legacy`, vault)
+		}
+		if err == nil {
+			_, err = tx.Exec(`DROP TABLE ` + t.table + `_legacy`)
+		}
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[memory-jobs] migrate %s: %v", t.table, err)
+			continue
+		}
+		tx.Commit()
+		log.Printf("[memory-jobs] %s keyed by vault (existing rows: %s)", t.table, vault)
+	}
+}
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+type vaultSection struct {
+	Title string
+	Text  string
+	Hash  string
+}
+
+// splitSections cuts a markdown document (frontmatter removed) into sections
+// at headings. Sections are hashed on their whitespace-normalised text, so
+// reflowing a paragraph does not count as a change.
+func splitSections(content string) []vaultSection {
+	if strings.HasPrefix(strings.TrimSpace(content), "---") {
+		if parts := strings.SplitN(content, "---", 3); len(parts) >= 3 {
+			content = parts[2]
+		}
+	}
+
+	var raw []vaultSection
+	cur := vaultSection{}
+	var buf strings.Builder
+	inFence := false
+	flush := func() {
+		cur.Text = strings.TrimSpace(buf.String())
+		if cur.Text != "" {
+			raw = append(raw, cur)
+		}
+		buf.Reset()
+	}
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(trimmed, "#") && strings.TrimLeft(trimmed, "#") != "" &&
+			strings.HasPrefix(strings.TrimLeft(trimmed, "#"), " ") {
+			flush()
+			cur = vaultSection{Title: strings.TrimSpace(strings.TrimLeft(trimmed, "#"))}
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	flush()
+
+	// Fold short sections (a heading with a line under it) into the next one
+	var merged []vaultSection
+	for i := 0; i < len(raw); i++ {
+		sec := raw[i]
+		for len(sec.Text) < jobSectionMin && i+1 < len(raw) {
+			i++
+			if sec.Title == "" {
+				sec.Title = raw[i].Title
+			}
+			sec.Text += "\n\n" + raw[i].Text
+		}
+		merged = append(merged, sec)
+	}
+
+	// Split long sections at paragraph boundaries
+	var out []vaultSection
+	for _, sec := range merged {
+		if len(sec.Text) <= jobSectionMax {
+			out = append(out, sec)
+			continue
+		}
+		var part strings.Builder
+		n := 0
+		emit := func() {
+			if strings.TrimSpace(part.String()) == "" {
+				return
+			}
+			n++
+			out = append(out, vaultSection{Title: fmt.Sprintf("%s (part %d)", sec.Title, n), Text: strings.TrimSpace(part.String())})
+			part.Reset()
+		}
+		for _, para := range strings.Split(sec.Text, "\n\n") {
+			for len(para) > jobSectionMax {
+				emit()
+				part.WriteString(para[:jobSectionMax])
+				para = para[jobSectionMax:]
+				emit()
+			}
+			if part.Len()+len(para) > jobSectionMax {
+				emit()
+			}
+			part.WriteString(para)
+			part.WriteString("\n\n")
+		}
+		emit()
+	}
+
+	for i := range out {
+		out[i].Hash = contentHash(strings.Join(strings.Fields(out[i].Text), " "))
+	}
+	return out
+}
+
+func memoryJobID() string {
+	return reviewID("job")
+}
+
+// createMemoryJob queues a job for vaultPath unless one is already queued or
+// running for it; the existing job is returned in that case.
+func (s *Server) createMemoryJob(vaultPath string, limit, rateMS int, actor string) (job *MemoryJob, existing bool, err error) {
+	if info, statErr := os.Stat(vaultPath); statErr != nil || !info.IsDir() {
+		return nil, false, fmt.Errorf("vault path not found: %s", vaultPath)
+	}
+	vaultPath = filepath.Clean(vaultPath)
+	var id string
+	s.db.QueryRow(`SELECT id FROM memory_jobs WHERE path=? AND status IN ('queued','running') ORDER BY created_at LIMIT 1`, vaultPath).Scan(&id)
+	if id != "" {
+		return s.memoryJob(id), true, nil
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if rateMS <= 0 {
+		rateMS, _ = strconv.Atoi(memoryJobRate)
+	}
+	id = memoryJobID()
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := s.db.Exec(`INSERT INTO memory_jobs (id, path, status, file_limit, rate_ms, created_by, created_at, updated_at)
+		VALUES (?,?,'queued',?,?,?,?,?)`, id, vaultPath, limit, rateMS, actor, now, now); err != nil {
+		return nil, false, err
+	}
+	s.wakeMemoryJobs()
+	return s.memoryJob(id), false, nil
+}
+
+func (s *Server) wakeMemoryJobs() {
+	select {
+	case memoryJobWake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) memoryJob(id string) *MemoryJob {
+	jobs := s.memoryJobs(`WHERE j.id=?`, id)
+	if len(jobs) == 0 {
+		return nil
+	}
+	return &jobs[0]
+}
+
+func (s *Server) memoryJobs(where string, args ...interface{}) []MemoryJob {
+	rows, err := s.db.Query(`SELECT j.id, j.path, j.status, j.file_limit, j.rate_ms, j.files_total, j.files_unchanged,
+		j.sections_done, j.sections_unchanged, j.extracted, j.queued, j.last_error, j.created_by, j.created_at,
+		j.started_at, j.updated_at, j.finished_at,
+		(SELECT COUNT(*) FROM memory_job_files f WHERE f.job_id=j.id AND f.status='done'),
+		(SELECT COUNT(*) FROM memory_job_files f WHERE f.job_id=j.id AND f.status='error')
+		FROM memory_jobs j `+where, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []MemoryJob
+	for rows.Next() {
+		var j MemoryJob
+		rows.Scan(&j.ID, &j.Path, &j.Status, &j.Limit, &j.RateMS, &j.FilesTotal, &j.FilesUnchanged,
+			&j.SectionsDone, &j.SectionsSkip, &j.Extracted, &j.Queued, &j.LastError, &j.CreatedBy, &j.CreatedAt,
+			&j.StartedAt, &j.UpdatedAt, &j.FinishedAt, &j.FilesDone, &j.FilesFailed)
+		out = append(out, j)
+	}
+	return out
+}
+
+func (s *Server) memoryJobStatus(id string) string {
+	var status string
+	s.db.QueryRow(`SELECT status FROM memory_jobs WHERE id=?`, id).Scan(&status)
+	return status
+}
+
+// finishMemoryJob moves a job to a final status unless it was cancelled meanwhile.
+func (s *Server) finishMemoryJob(id, status, lastErr string) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	s.db.Exec(`UPDATE memory_jobs SET status=?, last_error=CASE WHEN ?='' THEN last_error ELSE ? END,
+		updated_at=?, finished_at=? WHERE id=? AND status='running'`, status, lastErr, lastErr, now, now, id)
+}
+
+// startMemoryJobWorker runs jobs one at a time, oldest first. Jobs left
+// running by a previous process are picked up again on start.
+func (s *Server) startMemoryJobWorker() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			for {
+				var id string
+				s.db.QueryRow(`SELECT id FROM memory_jobs WHERE status IN ('running','queued')
+					ORDER BY CASE status WHEN 'running' THEN 0 ELSE 1 END, created_at LIMIT 1`).Scan(&id)
+				if id == "" {
+					break
+				}
+				s.runMemoryJob(id)
+			}
+			select {
+			case <-memoryJobWake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Server) runMemoryJob(id string) {
+	var path string
+	var scanned bool
+	var limit, rateMS int
+	if err := s.db.QueryRow(`SELECT path, scanned, file_limit, rate_ms FROM memory_jobs WHERE id=?`, id).
+		Scan(&path, &scanned, &limit, &rateMS); err != nil {
+		return
+	}
+	// Jobs created before paths were cleaned; the vault is part of the key
+	path = filepath.Clean(path)
+	now := time.Now().UTC().Format(time.RFC3339)
+	s.db.Exec(`UPDATE memory_jobs SET status='running', started_at=CASE WHEN started_at='' THEN ? ELSE started_at END,
+		updated_at=? WHERE id=? AND status IN ('queued','running')`, now, now, id)
+	if s.memoryJobStatus(id) != "running" {
+		return
+	}
+
+	if !scanned {
+		if err := s.scanMemoryJob(id, path, limit); err != nil {
+			log.Printf("[memory-jobs] %s scan failed: %v", id, err)
+			s.finishMemoryJob(id, "failed", err.Error())
+			return
+		}
+	}
+
+	for {
+		// Fewest attempts first, so one failing file doesn't block the rest
+		var relPath string
+		var attempts int
+		s.db.QueryRow(`SELECT rel_path, attempts FROM memory_job_files WHERE job_id=? AND status='pending'
+			ORDER BY attempts, rel_path LIMIT 1`, id).Scan(&relPath, &attempts)
+		if relPath == "" {
+			break
+		}
+		if s.memoryJobStatus(id) != "running" {
+			log.Printf("[memory-jobs] %s stopped (%s)", id, s.memoryJobStatus(id))
+			return
+		}
+		if err := s.extractJobFile(id, path, relPath, rateMS); err != nil {
+			if err == errMemoryJobStopped {
+				log.Printf("[memory-jobs] %s stopped (%s)", id, s.memoryJobStatus(id))
+				return
+			}
+			status := "pending"
+			if attempts+1 >= memoryJobMaxAttempts {
+				status = "error"
+			}
+			now := time.Now().UTC().Format(time.RFC3339)
+			s.db.Exec(`UPDATE memory_job_files SET status=?, attempts=attempts+1, error=?, updated_at=? WHERE job_id=? AND rel_path=?`,
+				status, err.Error(), now, id, relPath)
+			s.db.Exec(`UPDATE memory_jobs SET last_error=?, updated_at=? WHERE id=?`, relPath+": "+err.Error(), now, id)
+			log.Printf("[memory-jobs] %s %s (attempt %d): %v", id, relPath, attempts+1, err)
+			// Back off before the next file; a flaky gateway tends to stay flaky briefly
+			time.Sleep(time.Duration(rateMS*(attempts+1)) * time.Millisecond)
+		}
+	}
+
+	s.finishMemoryJob(id, "done", "")
+	if j := s.memoryJob(id); j != nil {
+		log.Printf("[memory-jobs] %s complete: %d/%d files (%d unchanged, %d failed), %d sections extracted, %d memories queued",
+			id, j.FilesDone, j.FilesTotal, j.FilesUnchanged, j.FilesFailed, j.SectionsDone, j.Queued)
+	}
+}
+
+var errMemoryJobStopped = fmt.Errorf("job no longer running")
+
+// scanMemoryJob enumerates the files a job has to extract.
+func (s *Server) scanMemoryJob(id, vaultPath string, limit int) error {
+	s.seedFromWatermark(vaultPath)
+
+	known := map[string]string{}
+	rows, err := s.db.Query(`SELECT rel_path, content_hash FROM memory_extracted_files WHERE vault=?`, vaultPath)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var p, h string
+		rows.Scan(&p, &h)
+		known[p] = h
+	}
+	rows.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	total, unchanged := 0, 0
+	err = filepath.Walk(vaultPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".md") {
+			return nil
+		}
+		if total >= limit {
+			return filepath.SkipAll
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		relPath := strings.TrimPrefix(path, vaultPath+"/")
+		hash := contentHash(string(data))
+		if known[relPath] == hash {
+			unchanged++
+			return nil
+		}
+		s.db.Exec(`INSERT OR IGNORE INTO memory_job_files (job_id, rel_path, content_hash, updated_at) VALUES (?,?,?,?)`,
+			id, relPath, hash, now)
+		total++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.db.Exec(`UPDATE memory_jobs SET scanned=1, files_total=?, files_unchanged=?, updated_at=? WHERE id=?`,
+		total, unchanged, now, id)
+	log.Printf("[memory-jobs] %s scanned %s: %d files to extract, %d unchanged", id, vaultPath, total, unchanged)
+	return nil
+}
+
+// seedFromWatermark imports the file list written by the old synchronous
+// extractor, once: those files count as extracted at their current content,
+// sections included. The watermark only ever described MEMORY_JOB_VAULT.
+func (s *Server) seedFromWatermark(vaultPath string) {
+	if vaultPath != filepath.Clean(memoryJobVault) {
+		return
+	}
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM memory_extracted_files WHERE vault=?`, vaultPath).Scan(&n)
+	if n > 0 {
+		return
+	}
+	data, err := os.ReadFile(memoryJobWatermark)
+	if err != nil {
+		return
+	}
+	var files []string
+	if json.Unmarshal(data, &files) != nil {
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	seeded := 0
+	for _, relPath := range files {
+		content, err := os.ReadFile(filepath.Join(vaultPath, relPath))
+		if err != nil {
+			continue
+		}
+		for _, sec := range splitSections(string(content)) {
+			s.db.Exec(`INSERT OR IGNORE INTO memory_extracted_sections (vault, rel_path, section_hash, title, extracted_at) VALUES (?,?,?,?,?)`,
+				vaultPath, relPath, sec.Hash, truncateRunes(sec.Title, 200), now)
+		}
+		s.db.Exec(`INSERT OR IGNORE INTO memory_extracted_files (vault, rel_path, content_hash, job_id, extracted_at) VALUES (?,?,?,'watermark',?)`,
+			vaultPath, relPath, contentHash(string(content)), now)
+		seeded++
+	}
+	if seeded > 0 {
+		log.Printf("[memory-jobs] seeded %d files from %s", seeded, memoryJobWatermark)
+	}
+}
+
+// extractJobFile extracts the changed sections of one file. Sections are
+// recorded as they succeed; the file is only marked done when all are.
+func (s *Server) extractJobFile(id, vaultPath, relPath string, rateMS int) error {
+	data, err := os.ReadFile(filepath.Join(vaultPath, relPath))
+	if err != nil {
+		return err
+	}
+	content := string(data)
+	hash := contentHash(content)
+	sections := splitSections(content)
+	docType, meta := classifyDocument(relPath, content)
+
+	// One redactor per file keeps placeholders stable across its sections;
+	// quotes are checked against the redacted text the LLM saw
+	rd := s.newRedactor(redactVaultSensitivity)
+	redacted := rd.Redact(content)
+	meta = rd.RedactMeta(meta)
+
+	done := map[string]bool{}
+	rows, err := s.db.Query(`SELECT section_hash FROM memory_extracted_sections WHERE vault=? AND rel_path=?`, vaultPath, relPath)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var h string
+		rows.Scan(&h)
+		done[h] = true
+	}
+	rows.Close()
+
+	changed, memories := 0, 0
+	current := map[string]bool{}
+	for _, sec := range sections {
+		current[sec.Hash] = true
+		if done[sec.Hash] {
+			s.db.Exec(`UPDATE memory_jobs SET sections_unchanged=sections_unchanged+1 WHERE id=?`, id)
+			continue
+		}
+		changed++
+		if s.memoryJobStatus(id) != "running" {
+			return errMemoryJobStopped
+		}
+
+		var extracted []MemoryExtraction
+		if len(sec.Text) >= 100 {
+			label := relPath
+			if sec.Title != "" {
+				label += " § " + sec.Title
+			}
+			prompt := buildExtractionPrompt(rd.Redact(label), redactionPromptNote+rd.Redact(sec.Text), docType, meta)
+			extracted, err = s.extractFromPrompt(relPath, redacted, prompt, docType)
+			time.Sleep(time.Duration(rateMS) * time.Millisecond)
+			if err != nil {
+				return fmt.Errorf("section %q: %w", sec.Title, err)
+			}
+			extracted = rd.RestoreMemories(extracted)
+		}
+		queued := 0
+		for _, m := range extracted {
+			if m.SourceSection == "" {
+				m.SourceSection = sec.Title
+			}
+			if err := s.QueueMemoryForApproval(m); err != nil {
+				return fmt.Errorf("queue: %w", err)
+			}
+			queued++
+		}
+		memories += queued
+		now := time.Now().UTC().Format(time.RFC3339)
+		s.db.Exec(`INSERT OR REPLACE INTO memory_extracted_sections (vault, rel_path, section_hash, title, memories, extracted_at) VALUES (?,?,?,?,?,?)`,
+			vaultPath, relPath, sec.Hash, truncateRunes(sec.Title, 200), queued, now)
+		s.db.Exec(`UPDATE memory_jobs SET sections_done=sections_done+1, extracted=extracted+?, queued=queued+?, updated_at=? WHERE id=?`,
+			len(extracted), queued, now, id)
+	}
+
+	// Sections that no longer exist in the file are forgotten, so restoring
+	// old text extracts it again rather than silently matching
+	for h := range done {
+		if !current[h] {
+			s.db.Exec(`DELETE FROM memory_extracted_sections WHERE vault=? AND rel_path=? AND section_hash=?`, vaultPath, relPath, h)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	s.db.Exec(`INSERT OR REPLACE INTO memory_extracted_files (vault, rel_path, content_hash, job_id, extracted_at) VALUES (?,?,?,?,?)`,
+		vaultPath, relPath, hash, id, now)
+	s.db.Exec(`UPDATE memory_job_files SET status='done', content_hash=?, sections=?, changed_sections=?,
+		memories=memories+?, error='', updated_at=? WHERE job_id=? AND rel_path=?`,
+		hash, len(sections), changed, memories, now, id, relPath)
+	return nil
+}
+
+// /v1/memory/jobs and /v1/memory/jobs/{id}[/cancel|/retry]
+func (s *Server) handleMemoryJobs(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	id, sub, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/memory/jobs"), "/"), "/")
+
+	switch {
+	case id == "" && r.Method == "GET":
+		jobs := s.memoryJobs(`ORDER BY j.created_at DESC LIMIT 50`)
+		if jobs == nil {
+			jobs = []MemoryJob{}
+		}
+		writeJSON(w, map[string]interface{}{"jobs": jobs})
+
+	case id == "" && r.Method == "POST":
+		var body struct {
+			Path   string `json:"path"`
+			Limit  int    `json:"limit"`
+			RateMS int    `json:"rate_ms"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Path == "" {
+			body.Path = memoryJobVault
+		}
+		job, existing, err := s.createMemoryJob(body.Path, body.Limit, body.RateMS, reviewActor(r))
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "existing": existing, "job": job})
+
+	case id != "" && sub == "" && r.Method == "GET":
+		job := s.memoryJob(id)
+		if job == nil {
+			http.Error(w, `{"error":"job not found"}`, 404)
+			return
+		}
+		q := `SELECT rel_path, content_hash, status, sections, changed_sections, memories, attempts, error, updated_at
+			FROM memory_job_files WHERE job_id=? AND (error != '' OR status='error') ORDER BY rel_path`
+		if r.URL.Query().Get("files") == "all" {
+			q = `SELECT rel_path, content_hash, status, sections, changed_sections, memories, attempts, error, updated_at
+				FROM memory_job_files WHERE job_id=? ORDER BY rel_path`
+		}
+		files := []MemoryJobFile{}
+		if rows, err := s.db.Query(q, id); err == nil {
+			for rows.Next() {
+				var f MemoryJobFile
+				rows.Scan(&f.Path, &f.ContentHash, &f.Status, &f.Sections, &f.Changed, &f.Memories, &f.Attempts, &f.Error, &f.UpdatedAt)
+				files = append(files, f)
+			}
+			rows.Close()
+		}
+		writeJSON(w, map[string]interface{}{"job": job, "files": files})
+
+	case id != "" && sub == "cancel" && r.Method == "POST":
+		now := time.Now().UTC().Format(time.RFC3339)
+		res, _ := s.db.Exec(`UPDATE memory_jobs SET status='cancelled', updated_at=?, finished_at=? WHERE id=? AND status IN ('queued','running')`,
+			now, now, id)
+		if n, _ := res.RowsAffected(); n == 0 {
+			if s.memoryJob(id) == nil {
+				http.Error(w, `{"error":"job not found"}`, 404)
+				return
+			}
+			http.Error(w, `{"error":"job is not queued or running"}`, 409)
+			return
+		}
+		log.Printf("[memory-jobs] %s cancelled by %s", id, reviewActor(r))
+		writeJSON(w, map[string]interface{}{"ok": true, "job": s.memoryJob(id)})
+
+	case id != "" && sub == "retry" && r.Method == "POST":
+		// Failed files get fresh attempts; a cancelled or failed job resumes
+		// from its remaining pending files without rescanning
+		job := s.memoryJob(id)
+		if job == nil {
+			http.Error(w, `{"error":"job not found"}`, 404)
+			return
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		res, _ := s.db.Exec(`UPDATE memory_job_files SET status='pending', attempts=0, updated_at=? WHERE job_id=? AND status='error'`, now, id)
+		retried, _ := res.RowsAffected()
+		if job.Status != "queued" && job.Status != "running" {
+			s.db.Exec(`UPDATE memory_jobs SET status='queued', last_error='', finished_at='', updated_at=? WHERE id=?`, now, id)
+		}
+		s.wakeMemoryJobs()
+		writeJSON(w, map[string]interface{}{"ok": true, "retried": retried, "job": s.memoryJob(id)})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, 405)
+	}
+}
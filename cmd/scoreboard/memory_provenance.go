@@ -0,0 +1,437 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MEMORY PROVENANCE + FORGET — where every approved memory went, and undoing it
+//
+// An approved memory fans out: the memory store (writebackApprovedMemory),
+// MEMORY.md, a git-tracked YAML fact file, and Letta (the state feeder). Each
+// write lands in memory_provenance with the destination's own ID:
+//
+//   mem0       memory store id, destination named after the backend: mem0,
+//   sqlite     sqlite or letta_archival (memory_store.go). Empty when the
+//   ...        backend didn't report one — forget then matches by text
+//   memory_md  the MEMORY.md path; detail is the exact line appended
+//   git        the YAML fact file
+//   letta      the agent the memory was sent to; 'assumed' instead of
+//              'written' when it got there under another id (memory_ttl.go)
+//
+// The source side (vault doc, conversation, queue item) is the memory_queue
+// row itself: source_type, source_file, source_context.
+//
+// POST /v1/memory/item/{id}/forget walks the written destinations and undoes
+// each: memory store delete, MEMORY.md line removal, git rm + commit, and a "forget
+// this" message to Letta (which edits its own blocks — a tombstone, not a
+// verified delete). Parsed facts (memory_facts.go) go too. The queue row is
+// kept as a tombstone: status 'forgotten', text cleared, sha256 of the text
+// kept so the same memory can be recognised if it is extracted again.
+// Rejecting an already-approved memory pulls it from the same destinations
+// but keeps the row, as 'rejected' with its text (unwriteMemory).
+//
+// GET /v1/memory/item/{id}/provenance shows the source, every destination
+// with its status, and near-duplicates merged into it (memory_dedup.go).
+// Memories written before provenance existed are covered by
+// memory_queue.destinations: forget falls back to text matching for those.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// memoryFileMu serialises MEMORY.md appends and rewrites.
+var memoryFileMu sync.Mutex
+
+func (s *Server) initMemoryProvenance() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS memory_provenance (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		memory_id TEXT NOT NULL,
+		destination TEXT NOT NULL,
+		dest_id TEXT DEFAULT '',
+		detail TEXT DEFAULT '',
+		status TEXT DEFAULT 'written',
+		error TEXT DEFAULT '',
+		written_at TEXT NOT NULL,
+		forgotten_at TEXT DEFAULT ''
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_memory_provenance ON memory_provenance(memory_id)`)
+}
+
+func (s *Server) recordProvenance(memID, destination, destID, detail string) {
+	s.insertProvenance(memID, destination, destID, detail, "written")
+}
+
+// recordAssumedProvenance notes a destination that should already hold the
+// memory although nothing here delivered it (a stored memory adopted on
+// re-confirmation was fed to Letta under its old identity). It stops a resend
+// and is forgotten like a write; a real write later replaces it.
+func (s *Server) recordAssumedProvenance(memID, destination, destID, detail string) {
+	s.insertProvenance(memID, destination, destID, detail, "assumed")
+}
+
+func (s *Server) insertProvenance(memID, destination, destID, detail, status string) {
+	if memID == "" {
+		return
+	}
+	var existing string
+	s.db.QueryRow(`SELECT status FROM memory_provenance WHERE memory_id=? AND destination=? AND dest_id=? AND status IN ('written','assumed')
+		ORDER BY status = 'written' DESC LIMIT 1`, memID, destination, destID).Scan(&existing)
+	switch {
+	case existing == "written", existing == status:
+		return
+	case existing == "assumed":
+		s.db.Exec(`UPDATE memory_provenance SET status='written', detail=?, written_at=? WHERE memory_id=? AND destination=? AND dest_id=? AND status='assumed'`,
+			detail, time.Now().UTC().Format(time.RFC3339), memID, destination, destID)
+		return
+	}
+	s.db.Exec(`INSERT INTO memory_provenance (memory_id, destination, dest_id, detail, status, written_at) VALUES (?,?,?,?,?,?)`,
+		memID, destination, destID, detail, status, time.Now().UTC().Format(time.RFC3339))
+}
+
+func (s *Server) hasProvenance(memID string) bool {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM memory_provenance WHERE memory_id=? AND status='written'`, memID).Scan(&n)
+	if n > 0 {
+		return true
+	}
+	var dests sql.NullString
+	s.db.QueryRow(`SELECT destinations FROM memory_queue WHERE id=?`, memID).Scan(&dests)
+	return dests.String != "" && dests.String != "[]"
+}
+
+// mem0ResultIDs pulls memory IDs out of a Mem0 /v1/store response, whatever
+// its shape: {"id"}, {"results":[{"id"}]}, {"result":{"results":[...]}}.
+func mem0ResultIDs(body []byte) []string {
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return nil
+	}
+	var ids []string
+	var walk func(interface{})
+	walk = func(n interface{}) {
+		switch t := n.(type) {
+		case map[string]interface{}:
+			if id, ok := t["id"].(string); ok && id != "" {
+				if ev, _ := t["event"].(string); ev != "DELETE" && ev != "NONE" {
+					ids = append(ids, id)
+				}
+			}
+			for k, c := range t {
+				if k != "id" {
+					walk(c)
+				}
+			}
+		case []interface{}:
+			for _, c := range t {
+				walk(c)
+			}
+		}
+	}
+	walk(v)
+	return ids
+}
+
+// clawdCommit stages paths and commits them. Commits are serialised so
+// concurrent writebacks don't trip over the workspace's index.lock.
+func clawdCommit(msg string, paths ...string) {
+	clawdGitMu.Lock()
+	defer clawdGitMu.Unlock()
+	clawdGit(append([]string{"add", "-A"}, paths...)...)
+	clawdGit("commit", "-m", msg, "--author", "wirebot <wirebot@wirebot.chat>")
+}
+
+var clawdGitMu sync.Mutex
+
+// clawdGit runs git in the workspace as the wirebot user.
+func clawdGit(args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = "/home/wirebot/clawd"
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: 549, Gid: 547},
+	}
+	return cmd.Run()
+}
+
+type provenanceRecord struct {
+	ID          int64  `json:"id"`
+	Destination string `json:"destination"`
+	DestID      string `json:"dest_id,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+	Status      string `json:"status"` // written | assumed | forgotten | tombstoned | failed
+	Error       string `json:"error,omitempty"`
+	WrittenAt   string `json:"written_at"`
+	ForgottenAt string `json:"forgotten_at,omitempty"`
+}
+
+type forgetResult struct {
+	Destination string `json:"destination"`
+	DestID      string `json:"dest_id,omitempty"`
+	OK          bool   `json:"ok"`
+	Action      string `json:"action"` // deleted | removed | tombstoned | not_found | failed
+	Error       string `json:"error,omitempty"`
+}
+
+func (s *Server) provenanceRecords(memID string) []provenanceRecord {
+	rows, err := s.db.Query(`SELECT id, destination, dest_id, detail, status, error, written_at, forgotten_at
+		FROM memory_provenance WHERE memory_id=? ORDER BY id`, memID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []provenanceRecord
+	for rows.Next() {
+		var p provenanceRecord
+		rows.Scan(&p.ID, &p.Destination, &p.DestID, &p.Detail, &p.Status, &p.Error, &p.WrittenAt, &p.ForgottenAt)
+		out = append(out, p)
+	}
+	return out
+}
+
+// forgetMemory removes a memory from every destination it was written to and
+// tombstones the queue item.
+func (s *Server) forgetMemory(memID, reason string) []forgetResult {
+	return s.unwriteMemory(memID, reason, true)
+}
+
+// unwriteMemory removes a memory from every destination it was written to.
+// Without tombstone the queue item keeps its text, so a reversible decision
+// (memory_review.go) can write it out again.
+func (s *Server) unwriteMemory(memID, reason string, tombstone bool) []forgetResult {
+	var text, correction, destsJSON, createdAt string
+	var nCorrection, nDests sql.NullString
+	queued := s.db.QueryRow(`SELECT memory_text, correction, destinations, created_at FROM memory_queue WHERE id=?`, memID).
+		Scan(&text, &nCorrection, &nDests, &createdAt) == nil
+	correction, destsJSON = nCorrection.String, nDests.String
+	if correction != "" {
+		text = correction
+	}
+
+	records := s.provenanceRecords(memID)
+	// Written before provenance existed: recreate rows from the queue's destinations
+	covered := map[string]bool{}
+	for _, p := range records {
+		covered[p.Destination] = true
+	}
+	var legacy []string
+	json.Unmarshal([]byte(destsJSON), &legacy)
+	for _, d := range legacy {
+		if covered[d] || text == "" {
+			continue
+		}
+		switch d {
+		case "mem0":
+			s.recordProvenance(memID, "mem0", "", text)
+		case "memory_md":
+			s.recordProvenance(memID, "memory_md", "/home/wirebot/clawd/MEMORY.md", "- "+strings.Join(strings.Fields(text), " "))
+		case "git":
+			s.recordProvenance(memID, "git", filepath.Join("/home/wirebot/clawd/memory/facts", memID+".yaml"), "")
+		}
+	}
+	if queued && len(legacy) > 0 && !covered["letta"] && s.lettaAgentID != "" {
+		// The feeder may have sent it before it started recording
+		var rowid int64
+		s.db.QueryRow(`SELECT rowid FROM memory_queue WHERE id=?`, memID).Scan(&rowid)
+		if rowid > 0 && rowid <= s.feederGetWatermark("approved_rowid") {
+			s.recordProvenance(memID, "letta", s.lettaAgentID, text)
+		}
+	}
+	records = s.provenanceRecords(memID)
+
+	var results []forgetResult
+	var storeIDs []string
+	gitTouched := false
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, p := range records {
+		// failed ones are retried; assumed ones were never delivered from
+		// here, but the destination should hold the memory all the same
+		switch p.Status {
+		case "written", "failed", "assumed":
+		default:
+			continue
+		}
+		res := forgetResult{Destination: p.Destination, DestID: p.DestID}
+		var err error
+		switch p.Destination {
+		case "mem0", "sqlite", "letta_archival":
+			store := s.memoryStore()
+			if store.Name() != p.Destination {
+				err = fmt.Errorf("%s is no longer the memory backend", p.Destination)
+				break
+			}
+			var deleted []string
+			deleted, err = forgetFromStore(store, p.DestID, p.Detail)
+			storeIDs = append(storeIDs, deleted...)
+			res.Action = "deleted"
+			if err == nil && len(deleted) == 0 {
+				res.Action = "not_found"
+			}
+		case "memory_md":
+			var removed bool
+			removed, err = removeMemoryLine(p.DestID, p.Detail)
+			res.Action = "removed"
+			if err == nil && !removed {
+				res.Action = "not_found"
+			}
+		case "git":
+			err = os.Remove(p.DestID)
+			res.Action = "removed"
+			if os.IsNotExist(err) {
+				err, res.Action = nil, "not_found"
+			}
+			gitTouched = true
+		case "letta":
+			msg := fmt.Sprintf("The operator asked you to forget this memory (%s):\n%s\n\n"+
+				"Remove it, and anything derived only from it, from your human, goals, kpis and business_stage blocks.", reason, p.Detail)
+			if s.lettaAgentID == "" || !s.lettaSendAsync(msg) {
+				err = fmt.Errorf("letta unreachable")
+			}
+			res.Action = "tombstoned"
+		default:
+			err = fmt.Errorf("unknown destination")
+		}
+		status := "forgotten"
+		if res.Action == "tombstoned" {
+			status = "tombstoned"
+		}
+		if err != nil {
+			res.Action, res.Error, status = "failed", err.Error(), "failed"
+		} else {
+			res.OK = true
+		}
+		s.db.Exec(`UPDATE memory_provenance SET status=?, error=?, forgotten_at=? WHERE id=?`, status, res.Error, now, p.ID)
+		results = append(results, res)
+	}
+
+	if gitTouched {
+		go clawdCommit("memory: forget "+memID, "memory/facts", "MEMORY.md")
+	}
+
+	// Parsed facts from the memory and from the memory store copies
+	s.db.Exec(`DELETE FROM memory_facts WHERE memory_id=?`, memID)
+	for _, mid := range storeIDs {
+		s.db.Exec(`DELETE FROM memory_facts WHERE memory_id=? AND source=?`, mid, s.memoryStore().Name())
+	}
+
+	if queued && tombstone {
+		if text != "" {
+			s.recordProvenance(memID, "queue", memID, fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(text))))
+			s.db.Exec(`UPDATE memory_provenance SET status='tombstoned', forgotten_at=? WHERE memory_id=? AND destination='queue'`, now, memID)
+		}
+		s.db.Exec(`UPDATE memory_queue SET status='forgotten', memory_text='', correction='', source_context='',
+			reviewed_at=CURRENT_TIMESTAMP WHERE id=?`, memID)
+	}
+	failed := 0
+	for _, r := range results {
+		if !r.OK {
+			failed++
+		}
+	}
+	log.Printf("[memory-forget] %s (%s): %d destinations, %d failed", memID, reason, len(results), failed)
+	if results == nil {
+		results = []forgetResult{}
+	}
+	return results
+}
+
+func normalizeMemoryText(t string) string {
+	return strings.Trim(strings.ToLower(strings.Join(strings.Fields(t), " ")), " .")
+}
+
+// removeMemoryLine drops every line equal to line from the file.
+func removeMemoryLine(path, line string) (bool, error) {
+	memoryFileMu.Lock()
+	defer memoryFileMu.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	lines := strings.Split(string(data), "\n")
+	kept := lines[:0]
+	removed := false
+	for _, l := range lines {
+		if strings.TrimRight(l, " \t\r") == line {
+			removed = true
+			continue
+		}
+		kept = append(kept, l)
+	}
+	if !removed {
+		return false, nil
+	}
+	tmp := path + ".forget"
+	if err := os.WriteFile(tmp, []byte(strings.Join(kept, "\n")), 0644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return false, err
+	}
+	exec.Command("chown", "wirebot:wirebot", path).Run()
+	return true, nil
+}
+
+// GET  /v1/memory/item/{id}/provenance — source + every destination
+// POST /v1/memory/item/{id}/forget     — remove from every destination
+func (s *Server) handleMemoryItemProvenance(w http.ResponseWriter, r *http.Request, id, action string) {
+	switch action {
+	case "provenance":
+		var sourceType, status, createdAt string
+		var srcFile, srcCtx, reviewedAt sql.NullString
+		s.db.QueryRow(`SELECT source_type, source_file, source_context, status, created_at, reviewed_at
+			FROM memory_queue WHERE id=?`, id).Scan(&sourceType, &srcFile, &srcCtx, &status, &createdAt, &reviewedAt)
+		records := s.provenanceRecords(id)
+		if records == nil && sourceType == "" {
+			http.Error(w, `{"error":"not found"}`, 404)
+			return
+		}
+		if records == nil {
+			records = []provenanceRecord{}
+		}
+		writeJSON(w, map[string]interface{}{
+			"id": id, "status": status,
+			"source": map[string]interface{}{
+				"type": sourceType, "file": srcFile.String, "context": srcCtx.String,
+				"created_at": createdAt, "reviewed_at": reviewedAt.String,
+			},
+			"destinations": records,
+			"merged":       s.memoryMerges(id),
+		})
+
+	case "forget":
+		if r.Method != "POST" {
+			http.Error(w, `{"error":"POST only"}`, 405)
+			return
+		}
+		var exists int
+		s.db.QueryRow(`SELECT COUNT(*) FROM memory_queue WHERE id=?`, id).Scan(&exists)
+		if exists == 0 && !s.hasProvenance(id) {
+			http.Error(w, `{"error":"not found"}`, 404)
+			return
+		}
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Reason == "" {
+			body.Reason = "operator request"
+		}
+		results := s.forgetMemory(id, body.Reason)
+		ok := true
+		for _, res := range results {
+			ok = ok && res.OK
+		}
+		writeJSON(w, map[string]interface{}{"ok": ok, "id": id, "results": results})
+
+	default:
+		http.Error(w, `{"error":"unknown action, use forget or provenance"}`, 404)
+	}
+}
@@ -0,0 +1,682 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MEMORY REVIEW — bulk decisions, saved rules, and an audit log to undo them
+//
+// One vault extraction can queue hundreds of items; reviewing them one at a
+// time (/v1/memory/queue/{id}/{action}) doesn't scale. A reviewFilter selects
+// queue items by source_type, source_file (prefix, or * wildcards),
+// confidence range and text pattern (case-insensitive regexp):
+//
+//   POST /v1/memory/queue/bulk     {filter, action: approve|reject, dry_run}
+//
+// Saved rules apply the same filter to every new extraction as it is queued
+// (QueueMemoryForApproval), e.g. approve pairing answers >= 0.9, or reject
+// ai_chat items matching "recipe". Reject rules win over approve rules and
+// over the built-in auto-approval.
+//
+//   GET    /v1/memory/rules
+//   POST   /v1/memory/rules              {name, action, filter, apply_existing}
+//   PUT    /v1/memory/rules/{id}         same body; enabled toggles it
+//   DELETE /v1/memory/rules/{id}
+//   POST   /v1/memory/rules/{id}/run     apply to what is pending now
+//
+// Every decision not taken by hand on a single item — bulk, rule, built-in
+// auto-approval — goes to memory_review_log with the previous status, and can
+// be reverted by log id, batch or rule:
+//
+//   GET  /v1/memory/review-log[?rule_id=&batch_id=&memory_id=]
+//   POST /v1/memory/review-log/revert   {ids} | {batch_id} | {rule_id}
+//
+// Reverting an approval pulls the memory back from every destination
+// (memory_provenance.go) but keeps the queue item and its text; reverting a
+// rejection of an approved item writes it out again. A decision is only
+// reverted while the item still has the status it was given.
+//
+// Writebacks and unwrites from these decisions don't run inline: they go on
+// reviewWrites, one at a time in order, so a bulk approve of thousands of
+// items doesn't become thousands of concurrent Mem0 calls and commits. A
+// revert's unwrite queues behind the writeback it undoes, and a writeback
+// whose item is no longer approved when its turn comes is skipped.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// maxBulkReview caps one bulk request.
+const maxBulkReview = 5000
+
+// reviewWriteQueue runs review side effects serially, in the order queued.
+type reviewWriteQueue struct {
+	mu      sync.Mutex
+	pending []func()
+	running bool
+}
+
+var reviewWrites = &reviewWriteQueue{}
+
+func (q *reviewWriteQueue) add(fn func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, fn)
+	if !q.running {
+		q.running = true
+		go q.drain()
+	}
+}
+
+func (q *reviewWriteQueue) drain() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		fn := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		fn()
+	}
+}
+
+// queueWriteback writes an approved item out once its turn comes, unless it
+// has been reverted or rejected in the meantime.
+func (s *Server) queueWriteback(memID, text string) {
+	reviewWrites.add(func() {
+		var status string
+		s.db.QueryRow(`SELECT status FROM memory_queue WHERE id=?`, memID).Scan(&status)
+		if status == "approved" {
+			s.writebackApprovedMemory(memID, text)
+		}
+	})
+}
+
+// queueUnwrite pulls an item back from wherever it was written, after any
+// writeback queued before it.
+func (s *Server) queueUnwrite(memID, reason string) {
+	reviewWrites.add(func() {
+		if s.hasProvenance(memID) {
+			s.unwriteMemory(memID, reason, false)
+		}
+	})
+}
+
+type reviewFilter struct {
+	SourceType    string   `json:"source_type,omitempty"`
+	SourceFile    string   `json:"source_file,omitempty"`
+	MinConfidence *float64 `json:"min_confidence,omitempty"`
+	MaxConfidence *float64 `json:"max_confidence,omitempty"`
+	Pattern       string   `json:"pattern,omitempty"`
+	Status        string   `json:"status,omitempty"` // bulk only; default pending
+
+	re *regexp.Regexp
+}
+
+// compile validates the filter and prepares its pattern.
+func (f *reviewFilter) compile() error {
+	if f.Pattern != "" {
+		re, err := regexp.Compile("(?i)" + f.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern: %v", err)
+		}
+		f.re = re
+	}
+	switch f.Status {
+	case "", "pending", "approved", "rejected":
+	default:
+		// Forgotten, retired, … items have no live text to decide on
+		return fmt.Errorf("status must be pending, approved or rejected")
+	}
+	if f.MinConfidence != nil && f.MaxConfidence != nil && *f.MinConfidence > *f.MaxConfidence {
+		return fmt.Errorf("min_confidence is above max_confidence")
+	}
+	if f.SourceType == "" && f.SourceFile == "" && f.MinConfidence == nil && f.MaxConfidence == nil && f.Pattern == "" {
+		return fmt.Errorf("filter matches everything; set at least one field")
+	}
+	return nil
+}
+
+func (f *reviewFilter) matches(sourceType, sourceFile string, confidence float64, text string) bool {
+	if f.SourceType != "" && !strings.EqualFold(f.SourceType, sourceType) {
+		return false
+	}
+	if f.SourceFile != "" {
+		pat := f.SourceFile
+		if !strings.Contains(pat, "*") {
+			pat += "*" // source_file carries a " [section]" suffix
+		}
+		if !wildcardMatch(strings.ToLower(pat), strings.ToLower(sourceFile)) {
+			return false
+		}
+	}
+	if f.MinConfidence != nil && confidence < *f.MinConfidence {
+		return false
+	}
+	if f.MaxConfidence != nil && confidence > *f.MaxConfidence {
+		return false
+	}
+	if f.re != nil && !f.re.MatchString(text) {
+		return false
+	}
+	return true
+}
+
+// wildcardMatch matches s against a pattern where * is any run of characters.
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, p := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(s, p)
+		}
+		idx := strings.Index(s, p)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(p):]
+	}
+	return s == ""
+}
+
+type reviewRule struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Action       string       `json:"action"` // approve | reject
+	Filter       reviewFilter `json:"filter"`
+	Enabled      bool         `json:"enabled"`
+	AppliedCount int          `json:"applied_count"`
+	CreatedBy    string       `json:"created_by,omitempty"`
+	CreatedAt    string       `json:"created_at"`
+	UpdatedAt    string       `json:"updated_at"`
+}
+
+type reviewLogEntry struct {
+	ID         int64  `json:"id"`
+	MemoryID   string `json:"memory_id"`
+	Text       string `json:"text,omitempty"`
+	Action     string `json:"action"`
+	PrevStatus string `json:"prev_status"`
+	NewStatus  string `json:"new_status"`
+	RuleID     string `json:"rule_id,omitempty"` // "builtin" for the auto-approval rules
+	BatchID    string `json:"batch_id,omitempty"`
+	Actor      string `json:"actor"`
+	Reason     string `json:"reason,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	RevertedAt string `json:"reverted_at,omitempty"`
+	RevertedBy string `json:"reverted_by,omitempty"`
+}
+
+func (s *Server) initMemoryReview() {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS memory_review_rules (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			action TEXT NOT NULL,
+			filter TEXT NOT NULL,
+			enabled INTEGER DEFAULT 1,
+			applied_count INTEGER DEFAULT 0,
+			created_by TEXT DEFAULT '',
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS memory_review_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			memory_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			prev_status TEXT NOT NULL,
+			new_status TEXT NOT NULL,
+			rule_id TEXT DEFAULT '',
+			batch_id TEXT DEFAULT '',
+			actor TEXT DEFAULT '',
+			reason TEXT DEFAULT '',
+			created_at TEXT NOT NULL,
+			reverted_at TEXT DEFAULT '',
+			reverted_by TEXT DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_log_memory ON memory_review_log(memory_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_log_batch ON memory_review_log(batch_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_log_rule ON memory_review_log(rule_id)`,
+	} {
+		s.db.Exec(stmt)
+	}
+}
+
+func reviewID(prefix string) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%x", prefix, time.Now().UnixNano(), b)
+}
+
+func reviewActor(r *http.Request) string {
+	if auth := resolveAuth(r); auth.Authenticated && auth.Username != "" {
+		return auth.Username
+	} else if auth.Authenticated && auth.UserID != 0 {
+		return fmt.Sprintf("user-%d", auth.UserID)
+	}
+	return "operator"
+}
+
+func (s *Server) logReviewDecision(memID, action, prev, next, ruleID, batchID, actor, reason string) {
+	s.db.Exec(`INSERT INTO memory_review_log (memory_id, action, prev_status, new_status, rule_id, batch_id, actor, reason, created_at)
+		VALUES (?,?,?,?,?,?,?,?,?)`, memID, action, prev, next, ruleID, batchID, actor, reason,
+		time.Now().UTC().Format(time.RFC3339))
+}
+
+func (s *Server) loadReviewRules(enabledOnly bool) []reviewRule {
+	q := `SELECT id, name, action, filter, enabled, applied_count, created_by, created_at, updated_at FROM memory_review_rules`
+	if enabledOnly {
+		q += ` WHERE enabled=1`
+	}
+	// Reject rules first: they win
+	q += ` ORDER BY CASE action WHEN 'reject' THEN 0 ELSE 1 END, created_at`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []reviewRule
+	for rows.Next() {
+		var rule reviewRule
+		var filter string
+		var enabled int
+		rows.Scan(&rule.ID, &rule.Name, &rule.Action, &filter, &enabled, &rule.AppliedCount,
+			&rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt)
+		json.Unmarshal([]byte(filter), &rule.Filter)
+		if rule.Filter.compile() != nil && enabledOnly {
+			continue
+		}
+		rule.Enabled = enabled == 1
+		out = append(out, rule)
+	}
+	return out
+}
+
+// matchReviewRule returns the first enabled rule matching a new extraction.
+func (s *Server) matchReviewRule(sourceType, sourceFile string, confidence float64, text string) *reviewRule {
+	for _, rule := range s.loadReviewRules(true) {
+		if rule.Filter.matches(sourceType, sourceFile, confidence, text) {
+			r := rule
+			return &r
+		}
+	}
+	return nil
+}
+
+// applyReviewDecision approves or rejects a queue item with the same side
+// effects as the per-item actions, and logs it. Returns false if the item is
+// already in that state or can't be found.
+func (s *Server) applyReviewDecision(memID, action, ruleID, batchID, actor, reason string) bool {
+	next := map[string]string{"approve": "approved", "reject": "rejected"}[action]
+	var prev, text string
+	var correction sql.NullString
+	if s.db.QueryRow(`SELECT status, memory_text, correction FROM memory_queue WHERE id=?`, memID).
+		Scan(&prev, &text, &correction) != nil || next == "" || prev == next {
+		return false
+	}
+	if correction.String != "" {
+		text = correction.String
+	}
+	switch action {
+	case "approve":
+		s.db.Exec(`UPDATE memory_queue SET status='approved', reviewed_at=CURRENT_TIMESTAMP WHERE id=?`, memID)
+		s.queueWriteback(memID, text)
+	case "reject":
+		s.db.Exec(`UPDATE memory_queue SET status='rejected', reviewed_at=CURRENT_TIMESTAMP WHERE id=?`, memID)
+		if prev == "approved" {
+			// Keep the text: this decision can be reverted
+			s.queueUnwrite(memID, "rejected by "+actor)
+		}
+	}
+	if ruleID != "" && ruleID != "builtin" {
+		s.db.Exec(`UPDATE memory_review_rules SET applied_count=applied_count+1 WHERE id=?`, ruleID)
+	}
+	s.logReviewDecision(memID, action, prev, next, ruleID, batchID, actor, reason)
+	return true
+}
+
+// revertReviewDecision undoes one logged decision.
+func (s *Server) revertReviewDecision(logID int64, actor string) error {
+	var e reviewLogEntry
+	err := s.db.QueryRow(`SELECT memory_id, action, prev_status, new_status, reverted_at FROM memory_review_log WHERE id=?`, logID).
+		Scan(&e.MemoryID, &e.Action, &e.PrevStatus, &e.NewStatus, &e.RevertedAt)
+	if err != nil {
+		return fmt.Errorf("log entry not found")
+	}
+	if e.RevertedAt != "" {
+		return fmt.Errorf("already reverted")
+	}
+	var status, text string
+	var correction sql.NullString
+	s.db.QueryRow(`SELECT status, memory_text, correction FROM memory_queue WHERE id=?`, e.MemoryID).
+		Scan(&status, &text, &correction)
+	if status != e.NewStatus {
+		return fmt.Errorf("item is now %q, not %q", status, e.NewStatus)
+	}
+	if correction.String != "" {
+		text = correction.String
+	}
+
+	reviewedAt := "CURRENT_TIMESTAMP"
+	if e.PrevStatus == "pending" {
+		reviewedAt = "NULL"
+	}
+	s.db.Exec(`UPDATE memory_queue SET status=?, reviewed_at=`+reviewedAt+` WHERE id=?`, e.PrevStatus, e.MemoryID)
+	switch {
+	case e.NewStatus == "approved":
+		s.queueUnwrite(e.MemoryID, "approval reverted by "+actor)
+		s.db.Exec(`UPDATE memory_queue SET category='', confirmed_at='', expires_at='' WHERE id=?`, e.MemoryID)
+	case e.PrevStatus == "approved":
+		s.queueWriteback(e.MemoryID, text)
+	}
+	s.db.Exec(`UPDATE memory_review_log SET reverted_at=?, reverted_by=? WHERE id=?`,
+		time.Now().UTC().Format(time.RFC3339), actor, logID)
+	return nil
+}
+
+type reviewCandidate struct {
+	ID         string  `json:"id"`
+	Text       string  `json:"memory_text"`
+	SourceType string  `json:"source_type"`
+	SourceFile string  `json:"source_file,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Status     string  `json:"status"`
+}
+
+// reviewCandidates returns queue items in status matching f. "Still true?"
+// items have their own actions (memory_ttl.go) and are never bulk-decided.
+func (s *Server) reviewCandidates(f *reviewFilter, status string) []reviewCandidate {
+	rows, err := s.db.Query(`SELECT id, CASE WHEN COALESCE(correction,'') != '' THEN correction ELSE memory_text END,
+		source_type, COALESCE(source_file,''), confidence, status
+		FROM memory_queue WHERE status=? AND source_type != 'reconfirm' ORDER BY created_at`, status)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []reviewCandidate
+	for rows.Next() {
+		var c reviewCandidate
+		rows.Scan(&c.ID, &c.Text, &c.SourceType, &c.SourceFile, &c.Confidence, &c.Status)
+		if f.matches(c.SourceType, c.SourceFile, c.Confidence, c.Text) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// POST /v1/memory/queue/bulk
+func (s *Server) handleMemoryBulkReview(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, `{"error":"POST only"}`, 405)
+		return
+	}
+	var body struct {
+		Filter reviewFilter `json:"filter"`
+		Action string       `json:"action"`
+		DryRun bool         `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, 400)
+		return
+	}
+	if body.Action != "approve" && body.Action != "reject" {
+		http.Error(w, `{"error":"action must be approve or reject"}`, 400)
+		return
+	}
+	if err := body.Filter.compile(); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
+		return
+	}
+	status := body.Filter.Status
+	if status == "" {
+		status = "pending"
+	}
+	matched := s.reviewCandidates(&body.Filter, status)
+	if len(matched) > maxBulkReview {
+		http.Error(w, fmt.Sprintf(`{"error":"%d items match; narrow the filter (max %d)"}`, len(matched), maxBulkReview), 400)
+		return
+	}
+	if body.DryRun {
+		preview := matched
+		if len(preview) > 50 {
+			preview = preview[:50]
+		}
+		if preview == nil {
+			preview = []reviewCandidate{}
+		}
+		writeJSON(w, map[string]interface{}{"dry_run": true, "action": body.Action, "matched": len(matched), "items": preview})
+		return
+	}
+
+	batchID := reviewID("batch")
+	actor := reviewActor(r)
+	applied := 0
+	for _, c := range matched {
+		if s.applyReviewDecision(c.ID, body.Action, "", batchID, actor, "bulk "+body.Action) {
+			applied++
+		}
+	}
+	log.Printf("[memory-review] bulk %s by %s: %d/%d items (batch %s)", body.Action, actor, applied, len(matched), batchID)
+	writeJSON(w, map[string]interface{}{"ok": true, "action": body.Action, "matched": len(matched), "applied": applied, "batch_id": batchID})
+}
+
+// /v1/memory/rules and /v1/memory/rules/{id}[/run]
+func (s *Server) handleMemoryReviewRules(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	id, sub, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/memory/rules"), "/"), "/")
+
+	var body struct {
+		Name          string       `json:"name"`
+		Action        string       `json:"action"`
+		Filter        reviewFilter `json:"filter"`
+		Enabled       *bool        `json:"enabled"`
+		ApplyExisting bool         `json:"apply_existing"`
+	}
+	decode := func() bool {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, 400)
+			return false
+		}
+		if body.Action != "approve" && body.Action != "reject" {
+			http.Error(w, `{"error":"action must be approve or reject"}`, 400)
+			return false
+		}
+		body.Filter.Status = ""
+		if err := body.Filter.compile(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
+			return false
+		}
+		if body.Name == "" {
+			body.Name = body.Action + " " + body.Filter.SourceType + " " + body.Filter.Pattern
+		}
+		return true
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	switch {
+	case id == "" && r.Method == "GET":
+		rules := s.loadReviewRules(false)
+		if rules == nil {
+			rules = []reviewRule{}
+		}
+		writeJSON(w, map[string]interface{}{"rules": rules})
+
+	case id == "" && r.Method == "POST":
+		if !decode() {
+			return
+		}
+		id = reviewID("rule")
+		filter, _ := json.Marshal(body.Filter)
+		enabled := body.Enabled == nil || *body.Enabled
+		s.db.Exec(`INSERT INTO memory_review_rules (id, name, action, filter, enabled, created_by, created_at, updated_at)
+			VALUES (?,?,?,?,?,?,?,?)`, id, strings.TrimSpace(body.Name), body.Action, string(filter), enabled, reviewActor(r), now, now)
+		resp := map[string]interface{}{"ok": true, "id": id}
+		if body.ApplyExisting && enabled {
+			resp["applied"] = s.runReviewRule(id, reviewActor(r))
+		}
+		writeJSON(w, resp)
+
+	case id != "" && sub == "" && r.Method == "PUT":
+		if !decode() {
+			return
+		}
+		filter, _ := json.Marshal(body.Filter)
+		enabled := body.Enabled == nil || *body.Enabled
+		res, _ := s.db.Exec(`UPDATE memory_review_rules SET name=?, action=?, filter=?, enabled=?, updated_at=? WHERE id=?`,
+			strings.TrimSpace(body.Name), body.Action, string(filter), enabled, now, id)
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, `{"error":"rule not found"}`, 404)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "id": id})
+
+	case id != "" && sub == "" && r.Method == "DELETE":
+		// Its log entries stay, so its decisions can still be reverted
+		s.db.Exec(`DELETE FROM memory_review_rules WHERE id=?`, id)
+		writeJSON(w, map[string]interface{}{"ok": true, "id": id})
+
+	case id != "" && sub == "run" && r.Method == "POST":
+		var exists int
+		s.db.QueryRow(`SELECT COUNT(*) FROM memory_review_rules WHERE id=?`, id).Scan(&exists)
+		if exists == 0 {
+			http.Error(w, `{"error":"rule not found"}`, 404)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "id": id, "applied": s.runReviewRule(id, reviewActor(r))})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, 405)
+	}
+}
+
+// runReviewRule applies a rule to the pending queue and returns how many items
+// it decided.
+func (s *Server) runReviewRule(ruleID, actor string) int {
+	for _, rule := range s.loadReviewRules(false) {
+		if rule.ID != ruleID {
+			continue
+		}
+		if rule.Filter.compile() != nil {
+			return 0
+		}
+		batchID := reviewID("batch")
+		applied := 0
+		for _, c := range s.reviewCandidates(&rule.Filter, "pending") {
+			if s.applyReviewDecision(c.ID, rule.Action, rule.ID, batchID, actor, "rule: "+rule.Name) {
+				applied++
+			}
+		}
+		log.Printf("[memory-review] rule %q run by %s: %d items", rule.Name, actor, applied)
+		return applied
+	}
+	return 0
+}
+
+// GET /v1/memory/review-log
+func (s *Server) handleMemoryReviewLog(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	where := []string{"1=1"}
+	var args []interface{}
+	for _, k := range []string{"rule_id", "batch_id", "memory_id"} {
+		if v := r.URL.Query().Get(k); v != "" {
+			where = append(where, "l."+k+"=?")
+			args = append(args, v)
+		}
+	}
+	limit := 200
+	fmt.Sscanf(r.URL.Query().Get("limit"), "%d", &limit)
+	args = append(args, limit)
+	rows, err := s.db.Query(`SELECT l.id, l.memory_id, COALESCE(q.memory_text,''), l.action, l.prev_status, l.new_status,
+		l.rule_id, l.batch_id, l.actor, l.reason, l.created_at, l.reverted_at, l.reverted_by
+		FROM memory_review_log l LEFT JOIN memory_queue q ON q.id = l.memory_id
+		WHERE `+strings.Join(where, " AND ")+` ORDER BY l.id DESC LIMIT ?`, args...)
+	if err != nil {
+		http.Error(w, `{"error":"db query failed"}`, 500)
+		return
+	}
+	defer rows.Close()
+	entries := []reviewLogEntry{}
+	for rows.Next() {
+		var e reviewLogEntry
+		rows.Scan(&e.ID, &e.MemoryID, &e.Text, &e.Action, &e.PrevStatus, &e.NewStatus,
+			&e.RuleID, &e.BatchID, &e.Actor, &e.Reason, &e.CreatedAt, &e.RevertedAt, &e.RevertedBy)
+		entries = append(entries, e)
+	}
+	writeJSON(w, map[string]interface{}{"entries": entries})
+}
+
+// POST /v1/memory/review-log/revert
+func (s *Server) handleMemoryReviewRevert(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, `{"error":"POST only"}`, 405)
+		return
+	}
+	var body struct {
+		IDs     []int64 `json:"ids"`
+		BatchID string  `json:"batch_id"`
+		RuleID  string  `json:"rule_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, 400)
+		return
+	}
+	ids := body.IDs
+	if body.BatchID != "" || body.RuleID != "" {
+		col, val := "batch_id", body.BatchID
+		if body.RuleID != "" {
+			col, val = "rule_id", body.RuleID
+		}
+		rows, err := s.db.Query(`SELECT id FROM memory_review_log WHERE `+col+`=? AND reverted_at='' ORDER BY id DESC`, val)
+		if err == nil {
+			for rows.Next() {
+				var id int64
+				rows.Scan(&id)
+				ids = append(ids, id)
+			}
+			rows.Close()
+		}
+	}
+	if len(ids) == 0 {
+		http.Error(w, `{"error":"nothing to revert: give ids, batch_id or rule_id"}`, 400)
+		return
+	}
+	actor := reviewActor(r)
+	reverted := 0
+	failed := map[string]string{}
+	for _, id := range ids {
+		if err := s.revertReviewDecision(id, actor); err != nil {
+			failed[fmt.Sprint(id)] = err.Error()
+			continue
+		}
+		reverted++
+	}
+	log.Printf("[memory-review] %s reverted %d decisions (%d failed)", actor, reverted, len(failed))
+	writeJSON(w, map[string]interface{}{"ok": len(failed) == 0, "reverted": reverted, "failed": failed})
+}
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MEMORY STORE — one interface over the long-term fact store, per tenant
+//
+// Everything that stores, lists, searches or deletes long-term memories goes
+// through Server.memoryStore(): queue writeback, training feedback, fact
+// parsing (memory_facts.go), forget (memory_provenance.go), re-confirmation
+// (memory_ttl.go), and the tenant pairing engine's profile sync.
+//
+// Backends:
+//   mem0    the Mem0 wrapper service (/v1/store, /v1/list, /v1/search,
+//           /v1/delete) under one namespace
+//   letta   a Letta agent's archival memory (passages)
+//   sqlite  built in: a memory_store table in the tenant's own database with
+//           an FTS4 index for search. Needs nothing else running.
+//
+// The operator defaults to mem0 (MEM0_URL, MEM0_NAMESPACE); every other tenant
+// defaults to sqlite, so a freshly provisioned tenant has working memory.
+// Either can be switched:
+//   GET /v1/memory/backend   config + reachability
+//   PUT /v1/memory/backend   {backend, mem0_url, mem0_namespace, letta_url,
+//                             letta_agent_id} — memories are not migrated
+// Tenants may only choose sqlite themselves; a remote backend for a tenant is
+// set by the operator, and never with the operator's own mem0 namespace.
+//
+// Direct access (tenant mux too):
+//   GET    /v1/memory/store[?q=&limit=]   list, or search when q is set
+//   POST   /v1/memory/store               {text, category}
+//   PATCH  /v1/memory/store/{id}          {text}
+//   DELETE /v1/memory/store/{id}
+//
+// Provenance rows name the backend a memory was written to (Name()), so
+// forget knows where to delete it from.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	defaultMem0URL       = envOr("MEM0_URL", "http://127.0.0.1:8200")
+	defaultMem0Namespace = envOr("MEM0_NAMESPACE", "wirebot_verious")
+)
+
+// MemoryRecord is one memory as a backend returns it.
+type MemoryRecord struct {
+	ID        string  `json:"id"`
+	Text      string  `json:"text"`
+	Category  string  `json:"category,omitempty"`
+	Score     float64 `json:"score,omitempty"` // search relevance, backend-specific scale
+	CreatedAt string  `json:"created_at,omitempty"`
+	UpdatedAt string  `json:"updated_at,omitempty"`
+}
+
+// MemoryStore is a long-term memory backend.
+type MemoryStore interface {
+	// Name identifies the backend in provenance and memory_facts.source.
+	Name() string
+	// Put stores text and returns the IDs it was stored under. Mem0 may split
+	// one text into several facts, or none if it already knows them.
+	Put(text, category string) ([]string, error)
+	List() ([]MemoryRecord, error)
+	Search(query string, limit int) ([]MemoryRecord, error)
+	// Delete returns errMemoryNotFound if id doesn't exist.
+	Delete(id string) error
+	// Update replaces the text of id and returns the ID now holding it, which
+	// differs from id on backends without in-place edits.
+	Update(id, text string) (string, error)
+}
+
+var errMemoryNotFound = errors.New("memory not found")
+
+type memoryBackendConfig struct {
+	Backend       string `json:"backend"` // sqlite | mem0 | letta
+	Mem0URL       string `json:"mem0_url,omitempty"`
+	Mem0Namespace string `json:"mem0_namespace,omitempty"`
+	LettaURL      string `json:"letta_url,omitempty"`
+	LettaAgentID  string `json:"letta_agent_id,omitempty"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
+}
+
+func (s *Server) initMemoryStore() {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS memory_backend (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			backend TEXT NOT NULL,
+			mem0_url TEXT DEFAULT '',
+			mem0_namespace TEXT DEFAULT '',
+			letta_url TEXT DEFAULT '',
+			letta_agent_id TEXT DEFAULT '',
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS memory_store (
+			id TEXT PRIMARY KEY,
+			text TEXT NOT NULL,
+			category TEXT DEFAULT '',
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS memory_store_fts USING fts4(text, tokenize=porter)`,
+		`CREATE TRIGGER IF NOT EXISTS memory_store_ai AFTER INSERT ON memory_store BEGIN
+			INSERT INTO memory_store_fts (docid, text) VALUES (new.rowid, new.text);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS memory_store_au AFTER UPDATE OF text ON memory_store BEGIN
+			UPDATE memory_store_fts SET text = new.text WHERE docid = old.rowid;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS memory_store_ad AFTER DELETE ON memory_store BEGIN
+			DELETE FROM memory_store_fts WHERE docid = old.rowid;
+		END`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			log.Printf("[memory-store] init: %v", err)
+		}
+	}
+	// Normalised text, indexed, so Put dedupes without scanning every memory
+	s.db.Exec(`ALTER TABLE memory_store ADD COLUMN norm_text TEXT DEFAULT ''`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_memory_store_norm ON memory_store(norm_text)`)
+	s.backfillMemoryStoreNorm()
+	s.loadMemoryStore()
+}
+
+// backfillMemoryStoreNorm fills norm_text for rows stored before it existed.
+func (s *Server) backfillMemoryStoreNorm() {
+	rows, err := s.db.Query(`SELECT id, text FROM memory_store WHERE norm_text='' OR norm_text IS NULL`)
+	if err != nil {
+		return
+	}
+	norm := map[string]string{}
+	for rows.Next() {
+		var id, text string
+		rows.Scan(&id, &text)
+		norm[id] = normalizeMemoryText(text)
+	}
+	rows.Close()
+	for id, n := range norm {
+		s.db.Exec(`UPDATE memory_store SET norm_text=? WHERE id=?`, n, id)
+	}
+}
+
+// memoryBackend returns the tenant's backend config, or the default for the
+// tenant when none has been set.
+func (s *Server) memoryBackend() memoryBackendConfig {
+	var cfg memoryBackendConfig
+	err := s.db.QueryRow(`SELECT backend, mem0_url, mem0_namespace, letta_url, letta_agent_id, updated_at
+		FROM memory_backend WHERE id=1`).
+		Scan(&cfg.Backend, &cfg.Mem0URL, &cfg.Mem0Namespace, &cfg.LettaURL, &cfg.LettaAgentID, &cfg.UpdatedAt)
+	if err == nil {
+		return cfg
+	}
+	if s.tenantID == "" && !s.dryRun {
+		return memoryBackendConfig{Backend: "mem0", Mem0URL: defaultMem0URL, Mem0Namespace: defaultMem0Namespace}
+	}
+	return memoryBackendConfig{Backend: "sqlite"}
+}
+
+func (s *Server) loadMemoryStore() {
+	cfg := s.memoryBackend()
+	store, err := newMemoryStore(cfg, s.db)
+	if err == nil && s.tenantID != "" && cfg.Backend == "mem0" && cfg.Mem0Namespace == defaultMem0Namespace {
+		// Saved before handleMemoryBackend refused it
+		err = fmt.Errorf("tenant %q configured with the operator's mem0 namespace", s.tenantID)
+	}
+	if err != nil {
+		log.Printf("[memory-store] %v — falling back to sqlite", err)
+		store = &sqliteMemoryStore{db: s.db}
+	}
+	s.mu.Lock()
+	s.memory = store
+	s.mu.Unlock()
+}
+
+// memoryStore returns the tenant's memory backend.
+func (s *Server) memoryStore() MemoryStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.memory == nil {
+		return &sqliteMemoryStore{db: s.db}
+	}
+	return s.memory
+}
+
+func newMemoryStore(cfg memoryBackendConfig, db *sql.DB) (MemoryStore, error) {
+	switch cfg.Backend {
+	case "sqlite", "":
+		return &sqliteMemoryStore{db: db}, nil
+	case "mem0":
+		if cfg.Mem0URL == "" || cfg.Mem0Namespace == "" {
+			return nil, fmt.Errorf("mem0 backend needs mem0_url and mem0_namespace")
+		}
+		return &mem0MemoryStore{baseURL: strings.TrimRight(cfg.Mem0URL, "/"), namespace: cfg.Mem0Namespace}, nil
+	case "letta":
+		if cfg.LettaURL == "" || cfg.LettaAgentID == "" {
+			return nil, fmt.Errorf("letta backend needs letta_url and letta_agent_id")
+		}
+		return &lettaMemoryStore{baseURL: strings.TrimRight(cfg.LettaURL, "/"), agentID: cfg.LettaAgentID}, nil
+	}
+	return nil, fmt.Errorf("unknown memory backend %q", cfg.Backend)
+}
+
+// isMemoryBackend reports whether a provenance destination is a MemoryStore.
+func isMemoryBackend(dest string) bool {
+	switch dest {
+	case "mem0", "sqlite", "letta_archival":
+		return true
+	}
+	return false
+}
+
+// forgetFromStore deletes a memory by ID, or — when the ID is unknown — every
+// memory whose text matches. Returns the IDs deleted.
+func forgetFromStore(store MemoryStore, id, text string) ([]string, error) {
+	ids := []string{}
+	if id != "" {
+		ids = append(ids, id)
+	} else {
+		all, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+		want := normalizeMemoryText(text)
+		for _, m := range all {
+			if normalizeMemoryText(m.Text) == want {
+				ids = append(ids, m.ID)
+			}
+		}
+	}
+	var deleted []string
+	for _, mid := range ids {
+		err := store.Delete(mid)
+		if errors.Is(err, errMemoryNotFound) {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, mid)
+	}
+	return deleted, nil
+}
+
+// ─── SQLite ─────────────────────────────────────────────────────────────────
+
+type sqliteMemoryStore struct {
+	db *sql.DB
+}
+
+func (m *sqliteMemoryStore) Name() string { return "sqlite" }
+
+func (m *sqliteMemoryStore) Put(text, category string) ([]string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("empty memory")
+	}
+	// Same fact twice is one memory, as in Mem0
+	norm := normalizeMemoryText(text)
+	var existing string
+	err := m.db.QueryRow(`SELECT id FROM memory_store WHERE norm_text=? ORDER BY created_at LIMIT 1`, norm).Scan(&existing)
+	if err == nil {
+		return []string{existing}, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	id := fmt.Sprintf("smem-%d-%x", time.Now().UnixNano(), b)
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := m.db.Exec(`INSERT INTO memory_store (id, text, norm_text, category, created_at, updated_at) VALUES (?,?,?,?,?,?)`,
+		id, text, norm, category, now, now); err != nil {
+		return nil, err
+	}
+	return []string{id}, nil
+}
+
+func (m *sqliteMemoryStore) List() ([]MemoryRecord, error) {
+	rows, err := m.db.Query(`SELECT id, text, category, created_at, updated_at FROM memory_store ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []MemoryRecord{}
+	for rows.Next() {
+		var r MemoryRecord
+		rows.Scan(&r.ID, &r.Text, &r.Category, &r.CreatedAt, &r.UpdatedAt)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+var ftsTermRe = regexp.MustCompile(`[\pL\pN]+`)
+
+// ftsStopwords would match nearly every memory.
+var ftsStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "at": true, "does": true, "do": true, "for": true,
+	"from": true, "has": true, "have": true, "how": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "was": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "with": true,
+}
+
+// Search matches any query term (porter-stemmed) and ranks by the share of
+// terms a memory contains, then by how often they occur, then recency.
+func (m *sqliteMemoryStore) Search(query string, limit int) ([]MemoryRecord, error) {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range ftsTermRe.FindAllString(strings.ToLower(query), -1) {
+		if !seen[t] && !ftsStopwords[t] {
+			seen[t] = true
+			terms = append(terms, `"`+t+`"`)
+		}
+	}
+	if len(terms) == 0 {
+		return []MemoryRecord{}, nil
+	}
+	rows, err := m.db.Query(`SELECT s.id, s.text, s.category, s.created_at, s.updated_at, offsets(memory_store_fts)
+		FROM memory_store_fts JOIN memory_store s ON s.rowid = memory_store_fts.docid
+		WHERE memory_store_fts MATCH ?`, strings.Join(terms, " OR "))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []MemoryRecord{}
+	for rows.Next() {
+		var r MemoryRecord
+		var offsets string
+		rows.Scan(&r.ID, &r.Text, &r.Category, &r.CreatedAt, &r.UpdatedAt, &offsets)
+		// offsets(): "column term byte size" per hit
+		f := strings.Fields(offsets)
+		matched := map[string]bool{}
+		for i := 1; i < len(f); i += 4 {
+			matched[f[i]] = true
+		}
+		hits := len(f) / 4
+		r.Score = float64(len(matched))/float64(len(terms)) + 0.01*float64(min(hits, 10))
+		out = append(out, r)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].UpdatedAt > out[j].UpdatedAt
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, rows.Err()
+}
+
+func (m *sqliteMemoryStore) Delete(id string) error {
+	res, err := m.db.Exec(`DELETE FROM memory_store WHERE id=?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errMemoryNotFound
+	}
+	return nil
+}
+
+func (m *sqliteMemoryStore) Update(id, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	res, err := m.db.Exec(`UPDATE memory_store SET text=?, norm_text=?, updated_at=? WHERE id=?`,
+		text, normalizeMemoryText(text), time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", errMemoryNotFound
+	}
+	return id, nil
+}
+
+// ─── Mem0 ───────────────────────────────────────────────────────────────────
+
+type mem0MemoryStore struct {
+	baseURL   string
+	namespace string
+}
+
+func (m *mem0MemoryStore) Name() string { return "mem0" }
+
+func (m *mem0MemoryStore) post(path string, body interface{}) ([]byte, int, error) {
+	payload, _ := json.Marshal(body)
+	resp, err := mem0Client.Post(m.baseURL+path, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 && resp.StatusCode != 404 {
+		return respBody, resp.StatusCode, fmt.Errorf("mem0 %s returned %d: %s", path, resp.StatusCode, string(respBody[:min(200, len(respBody))]))
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+func (m *mem0MemoryStore) Put(text, category string) ([]string, error) {
+	body, _, err := m.post("/v1/store", map[string]interface{}{"namespace": m.namespace, "category": category, "text": text})
+	if err != nil {
+		return nil, err
+	}
+	return mem0ResultIDs(body), nil
+}
+
+// PutMessages stores a conversation turn; Mem0's LLM extracts the facts.
+func (m *mem0MemoryStore) PutMessages(messages []map[string]string, category string) ([]string, error) {
+	body, _, err := m.post("/v1/store", map[string]interface{}{"namespace": m.namespace, "category": category, "messages": messages})
+	if err != nil {
+		return nil, err
+	}
+	return mem0ResultIDs(body), nil
+}
+
+type mem0Result struct {
+	ID        string  `json:"id"`
+	Memory    string  `json:"memory"`
+	Score     float64 `json:"score"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+	Metadata  struct {
+		Category string `json:"category"`
+	} `json:"metadata"`
+}
+
+func mem0Records(body []byte) ([]MemoryRecord, error) {
+	var resp struct {
+		Results []mem0Result `json:"results"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("mem0: %w", err)
+	}
+	out := make([]MemoryRecord, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		out = append(out, MemoryRecord{ID: r.ID, Text: r.Memory, Category: r.Metadata.Category,
+			Score: r.Score, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt})
+	}
+	return out, nil
+}
+
+func (m *mem0MemoryStore) List() ([]MemoryRecord, error) {
+	body, _, err := m.post("/v1/list", map[string]string{"namespace": m.namespace})
+	if err != nil {
+		return nil, err
+	}
+	return mem0Records(body)
+}
+
+func (m *mem0MemoryStore) Search(query string, limit int) ([]MemoryRecord, error) {
+	body, _, err := m.post("/v1/search", map[string]interface{}{"query": query, "namespace": m.namespace, "limit": limit})
+	if err != nil {
+		return nil, err
+	}
+	return mem0Records(body)
+}
+
+func (m *mem0MemoryStore) Delete(id string) error {
+	_, status, err := m.post("/v1/delete", map[string]string{"id": id, "namespace": m.namespace})
+	if err == nil && status == 404 {
+		return errMemoryNotFound
+	}
+	return err
+}
+
+// Update re-stores the text and drops the old memory: the Mem0 service has no
+// edit endpoint.
+func (m *mem0MemoryStore) Update(id, text string) (string, error) {
+	ids, err := m.Put(text, "")
+	if err != nil {
+		return "", err
+	}
+	if err := m.Delete(id); err != nil && !errors.Is(err, errMemoryNotFound) {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+	return ids[0], nil
+}
+
+// ─── Letta archival memory ──────────────────────────────────────────────────
+
+type lettaMemoryStore struct {
+	baseURL string
+	agentID string
+}
+
+var lettaStoreClient = &http.Client{Timeout: 30 * time.Second}
+
+// Not "letta": that destination is the feeder's message to the agent's core
+// memory blocks (letta_feeder.go).
+func (m *lettaMemoryStore) Name() string { return "letta_archival" }
+
+func (m *lettaMemoryStore) do(method, path string, body interface{}) ([]byte, int, error) {
+	var rd io.Reader
+	if body != nil {
+		payload, _ := json.Marshal(body)
+		rd = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/agents/%s/archival-memory%s", m.baseURL, m.agentID, path), rd)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := lettaStoreClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 && resp.StatusCode != 404 {
+		return respBody, resp.StatusCode, fmt.Errorf("letta %s archival-memory%s returned %d: %s",
+			method, path, resp.StatusCode, string(respBody[:min(200, len(respBody))]))
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+type lettaPassage struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Content   string `json:"content"` // search results
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	Timestamp string `json:"timestamp"` // search results
+}
+
+// lettaRecords decodes a passage list, bare or wrapped in {"results": [...]}.
+func lettaRecords(body []byte) ([]MemoryRecord, error) {
+	var passages []lettaPassage
+	if err := json.Unmarshal(body, &passages); err != nil {
+		var wrapped struct {
+			Results []lettaPassage `json:"results"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, fmt.Errorf("letta: %w", err)
+		}
+		passages = wrapped.Results
+	}
+	out := make([]MemoryRecord, 0, len(passages))
+	for _, p := range passages {
+		r := MemoryRecord{ID: p.ID, Text: p.Text, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt}
+		if r.Text == "" {
+			r.Text = p.Content
+		}
+		if r.CreatedAt == "" {
+			r.CreatedAt = p.Timestamp
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (m *lettaMemoryStore) Put(text, category string) ([]string, error) {
+	body, _, err := m.do("POST", "", map[string]string{"text": text})
+	if err != nil {
+		return nil, err
+	}
+	recs, err := lettaRecords(body)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, r := range recs {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+func (m *lettaMemoryStore) List() ([]MemoryRecord, error) {
+	body, _, err := m.do("GET", "?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	return lettaRecords(body)
+}
+
+func (m *lettaMemoryStore) Search(query string, limit int) ([]MemoryRecord, error) {
+	body, _, err := m.do("GET", fmt.Sprintf("/search?query=%s&limit=%d", url.QueryEscape(query), limit), nil)
+	if err != nil {
+		return nil, err
+	}
+	return lettaRecords(body)
+}
+
+func (m *lettaMemoryStore) Delete(id string) error {
+	_, status, err := m.do("DELETE", "/"+url.PathEscape(id), nil)
+	if err == nil && status == 404 {
+		return errMemoryNotFound
+	}
+	return err
+}
+
+// Update inserts the new passage and deletes the old one; passages are
+// re-embedded on write anyway.
+func (m *lettaMemoryStore) Update(id, text string) (string, error) {
+	ids, err := m.Put(text, "")
+	if err != nil {
+		return "", err
+	}
+	if err := m.Delete(id); err != nil && !errors.Is(err, errMemoryNotFound) {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+	return ids[0], nil
+}
+
+// ─── API ────────────────────────────────────────────────────────────────────
+
+// GET/PUT /v1/memory/backend
+func (s *Server) handleMemoryBackend(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	switch r.Method {
+	case "GET":
+		store := s.memoryStore()
+		resp := map[string]interface{}{"config": s.memoryBackend(), "active": store.Name(), "ok": true}
+		if all, err := store.List(); err != nil {
+			resp["ok"], resp["error"] = false, err.Error()
+		} else {
+			resp["memories"] = len(all)
+		}
+		writeJSON(w, resp)
+
+	case "PUT", "POST":
+		operator := resolveAuth(r).TierLevel >= 99
+		if s.tenantID == "" && !operator {
+			http.Error(w, `{"error":"admin only"}`, 403)
+			return
+		}
+		var cfg memoryBackendConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, `{"error":"invalid json"}`, 400)
+			return
+		}
+		if cfg.Backend == "" {
+			cfg.Backend = "sqlite"
+		}
+		// A remote backend is a URL the server will call: tenants can't pick one
+		if s.tenantID != "" && cfg.Backend != "sqlite" && !operator {
+			http.Error(w, `{"error":"only the operator can give a tenant a remote memory backend"}`, 403)
+			return
+		}
+		if s.tenantID != "" && cfg.Backend == "mem0" && cfg.Mem0Namespace == defaultMem0Namespace {
+			http.Error(w, `{"error":"that mem0 namespace is the operator's"}`, 400)
+			return
+		}
+		if _, err := newMemoryStore(cfg, s.db); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), 400)
+			return
+		}
+		cfg.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		s.db.Exec(`INSERT OR REPLACE INTO memory_backend (id, backend, mem0_url, mem0_namespace, letta_url, letta_agent_id, updated_at)
+			VALUES (1,?,?,?,?,?,?)`, cfg.Backend, cfg.Mem0URL, cfg.Mem0Namespace, cfg.LettaURL, cfg.LettaAgentID, cfg.UpdatedAt)
+		s.loadMemoryStore()
+		log.Printf("[memory-store] tenant %q now on %s", s.tenantID, cfg.Backend)
+		writeJSON(w, map[string]interface{}{"ok": true, "config": cfg})
+
+	default:
+		http.Error(w, `{"error":"GET or PUT"}`, 405)
+	}
+}
+
+// /v1/memory/store and /v1/memory/store/{id}
+func (s *Server) handleMemoryStore(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	store := s.memoryStore()
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/memory/store"), "/")
+
+	switch {
+	case r.Method == "GET" && id == "":
+		q := r.URL.Query().Get("q")
+		limit := 20
+		fmt.Sscanf(r.URL.Query().Get("limit"), "%d", &limit)
+		var recs []MemoryRecord
+		var err error
+		if q != "" {
+			recs, err = store.Search(q, limit)
+		} else {
+			recs, err = store.List()
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q,"backend":%q}`, err.Error(), store.Name()), 502)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"backend": store.Name(), "memories": recs, "count": len(recs)})
+
+	case r.Method == "POST" && id == "":
+		var body struct {
+			Text     string `json:"text"`
+			Category string `json:"category"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+			http.Error(w, `{"error":"text required"}`, 400)
+			return
+		}
+		ids, err := store.Put(body.Text, body.Category)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q,"backend":%q}`, err.Error(), store.Name()), 502)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "backend": store.Name(), "ids": ids})
+
+	case r.Method == "PATCH" && id != "":
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+			http.Error(w, `{"error":"text required"}`, 400)
+			return
+		}
+		newID, err := store.Update(id, body.Text)
+		if errors.Is(err, errMemoryNotFound) {
+			http.Error(w, `{"error":"memory not found"}`, 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q,"backend":%q}`, err.Error(), store.Name()), 502)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "id": newID})
+
+	case r.Method == "DELETE" && id != "":
+		err := store.Delete(id)
+		if errors.Is(err, errMemoryNotFound) {
+			http.Error(w, `{"error":"memory not found"}`, 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q,"backend":%q}`, err.Error(), store.Name()), 502)
+			return
+		}
+		s.db.Exec(`DELETE FROM memory_facts WHERE memory_id=? AND source=?`, id, store.Name())
+		writeJSON(w, map[string]interface{}{"ok": true, "id": id})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, 405)
+	}
+}
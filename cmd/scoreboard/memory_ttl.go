@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// MEMORY SHELF LIFE — expiry per category and "still true?" re-confirmation
+//
+// "MRR is $2k" is stale in weeks, "based in Corona" in a year, a name never.
+// Every approved memory gets a category (from its parsed facts, see
+// memory_facts.go) and an expires_at = last confirmation + the category TTL.
+//
+// checkMemoryExpiry (every 6h) puts expired memories back in the review queue
+// as pending items with source_type 'reconfirm' and reconfirm_of pointing at
+// the original. Memory store entries that never went through the queue are covered
+// too, via their parsed facts. Answering one:
+//
+//   POST /v1/memory/queue/{id}/confirm   still true: original's clock restarts
+//   POST /v1/memory/queue/{id}/update    {correction}: the original is forgotten
+//                                        everywhere, the correction is approved
+//                                        and written out as a new memory
+//   POST /v1/memory/queue/{id}/retire    no longer true: the original is
+//                                        forgotten everywhere (memory store, MEMORY.md,
+//                                        git, Letta — memory_provenance.go)
+//
+// TTLs: memoryTTLDefaults, overridable per category with
+//   GET /v1/memory/ttl      PUT /v1/memory/ttl {category, ttl_days}
+// ttl_days 0 = never expires.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// memoryTTLDefaults is the shelf life in days per memory category.
+var memoryTTLDefaults = map[string]int{
+	"progress": 30,
+	"revenue":  45,
+	"goal":     90,
+	"stage":    120,
+	"team":     180,
+	"tool":     180,
+	"location": 365,
+	"timezone": 365,
+	"skill":    365,
+	"general":  365,
+	"name":     0,
+}
+
+// factCategories maps fact attributes onto TTL categories.
+var factCategories = map[string]string{
+	"revenue.mrr":    "revenue",
+	"revenue":        "revenue",
+	"business.stage": "stage",
+	"team.size":      "team",
+}
+
+// maxReconfirmPerRun keeps a backlog of expired memories from flooding the queue.
+const maxReconfirmPerRun = 20
+
+func (s *Server) initMemoryTTL() {
+	for _, stmt := range []string{
+		`ALTER TABLE memory_queue ADD COLUMN category TEXT DEFAULT ''`,
+		`ALTER TABLE memory_queue ADD COLUMN confirmed_at TEXT DEFAULT ''`,
+		`ALTER TABLE memory_queue ADD COLUMN expires_at TEXT DEFAULT ''`,
+		`ALTER TABLE memory_queue ADD COLUMN reconfirm_of TEXT DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS idx_memory_queue_expiry ON memory_queue(status, expires_at)`,
+		`CREATE TABLE IF NOT EXISTS memory_ttl (
+			category TEXT PRIMARY KEY,
+			ttl_days INTEGER NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+	} {
+		s.db.Exec(stmt)
+	}
+}
+
+func (s *Server) memoryTTL(category string) int {
+	var days int
+	if s.db.QueryRow(`SELECT ttl_days FROM memory_ttl WHERE category=?`, category).Scan(&days) == nil {
+		return days
+	}
+	if d, ok := memoryTTLDefaults[category]; ok {
+		return d
+	}
+	return memoryTTLDefaults["general"]
+}
+
+// memoryCategory picks the category with the shortest shelf life among the
+// facts in a memory; memories with no recognisable facts are "general".
+func (s *Server) memoryCategory(text string) string {
+	best, bestTTL := "general", -1
+	for _, f := range parseFacts(text, time.Now()) {
+		cat := f.Attribute
+		if c, ok := factCategories[cat]; ok {
+			cat = c
+		}
+		if _, known := memoryTTLDefaults[cat]; !known {
+			continue
+		}
+		ttl := s.memoryTTL(cat)
+		if ttl > 0 && (bestTTL < 0 || ttl < bestTTL) {
+			best, bestTTL = cat, ttl
+		} else if ttl == 0 && bestTTL < 0 {
+			best = cat
+		}
+	}
+	return best
+}
+
+// stampMemoryExpiry sets category, confirmed_at and expires_at on an approved
+// memory, counting its shelf life from confirmed.
+func (s *Server) stampMemoryExpiry(memID, text string, confirmed time.Time) {
+	cat := s.memoryCategory(text)
+	expires := ""
+	if ttl := s.memoryTTL(cat); ttl > 0 {
+		expires = confirmed.UTC().AddDate(0, 0, ttl).Format(time.RFC3339)
+	}
+	s.db.Exec(`UPDATE memory_queue SET category=?, confirmed_at=?, expires_at=? WHERE id=?`,
+		cat, confirmed.UTC().Format(time.RFC3339), expires, memID)
+}
+
+func newMemoryID() string {
+	randBytes := make([]byte, 4)
+	rand.Read(randBytes)
+	return fmt.Sprintf("mem-%d-%x", time.Now().UnixNano(), randBytes)
+}
+
+// checkMemoryExpiry stamps approved memories that predate shelf lives and
+// re-surfaces expired ones as "still true?" items.
+func (s *Server) checkMemoryExpiry() {
+	now := time.Now().UTC()
+
+	// Backfill: approved before TTLs existed — the clock starts at review time
+	type stamp struct {
+		id, text, reviewed string
+	}
+	var backfill []stamp
+	rows, err := s.db.Query(`SELECT id, CASE WHEN correction != '' THEN correction ELSE memory_text END,
+		COALESCE(reviewed_at, created_at) FROM memory_queue WHERE status='approved' AND COALESCE(category,'')=''`)
+	if err != nil {
+		log.Printf("[memory-ttl] query error: %v", err)
+		return
+	}
+	for rows.Next() {
+		var st stamp
+		rows.Scan(&st.id, &st.text, &st.reviewed)
+		backfill = append(backfill, st)
+	}
+	rows.Close()
+	for _, st := range backfill {
+		confirmed := parseMemoryTime(st.reviewed)
+		if confirmed.IsZero() {
+			confirmed = now
+		}
+		s.stampMemoryExpiry(st.id, st.text, confirmed)
+	}
+
+	type expired struct {
+		id, text, category, sourceFile, confirmed string
+		confidence                                float64
+	}
+	var due []expired
+	rows, err = s.db.Query(`SELECT q.id, CASE WHEN q.correction != '' THEN q.correction ELSE q.memory_text END,
+		q.category, COALESCE(q.source_file,''), q.confirmed_at, q.confidence
+		FROM memory_queue q
+		WHERE q.status='approved' AND q.expires_at != '' AND q.expires_at <= ?
+		  AND NOT EXISTS (SELECT 1 FROM memory_queue r WHERE r.reconfirm_of=q.id AND r.status='pending')
+		ORDER BY q.expires_at LIMIT ?`, now.Format(time.RFC3339), maxReconfirmPerRun)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var e expired
+		rows.Scan(&e.id, &e.text, &e.category, &e.sourceFile, &e.confirmed, &e.confidence)
+		due = append(due, e)
+	}
+	rows.Close()
+
+	created := 0
+	for _, e := range due {
+		s.queueReconfirm(e.id, e.text, e.category, e.sourceFile, e.confirmed, e.confidence)
+		created++
+	}
+	if created < maxReconfirmPerRun {
+		created += s.queueExpiredStored(now, maxReconfirmPerRun-created)
+	}
+	if created > 0 {
+		log.Printf("[memory-ttl] %d memories re-surfaced for confirmation", created)
+	}
+}
+
+func (s *Server) queueReconfirm(origID, text, category, sourceFile, confirmed string, confidence float64) {
+	since := confirmed
+	if t := parseMemoryTime(confirmed); !t.IsZero() {
+		since = t.Format("2006-01-02")
+	}
+	ctx := fmt.Sprintf("Still true? Last confirmed %s; %s memories are re-checked every %d days.",
+		since, category, s.memoryTTL(category))
+	s.db.Exec(`INSERT INTO memory_queue (id, memory_text, source_type, source_file, source_context, confidence,
+		category, reconfirm_of) VALUES (?, ?, 'reconfirm', ?, ?, ?, ?, ?)`,
+		newMemoryID(), text, sourceFile, ctx, confidence, category, origID)
+}
+
+// queueExpiredStored re-surfaces memory store entries that never went through
+// the queue (conversation extraction, memory-syncd), judged by their parsed
+// facts. reconfirm_of is "<backend>:<id>".
+func (s *Server) queueExpiredStored(now time.Time, limit int) int {
+	queued := map[string]bool{}
+	rows, err := s.db.Query(`SELECT memory_text, correction, reconfirm_of FROM memory_queue
+		WHERE status IN ('approved','pending')`)
+	if err != nil {
+		return 0
+	}
+	for rows.Next() {
+		var text, ref string
+		var correction sql.NullString
+		rows.Scan(&text, &correction, &ref)
+		queued[normalizeMemoryText(text)] = true
+		if correction.String != "" {
+			queued[normalizeMemoryText(correction.String)] = true
+		}
+		if ref != "" {
+			queued[ref] = true
+		}
+	}
+	rows.Close()
+
+	type storedMemory struct {
+		id, text, validFrom string
+		attrs               []string
+	}
+	store := s.memoryStore()
+	byID := map[string]*storedMemory{}
+	var order []string
+	for _, f := range s.loadFacts(`WHERE source=? AND status='active' ORDER BY valid_from`, store.Name()) {
+		m := byID[f.MemoryID]
+		if m == nil {
+			m = &storedMemory{id: f.MemoryID, validFrom: f.ValidFrom}
+			byID[f.MemoryID] = m
+			order = append(order, f.MemoryID)
+		}
+		// facts keep the sentence, not the whole memory; join them back up
+		if m.text == "" {
+			m.text = f.Text
+		} else if m.text != f.Text {
+			m.text += ". " + f.Text
+		}
+		m.attrs = append(m.attrs, f.Attribute)
+	}
+
+	created := 0
+	for _, id := range order {
+		if created >= limit {
+			break
+		}
+		m := byID[id]
+		ref := store.Name() + ":" + m.id
+		if queued[ref] || queued[normalizeMemoryText(m.text)] {
+			continue
+		}
+		cat := s.memoryCategory(m.text)
+		ttl := s.memoryTTL(cat)
+		start, err := time.Parse("2006-01-02", m.validFrom)
+		if ttl == 0 || err != nil || start.AddDate(0, 0, ttl).After(now) {
+			continue
+		}
+		s.queueReconfirm(ref, m.text, cat, "", m.validFrom, 0.5)
+		created++
+	}
+	return created
+}
+
+// handleMemoryReconfirm answers a "still true?" item.
+func (s *Server) handleMemoryReconfirm(w http.ResponseWriter, r *http.Request, id, action string) {
+	var origID, text, status string
+	s.db.QueryRow(`SELECT COALESCE(reconfirm_of,''), memory_text, status FROM memory_queue WHERE id=?`, id).
+		Scan(&origID, &text, &status)
+	if origID == "" {
+		http.Error(w, `{"error":"not a re-confirmation item"}`, 400)
+		return
+	}
+	if status != "pending" {
+		http.Error(w, `{"error":"already answered"}`, 409)
+		return
+	}
+	now := time.Now()
+	// "<backend>:<id>" for memory store entries, else a queue item
+	backend, storeID, _ := strings.Cut(origID, ":")
+	if !isMemoryBackend(backend) {
+		backend, storeID = "", ""
+	}
+
+	switch action {
+	case "confirm":
+		if storeID != "" {
+			// Adopt the stored memory into the queue so its shelf life is
+			// tracked from here on; it is already stored, so no writeback.
+			// Letta should have it from when it was first approved, but
+			// nothing sent it under this id: it is recorded as assumed, which
+			// keeps the feeder from resending and a forget still reaching Letta.
+			s.db.Exec(`UPDATE memory_queue SET status='approved', reviewed_at=CURRENT_TIMESTAMP WHERE id=?`, id)
+			s.recordProvenance(id, backend, storeID, text)
+			if s.lettaAgentID != "" {
+				s.recordAssumedProvenance(id, "letta", s.lettaAgentID, text)
+			}
+			s.stampMemoryExpiry(id, text, now)
+		} else {
+			var origText string
+			s.db.QueryRow(`SELECT CASE WHEN correction != '' THEN correction ELSE memory_text END FROM memory_queue WHERE id=?`,
+				origID).Scan(&origText)
+			s.stampMemoryExpiry(origID, origText, now)
+			s.db.Exec(`UPDATE memory_queue SET status='confirmed', reviewed_at=CURRENT_TIMESTAMP WHERE id=?`, id)
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "action": "confirmed", "memory_id": origID})
+
+	case "update":
+		var body struct {
+			Correction string `json:"correction"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Correction == "" {
+			http.Error(w, `{"error":"correction required"}`, 400)
+			return
+		}
+		results := s.retireMemory(origID, backend, storeID, text, "updated on re-confirmation")
+		s.db.Exec(`UPDATE memory_queue SET status='approved', correction=?, reviewed_at=CURRENT_TIMESTAMP WHERE id=?`,
+			body.Correction, id)
+		go s.writebackApprovedMemory(id, body.Correction)
+		writeJSON(w, map[string]interface{}{"ok": true, "action": "updated", "memory": body.Correction, "retired": results})
+
+	case "retire":
+		results := s.retireMemory(origID, backend, storeID, text, "retired: no longer true")
+		s.db.Exec(`UPDATE memory_queue SET status='retired', reviewed_at=CURRENT_TIMESTAMP WHERE id=?`, id)
+		writeJSON(w, map[string]interface{}{"ok": true, "action": "retired", "results": results})
+	}
+}
+
+// retireMemory forgets the original everywhere. Letta gets a tombstone even
+// when the feeder never recorded sending it — Letta blocks are also fed from
+// MEMORY.md by memory-syncd.
+func (s *Server) retireMemory(origID, backend, storeID, text, reason string) []forgetResult {
+	if storeID != "" {
+		s.recordProvenance(origID, backend, storeID, text)
+	}
+	if s.lettaAgentID != "" {
+		s.recordProvenance(origID, "letta", s.lettaAgentID, text)
+	}
+	return s.forgetMemory(origID, reason)
+}
+
+// GET /v1/memory/ttl — shelf life per category
+// PUT /v1/memory/ttl — {category, ttl_days}; 0 = never expires
+func (s *Server) handleMemoryTTL(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	switch r.Method {
+	case "GET":
+		ttls := map[string]int{}
+		for cat := range memoryTTLDefaults {
+			ttls[cat] = s.memoryTTL(cat)
+		}
+		rows, err := s.db.Query(`SELECT category, ttl_days FROM memory_ttl`)
+		if err == nil {
+			for rows.Next() {
+				var cat string
+				var d int
+				rows.Scan(&cat, &d)
+				ttls[cat] = d
+			}
+			rows.Close()
+		}
+		var stale int
+		s.db.QueryRow(`SELECT COUNT(*) FROM memory_queue WHERE status='pending' AND reconfirm_of != ''`).Scan(&stale)
+		writeJSON(w, map[string]interface{}{"ttl_days": ttls, "pending_reconfirm": stale})
+
+	case "PUT", "POST":
+		var body struct {
+			Category string `json:"category"`
+			TTLDays  *int   `json:"ttl_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Category == "" || body.TTLDays == nil || *body.TTLDays < 0 {
+			http.Error(w, `{"error":"category and ttl_days (>= 0) required"}`, 400)
+			return
+		}
+		s.db.Exec(`INSERT OR REPLACE INTO memory_ttl (category, ttl_days, updated_at) VALUES (?,?,?)`,
+			body.Category, *body.TTLDays, time.Now().UTC().Format(time.RFC3339))
+		// Re-stamp so the new shelf life applies to what's already approved
+		s.db.Exec(`UPDATE memory_queue SET category='' WHERE status='approved' AND category=?`, body.Category)
+		go s.checkMemoryExpiry()
+		writeJSON(w, map[string]interface{}{"ok": true, "category": body.Category, "ttl_days": *body.TTLDays})
+
+	default:
+		http.Error(w, `{"error":"GET or PUT"}`, 405)
+	}
+}
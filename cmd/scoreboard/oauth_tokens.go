@@ -0,0 +1,452 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// OAUTH TOKEN LIFECYCLE — one refresh path for every OAuth2 integration
+//
+// OAuth integrations store the provider's token response as their credential.
+// The callback stamps it with obtained_at / expires_at (from expires_in) so
+// expiry is known without asking the provider. From there:
+//
+//   ensureFreshToken   before each poll: refresh when the access token expires
+//                      within oauthRefreshSkew (or already has)
+//   refreshExpiringTokens  sweep every few minutes, so tokens used outside the
+//                      poller (webhook setup, metric collectors) stay fresh too
+//   handleTokenRejected  a poller got 401: refresh once, then give up
+//
+// Refresh is a standard refresh_token grant against oauthProviderConfig's
+// TokenURL, so Stripe, GitHub, Google, FreshBooks, HubSpot and Dropbox share it.
+// A refresh the provider refuses (invalid_grant and friends), or an expired
+// token with no refresh_token, means the grant is gone: the integration goes to
+// status 'expired' with a re-auth link in last_error, and the poller skips it
+// until the operator reconnects (the link updates the same integration).
+//
+// Refreshes are serialised per integration: the poller, the sweep and a 401
+// can race, and providers that rotate refresh tokens (FreshBooks) refuse the
+// loser's, which would park a healthy integration. Whoever waits re-reads the
+// stored token and uses it if someone else already refreshed.
+//
+// Every refresh, failure and expiry lands in oauth_token_events — never the
+// tokens themselves. GET /v1/oauth/tokens shows it per integration.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+const (
+	oauthRefreshSkew     = 10 * time.Minute // refresh this long before expires_at
+	oauthSweepInterval   = 5 * time.Minute
+	oauthSweepHorizon    = 15 * time.Minute
+	oauthAuditRetainDays = 180
+)
+
+// oauthRevokedErrors are token-endpoint error codes meaning the refresh token
+// itself is no longer valid — retrying won't help.
+var oauthRevokedErrors = map[string]bool{
+	"invalid_grant": true, "invalid_token": true, "unauthorized_client": true,
+	"bad_refresh_token": true, "invalid_refresh_token": true, "access_denied": true,
+}
+
+// oauthRefreshLocks holds a *sync.Mutex per integration ID.
+var oauthRefreshLocks sync.Map
+
+func (s *Server) initOAuthTokens() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS oauth_token_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		integration_id TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		action TEXT NOT NULL,
+		detail TEXT DEFAULT '',
+		expires_at TEXT DEFAULT '',
+		created_at TEXT NOT NULL
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_oauth_token_events ON oauth_token_events(integration_id, created_at)`)
+}
+
+// oauthConfigForIntegration maps a scoreboard provider to the OAuth app that
+// issued its token, plus the URL that starts re-authorisation.
+func oauthConfigForIntegration(provider, integrationID string) (*oauthProviderConfig, string) {
+	oauthProvider, extra := provider, ""
+	switch provider {
+	case "youtube":
+		oauthProvider, extra = "google", "&scope=youtube"
+	case "gdrive":
+		oauthProvider, extra = "google", "&scope=drive"
+	}
+	reauth := fmt.Sprintf("/v1/oauth/%s/authorize?reauth=%s%s", oauthProvider, url.QueryEscape(integrationID), extra)
+	return getOAuthConfig(oauthProvider), reauth
+}
+
+// stampTokenExpiry records when a token response was obtained and, when the
+// provider said, when it expires.
+func stampTokenExpiry(token map[string]interface{}) {
+	now := time.Now().UTC()
+	token["obtained_at"] = now.Format(time.RFC3339)
+	var secs float64
+	switch v := token["expires_in"].(type) {
+	case float64:
+		secs = v
+	case string:
+		fmt.Sscanf(v, "%f", &secs)
+	}
+	if secs > 0 {
+		token["expires_at"] = now.Add(time.Duration(secs) * time.Second).Format(time.RFC3339)
+	} else {
+		delete(token, "expires_at")
+	}
+}
+
+// oauthToken is the part of a stored token response the manager reads.
+type oauthToken struct {
+	raw          map[string]interface{}
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time // zero = provider didn't say
+}
+
+func parseOAuthToken(credential string) (*oauthToken, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(credential), "{") {
+		return nil, false
+	}
+	t := &oauthToken{raw: map[string]interface{}{}}
+	if json.Unmarshal([]byte(credential), &t.raw) != nil {
+		return nil, false
+	}
+	t.AccessToken, _ = t.raw["access_token"].(string)
+	t.RefreshToken, _ = t.raw["refresh_token"].(string)
+	if v, _ := t.raw["expires_at"].(string); v != "" {
+		t.ExpiresAt, _ = time.Parse(time.RFC3339, v)
+	}
+	return t, t.AccessToken != ""
+}
+
+// oauthAccessToken returns the bearer token inside an OAuth credential, or the
+// credential itself when it is already a bare token.
+func oauthAccessToken(credential string) string {
+	if t, ok := parseOAuthToken(credential); ok {
+		return t.AccessToken
+	}
+	return credential
+}
+
+// pollerCredential is what a provider's poller expects from an OAuth
+// credential: a few take the bare bearer token, the rest parse the JSON.
+func pollerCredential(provider, credential string) string {
+	switch provider {
+	case "hubspot", "freshbooks", "github":
+		return oauthAccessToken(credential)
+	}
+	return credential
+}
+
+func (s *Server) auditToken(integrationID, provider, action, detail, expiresAt string) {
+	s.db.Exec(`INSERT INTO oauth_token_events (integration_id, provider, action, detail, expires_at, created_at)
+		VALUES (?,?,?,?,?,?)`, integrationID, provider, action, detail, expiresAt, time.Now().UTC().Format(time.RFC3339))
+}
+
+// errTokenRevoked marks refresh failures that need the operator to reconnect.
+type errTokenRevoked struct{ reason string }
+
+func (e errTokenRevoked) Error() string { return "oauth grant revoked: " + e.reason }
+
+func isTokenRevoked(err error) bool {
+	var revoked errTokenRevoked
+	return errors.As(err, &revoked)
+}
+
+// ensureFreshToken returns the credential to poll with, refreshing it first
+// when it is about to expire. Non-OAuth credentials pass through untouched.
+func (s *Server) ensureFreshToken(integrationID, provider, credential string) (string, error) {
+	t, ok := parseOAuthToken(credential)
+	if !ok || t.ExpiresAt.IsZero() || time.Until(t.ExpiresAt) > oauthRefreshSkew {
+		return credential, nil
+	}
+	if t.RefreshToken == "" {
+		if time.Now().Before(t.ExpiresAt) {
+			return credential, nil // still valid for a few minutes; nothing we can do early
+		}
+		s.markTokenExpired(integrationID, provider, "access token expired and the provider issued no refresh token")
+		return "", errTokenRevoked{"access token expired, no refresh token"}
+	}
+	fresh, err := s.refreshOAuthToken(integrationID, provider, credential)
+	if err != nil && !isTokenRevoked(err) && time.Now().Before(t.ExpiresAt) {
+		// Refreshing early failed for a transient reason; the old token still works
+		log.Printf("OAuth: early refresh of %s failed, using current token: %v", integrationID, err)
+		return credential, nil
+	}
+	return fresh, err
+}
+
+// handleTokenRejected runs after a provider answered 401 with this credential.
+func (s *Server) handleTokenRejected(integrationID, provider, credential string) (string, error) {
+	if s.dryRun {
+		// A test poll must not rotate the real integration's tokens
+		return "", fmt.Errorf("access token rejected (test polls don't refresh)")
+	}
+	t, ok := parseOAuthToken(credential)
+	if !ok {
+		return "", fmt.Errorf("credential rejected")
+	}
+	if t.RefreshToken == "" {
+		s.markTokenExpired(integrationID, provider, "provider rejected the access token and there is no refresh token")
+		return "", errTokenRevoked{"access token rejected, no refresh token"}
+	}
+	return s.refreshOAuthToken(integrationID, provider, credential)
+}
+
+// storedCredential reads and decrypts an integration's current credential.
+func (s *Server) storedCredential(integrationID string) (string, error) {
+	var enc, nonce []byte
+	var keyID string
+	if err := s.db.QueryRow(`SELECT encrypted_data, nonce, COALESCE(key_id,'') FROM integrations WHERE id=?`,
+		integrationID).Scan(&enc, &nonce, &keyID); err != nil {
+		return "", err
+	}
+	plain, err := s.decryptCredentialKey(enc, nonce, keyID)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// refreshOAuthToken runs the refresh_token grant and stores the new token.
+// Returns the new credential JSON. credential is what the caller polled with;
+// if the stored token has moved on since, it was already refreshed and is
+// returned as is.
+func (s *Server) refreshOAuthToken(integrationID, provider, credential string) (string, error) {
+	lock, _ := oauthRefreshLocks.LoadOrStore(integrationID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	t, ok := parseOAuthToken(credential)
+	if !ok || t.RefreshToken == "" {
+		return "", fmt.Errorf("no refresh token")
+	}
+	if stored, err := s.storedCredential(integrationID); err == nil {
+		if st, ok := parseOAuthToken(stored); ok {
+			if st.AccessToken != t.AccessToken {
+				return stored, nil
+			}
+			t = st
+		}
+	}
+	cfg, _ := oauthConfigForIntegration(provider, integrationID)
+	if cfg == nil {
+		err := fmt.Errorf("%s OAuth app not configured — can't refresh", provider)
+		s.auditToken(integrationID, provider, "refresh_failed", err.Error(), "")
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", t.RefreshToken)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("client_secret", cfg.ClientSecret)
+	req, _ := http.NewRequest("POST", cfg.TokenURL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json") // GitHub answers form-encoded otherwise
+	client := providerClient(15 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		s.auditToken(integrationID, provider, "refresh_failed", err.Error(), "")
+		return "", fmt.Errorf("token refresh: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	errCode, _ := result["error"].(string)
+	errDesc, _ := result["error_description"].(string)
+	newAccess, _ := result["access_token"].(string)
+	if errCode != "" || newAccess == "" {
+		reason := strings.TrimSpace(errCode + " " + errDesc)
+		if reason == "" {
+			reason = fmt.Sprintf("HTTP %d, no access_token", resp.StatusCode)
+		}
+		if oauthRevokedErrors[errCode] || resp.StatusCode == 401 {
+			s.auditToken(integrationID, provider, "refresh_failed", reason, "")
+			s.markTokenExpired(integrationID, provider, "refresh refused: "+reason)
+			return "", errTokenRevoked{reason}
+		}
+		s.auditToken(integrationID, provider, "refresh_failed", reason, "")
+		return "", fmt.Errorf("token refresh: %s", reason)
+	}
+
+	// Merge: providers that don't rotate refresh tokens omit them
+	for k, v := range result {
+		t.raw[k] = v
+	}
+	if _, rotated := result["expires_in"]; !rotated {
+		delete(t.raw, "expires_in")
+	}
+	stampTokenExpiry(t.raw)
+	newCred, _ := json.Marshal(t.raw)
+	encrypted, nonce, keyID, err := s.encryptCredential(newCred)
+	if err != nil {
+		s.auditToken(integrationID, provider, "refresh_failed", "store: "+err.Error(), "")
+		return "", fmt.Errorf("store refreshed token: %w", err)
+	}
+	expiresAt, _ := t.raw["expires_at"].(string)
+	s.mu.Lock()
+	s.db.Exec(`UPDATE integrations SET encrypted_data=?, nonce=?, key_id=?, updated_at=? WHERE id=?`,
+		encrypted, nonce, keyID, time.Now().UTC().Format(time.RFC3339), integrationID)
+	s.mu.Unlock()
+	detail := "access token refreshed"
+	if _, rotated := result["refresh_token"]; rotated {
+		detail += ", refresh token rotated"
+	}
+	s.auditToken(integrationID, provider, "refreshed", detail, expiresAt)
+	log.Printf("OAuth: refreshed %s token for %s (expires %s)", provider, integrationID, expiresAt)
+	return string(newCred), nil
+}
+
+// markTokenExpired parks the integration until the operator reconnects it.
+func (s *Server) markTokenExpired(integrationID, provider, reason string) {
+	_, reauth := oauthConfigForIntegration(provider, integrationID)
+	msg := fmt.Sprintf("OAuth token expired or revoked (%s) — reconnect: %s", reason, reauth)
+	s.db.Exec(`UPDATE integrations SET status='expired', last_error=?, updated_at=? WHERE id=?`,
+		msg, time.Now().UTC().Format(time.RFC3339), integrationID)
+	s.auditToken(integrationID, provider, "expired", reason, "")
+	s.insertAlert("oauth_expired_"+sanitizeID(integrationID), "integration",
+		fmt.Sprintf("%s connection needs to be re-authorised", provider), msg, "warning")
+	log.Printf("OAuth: %s integration %s expired: %s", provider, integrationID, reason)
+}
+
+// refreshExpiringTokens proactively refreshes active OAuth tokens close to expiry.
+func (s *Server) refreshExpiringTokens() {
+	rows, err := s.db.Query(`SELECT id, provider, encrypted_data, nonce, COALESCE(key_id,'') FROM integrations
+		WHERE status='active' AND auth_type='oauth2' AND length(encrypted_data) > 0`)
+	if err != nil {
+		return
+	}
+	type candidate struct {
+		id, provider, credential string
+	}
+	var due []candidate
+	for rows.Next() {
+		var id, provider, keyID string
+		var enc, nonce []byte
+		rows.Scan(&id, &provider, &enc, &nonce, &keyID)
+		plain, err := s.decryptCredentialKey(enc, nonce, keyID)
+		if err != nil {
+			continue
+		}
+		if t, ok := parseOAuthToken(string(plain)); ok && !t.ExpiresAt.IsZero() &&
+			time.Until(t.ExpiresAt) < oauthSweepHorizon {
+			due = append(due, candidate{id, provider, string(plain)})
+		}
+	}
+	rows.Close()
+	for _, c := range due {
+		if _, err := s.ensureFreshToken(c.id, c.provider, c.credential); err != nil {
+			log.Printf("OAuth: proactive refresh of %s (%s): %v", c.id, c.provider, err)
+		}
+	}
+	s.db.Exec(`DELETE FROM oauth_token_events WHERE created_at < ?`,
+		time.Now().UTC().AddDate(0, 0, -oauthAuditRetainDays).Format(time.RFC3339))
+}
+
+// looksUnauthorized recognises poller errors caused by a rejected token.
+func looksUnauthorized(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{" 401", "unauthorized", "invalid_token", "expired_access_token", "token expired"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// ─── API ────────────────────────────────────────────────────────────────────
+
+// handleOAuthTokens: GET /v1/oauth/tokens[?integration_id=] — token state and
+// refresh audit trail for the caller's OAuth integrations.
+func (s *Server) handleOAuthTokens(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+	auth := resolveAuth(r)
+	userID := "default"
+	if auth.Authenticated && auth.Username != "" {
+		userID = auth.Username
+	} else if auth.Authenticated && auth.UserID != 0 {
+		userID = fmt.Sprintf("user-%d", auth.UserID)
+	}
+
+	q := `SELECT id, provider, status, COALESCE(last_error,''), encrypted_data, nonce, COALESCE(key_id,'')
+		FROM integrations WHERE auth_type='oauth2'`
+	var args []interface{}
+	if auth.TierLevel < 99 {
+		q += ` AND user_id=?`
+		args = append(args, userID)
+	}
+	if id := r.URL.Query().Get("integration_id"); id != "" {
+		q += ` AND id=?`
+		args = append(args, id)
+	}
+	rows, err := s.db.Query(q+` ORDER BY created_at`, args...)
+	if err != nil {
+		http.Error(w, `{"error":"query failed"}`, 500)
+		return
+	}
+	var list []map[string]interface{}
+	for rows.Next() {
+		var id, provider, status, lastError, keyID string
+		var enc, nonce []byte
+		rows.Scan(&id, &provider, &status, &lastError, &enc, &nonce, &keyID)
+		entry := map[string]interface{}{"integration_id": id, "provider": provider, "status": status}
+		if plain, err := s.decryptCredentialKey(enc, nonce, keyID); err == nil {
+			if t, ok := parseOAuthToken(string(plain)); ok {
+				entry["has_refresh_token"] = t.RefreshToken != ""
+				if !t.ExpiresAt.IsZero() {
+					entry["expires_at"] = t.ExpiresAt.Format(time.RFC3339)
+					entry["expires_in_seconds"] = int(time.Until(t.ExpiresAt).Seconds())
+				}
+			}
+		}
+		if status == "expired" {
+			_, entry["reauth_url"] = oauthConfigForIntegration(provider, id)
+			entry["last_error"] = lastError
+		}
+		list = append(list, entry)
+	}
+	rows.Close()
+
+	for _, entry := range list {
+		var events []map[string]interface{}
+		ev, err := s.db.Query(`SELECT action, detail, expires_at, created_at FROM oauth_token_events
+			WHERE integration_id=? ORDER BY id DESC LIMIT 20`, entry["integration_id"])
+		if err == nil {
+			for ev.Next() {
+				var action, detail, expiresAt, createdAt string
+				ev.Scan(&action, &detail, &expiresAt, &createdAt)
+				events = append(events, map[string]interface{}{
+					"action": action, "detail": detail, "expires_at": expiresAt, "at": createdAt,
+				})
+			}
+			ev.Close()
+		}
+		entry["events"] = events
+	}
+	if list == nil {
+		list = []map[string]interface{}{}
+	}
+	writeJSON(w, map[string]interface{}{"integrations": list})
+}
@@ -341,7 +341,7 @@ func (dt *DualTrackDimension) UpdateEMA(dim string, value float64) {
 type ContextWindowType string
 
 const (
-	CtxFinancialPressure ContextWindowType = "FINANCIAL_PRESSURE"  // Revenue drop, debt keywords, Stripe failures. τ=72h.
+	CtxFinancialPressure ContextWindowType = "FINANCIAL_PRESSURE"  // Revenue drop, debt keywords, Stripe failures, short runway. τ=72h.
 	CtxShippingSprint    ContextWindowType = "SHIPPING_SPRINT"    // 5+ ships in 72h. τ=48h. Calibration: reduce nudges.
 	CtxRecoveryPeriod    ContextWindowType = "RECOVERY_PERIOD"    // Ship after stall. τ=72h. Calibration: protect, don't push.
 	CtxContextExplosion  ContextWindowType = "CONTEXT_EXPLOSION"  // 5+ unique projects/day. τ=48h. Calibration: focus prompts.
@@ -943,6 +943,7 @@ type PairingConfig struct {
 	Mem0URL        string // Mem0 server URL (default: http://localhost:8200)
 	GatewayToken   string // OpenClaw gateway token for wirebot_remember
 	GatewayURL     string // OpenClaw gateway URL (default: http://127.0.0.1:18789)
+	Memory         func() MemoryStore // tenant memory store, used when Mem0Namespace is empty (nil = skip)
 }
 
 type PairingEngine struct {
@@ -965,6 +966,7 @@ type PairingEngine struct {
 	recentProjects  map[string]time.Time // project → last seen
 	approvalLatencies []float64
 	dailyEventCounts map[string]int // date string → count
+	restDays        map[string]bool // date string → calendar day off (exempt from stall)
 }
 
 func NewPairingEngine(profilePath string, db *sql.DB, cfg PairingConfig) *PairingEngine {
@@ -995,6 +997,7 @@ func NewPairingEngine(profilePath string, db *sql.DB, cfg PairingConfig) *Pairin
 		recentProjects:  make(map[string]time.Time),
 		approvalLatencies: make([]float64, 0, 100),
 		dailyEventCounts: make(map[string]int),
+		restDays:        make(map[string]bool),
 	}
 
 	// Load or create profile
@@ -1052,7 +1055,7 @@ func (pe *PairingEngine) syncToMemory() {
 	summary := pe.GetChatContextSummary()
 	client := &http.Client{Timeout: 30 * time.Second}
 
-	// ── 1. Mem0 (if namespace configured) ───────────────────────────────────
+	// ── 1. Mem0 (if namespace configured), else the tenant's memory store ───
 	if pe.config.Mem0Namespace != "" {
 		payload, _ := json.Marshal(map[string]interface{}{
 			"messages":  []map[string]string{{"role": "user", "content": "Founder profile update: " + summary}},
@@ -1073,6 +1076,30 @@ func (pe *PairingEngine) syncToMemory() {
 				log.Printf("[pairing] Mem0 sync: status=%d body=%s", resp.StatusCode, string(respBody[:min(200, len(respBody))]))
 			}
 		}
+	} else if pe.config.Memory != nil {
+		// One profile memory, kept current rather than appended every sync
+		store := pe.config.Memory()
+		text := "Founder profile update: " + summary
+		existing := ""
+		if all, err := store.List(); err == nil {
+			for _, m := range all {
+				if m.Category == "founder_profile" || strings.HasPrefix(m.Text, "Founder profile update: ") {
+					existing = m.ID
+					break
+				}
+			}
+		}
+		var err error
+		if existing != "" {
+			_, err = store.Update(existing, text)
+		} else {
+			_, err = store.Put(text, "founder_profile")
+		}
+		if err != nil {
+			log.Printf("[pairing] %s sync error: %v", store.Name(), err)
+		} else {
+			log.Printf("[pairing] %s sync: profile stored", store.Name())
+		}
 	}
 
 	// ── 2. Letta (if agent ID configured) — route through agent message
@@ -1418,7 +1445,7 @@ func (pe *PairingEngine) processEvent(sig Signal, ev *EvidenceEntry) {
 	// Stall detection
 	if len(pe.recentShips) > 0 {
 		lastShip := pe.recentShips[len(pe.recentShips)-1]
-		hoursSince := time.Since(lastShip).Hours()
+		hoursSince := time.Since(lastShip).Hours() - pe.restHoursSince(lastShip)
 		if hoursSince > 24 {
 			pe.profile.ContextWindows[CtxStall].AddSignal(math.Min(1.0, hoursSince/48))
 		} else {
@@ -1559,11 +1586,99 @@ func (pe *PairingEngine) processAccountData(sig Signal, ev *EvidenceEntry) {
 		if commits, ok := sig.Metadata["weekly_commits"].(float64); ok {
 			pe.profile.ActionStyle.UpdateEMA("IM", math.Min(10, commits/5))
 		}
+	case "calendar":
+		pe.processCalendar(sig, ev)
+		return
+	case "finance":
+		pe.processRunway(sig, ev)
+		return
 	}
 
 	ev.ConstructsAffected = append(ev.ConstructsAffected, "business_reality")
 }
 
+// restDayRetentionDays is how far back calendar days off are remembered.
+const restDayRetentionDays = 60
+
+// processCalendar folds schedule features (calendar.go) into TemporalPatterns.
+// Calendar is a planned-time signal, so it nudges peak_hour and planning style
+// rather than replacing what message/event timestamps observe.
+func (pe *PairingEngine) processCalendar(sig Signal, ev *EvidenceEntry) {
+	f := sig.Features
+	if days, ok := sig.Metadata["days_off"].([]string); ok {
+		for _, d := range days {
+			pe.restDays[d] = true
+		}
+	}
+	// Older rest days no longer matter for stall detection
+	cutoff := time.Now().AddDate(0, 0, -restDayRetentionDays).Format("2006-01-02")
+	for d := range pe.restDays {
+		if d < cutoff {
+			delete(pe.restDays, d)
+		}
+	}
+
+	if peak, ok := f["deep_work_peak_hour"]; ok {
+		pe.profile.TemporalPatterns.UpdateEMA("peak_hour", peak)
+		ev.ProfileImpact["temporal_patterns.peak_hour"] = peak
+	}
+	// Blocked deep work = planner; an empty calendar says nothing either way
+	deep, meetings := f["deep_work_hours_per_day"], f["meeting_hours_per_day"]
+	if deep+meetings > 0 {
+		planning := math.Min(10, 5+deep*1.5)
+		pe.profile.TemporalPatterns.UpdateEMA("planning_style", planning)
+		ev.ProfileImpact["temporal_patterns.planning_style"] = planning
+	}
+	// Meeting-heavy days fragment focus
+	if meetings > 0 {
+		switchCost := math.Min(10, meetings*2)
+		pe.profile.TemporalPatterns.UpdateEMA("context_switch_cost", switchCost)
+		ev.ProfileImpact["temporal_patterns.context_switch_cost"] = switchCost
+	}
+	// A scheduled day off today is a deliberate rest, not a stall
+	if pe.restDays[time.Now().Format("2006-01-02")] {
+		pe.profile.ContextWindows[CtxRecoveryPeriod].AddSignal(0.3)
+		pe.profile.ContextWindows[CtxStall].Activation *= 0.5
+	}
+
+	ev.ConstructsAffected = append(ev.ConstructsAffected, "temporal_patterns")
+}
+
+// processRunway holds FINANCIAL_PRESSURE at no less than the runway-derived
+// level (expenses.go). Runway is a standing condition, so it sets a floor
+// rather than stacking like keyword spikes; a healthy runway leaves the
+// window to decay on its own.
+func (pe *PairingEngine) processRunway(sig Signal, ev *EvidenceEntry) {
+	pressure := sig.Features["runway_pressure"]
+	cw := pe.profile.ContextWindows[CtxFinancialPressure]
+	if pressure > cw.Activation {
+		cw.Activate(pressure-cw.Activation, time.Now())
+		ev.ProfileImpact["context.financial_pressure"] = pressure
+	}
+	// Short runway is measured debt pressure (0-10 scale)
+	pe.profile.BusinessReality.UpdateEMA("debt_pressure", pressure*10)
+	ev.ProfileImpact["business_reality.debt_pressure"] = pressure * 10
+	ev.ConstructsAffected = append(ev.ConstructsAffected, "context_windows", "business_reality")
+}
+
+// restHoursSince counts hours since t that fell on calendar rest days.
+func (pe *PairingEngine) restHoursSince(t time.Time) float64 {
+	var hours float64
+	now := time.Now()
+	for d := t; d.Before(now); {
+		dayEnd := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location()).AddDate(0, 0, 1)
+		if pe.restDays[d.Format("2006-01-02")] {
+			end := dayEnd
+			if now.Before(end) {
+				end = now
+			}
+			hours += end.Sub(d).Hours()
+		}
+		d = dayEnd
+	}
+	return hours
+}
+
 // ─── Assessment Scorers ──────────────────────────────────────────────────────
 
 // ASI-12: Action Style Inventory — forced-choice pairs
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// PROVIDER HTTP TRANSPORT — record and replay third-party API traffic
+//
+// Every poller (and the metric/refresh calls around them) builds its client
+// with providerClient, so one switch decides where provider traffic goes:
+//
+//   SCOREBOARD_HTTP_MODE=live     default: straight to the provider
+//   SCOREBOARD_HTTP_MODE=record   live, and each exchange is appended to a
+//                                 fixture file under SCOREBOARD_FIXTURES
+//   SCOREBOARD_HTTP_MODE=replay   never touches the network: responses come
+//                                 from the fixtures; a request with no
+//                                 fixture fails like an unreachable host
+//
+// Fixtures are sanitised before they touch disk. Request headers are not kept
+// at all; secret-looking query parameters and JSON/form fields (api_key,
+// access_token, client_secret...) become "REDACTED", and long opaque URL path
+// segments (webhook tokens) become a short hash. The same sanitising produces
+// the lookup key, so a replayed request finds its fixture without the secret.
+//
+// One file per request key (<dir>/<host>/<method>-<hash>.json) holds every
+// response recorded for it, in order. Replay walks that list and then keeps
+// serving the last one, so a recorded timeline plays back the same way on
+// every run. A request whose exact key is missing (a since= timestamp moved)
+// falls back to a fixture with the same method, host and path.
+//
+// Local services (Letta, Mem0, the LLM gateway) don't use providerClient and
+// are unaffected.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	providerHTTPMode = envOr("SCOREBOARD_HTTP_MODE", "live")
+	fixturesDir      = envOr("SCOREBOARD_FIXTURES", "/data/wirebot/scoreboard/fixtures")
+)
+
+var (
+	providerRTOnce sync.Once
+	providerRT     http.RoundTripper
+)
+
+// providerClient is the http.Client for third-party provider APIs.
+func providerClient(timeout time.Duration) *http.Client {
+	providerRTOnce.Do(func() {
+		switch providerHTTPMode {
+		case "record", "replay":
+			providerRT = newFixtureTransport(providerHTTPMode, fixturesDir, http.DefaultTransport)
+			log.Printf("Provider HTTP: %s mode, fixtures in %s", providerHTTPMode, fixturesDir)
+		case "live", "":
+		default:
+			log.Printf("Provider HTTP: unknown SCOREBOARD_HTTP_MODE %q, using live", providerHTTPMode)
+		}
+	})
+	return &http.Client{Timeout: timeout, Transport: providerRT}
+}
+
+type fixtureResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+	RecordedAt  string `json:"recorded_at"`
+}
+
+type fixtureFile struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"` // sanitised
+	BodyHash  string            `json:"body_hash,omitempty"`
+	Responses []fixtureResponse `json:"responses"`
+}
+
+type fixtureTransport struct {
+	mode string
+	dir  string
+	live http.RoundTripper
+
+	mu     sync.Mutex
+	cursor map[string]int // replay position per fixture file
+}
+
+func newFixtureTransport(mode, dir string, live http.RoundTripper) *fixtureTransport {
+	return &fixtureTransport{mode: mode, dir: dir, live: live, cursor: map[string]int{}}
+}
+
+var (
+	secretFieldRe = regexp.MustCompile(`(?i)^(key|api_?key|access_?token|refresh_?token|id_?token|token|client_secret|secret|password|passwd|signature|sig|auth|authorization|credentials?|consumer_key|consumer_secret|oauth_consumer_key|private_key)$`)
+	opaqueSegRe   = regexp.MustCompile(`^[A-Za-z0-9_\-]{32,}$`)
+)
+
+func isSecretField(name string) bool { return secretFieldRe.MatchString(name) }
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// sanitizeURL strips credentials from a request URL. Deterministic, so it
+// doubles as the fixture lookup key.
+func sanitizeURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	segs := strings.Split(c.Path, "/")
+	for i, seg := range segs {
+		if opaqueSegRe.MatchString(seg) {
+			segs[i] = "tok-" + shortHash(seg)
+		}
+	}
+	c.Path = strings.Join(segs, "/")
+	c.RawPath = ""
+	q := c.Query()
+	for k := range q {
+		if isSecretField(k) {
+			q.Set(k, "REDACTED")
+		}
+	}
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
+// sanitizeJSON redacts secret-named fields at any depth and reports whether
+// it changed anything.
+func sanitizeJSON(v interface{}) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			switch val.(type) {
+			case map[string]interface{}, []interface{}:
+				changed = sanitizeJSON(val) || changed
+			default:
+				if isSecretField(k) && val != nil {
+					t[k] = "REDACTED"
+					changed = true
+				}
+			}
+		}
+	case []interface{}:
+		for _, val := range t {
+			changed = sanitizeJSON(val) || changed
+		}
+	}
+	return changed
+}
+
+// sanitizeBody redacts secrets in a JSON or form-encoded body; anything else
+// is returned unchanged.
+func sanitizeBody(contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber() // keep large IDs exact
+		if dec.Decode(&v) == nil {
+			if !sanitizeJSON(v) {
+				return body
+			}
+			out, _ := json.Marshal(v)
+			return out
+		}
+	}
+	if strings.Contains(contentType, "application/x-www-form-urlencoded") {
+		if q, err := url.ParseQuery(string(body)); err == nil {
+			for k := range q {
+				if isSecretField(k) {
+					q.Set(k, "REDACTED")
+				}
+			}
+			return []byte(q.Encode())
+		}
+	}
+	return body
+}
+
+// fixturePath returns the fixture file for a request and the sanitised parts
+// of the request that identify it.
+func (t *fixtureTransport) fixturePath(req *http.Request, body []byte) (path, cleanURL, bodyHash string) {
+	cleanURL = sanitizeURL(req.URL)
+	if len(body) > 0 {
+		bodyHash = shortHash(string(sanitizeBody(req.Header.Get("Content-Type"), body)))
+	}
+	name := fmt.Sprintf("%s-%s.json", strings.ToLower(req.Method), shortHash(req.Method+" "+cleanURL+" "+bodyHash))
+	return filepath.Join(t.dir, req.URL.Hostname(), name), cleanURL, bodyHash
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	path, cleanURL, bodyHash := t.fixturePath(req, body)
+	if t.mode == "replay" {
+		return t.replay(req, path, cleanURL)
+	}
+
+	resp, err := t.live.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	ct := resp.Header.Get("Content-Type")
+	rec := fixtureResponse{
+		Status: resp.StatusCode, ContentType: ct,
+		Body:       string(sanitizeBody(ct, respBody)),
+		RecordedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var f fixtureFile
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &f)
+	}
+	f.Method, f.URL, f.BodyHash = req.Method, cleanURL, bodyHash
+	f.Responses = append(f.Responses, rec)
+	if err := writeFixture(path, &f); err != nil {
+		log.Printf("Provider HTTP: record %s: %v", cleanURL, err)
+	}
+	return resp, nil
+}
+
+func writeFixture(path string, f *fixtureFile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(f, "", "  ")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (t *fixtureTransport) replay(req *http.Request, path, cleanURL string) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		path, data, err = t.nearestFixture(req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("replay: no fixture for %s %s", req.Method, cleanURL)
+	}
+	var f fixtureFile
+	if err := json.Unmarshal(data, &f); err != nil || len(f.Responses) == 0 {
+		return nil, fmt.Errorf("replay: bad fixture %s", filepath.Base(path))
+	}
+	i := t.cursor[path]
+	if i >= len(f.Responses) {
+		i = len(f.Responses) - 1
+	}
+	t.cursor[path] = i + 1
+	rec := f.Responses[i]
+
+	h := http.Header{}
+	if rec.ContentType != "" {
+		h.Set("Content-Type", rec.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(strings.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}, nil
+}
+
+// nearestFixture finds a fixture for the same method, host and path when the
+// query or body differ from anything recorded. Picks the first by name, so the
+// choice is stable across runs.
+func (t *fixtureTransport) nearestFixture(req *http.Request) (string, []byte, error) {
+	want, _ := url.Parse(sanitizeURL(req.URL))
+	matches, _ := filepath.Glob(filepath.Join(t.dir, req.URL.Hostname(), strings.ToLower(req.Method)+"-*.json"))
+	for _, m := range matches {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			continue
+		}
+		var f fixtureFile
+		if json.Unmarshal(data, &f) != nil {
+			continue
+		}
+		if got, err := url.Parse(f.URL); err == nil && got.Path == want.Path {
+			return m, data, nil
+		}
+	}
+	return "", nil, os.ErrNotExist
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// RECEIVABLES — open invoices, aging and collections velocity
+//
+// pollFreshBooks feeds every invoice it sees through trackReceivable, which
+// keeps one row per invoice in receivables and emits lifecycle events once:
+//
+//   INVOICE_SENT     first time the invoice is seen open (sent, unpaid)
+//   INVOICE_OVERDUE  first poll after the due date with money still owed
+//   INVOICE_PAID     (existing) when FreshBooks reports it paid
+//
+// checkReceivables raises alerts for overdue invoices through the alerts
+// table, escalating at 30 / 60 / 90 days past due — each level is its own
+// alert ID so dismissing one doesn't silence the next.
+//
+// Aging buckets are by invoice age (days since issue): 0-30, 31-60, 61-90,
+// 90+. Collections velocity is measured on invoices paid in the last 90 days:
+// average / median days from issue to payment, and DSO (open receivables ÷
+// trailing 90d revenue × 90).
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// receivableAgingBuckets are upper bounds in days; the last bucket is open-ended.
+var receivableAgingBuckets = []struct {
+	label string
+	max   int
+}{
+	{"0-30", 30}, {"31-60", 60}, {"61-90", 90}, {"90+", math.MaxInt32},
+}
+
+func (s *Server) initReceivables() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS receivables (
+		id TEXT PRIMARY KEY,
+		source TEXT NOT NULL,
+		invoice_number TEXT DEFAULT '',
+		customer TEXT DEFAULT '',
+		currency TEXT NOT NULL,
+		total REAL DEFAULT 0,
+		outstanding REAL DEFAULT 0,
+		issued_date TEXT NOT NULL,
+		due_date TEXT DEFAULT '',
+		status TEXT NOT NULL,
+		paid_date TEXT DEFAULT '',
+		sent_emitted INTEGER DEFAULT 0,
+		overdue_emitted INTEGER DEFAULT 0,
+		first_seen TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_receivables_status ON receivables(status)`)
+}
+
+// receivable is one invoice as tracked for aging.
+type receivable struct {
+	ID            string  `json:"id"`
+	Source        string  `json:"source"`
+	InvoiceNumber string  `json:"invoice_number"`
+	Customer      string  `json:"customer"`
+	Currency      string  `json:"currency"`
+	Total         float64 `json:"total"`
+	Outstanding   float64 `json:"outstanding"`
+	IssuedDate    string  `json:"issued_date"`
+	DueDate       string  `json:"due_date"`
+	Status        string  `json:"status"` // draft | open | partial | paid | void
+	PaidDate      string  `json:"paid_date,omitempty"`
+
+	overdueEmitted bool
+}
+
+// freshbooksReceivable maps a FreshBooks invoice object to a receivable.
+func freshbooksReceivable(inv map[string]interface{}) (receivable, bool) {
+	money := func(k string) (float64, string) {
+		m, _ := inv[k].(map[string]interface{})
+		amt, _ := m["amount"].(string)
+		code, _ := m["code"].(string)
+		f, _ := strconv.ParseFloat(amt, 64)
+		return f, strings.ToUpper(code)
+	}
+	id, _ := inv["invoiceid"].(float64)
+	if id == 0 {
+		id, _ = inv["id"].(float64)
+	}
+	if id == 0 {
+		return receivable{}, false
+	}
+	r := receivable{ID: fmt.Sprintf("fb-%d", int64(id)), Source: "freshbooks"}
+	r.InvoiceNumber, _ = inv["invoice_number"].(string)
+	r.Customer, _ = inv["organization"].(string)
+	if r.Customer == "" {
+		fname, _ := inv["fname"].(string)
+		lname, _ := inv["lname"].(string)
+		r.Customer = strings.TrimSpace(fname + " " + lname)
+	}
+	r.Total, r.Currency = money("amount")
+	r.Outstanding, _ = money("outstanding")
+	r.IssuedDate, _ = inv["create_date"].(string)
+	r.DueDate, _ = inv["due_date"].(string)
+	r.PaidDate, _ = inv["date_paid"].(string)
+	if r.IssuedDate == "" {
+		return receivable{}, false
+	}
+
+	v3, _ := inv["v3_status"].(string)
+	payment, _ := inv["payment_status"].(string)
+	visState, _ := inv["vis_state"].(float64)
+	switch {
+	case visState == 1 || v3 == "deleted":
+		r.Status = "void"
+	case payment == "paid" || v3 == "paid":
+		r.Status = "paid"
+		r.Outstanding = 0
+	case v3 == "draft":
+		r.Status = "draft"
+	case payment == "partial" || v3 == "partial":
+		r.Status = "partial"
+	default:
+		r.Status = "open"
+	}
+	if r.Status == "paid" && r.PaidDate == "" {
+		updated, _ := inv["updated"].(string)
+		if len(updated) >= 10 {
+			r.PaidDate = updated[:10]
+		}
+	}
+	return r, true
+}
+
+// trackReceivable upserts the invoice and emits INVOICE_SENT once.
+func (s *Server) trackReceivable(r receivable) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var sentEmitted int
+	s.db.QueryRow(`SELECT sent_emitted FROM receivables WHERE id=?`, r.ID).Scan(&sentEmitted)
+
+	s.db.Exec(`INSERT INTO receivables (id, source, invoice_number, customer, currency, total, outstanding,
+		issued_date, due_date, status, paid_date, first_seen, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET invoice_number=excluded.invoice_number, customer=excluded.customer,
+			currency=excluded.currency, total=excluded.total, outstanding=excluded.outstanding,
+			issued_date=excluded.issued_date, due_date=excluded.due_date, status=excluded.status,
+			paid_date=excluded.paid_date, updated_at=excluded.updated_at`,
+		r.ID, r.Source, r.InvoiceNumber, r.Customer, r.Currency, r.Total, r.Outstanding,
+		r.IssuedDate, r.DueDate, r.Status, r.PaidDate, now, now)
+
+	// Invoices first seen already paid were never observed as sent; the
+	// INVOICE_PAID event covers them.
+	if sentEmitted == 0 && (r.Status == "open" || r.Status == "partial") {
+		meta, _ := json.Marshal(map[string]interface{}{
+			"amount":         r.Total,
+			"currency":       r.Currency,
+			"customer":       r.Customer,
+			"invoice_number": r.InvoiceNumber,
+			"due_date":       r.DueDate,
+		})
+		s.insertEventIfNew(Event{
+			EventType:     "INVOICE_SENT",
+			Lane:          "revenue",
+			Source:        r.Source,
+			ArtifactTitle: fmt.Sprintf("📨 Invoice #%s sent — %s (%s)", r.InvoiceNumber, r.Customer, formatMajor(r.Total, r.Currency)),
+			Detail:        fmt.Sprintf("Invoice issued %s, due %s", r.IssuedDate, r.DueDate),
+			ScoreDelta:    calcScoreDelta("revenue", "INVOICE_SENT", 1.0),
+			Confidence:    1.0,
+			Verification:  "PROVIDER_API",
+			ExternalID:    r.ID + "-sent",
+			Timestamp:     r.IssuedDate + "T00:00:00Z",
+			Metadata:      string(meta),
+		})
+		s.db.Exec(`UPDATE receivables SET sent_emitted=1 WHERE id=?`, r.ID)
+	}
+}
+
+// daysBetween counts whole calendar days from a to b (YYYY-MM-DD).
+func daysBetween(a, b string) int {
+	ta, err1 := time.Parse("2006-01-02", a)
+	tb, err2 := time.Parse("2006-01-02", b)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return int(tb.Sub(ta).Hours() / 24)
+}
+
+func (s *Server) loadReceivables(where string, args ...interface{}) []receivable {
+	rows, err := s.db.Query(`SELECT id, source, invoice_number, customer, currency, total, outstanding,
+		issued_date, due_date, status, paid_date, overdue_emitted FROM receivables WHERE `+where+` ORDER BY issued_date`, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var out []receivable
+	for rows.Next() {
+		var r receivable
+		rows.Scan(&r.ID, &r.Source, &r.InvoiceNumber, &r.Customer, &r.Currency, &r.Total, &r.Outstanding,
+			&r.IssuedDate, &r.DueDate, &r.Status, &r.PaidDate, &r.overdueEmitted)
+		out = append(out, r)
+	}
+	return out
+}
+
+// checkReceivables emits INVOICE_OVERDUE once per invoice and raises
+// escalating overdue alerts. Safe to call on every poll.
+func (s *Server) checkReceivables() int {
+	today := operatorNow().Format("2006-01-02")
+	alerts := 0
+	for _, r := range s.loadReceivables(`status IN ('open','partial') AND due_date != '' AND due_date < ?`, today) {
+		late := daysBetween(r.DueDate, today)
+		owed := formatMajor(r.Outstanding, r.Currency)
+		if !r.overdueEmitted {
+			meta, _ := json.Marshal(map[string]interface{}{
+				"amount":         r.Outstanding,
+				"currency":       r.Currency,
+				"customer":       r.Customer,
+				"invoice_number": r.InvoiceNumber,
+				"due_date":       r.DueDate,
+			})
+			s.insertEventIfNew(Event{
+				EventType:     "INVOICE_OVERDUE",
+				Lane:          "revenue",
+				Source:        r.Source,
+				ArtifactTitle: fmt.Sprintf("⏰ Invoice #%s overdue — %s (%s)", r.InvoiceNumber, r.Customer, owed),
+				Detail:        fmt.Sprintf("Due %s, %s still outstanding", r.DueDate, owed),
+				ScoreDelta:    calcScoreDelta("revenue", "INVOICE_OVERDUE", 1.0),
+				Confidence:    1.0,
+				Verification:  "PROVIDER_API",
+				ExternalID:    r.ID + "-overdue",
+				Timestamp:     time.Now().UTC().Format(time.RFC3339),
+				Metadata:      string(meta),
+			})
+			s.db.Exec(`UPDATE receivables SET overdue_emitted=1 WHERE id=?`, r.ID)
+		}
+
+		level, severity := 0, "warning"
+		switch {
+		case late > 90:
+			level, severity = 90, "critical"
+		case late > 60:
+			level, severity = 60, "critical"
+		case late > 30:
+			level = 30
+		}
+		id := fmt.Sprintf("invoice_overdue_%d_%s", level, sanitizeID(r.ID))
+		title := fmt.Sprintf("Invoice #%s — %s overdue", r.InvoiceNumber, r.Customer)
+		if s.insertAlert(id, "invoice_overdue", title,
+			fmt.Sprintf("%s outstanding, %d days past due (due %s)", owed, late, r.DueDate), severity) {
+			alerts++
+		}
+	}
+	if alerts > 0 {
+		log.Printf("[receivables] %d new overdue alerts", alerts)
+	}
+	return alerts
+}
+
+// receivablesSummary is aging plus collections velocity in the base currency.
+func (s *Server) receivablesSummary() map[string]interface{} {
+	now := time.Now()
+	today := operatorNow().Format("2006-01-02")
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+
+	buckets := map[string]float64{}
+	counts := map[string]int{}
+	for _, b := range receivableAgingBuckets {
+		buckets[b.label], counts[b.label] = 0, 0
+	}
+	var open []map[string]interface{}
+	var outstanding, overdue float64
+	for _, r := range s.loadReceivables(`status IN ('open','partial')`) {
+		amount, _, _, _ := s.toBase(r.Outstanding, r.Currency, now)
+		age := daysBetween(r.IssuedDate, today)
+		for _, b := range receivableAgingBuckets {
+			if age <= b.max {
+				buckets[b.label] += amount
+				counts[b.label]++
+				break
+			}
+		}
+		outstanding += amount
+		late := 0
+		if r.DueDate != "" && r.DueDate < today {
+			late = daysBetween(r.DueDate, today)
+			overdue += amount
+		}
+		open = append(open, map[string]interface{}{
+			"id": r.ID, "invoice_number": r.InvoiceNumber, "customer": r.Customer,
+			"outstanding": r.Outstanding, "currency": r.Currency, "outstanding_base": amount,
+			"issued_date": r.IssuedDate, "due_date": r.DueDate, "age_days": age, "days_past_due": late,
+		})
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i]["age_days"].(int) > open[j]["age_days"].(int) })
+
+	aging := make([]map[string]interface{}, 0, len(receivableAgingBuckets))
+	for _, b := range receivableAgingBuckets {
+		aging = append(aging, map[string]interface{}{
+			"bucket": b.label, "amount": round(buckets[b.label]), "count": counts[b.label],
+		})
+	}
+
+	// Collections velocity: invoices paid in the last 90 days
+	since := operatorNow().AddDate(0, 0, -90).Format("2006-01-02")
+	var days []int
+	var collected float64
+	onTime := 0
+	for _, r := range s.loadReceivables(`status = 'paid' AND paid_date >= ?`, since) {
+		d := daysBetween(r.IssuedDate, r.PaidDate)
+		if d < 0 {
+			d = 0
+		}
+		days = append(days, d)
+		amount, _, _, _ := s.toBase(r.Total, r.Currency, now)
+		collected += amount
+		if r.DueDate == "" || r.PaidDate <= r.DueDate {
+			onTime++
+		}
+	}
+	velocity := map[string]interface{}{
+		"paid_invoices_90d": len(days),
+		"collected_90d":     round(collected),
+	}
+	if len(days) > 0 {
+		sort.Ints(days)
+		sum := 0
+		for _, d := range days {
+			sum += d
+		}
+		velocity["avg_days_to_pay"] = math.Round(float64(sum)/float64(len(days))*10) / 10
+		velocity["median_days_to_pay"] = days[len(days)/2]
+		velocity["on_time_rate"] = math.Round(float64(onTime)/float64(len(days))*1000) / 1000
+	}
+	if rev90 := s.trailingRevenue90d(); rev90 > 0 {
+		velocity["dso"] = math.Round(outstanding/rev90*90*10) / 10
+	}
+
+	return map[string]interface{}{
+		"base_currency": s.baseCurrency(),
+		"outstanding":   round(outstanding),
+		"overdue":       round(overdue),
+		"open_invoices": len(open),
+		"aging":         aging,
+		"collections":   velocity,
+		"invoices":      open,
+	}
+}
+
+// trailingRevenue90d is deduplicated, non-test gross revenue over 90 days.
+func (s *Server) trailingRevenue90d() float64 {
+	to := operatorNow().AddDate(0, 0, 1)
+	report := s.reconcileReport(to.AddDate(0, 0, -91), to)
+	gross, _ := report["gross_revenue"].(float64)
+	return gross
+}
+
+// GET /v1/financial/receivables — open invoices, aging buckets, collections velocity.
+func (s *Server) handleReceivables(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+	writeJSON(w, s.receivablesSummary())
+}
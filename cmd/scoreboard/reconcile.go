@@ -0,0 +1,615 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// RECONCILIATION v2 — explainable matches + operator overrides
+//
+// ReconcileRevenue (main.go) clusters money-in events by amount and date. This
+// file adds what it needs to be auditable:
+//
+//   scoreReconMatch   0–1 match score of each duplicate against its primary,
+//                     with human-readable reasons (amount, date delta, shared
+//                     external reference, source priority)
+//   reconcile_overrides  operator decisions keyed by event IDs, so they survive
+//                     every rerun regardless of how clusters get renumbered:
+//                       must_link   (merge)     these two are the same money
+//                       cannot_link (split)     these two are not
+//                       primary     (override)  this event is the one to keep
+//   /v1/reconcile/{cluster}  GET detail, POST merge | split | override | reset
+//   /v1/reconcile/report     gross revenue by source after deduplication
+//
+// Duplicates keep their original score in reconciled_events.original_score so
+// a split or override can give it back.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// reconcileExcludedTypes never represent money arriving, so they're never
+// clustered against payments or deposits.
+var reconcileExcludedTypes = map[string]bool{
+	"EXPENSE": true, "EXPENSE_RECORDED": true, "REFUND_ISSUED": true,
+	"PAYMENT_FAILED": true, "INVOICE_FAILED": true, "SUBSCRIPTION_CREATED": true,
+	"SUBSCRIPTION_CANCELED": true, "SUBSCRIPTION_UPDATED": true,
+	"INVOICE_SENT": true, "INVOICE_OVERDUE": true,
+}
+
+// reconcileGrossTypes count as gross revenue in the period report. Payouts are
+// transfers of money already counted as payments, so they're reported apart.
+var reconcileGrossTypes = map[string]bool{
+	"PAYMENT_RECEIVED": true, "INVOICE_PAID": true, "revenue": true,
+}
+
+func (s *Server) initReconcileOverrides() {
+	for _, stmt := range []string{
+		`ALTER TABLE reconciled_events ADD COLUMN match_score REAL DEFAULT 0`,
+		`ALTER TABLE reconciled_events ADD COLUMN reasons TEXT DEFAULT '[]'`,
+		`ALTER TABLE reconciled_events ADD COLUMN original_score INTEGER`,
+		`ALTER TABLE reconciled_events ADD COLUMN manual INTEGER DEFAULT 0`,
+	} {
+		s.db.Exec(stmt) // ignore "duplicate column" on restart
+	}
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS reconcile_overrides (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		event_a TEXT NOT NULL,
+		event_b TEXT DEFAULT '',
+		cluster_id TEXT DEFAULT '',
+		note TEXT DEFAULT '',
+		created_at TEXT NOT NULL
+	)`)
+}
+
+// reconEvent is one revenue event as seen by the reconciler.
+type reconEvent struct {
+	ID        string
+	EventType string
+	Source    string
+	Title     string
+	Amount    float64
+	Score     int
+	Timestamp string
+	Time      time.Time
+	ExtID     string
+	Metadata  string
+	TestMode  bool
+}
+
+// canonicalSource folds transport suffixes so "stripe-webhook" ranks as stripe.
+func canonicalSource(source string) string {
+	for _, suffix := range []string{"-webhook", "-poller", "-poll", "-api"} {
+		source = strings.TrimSuffix(source, suffix)
+	}
+	return source
+}
+
+// reconReferences pulls ID-like tokens (ch_…, po_…, txn_…, numeric order IDs)
+// from an event's external ID and metadata values.
+func reconReferences(e reconEvent) map[string]bool {
+	refs := map[string]bool{}
+	add := func(v string) {
+		v = strings.TrimSpace(v)
+		if len(v) < 6 || !strings.ContainsAny(v, "0123456789") || strings.ContainsAny(v, " :/") {
+			return
+		}
+		refs[strings.ToLower(v)] = true
+	}
+	// External IDs are usually "<source>-<kind>-<provider id>"
+	if e.ExtID != "" {
+		parts := strings.Split(e.ExtID, "-")
+		add(parts[len(parts)-1])
+	}
+	var meta map[string]interface{}
+	if json.Unmarshal([]byte(e.Metadata), &meta) == nil {
+		for k, v := range meta {
+			if strings.HasPrefix(k, "amount") || strings.HasPrefix(k, "fx_") || k == "score_delta" {
+				continue
+			}
+			if str, ok := v.(string); ok {
+				add(str)
+			}
+		}
+	}
+	return refs
+}
+
+// scoreReconMatch rates how likely other is the same money as primary.
+func scoreReconMatch(primary, other reconEvent) (float64, []string) {
+	var reasons []string
+
+	diff := math.Abs(primary.Amount - other.Amount)
+	amountScore := math.Max(0, 1-diff)
+	if diff < 0.005 {
+		reasons = append(reasons, fmt.Sprintf("amount exact match %.2f", primary.Amount))
+	} else {
+		reasons = append(reasons, fmt.Sprintf("amount %.2f vs %.2f (Δ %.2f)", primary.Amount, other.Amount, diff))
+	}
+
+	dateScore := 0.5
+	if !primary.Time.IsZero() && !other.Time.IsZero() {
+		days := math.Abs(primary.Time.Sub(other.Time).Hours()) / 24
+		dateScore = math.Max(0, 1-days/6)
+		if primary.Time.In(operatorTZ).Format("2006-01-02") == other.Time.In(operatorTZ).Format("2006-01-02") {
+			reasons = append(reasons, "same day")
+		} else {
+			reasons = append(reasons, fmt.Sprintf("%.1f days apart", days))
+		}
+	}
+
+	extScore := 0.0
+	pRefs, oRefs := reconReferences(primary), reconReferences(other)
+	for ref := range pRefs {
+		if oRefs[ref] {
+			extScore = 1
+			reasons = append(reasons, "shared external reference "+ref)
+			break
+		}
+	}
+
+	pSrc, oSrc := canonicalSource(primary.Source), canonicalSource(other.Source)
+	sourceScore := 0.3
+	switch {
+	case pSrc == oSrc:
+		reasons = append(reasons, "same source "+pSrc)
+	case pSrc == "plaid" || oSrc == "plaid":
+		sourceScore = 1 // processor ↔ bank settlement is the classic duplicate
+		processor := pSrc
+		if pSrc == "plaid" {
+			processor = oSrc
+		}
+		reasons = append(reasons, fmt.Sprintf("%s (%d) settled to bank feed plaid (%d)",
+			processor, sourcePriority(processor), sourcePriority("plaid")))
+	default:
+		sourceScore = 0.8
+		reasons = append(reasons, fmt.Sprintf("%s (%d) outranks %s (%d)",
+			pSrc, sourcePriority(pSrc), oSrc, sourcePriority(oSrc)))
+	}
+
+	score := 0.4*amountScore + 0.3*dateScore + 0.15*sourceScore + 0.15*extScore
+	if extScore == 1 {
+		score = math.Max(score, 0.9)
+	}
+	return math.Round(score*1000) / 1000, reasons
+}
+
+// reconOverrides is the operator's standing decisions, keyed by event IDs.
+type reconOverrides struct {
+	mustLink   [][2]string
+	cannotLink map[[2]string]bool
+	primary    map[string]bool
+	touched    map[string]bool
+}
+
+func reconPair(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+func (o reconOverrides) forbidden(a, b string) bool {
+	return o.cannotLink[reconPair(a, b)]
+}
+
+func (s *Server) loadReconOverrides() reconOverrides {
+	o := reconOverrides{cannotLink: map[[2]string]bool{}, primary: map[string]bool{}, touched: map[string]bool{}}
+	rows, err := s.db.Query(`SELECT action, event_a, event_b FROM reconcile_overrides ORDER BY id`)
+	if err != nil {
+		return o
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var action, a, b string
+		rows.Scan(&action, &a, &b)
+		o.touched[a] = true
+		if b != "" {
+			o.touched[b] = true
+		}
+		switch action {
+		case "must_link":
+			o.mustLink = append(o.mustLink, [2]string{a, b})
+		case "cannot_link":
+			o.cannotLink[reconPair(a, b)] = true
+		case "primary":
+			o.primary[a] = true
+		}
+	}
+	return o
+}
+
+// stableClusterID reuses the ID any member already carries so operator links
+// and bookmarks keep working across reruns; new clusters hash their primary.
+func stableClusterID(existing map[string]string, used map[string]bool, primaryID string, members []reconEvent) string {
+	if id := existing[primaryID]; id != "" && !used[id] {
+		return id
+	}
+	for _, m := range members {
+		if id := existing[m.ID]; id != "" && !used[id] {
+			return id
+		}
+	}
+	sum := sha1.Sum([]byte(primaryID))
+	id := "rc-" + hex.EncodeToString(sum[:])[:12]
+	for n := 2; used[id]; n++ {
+		id = fmt.Sprintf("rc-%s-%d", hex.EncodeToString(sum[:])[:12], n)
+	}
+	return id
+}
+
+// reconEventAmount is the base-currency amount of an event for the report.
+func reconEventAmount(metadata, title string) float64 {
+	if amt, _, ok := revenueMetadataAmount(metadata); ok {
+		return amt
+	}
+	return extractAmountFromTitle(title)
+}
+
+// ─── /v1/reconcile/{cluster} ────────────────────────────────────────────────
+
+func (s *Server) reconcileClusterDetail(clusterID string) map[string]interface{} {
+	rows, err := s.db.Query(`SELECT r.event_id, r.status, COALESCE(r.primary_event_id,''),
+		COALESCE(r.match_score,0), COALESCE(r.reasons,'[]'), r.original_score, COALESCE(r.manual,0),
+		COALESCE(e.source,''), COALESCE(e.event_type,''), COALESCE(e.artifact_title,''),
+		COALESCE(e.timestamp,''), COALESCE(e.metadata,''), COALESCE(e.score_delta,0)
+		FROM reconciled_events r LEFT JOIN events e ON e.id = r.event_id
+		WHERE r.cluster_id=? ORDER BY r.status DESC, e.timestamp`, clusterID)
+	if err != nil {
+		return nil
+	}
+	var members []map[string]interface{}
+	var ids []string
+	primaryID := ""
+	manual := false
+	for rows.Next() {
+		var eid, status, pid, reasonsJSON, source, etype, title, ts, meta string
+		var score float64
+		var original *int64
+		var isManual, scoreDelta int
+		rows.Scan(&eid, &status, &pid, &score, &reasonsJSON, &original, &isManual,
+			&source, &etype, &title, &ts, &meta, &scoreDelta)
+		var reasons []string
+		json.Unmarshal([]byte(reasonsJSON), &reasons)
+		m := map[string]interface{}{
+			"event_id": eid, "status": status, "source": source, "event_type": etype,
+			"title": title, "timestamp": ts, "amount": reconEventAmount(meta, title),
+			"score_delta": scoreDelta, "match_score": score, "reasons": reasons,
+		}
+		if original != nil {
+			m["original_score"] = *original
+		}
+		members = append(members, m)
+		ids = append(ids, eid)
+		primaryID = pid
+		manual = manual || isManual == 1
+	}
+	rows.Close()
+	if len(members) == 0 {
+		return nil
+	}
+
+	var overrides []map[string]interface{}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, 0, len(ids)*2)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+	orows, err := s.db.Query(`SELECT id, action, event_a, event_b, note, created_at FROM reconcile_overrides
+		WHERE event_a IN (`+placeholders+`) OR event_b IN (`+placeholders+`) ORDER BY id`, args...)
+	if err == nil {
+		for orows.Next() {
+			var id int
+			var action, a, b, note, created string
+			orows.Scan(&id, &action, &a, &b, &note, &created)
+			overrides = append(overrides, map[string]interface{}{
+				"id": id, "action": action, "event_a": a, "event_b": b, "note": note, "created_at": created,
+			})
+		}
+		orows.Close()
+	}
+
+	status := "auto"
+	if manual {
+		status = "manual_confirmed"
+	}
+	return map[string]interface{}{
+		"cluster_id": clusterID,
+		"primary_id": primaryID,
+		"status":     status,
+		"members":    members,
+		"overrides":  overrides,
+	}
+}
+
+func (s *Server) addReconOverride(action, a, b, clusterID, note string) {
+	s.db.Exec(`INSERT INTO reconcile_overrides (action, event_a, event_b, cluster_id, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, action, a, b, clusterID, note, time.Now().UTC().Format(time.RFC3339))
+}
+
+func (s *Server) dropReconLinks(a, b string) {
+	s.db.Exec(`DELETE FROM reconcile_overrides WHERE action IN ('must_link','cannot_link')
+		AND ((event_a=? AND event_b=?) OR (event_a=? AND event_b=?))`, a, b, b, a)
+}
+
+// GET  /v1/reconcile/{cluster}  — members with match scores, reasons, overrides
+// POST /v1/reconcile/{cluster}  — {"action":"merge","event_ids":[…]}
+//
+//	{"action":"split","event_ids":[…]}
+//	{"action":"override","primary_id":"…"} | {"action":"override","status":"not_duplicate"|"confirmed"}
+//	{"action":"reset"}
+func (s *Server) handleReconcileCluster(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	clusterID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/reconcile/"), "/")
+	if clusterID == "" {
+		http.Error(w, `{"error":"cluster id required"}`, 400)
+		return
+	}
+
+	detail := s.reconcileClusterDetail(clusterID)
+	if detail == nil {
+		http.Error(w, `{"error":"cluster not found"}`, 404)
+		return
+	}
+	if r.Method == "GET" {
+		writeJSON(w, detail)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, `{"error":"GET or POST"}`, 405)
+		return
+	}
+
+	var req struct {
+		Action    string   `json:"action"`
+		EventIDs  []string `json:"event_ids"`
+		PrimaryID string   `json:"primary_id"`
+		Status    string   `json:"status"`
+		Note      string   `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json"}`, 400)
+		return
+	}
+
+	primaryID, _ := detail["primary_id"].(string)
+	var members []string
+	isMember := map[string]bool{}
+	for _, m := range detail["members"].([]map[string]interface{}) {
+		id := m["event_id"].(string)
+		members = append(members, id)
+		isMember[id] = true
+	}
+
+	s.mu.Lock()
+	switch req.Action {
+	case "merge":
+		if len(req.EventIDs) == 0 {
+			s.mu.Unlock()
+			http.Error(w, `{"error":"event_ids required"}`, 400)
+			return
+		}
+		for _, id := range req.EventIDs {
+			var exists int
+			s.db.QueryRow(`SELECT COUNT(*) FROM events WHERE id=? AND lane='revenue'`, id).Scan(&exists)
+			if exists == 0 || id == primaryID {
+				continue
+			}
+			s.dropReconLinks(primaryID, id)
+			s.addReconOverride("must_link", primaryID, id, clusterID, req.Note)
+		}
+
+	case "split":
+		if len(req.EventIDs) == 0 {
+			s.mu.Unlock()
+			http.Error(w, `{"error":"event_ids required"}`, 400)
+			return
+		}
+		splitting := map[string]bool{}
+		for _, id := range req.EventIDs {
+			if isMember[id] {
+				splitting[id] = true
+			}
+		}
+		for id := range splitting {
+			for _, m := range members {
+				if splitting[m] {
+					continue
+				}
+				s.dropReconLinks(id, m)
+				s.addReconOverride("cannot_link", id, m, clusterID, req.Note)
+			}
+		}
+
+	case "override":
+		switch {
+		case req.PrimaryID != "":
+			if !isMember[req.PrimaryID] {
+				s.mu.Unlock()
+				http.Error(w, `{"error":"primary_id must be a cluster member"}`, 400)
+				return
+			}
+			for _, m := range members {
+				s.db.Exec(`DELETE FROM reconcile_overrides WHERE action='primary' AND event_a=?`, m)
+			}
+			s.addReconOverride("primary", req.PrimaryID, "", clusterID, req.Note)
+		case req.Status == "not_duplicate":
+			for i := range members {
+				for j := i + 1; j < len(members); j++ {
+					s.dropReconLinks(members[i], members[j])
+					s.addReconOverride("cannot_link", members[i], members[j], clusterID, req.Note)
+				}
+			}
+		case req.Status == "confirmed":
+			for _, m := range members {
+				if m != primaryID {
+					s.dropReconLinks(primaryID, m)
+					s.addReconOverride("must_link", primaryID, m, clusterID, req.Note)
+				}
+			}
+		default:
+			s.mu.Unlock()
+			http.Error(w, `{"error":"override needs primary_id or status (not_duplicate|confirmed)"}`, 400)
+			return
+		}
+
+	case "reset":
+		for _, m := range members {
+			s.db.Exec(`DELETE FROM reconcile_overrides WHERE event_a=? OR event_b=?`, m, m)
+		}
+
+	default:
+		s.mu.Unlock()
+		http.Error(w, `{"error":"action must be merge, split, override or reset"}`, 400)
+		return
+	}
+	s.mu.Unlock()
+
+	results := s.ReconcileRevenue()
+	writeJSON(w, map[string]interface{}{
+		"ok":             true,
+		"action":         req.Action,
+		"cluster":        s.reconcileClusterDetail(clusterID), // nil when the cluster dissolved
+		"clusters_found": len(results),
+	})
+}
+
+// ─── /v1/reconcile/report ───────────────────────────────────────────────────
+
+// GET /v1/reconcile/report?period=2026-09 | ?from=2026-07-01&to=2026-09-30
+// Gross revenue by source after removing reconciled duplicates and test
+// transactions, in the tenant's base currency.
+func (s *Server) handleReconcileReport(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	q := r.URL.Query()
+	now := operatorNow()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, operatorTZ)
+	to := from.AddDate(0, 1, 0)
+	if p := q.Get("period"); p != "" {
+		t, err := time.ParseInLocation("2006-01", p, operatorTZ)
+		if err != nil {
+			http.Error(w, `{"error":"period must be YYYY-MM"}`, 400)
+			return
+		}
+		from, to = t, t.AddDate(0, 1, 0)
+	}
+	if f := q.Get("from"); f != "" {
+		t, err := time.ParseInLocation("2006-01-02", f, operatorTZ)
+		if err != nil {
+			http.Error(w, `{"error":"from must be YYYY-MM-DD"}`, 400)
+			return
+		}
+		from = t
+		to = now
+	}
+	if tv := q.Get("to"); tv != "" {
+		t, err := time.ParseInLocation("2006-01-02", tv, operatorTZ)
+		if err != nil {
+			http.Error(w, `{"error":"to must be YYYY-MM-DD"}`, 400)
+			return
+		}
+		to = t.AddDate(0, 0, 1) // inclusive
+	}
+
+	s.normalizeRevenueEvents(false)
+	writeJSON(w, s.reconcileReport(from, to))
+}
+
+type reconSourceLine struct {
+	Source          string  `json:"source"`
+	Gross           float64 `json:"gross"`
+	Count           int     `json:"count"`
+	Refunds         float64 `json:"refunds"`
+	Payouts         float64 `json:"payouts"`
+	DuplicatesCount int     `json:"duplicates_removed"`
+	DuplicateAmount float64 `json:"duplicate_amount"`
+}
+
+func (s *Server) reconcileReport(from, to time.Time) map[string]interface{} {
+	rows, err := s.db.Query(`SELECT e.id, e.event_type, e.source, COALESCE(e.artifact_title,''),
+		COALESCE(e.metadata,''), COALESCE(r.status,''), t.event_id IS NOT NULL
+		FROM events e
+		LEFT JOIN reconciled_events r ON r.event_id = e.id
+		LEFT JOIN test_transactions t ON t.event_id = e.id
+		WHERE e.lane='revenue' AND e.status='approved' AND e.timestamp >= ? AND e.timestamp < ?`,
+		from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	defer rows.Close()
+
+	lines := map[string]*reconSourceLine{}
+	var gross, refunds, payouts, dupAmount float64
+	var dupCount, testCount int
+	for rows.Next() {
+		var id, etype, source, title, meta, reconStatus string
+		var isTest bool
+		rows.Scan(&id, &etype, &source, &title, &meta, &reconStatus, &isTest)
+		src := canonicalSource(source)
+		line := lines[src]
+		if line == nil {
+			line = &reconSourceLine{Source: src}
+			lines[src] = line
+		}
+		amount := reconEventAmount(meta, title)
+		switch {
+		case isTest:
+			testCount++
+		case reconStatus == "duplicate":
+			line.DuplicatesCount++
+			line.DuplicateAmount += amount
+			dupCount++
+			dupAmount += amount
+		case etype == "REFUND_ISSUED":
+			line.Refunds += amount
+			refunds += amount
+		case etype == "PAYOUT_RECEIVED" || etype == "payout":
+			line.Payouts += amount
+			payouts += amount
+		case reconcileGrossTypes[etype]:
+			line.Gross += amount
+			line.Count++
+			gross += amount
+		}
+	}
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	var bySource []reconSourceLine
+	for _, l := range lines {
+		l.Gross, l.Refunds, l.Payouts, l.DuplicateAmount = round(l.Gross), round(l.Refunds), round(l.Payouts), round(l.DuplicateAmount)
+		if l.Gross == 0 && l.Refunds == 0 && l.Payouts == 0 && l.DuplicatesCount == 0 {
+			continue
+		}
+		bySource = append(bySource, *l)
+	}
+	sort.Slice(bySource, func(i, j int) bool { return bySource[i].Gross > bySource[j].Gross })
+
+	return map[string]interface{}{
+		"from":               from.Format("2006-01-02"),
+		"to":                 to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"base_currency":      s.baseCurrency(),
+		"gross_revenue":      round(gross),
+		"refunds":            round(refunds),
+		"net_revenue":        round(gross - refunds),
+		"payouts":            round(payouts),
+		"by_source":          bySource,
+		"duplicates_removed": dupCount,
+		"duplicate_amount":   round(dupAmount),
+		"test_transactions":  testCount,
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// PII REDACTION — what leaves the box in an extraction prompt
+//
+// Documents are redacted locally before they are put in an extraction prompt
+// (ExtractMemoriesFromDocument, vault jobs in memory_jobs.go). Each value is
+// swapped for a placeholder that is stable within the document, so "[EMAIL_1]"
+// is the same address in every chunk and section:
+//
+//   email     addresses
+//   phone     US and +international numbers
+//   card      13–19 digit numbers that pass the Luhn check
+//   account   IBANs, SSNs, and numbers labelled account/acct/routing/IBAN
+//   address   street addresses ("1234 Main St, Apt 5")
+//   client    names from redaction_names (and REDACT_CLIENT_NAMES, comma list)
+//
+// The LLM sees placeholders and is told to copy them. In the memories that
+// come back, kinds the policy restores get their values back; the rest become
+// a generic label ("[card number]") so the value is never stored either.
+//
+// The policy follows the source's sensitivity — Integration.Sensitivity for
+// Drive and Dropbox, REDACT_VAULT_SENSITIVITY (default sensitive) for the
+// vault. Defaults are redactionPolicyDefaults, overridable per sensitivity:
+//
+//   GET    /v1/memory/redaction                 policies + client names
+//   PUT    /v1/memory/redaction/policy          {sensitivity, redact, restore}
+//   POST   /v1/memory/redaction/names           {names: [...]}
+//   DELETE /v1/memory/redaction/names?name=
+//   POST   /v1/memory/redaction/preview         {text, sensitivity}
+// ═══════════════════════════════════════════════════════════════════════════════
+
+var (
+	redactVaultSensitivity = envOr("REDACT_VAULT_SENSITIVITY", "sensitive")
+	redactClientNames      = envOr("REDACT_CLIENT_NAMES", "")
+)
+
+// redactionKinds in the order they are applied: specific patterns first, so a
+// card number is not taken for a phone number.
+var redactionKinds = []string{"email", "card", "account", "address", "phone", "client"}
+
+var redactionLabels = map[string]string{
+	"email":   "[email]",
+	"phone":   "[phone number]",
+	"card":    "[card number]",
+	"account": "[account number]",
+	"address": "[street address]",
+	"client":  "[client]",
+}
+
+type redactionPolicy struct {
+	Sensitivity string   `json:"sensitivity"`
+	Redact      []string `json:"redact"`
+	Restore     []string `json:"restore"` // subset of Redact
+	UpdatedAt   string   `json:"updated_at,omitempty"`
+}
+
+// redactionPolicyDefaults by Integration.Sensitivity.
+var redactionPolicyDefaults = map[string]redactionPolicy{
+	"public":    {Redact: []string{"card", "account"}},
+	"standard":  {Redact: []string{"email", "phone", "card", "account", "client"}, Restore: []string{"email", "phone", "client"}},
+	"sensitive": {Redact: []string{"email", "phone", "card", "account", "address", "client"}, Restore: []string{"client", "address"}},
+	"financial": {Redact: []string{"email", "phone", "card", "account", "address", "client"}, Restore: []string{"client"}},
+}
+
+var (
+	redactEmailRe = regexp.MustCompile(`(?i)\b[A-Z0-9._%+-]+@[A-Z0-9.-]+\.[A-Z]{2,}\b`)
+	redactCardRe  = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	redactIBANRe  = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){3,7}(?: ?[A-Z0-9]{1,3})?\b`)
+	redactSSNRe   = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	// Only the number after the label is replaced
+	redactAcctRe    = regexp.MustCompile(`(?i)\b(?:account|acct|routing|aba|iban|sort code)(?:\s*(?:no\.?|number|num|#))?\s*[:#]?\s*(\d[\d -]{4,22}\d)`)
+	redactAddressRe = regexp.MustCompile(`\b\d{1,6}\s+(?:[NSEW]\.?\s+)?(?:[A-Z0-9][\w'.-]*\s+){1,4}(?i:street|st|avenue|ave|road|rd|boulevard|blvd|lane|ln|drive|dr|court|ct|way|place|pl|terrace|ter|circle|cir|parkway|pkwy|highway|hwy)\b\.?(?:,?\s+(?i:apt|suite|ste|unit|#)\.?\s*[\w-]+)?`)
+	redactPhoneRe   = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)\s?|\b\d{3}[\s.-])\d{3}[\s.-]\d{4}\b|\+\d{1,3}(?:[\s.-]?\d){7,12}\b`)
+	redactTokenRe   = regexp.MustCompile(`\[(EMAIL|PHONE|CARD|ACCOUNT|ADDRESS|CLIENT)_(\d+)\]`)
+)
+
+func (s *Server) initRedaction() {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS redaction_policies (
+			sensitivity TEXT PRIMARY KEY,
+			redact TEXT NOT NULL,
+			restore TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS redaction_names (
+			name TEXT PRIMARY KEY COLLATE NOCASE,
+			created_at TEXT NOT NULL
+		)`,
+	} {
+		s.db.Exec(stmt)
+	}
+}
+
+func (s *Server) redactionPolicy(sensitivity string) redactionPolicy {
+	if sensitivity == "" {
+		sensitivity = "standard"
+	}
+	var redact, restore, updated string
+	if s.db.QueryRow(`SELECT redact, restore, updated_at FROM redaction_policies WHERE sensitivity=?`, sensitivity).
+		Scan(&redact, &restore, &updated) == nil {
+		var p redactionPolicy
+		json.Unmarshal([]byte(redact), &p.Redact)
+		json.Unmarshal([]byte(restore), &p.Restore)
+		p.Sensitivity, p.UpdatedAt = sensitivity, updated
+		return p
+	}
+	p, ok := redactionPolicyDefaults[sensitivity]
+	if !ok {
+		// Unknown sensitivity: the strictest default
+		p = redactionPolicyDefaults["financial"]
+	}
+	p.Sensitivity = sensitivity
+	return p
+}
+
+func (s *Server) redactionNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	add := func(n string) {
+		n = strings.TrimSpace(n)
+		if len([]rune(n)) >= 3 && !seen[strings.ToLower(n)] {
+			seen[strings.ToLower(n)] = true
+			names = append(names, n)
+		}
+	}
+	for _, n := range strings.Split(redactClientNames, ",") {
+		add(n)
+	}
+	if rows, err := s.db.Query(`SELECT name FROM redaction_names ORDER BY name`); err == nil {
+		for rows.Next() {
+			var n string
+			rows.Scan(&n)
+			add(n)
+		}
+		rows.Close()
+	}
+	return names
+}
+
+// integrationSensitivity returns Integration.Sensitivity for id.
+func (s *Server) integrationSensitivity(integrationID string) string {
+	var sensitivity string
+	s.db.QueryRow(`SELECT sensitivity FROM integrations WHERE id=?`, integrationID).Scan(&sensitivity)
+	if sensitivity == "" {
+		sensitivity = "standard"
+	}
+	return sensitivity
+}
+
+// redactor redacts one document and restores its placeholders afterwards.
+type redactor struct {
+	redact  map[string]bool
+	restore map[string]bool
+	client  *regexp.Regexp
+	tokens  map[string]string // "[EMAIL_1]" → value
+	byValue map[string]string // kind + normalised value → token
+	counts  map[string]int
+}
+
+func (s *Server) newRedactor(sensitivity string) *redactor {
+	p := s.redactionPolicy(sensitivity)
+	rd := &redactor{
+		redact:  map[string]bool{},
+		restore: map[string]bool{},
+		tokens:  map[string]string{},
+		byValue: map[string]string{},
+		counts:  map[string]int{},
+	}
+	for _, k := range p.Redact {
+		rd.redact[k] = true
+	}
+	for _, k := range p.Restore {
+		rd.restore[k] = true
+	}
+	if rd.redact["client"] {
+		names := s.redactionNames()
+		// Longest first, so "Acme Corp" wins over "Acme"
+		sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+		quoted := make([]string, len(names))
+		for i, n := range names {
+			quoted[i] = regexp.QuoteMeta(n)
+		}
+		if len(quoted) > 0 {
+			rd.client = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+		}
+	}
+	return rd
+}
+
+func (rd *redactor) token(kind, value string) string {
+	key := kind + "\x00" + strings.ToLower(strings.Join(strings.Fields(value), " "))
+	if kind == "card" || kind == "account" || kind == "phone" {
+		key = kind + "\x00" + digitsOnly(value)
+	}
+	if t, ok := rd.byValue[key]; ok {
+		return t
+	}
+	rd.counts[kind]++
+	t := fmt.Sprintf("[%s_%d]", strings.ToUpper(kind), rd.counts[kind])
+	rd.byValue[key] = t
+	rd.tokens[t] = value
+	return t
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// luhnValid reports whether digits pass the card checksum.
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// Redact replaces every value of a redacted kind in text.
+func (rd *redactor) Redact(text string) string {
+	for _, kind := range redactionKinds {
+		if !rd.redact[kind] {
+			continue
+		}
+		switch kind {
+		case "email":
+			text = redactEmailRe.ReplaceAllStringFunc(text, func(m string) string { return rd.token(kind, m) })
+		case "card":
+			text = redactCardRe.ReplaceAllStringFunc(text, func(m string) string {
+				if d := digitsOnly(m); len(d) < 13 || !luhnValid(d) {
+					return m
+				}
+				return rd.token(kind, m)
+			})
+		case "account":
+			text = redactIBANRe.ReplaceAllStringFunc(text, func(m string) string { return rd.token(kind, m) })
+			text = redactSSNRe.ReplaceAllStringFunc(text, func(m string) string { return rd.token(kind, m) })
+			text = replaceSubmatch(redactAcctRe, text, func(m string) string { return rd.token(kind, m) })
+		case "address":
+			text = redactAddressRe.ReplaceAllStringFunc(text, func(m string) string { return rd.token(kind, m) })
+		case "phone":
+			text = redactPhoneRe.ReplaceAllStringFunc(text, func(m string) string {
+				if len(digitsOnly(m)) < 10 {
+					return m
+				}
+				return rd.token(kind, m)
+			})
+		case "client":
+			if rd.client != nil {
+				text = rd.client.ReplaceAllStringFunc(text, func(m string) string { return rd.token(kind, m) })
+			}
+		}
+	}
+	return text
+}
+
+// replaceSubmatch replaces only the first capture group of each match.
+func replaceSubmatch(re *regexp.Regexp, text string, repl func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		if loc[2] < 0 {
+			continue
+		}
+		b.WriteString(text[last:loc[2]])
+		b.WriteString(repl(text[loc[2]:loc[3]]))
+		last = loc[3]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Restore puts back the values the policy restores and labels the rest.
+// Placeholders the LLM made up are labelled too.
+func (rd *redactor) Restore(text string) string {
+	return redactTokenRe.ReplaceAllStringFunc(text, func(t string) string {
+		kind := strings.ToLower(redactTokenRe.FindStringSubmatch(t)[1])
+		if v, ok := rd.tokens[t]; ok && rd.restore[kind] {
+			return v
+		}
+		return redactionLabels[kind]
+	})
+}
+
+// RestoreMemories applies Restore to everything an extraction returned.
+func (rd *redactor) RestoreMemories(memories []MemoryExtraction) []MemoryExtraction {
+	for i := range memories {
+		memories[i].MemoryText = rd.Restore(memories[i].MemoryText)
+		memories[i].SourceSection = rd.Restore(memories[i].SourceSection)
+		memories[i].SourceContext = rd.Restore(memories[i].SourceContext)
+	}
+	return memories
+}
+
+// RedactMeta redacts frontmatter values that go into the prompt.
+func (rd *redactor) RedactMeta(meta map[string]string) map[string]string {
+	out := make(map[string]string, len(meta))
+	for k, v := range meta {
+		out[k] = rd.Redact(v)
+	}
+	return out
+}
+
+// Counts is how many distinct values of each kind were redacted.
+func (rd *redactor) Counts() map[string]int {
+	return rd.counts
+}
+
+// redactionPromptNote tells the model how to treat placeholders.
+const redactionPromptNote = "NOTE: Bracketed placeholders such as [CLIENT_1] or [EMAIL_2] stand for redacted values. Copy them verbatim into memories and quotes; do not guess what they hide.\n\n"
+
+// /v1/memory/redaction[/policy|/names|/preview]
+func (s *Server) handleMemoryRedaction(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	sub := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/memory/redaction"), "/")
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	switch {
+	case sub == "" && r.Method == "GET":
+		policies := []redactionPolicy{}
+		for _, sens := range []string{"public", "standard", "sensitive", "financial"} {
+			policies = append(policies, s.redactionPolicy(sens))
+		}
+		writeJSON(w, map[string]interface{}{
+			"policies":          policies,
+			"names":             s.redactionNames(),
+			"kinds":             redactionKinds,
+			"vault_sensitivity": redactVaultSensitivity,
+		})
+
+	case sub == "policy" && r.Method == "PUT":
+		var body redactionPolicy
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Sensitivity == "" {
+			http.Error(w, `{"error":"sensitivity required"}`, 400)
+			return
+		}
+		known := map[string]bool{}
+		for _, k := range redactionKinds {
+			known[k] = true
+		}
+		redacted := map[string]bool{}
+		for _, k := range body.Redact {
+			if !known[k] {
+				http.Error(w, fmt.Sprintf(`{"error":%q}`, "unknown kind: "+k), 400)
+				return
+			}
+			redacted[k] = true
+		}
+		for _, k := range body.Restore {
+			if !redacted[k] {
+				http.Error(w, fmt.Sprintf(`{"error":%q}`, "restore lists a kind that is not redacted: "+k), 400)
+				return
+			}
+		}
+		if body.Redact == nil {
+			body.Redact = []string{}
+		}
+		if body.Restore == nil {
+			body.Restore = []string{}
+		}
+		redact, _ := json.Marshal(body.Redact)
+		restore, _ := json.Marshal(body.Restore)
+		s.db.Exec(`INSERT OR REPLACE INTO redaction_policies (sensitivity, redact, restore, updated_at) VALUES (?,?,?,?)`,
+			body.Sensitivity, string(redact), string(restore), now)
+		log.Printf("[redaction] policy %s: redact %v, restore %v", body.Sensitivity, body.Redact, body.Restore)
+		writeJSON(w, map[string]interface{}{"ok": true, "policy": s.redactionPolicy(body.Sensitivity)})
+
+	case sub == "names" && r.Method == "POST":
+		var body struct {
+			Names []string `json:"names"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Names) == 0 {
+			http.Error(w, `{"error":"names required"}`, 400)
+			return
+		}
+		added := 0
+		for _, n := range body.Names {
+			n = strings.TrimSpace(n)
+			if len([]rune(n)) < 3 {
+				continue
+			}
+			if res, err := s.db.Exec(`INSERT OR IGNORE INTO redaction_names (name, created_at) VALUES (?,?)`, n, now); err == nil {
+				if c, _ := res.RowsAffected(); c > 0 {
+					added++
+				}
+			}
+		}
+		writeJSON(w, map[string]interface{}{"ok": true, "added": added, "names": s.redactionNames()})
+
+	case sub == "names" && r.Method == "DELETE":
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, `{"error":"name required"}`, 400)
+			return
+		}
+		s.db.Exec(`DELETE FROM redaction_names WHERE name=?`, name)
+		writeJSON(w, map[string]interface{}{"ok": true, "names": s.redactionNames()})
+
+	case sub == "preview" && r.Method == "POST":
+		var body struct {
+			Text        string `json:"text"`
+			Sensitivity string `json:"sensitivity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Text == "" {
+			http.Error(w, `{"error":"text required"}`, 400)
+			return
+		}
+		rd := s.newRedactor(body.Sensitivity)
+		redacted := rd.Redact(body.Text)
+		writeJSON(w, map[string]interface{}{
+			"redacted": redacted,
+			"restored": rd.Restore(redacted),
+			"counts":   rd.Counts(),
+			"policy":   s.redactionPolicy(body.Sensitivity),
+		})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, 405)
+	}
+}
+
+// redactionSummary formats counts for logs, e.g. "email=2 phone=1".
+func redactionSummary(counts map[string]int) string {
+	var parts []string
+	for _, k := range redactionKinds {
+		if counts[k] > 0 {
+			parts = append(parts, k+"="+strconv.Itoa(counts[k]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
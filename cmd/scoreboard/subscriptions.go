@@ -0,0 +1,613 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════════════════════════
+// SUBSCRIPTION LEDGER — MRR / ARR from the subscription state machine
+//
+// Each subscription moves through trialing → active ⇄ past_due → canceled.
+// Stripe webhooks (customer.subscription.*) and the Stripe poller both call
+// applySubscription; every transition that changes contracted MRR is written
+// to subscription_changes as one movement:
+//
+//   new           first time the subscription counts toward MRR
+//   reactivation  counts again after having been canceled
+//   expansion     MRR went up (upgrade, seats, interval change)
+//   contraction   MRR went down while still paying
+//   churn         stopped counting (canceled / ended)
+//
+// active and past_due count toward MRR (past_due is still contracted revenue
+// until the dunning cycle cancels it); trialing, incomplete and canceled don't.
+// Summing movements up to a point in time yields MRR at that time, which is
+// how /v1/financial/mrr builds month-over-month history.
+//
+// Ledger rows keep the subscription's own currency. Reports convert to the
+// tenant's base currency at today's rate (constant-currency MRR), so FX moves
+// never show up as expansion or contraction.
+// ═══════════════════════════════════════════════════════════════════════════════
+
+// ledgerSubscription is the normalised subscription state from any provider.
+type ledgerSubscription struct {
+	Provider      string
+	ExternalID    string
+	Customer      string
+	Status        string // trialing, active, past_due, canceled, incomplete
+	Plan          string
+	Interval      string // day, week, month, year
+	IntervalCount int
+	Quantity      int
+	AmountMinor   int64 // per interval, per unit
+	Currency      string
+	StartedAt     time.Time
+	CanceledAt    time.Time
+	TrialEnd      time.Time
+	BusinessID    string
+}
+
+func (s *Server) initSubscriptions() {
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS subscriptions (
+		id TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		external_id TEXT NOT NULL,
+		customer TEXT DEFAULT '',
+		status TEXT NOT NULL,
+		plan TEXT DEFAULT '',
+		interval TEXT DEFAULT 'month',
+		interval_count INTEGER DEFAULT 1,
+		quantity INTEGER DEFAULT 1,
+		amount_minor INTEGER DEFAULT 0,
+		currency TEXT DEFAULT 'USD',
+		mrr REAL DEFAULT 0,
+		started_at TEXT DEFAULT '',
+		trial_end TEXT DEFAULT '',
+		canceled_at TEXT DEFAULT '',
+		business_id TEXT DEFAULT '',
+		ever_counted INTEGER DEFAULT 0,
+		updated_at TEXT NOT NULL
+	)`)
+	s.db.Exec(`CREATE TABLE IF NOT EXISTS subscription_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscription_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		from_status TEXT DEFAULT '',
+		to_status TEXT NOT NULL,
+		mrr_before REAL DEFAULT 0,
+		mrr_after REAL DEFAULT 0,
+		delta REAL DEFAULT 0,
+		currency TEXT DEFAULT 'USD',
+		source TEXT DEFAULT '',
+		changed_at TEXT NOT NULL
+	)`)
+	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_sub_changes_at ON subscription_changes(changed_at)`)
+}
+
+// subscriptionCountsTowardMRR reports whether a status is contracted revenue.
+func subscriptionCountsTowardMRR(status string) bool {
+	return status == "active" || status == "past_due"
+}
+
+// normalizeSubscriptionStatus folds provider vocabularies into the ledger's states.
+func normalizeSubscriptionStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "trialing", "on_trial", "trial":
+		return "trialing"
+	case "active", "paid":
+		return "active"
+	case "past_due", "unpaid", "paused":
+		return "past_due"
+	case "canceled", "cancelled", "expired", "incomplete_expired", "ended":
+		return "canceled"
+	default:
+		return "incomplete"
+	}
+}
+
+// monthlyAmount converts a per-interval price into a monthly amount in major units.
+func (sub ledgerSubscription) monthlyAmount() float64 {
+	count := sub.IntervalCount
+	if count < 1 {
+		count = 1
+	}
+	qty := sub.Quantity
+	if qty < 1 {
+		qty = 1
+	}
+	perInterval := minorToMajor(sub.AmountMinor*int64(qty), sub.Currency)
+	var monthly float64
+	switch sub.Interval {
+	case "day":
+		monthly = perInterval * 365.0 / 12.0
+	case "week":
+		monthly = perInterval * 52.0 / 12.0
+	case "year":
+		monthly = perInterval / 12.0
+	default:
+		monthly = perInterval
+	}
+	return math.Round(monthly/float64(count)*100) / 100
+}
+
+// applySubscription upserts the ledger row and records an MRR movement when
+// contracted revenue changed. at is when the change happened (webhook created
+// time, or the subscription's own timestamps on first sight).
+func (s *Server) applySubscription(sub ledgerSubscription, at time.Time, source string) {
+	if sub.ExternalID == "" {
+		return
+	}
+	sub.Status = normalizeSubscriptionStatus(sub.Status)
+	sub.Currency = strings.ToUpper(sub.Currency)
+	if sub.Currency == "" {
+		sub.Currency = "USD"
+	}
+	id := sub.Provider + ":" + sub.ExternalID
+	mrrAfter := 0.0
+	if subscriptionCountsTowardMRR(sub.Status) {
+		mrrAfter = sub.monthlyAmount()
+	}
+
+	var prevStatus string
+	var mrrBefore float64
+	var everCounted int
+	err := s.db.QueryRow(`SELECT status, mrr, ever_counted FROM subscriptions WHERE id=?`, id).
+		Scan(&prevStatus, &mrrBefore, &everCounted)
+	firstSight := err != nil
+
+	// First sight of a subscription that has been paying for a while: backdate
+	// its start (after any trial) so history reflects when it began paying.
+	paidFrom := sub.StartedAt
+	if !sub.TrialEnd.IsZero() && sub.TrialEnd.After(paidFrom) {
+		paidFrom = sub.TrialEnd
+	}
+	if firstSight && mrrAfter > 0 && !paidFrom.IsZero() && paidFrom.Before(at) {
+		at = paidFrom
+	}
+
+	kind := ""
+	delta := mrrAfter - mrrBefore
+	switch {
+	case mrrBefore == 0 && mrrAfter > 0:
+		kind = "new"
+		if everCounted == 1 {
+			kind = "reactivation"
+		}
+	case mrrBefore > 0 && mrrAfter == 0:
+		kind = "churn"
+	case math.Abs(delta) >= 0.01 && delta > 0:
+		kind = "expansion"
+	case math.Abs(delta) >= 0.01 && delta < 0:
+		kind = "contraction"
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if mrrAfter > 0 {
+		everCounted = 1
+	}
+	fmtTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db.Exec(`INSERT INTO subscriptions (id, provider, external_id, customer, status, plan, interval,
+		interval_count, quantity, amount_minor, currency, mrr, started_at, trial_end, canceled_at,
+		business_id, ever_counted, updated_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+		ON CONFLICT(id) DO UPDATE SET customer=excluded.customer, status=excluded.status,
+			plan=excluded.plan, interval=excluded.interval, interval_count=excluded.interval_count,
+			quantity=excluded.quantity, amount_minor=excluded.amount_minor, currency=excluded.currency,
+			mrr=excluded.mrr, trial_end=excluded.trial_end, canceled_at=excluded.canceled_at,
+			business_id=CASE WHEN excluded.business_id='' THEN subscriptions.business_id ELSE excluded.business_id END,
+			ever_counted=excluded.ever_counted, updated_at=excluded.updated_at`,
+		id, sub.Provider, sub.ExternalID, sub.Customer, sub.Status, sub.Plan, sub.Interval,
+		sub.IntervalCount, sub.Quantity, sub.AmountMinor, sub.Currency, mrrAfter,
+		fmtTime(sub.StartedAt), fmtTime(sub.TrialEnd), fmtTime(sub.CanceledAt),
+		sub.BusinessID, everCounted, now)
+
+	// First sight of an already-canceled subscription that once paid: replay
+	// its whole life so past months carry both the new and the churned MRR.
+	if firstSight && sub.Status == "canceled" && !paidFrom.IsZero() && sub.CanceledAt.After(paidFrom) {
+		if full := sub.monthlyAmount(); full > 0 {
+			s.db.Exec(`UPDATE subscriptions SET ever_counted=1 WHERE id=?`, id)
+			s.db.Exec(`INSERT INTO subscription_changes (subscription_id, kind, from_status, to_status,
+				mrr_before, mrr_after, delta, currency, source, changed_at) VALUES (?,?,?,?,?,?,?,?,?,?)`,
+				id, "new", "", "active", 0, full, full, sub.Currency, source, paidFrom.UTC().Format(time.RFC3339))
+			s.db.Exec(`INSERT INTO subscription_changes (subscription_id, kind, from_status, to_status,
+				mrr_before, mrr_after, delta, currency, source, changed_at) VALUES (?,?,?,?,?,?,?,?,?,?)`,
+				id, "churn", "active", "canceled", full, 0, -full, sub.Currency, source, sub.CanceledAt.UTC().Format(time.RFC3339))
+			return
+		}
+	}
+
+	if kind == "" && prevStatus == sub.Status {
+		return
+	}
+	if kind == "" {
+		kind = "status" // e.g. trialing → canceled, active → past_due: no MRR effect
+	}
+	s.db.Exec(`INSERT INTO subscription_changes (subscription_id, kind, from_status, to_status,
+		mrr_before, mrr_after, delta, currency, source, changed_at) VALUES (?,?,?,?,?,?,?,?,?,?)`,
+		id, kind, prevStatus, sub.Status, mrrBefore, mrrAfter, delta, sub.Currency, source,
+		at.UTC().Format(time.RFC3339))
+	if kind != "status" {
+		log.Printf("[mrr] %s %s: %.2f → %.2f %s (%s)", id, kind, mrrBefore, mrrAfter, sub.Currency, source)
+	}
+}
+
+// ─── Stripe ─────────────────────────────────────────────────────────────────
+
+// stripeLedgerSubscription maps a Stripe subscription object (webhook or API).
+func stripeLedgerSubscription(obj map[string]interface{}) ledgerSubscription {
+	str := func(m map[string]interface{}, k string) string { v, _ := m[k].(string); return v }
+	num := func(m map[string]interface{}, k string) float64 { v, _ := m[k].(float64); return v }
+	unix := func(m map[string]interface{}, k string) time.Time {
+		if v := num(m, k); v > 0 {
+			return time.Unix(int64(v), 0)
+		}
+		return time.Time{}
+	}
+
+	sub := ledgerSubscription{
+		Provider:   "stripe",
+		ExternalID: str(obj, "id"),
+		Customer:   str(obj, "customer"),
+		Status:     str(obj, "status"),
+		Currency:   str(obj, "currency"),
+		StartedAt:  unix(obj, "start_date"),
+		CanceledAt: unix(obj, "ended_at"),
+		TrialEnd:   unix(obj, "trial_end"),
+	}
+	if sub.StartedAt.IsZero() {
+		sub.StartedAt = unix(obj, "created")
+	}
+
+	// Sum every item so multi-price subscriptions aren't undercounted.
+	// Items are normalised to the first item's interval.
+	var monthlyMinor float64
+	if items, ok := obj["items"].(map[string]interface{}); ok {
+		data, _ := items["data"].([]interface{})
+		for _, raw := range data {
+			item, _ := raw.(map[string]interface{})
+			price, _ := item["price"].(map[string]interface{})
+			if price == nil {
+				price, _ = item["plan"].(map[string]interface{})
+			}
+			recurring, _ := price["recurring"].(map[string]interface{})
+			interval := str(recurring, "interval")
+			count := int(num(recurring, "interval_count"))
+			if interval == "" {
+				interval, count = str(price, "interval"), int(num(price, "interval_count"))
+			}
+			unitAmount := num(price, "unit_amount")
+			if unitAmount == 0 {
+				unitAmount = num(price, "amount")
+			}
+			qty := int(num(item, "quantity"))
+			if qty < 1 {
+				qty = 1
+			}
+			if sub.Interval == "" {
+				sub.Interval, sub.IntervalCount = interval, count
+				sub.Plan = str(price, "nickname")
+				if sub.Plan == "" {
+					sub.Plan = str(price, "product")
+				}
+				if sub.Currency == "" {
+					sub.Currency = str(price, "currency")
+				}
+			}
+			itemSub := ledgerSubscription{Interval: interval, IntervalCount: count, Quantity: qty,
+				AmountMinor: int64(unitAmount), Currency: sub.Currency}
+			monthlyMinor += itemSub.monthlyAmount() * math.Pow10(currencyExponent(sub.Currency))
+		}
+	}
+	if sub.Interval == "" {
+		// Legacy single-plan shape
+		plan, _ := obj["plan"].(map[string]interface{})
+		sub.Interval = str(plan, "interval")
+		sub.IntervalCount = int(num(plan, "interval_count"))
+		sub.Plan = str(plan, "nickname")
+		sub.Quantity = int(num(obj, "quantity"))
+		sub.AmountMinor = int64(num(plan, "amount"))
+		return sub
+	}
+	// Store the combined amount as a monthly price so monthlyAmount() round-trips
+	sub.Interval, sub.IntervalCount, sub.Quantity = "month", 1, 1
+	sub.AmountMinor = int64(math.Round(monthlyMinor))
+	return sub
+}
+
+// applyStripeSubscriptionEvent feeds a customer.subscription.* webhook into the ledger.
+func (s *Server) applyStripeSubscriptionEvent(payload, obj map[string]interface{}) {
+	at := time.Now()
+	if created, ok := payload["created"].(float64); ok && created > 0 {
+		at = time.Unix(int64(created), 0)
+	}
+	s.applySubscription(stripeLedgerSubscription(obj), at, "stripe-webhook")
+}
+
+// syncStripeSubscriptions lists every subscription (status=all) and applies
+// the current state. Transitions missed by webhooks are recorded at poll time.
+func (s *Server) syncStripeSubscriptions(apiKey string) error {
+	client := providerClient(30 * time.Second)
+	startingAfter := ""
+	seen := 0
+	for pages := 0; pages < 20; pages++ {
+		u := "https://api.stripe.com/v1/subscriptions?status=all&limit=100"
+		if startingAfter != "" {
+			u += "&starting_after=" + startingAfter
+		}
+		req, _ := http.NewRequest("GET", u, nil)
+		req.SetBasicAuth(apiKey, "")
+		var resp struct {
+			Data    []map[string]interface{} `json:"data"`
+			HasMore bool                     `json:"has_more"`
+		}
+		if _, err := revenueGetJSON(client, req, &resp); err != nil {
+			return fmt.Errorf("stripe subscriptions: %w", err)
+		}
+		for _, obj := range resp.Data {
+			s.applySubscription(stripeLedgerSubscription(obj), time.Now(), "stripe-poll")
+			seen++
+		}
+		if !resp.HasMore || len(resp.Data) == 0 {
+			break
+		}
+		startingAfter, _ = resp.Data[len(resp.Data)-1]["id"].(string)
+	}
+	log.Printf("[stripe] Synced %d subscriptions into ledger", seen)
+	return nil
+}
+
+// ─── Metrics ────────────────────────────────────────────────────────────────
+
+// MRRMonth is one month of MRR movements.
+type MRRMonth struct {
+	Month           string  `json:"month"`
+	StartingMRR     float64 `json:"starting_mrr"`
+	NewMRR          float64 `json:"new_mrr"`
+	ReactivationMRR float64 `json:"reactivation_mrr"`
+	ExpansionMRR    float64 `json:"expansion_mrr"`
+	ContractionMRR  float64 `json:"contraction_mrr"`
+	ChurnedMRR      float64 `json:"churned_mrr"`
+	NetNewMRR       float64 `json:"net_new_mrr"`
+	EndingMRR       float64 `json:"ending_mrr"`
+	NRR             float64 `json:"nrr"` // (start + expansion − contraction − churn) / start
+}
+
+type subscriptionChange struct {
+	SubID string
+	Kind  string
+	Delta float64
+	After float64
+	At    time.Time
+}
+
+func (s *Server) loadSubscriptionChanges() []subscriptionChange {
+	rows, err := s.db.Query(`SELECT subscription_id, kind, delta, mrr_after, currency, changed_at
+		FROM subscription_changes WHERE kind != 'status' ORDER BY changed_at, id`)
+	if err != nil {
+		return nil
+	}
+	var out []subscriptionChange
+	var currencies []string
+	for rows.Next() {
+		var c subscriptionChange
+		var at, currency string
+		if rows.Scan(&c.SubID, &c.Kind, &c.Delta, &c.After, &currency, &at) != nil {
+			continue
+		}
+		c.At, _ = time.Parse(time.RFC3339, at)
+		out = append(out, c)
+		currencies = append(currencies, currency)
+	}
+	rows.Close()
+
+	rates := s.mrrRates()
+	for i := range out {
+		r := rates(currencies[i])
+		out[i].Delta *= r
+		out[i].After *= r
+	}
+	return out
+}
+
+// mrrRates returns a memoised currency → base-currency rate at today's FX.
+// Currencies without a rate count 1:1 and are logged once.
+func (s *Server) mrrRates() func(string) float64 {
+	cache := map[string]float64{}
+	now := time.Now()
+	return func(currency string) float64 {
+		if r, ok := cache[currency]; ok {
+			return r
+		}
+		r, _, ok := s.fxRate(currency, s.baseCurrency(), now)
+		if !ok {
+			log.Printf("[mrr] no FX rate %s→%s, counting 1:1", currency, s.baseCurrency())
+			r = 1
+		}
+		cache[currency] = r
+		return r
+	}
+}
+
+// mrrByMonth replays movements into monthly buckets for the last n months
+// (current month included), in operator time.
+func mrrByMonth(changes []subscriptionChange, months int) []MRRMonth {
+	now := operatorNow()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, operatorTZ).AddDate(0, -(months - 1), 0)
+
+	// MRR per subscription as of the start of the window
+	perSub := map[string]float64{}
+	i := 0
+	for ; i < len(changes) && changes[i].At.Before(first); i++ {
+		perSub[changes[i].SubID] = changes[i].After
+	}
+
+	var out []MRRMonth
+	for m := 0; m < months; m++ {
+		start := first.AddDate(0, m, 0)
+		end := start.AddDate(0, 1, 0)
+		bucket := MRRMonth{Month: start.Format("2006-01")}
+		for _, v := range perSub {
+			bucket.StartingMRR += v
+		}
+		for ; i < len(changes) && changes[i].At.Before(end); i++ {
+			c := changes[i]
+			switch c.Kind {
+			case "new":
+				bucket.NewMRR += c.Delta
+			case "reactivation":
+				bucket.ReactivationMRR += c.Delta
+			case "expansion":
+				bucket.ExpansionMRR += c.Delta
+			case "contraction":
+				bucket.ContractionMRR += -c.Delta
+			case "churn":
+				bucket.ChurnedMRR += -c.Delta
+			}
+			perSub[c.SubID] = c.After
+		}
+		bucket.NetNewMRR = bucket.NewMRR + bucket.ReactivationMRR + bucket.ExpansionMRR -
+			bucket.ContractionMRR - bucket.ChurnedMRR
+		bucket.EndingMRR = bucket.StartingMRR + bucket.NetNewMRR
+		if bucket.StartingMRR > 0 {
+			bucket.NRR = (bucket.StartingMRR + bucket.ExpansionMRR - bucket.ContractionMRR - bucket.ChurnedMRR) /
+				bucket.StartingMRR
+		}
+		out = append(out, roundMRRMonth(bucket))
+	}
+	return out
+}
+
+func roundMRRMonth(m MRRMonth) MRRMonth {
+	r := func(v float64) float64 { return math.Round(v*100) / 100 }
+	m.StartingMRR, m.NewMRR, m.ReactivationMRR = r(m.StartingMRR), r(m.NewMRR), r(m.ReactivationMRR)
+	m.ExpansionMRR, m.ContractionMRR, m.ChurnedMRR = r(m.ExpansionMRR), r(m.ContractionMRR), r(m.ChurnedMRR)
+	m.NetNewMRR, m.EndingMRR = r(m.NetNewMRR), r(m.EndingMRR)
+	m.NRR = math.Round(m.NRR*1000) / 1000
+	return m
+}
+
+// cohortNRR is net revenue retention over a window: MRR today from the
+// subscriptions that were paying at `since`, divided by their MRR then.
+func cohortNRR(changes []subscriptionChange, since time.Time) float64 {
+	then, nowMRR := map[string]float64{}, map[string]float64{}
+	for _, c := range changes {
+		if c.At.Before(since) {
+			then[c.SubID] = c.After
+		}
+		nowMRR[c.SubID] = c.After
+	}
+	var base, retained float64
+	for id, v := range then {
+		if v <= 0 {
+			continue
+		}
+		base += v
+		retained += nowMRR[id]
+	}
+	if base == 0 {
+		return 0
+	}
+	return math.Round(retained/base*1000) / 1000
+}
+
+// currentMRR returns live MRR (base currency) and subscriber counts by status.
+func (s *Server) currentMRR() (mrr float64, counts map[string]int) {
+	counts = map[string]int{}
+	rows, err := s.db.Query(`SELECT status, currency, COUNT(*), COALESCE(SUM(mrr),0)
+		FROM subscriptions GROUP BY status, currency`)
+	if err != nil {
+		return 0, counts
+	}
+	type bucket struct {
+		currency string
+		sum      float64
+	}
+	var paying []bucket
+	for rows.Next() {
+		var status, currency string
+		var n int
+		var sum float64
+		rows.Scan(&status, &currency, &n, &sum)
+		counts[status] += n
+		if subscriptionCountsTowardMRR(status) {
+			paying = append(paying, bucket{currency, sum})
+		}
+	}
+	rows.Close()
+	rates := s.mrrRates()
+	for _, b := range paying {
+		mrr += b.sum * rates(b.currency)
+	}
+	return math.Round(mrr*100) / 100, counts
+}
+
+// GET /v1/financial/mrr?months=12 — current MRR/ARR, subscriber counts and
+// month-over-month movements.
+func (s *Server) handleMRR(w http.ResponseWriter, r *http.Request) {
+	cors(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(200)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"GET only"}`, 405)
+		return
+	}
+
+	months := 12
+	if v, err := strconv.Atoi(r.URL.Query().Get("months")); err == nil && v > 0 && v <= 60 {
+		months = v
+	}
+
+	mrr, counts := s.currentMRR()
+	changes := s.loadSubscriptionChanges()
+	history := mrrByMonth(changes, months)
+
+	var currencies []string
+	rows, err := s.db.Query(`SELECT DISTINCT currency FROM subscriptions WHERE status IN ('active','past_due')`)
+	if err == nil {
+		for rows.Next() {
+			var c string
+			rows.Scan(&c)
+			currencies = append(currencies, c)
+		}
+		rows.Close()
+	}
+	sort.Strings(currencies)
+
+	current := map[string]interface{}{
+		"base_currency": s.baseCurrency(),
+		"mrr":           mrr,
+		"arr":           math.Round(mrr*12*100) / 100,
+		"subscribers":   counts,
+		"currencies":    currencies,
+	}
+	if len(history) > 0 {
+		last := history[len(history)-1]
+		current["new_mrr"] = last.NewMRR + last.ReactivationMRR
+		current["expansion_mrr"] = last.ExpansionMRR
+		current["contraction_mrr"] = last.ContractionMRR
+		current["churned_mrr"] = last.ChurnedMRR
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"current": current,
+		"nrr_12m": cohortNRR(changes, operatorNow().AddDate(-1, 0, 0)),
+		"history": history,
+	})
+}